@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/yomek33/newln/internal/config"
 	"github.com/yomek33/newln/internal/handler"
+	"github.com/yomek33/newln/internal/logger"
 	"github.com/yomek33/newln/internal/models"
 	"github.com/yomek33/newln/internal/models/migrations"
+	"github.com/yomek33/newln/internal/pkg/scheduler"
 	"github.com/yomek33/newln/internal/pkg/vertex"
 	"github.com/yomek33/newln/internal/services"
 	"github.com/yomek33/newln/internal/stores"
@@ -49,7 +53,7 @@ func main() {
 	app := &application{DB: db}
 
 	stores := stores.NewStores(app.DB)
-	services := services.NewServices(stores, vertexClient)
+	services := services.NewServices(stores, vertexClient, cfg.Plans, cfg.Scoring, cfg.TrashRetention, cfg.FeedPollInterval)
 
 	h := handler.NewHandler(services, cfg.JwtSecret)
 
@@ -65,14 +69,74 @@ func main() {
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Material{},
+		&models.MaterialRevision{},
+		&models.FetchedPage{},
+		&models.SubtitleCue{},
+		&models.Tag{},
+		&models.MaterialTag{},
+		&models.Collection{},
+		&models.CollectionMaterial{},
+		&models.FeedSubscription{},
+		&models.FeedEntry{},
 		&models.Word{},
+		&models.WordOccurrence{},
 		&models.Phrase{},
+		&models.Translation{},
+		&models.DictionaryEntry{},
 		&models.Progress{},
 		&models.Chat{},
 		&models.Message{},
+		&models.LLMUsage{},
+		&models.MaterialCreation{},
 	); err != nil {
 		log.Fatalf("failed to run auto-migration: %v", err)
 	}
+	if err := migrations.MoveJPMeaningsToTranslations(db); err != nil {
+		log.Fatalf("failed to migrate translations: %v", err)
+	}
+	if err := migrations.CreateMaterialListIndexes(db); err != nil {
+		log.Fatalf("failed to migrate materials: %v", err)
+	}
+	if err := migrations.BackfillMaterialCreations(db); err != nil {
+		log.Fatalf("failed to migrate material creations: %v", err)
+	}
+	if err := migrations.CreateSearchIndexes(db); err != nil {
+		log.Fatalf("failed to create search indexes: %v", err)
+	}
+
+	jobs := scheduler.New()
+
+	// ゴミ箱の保持期間を過ぎた教材を1時間ごとに完全に削除
+	if cfg.TrashRetention > 0 {
+		if err := jobs.Add(scheduler.Job{
+			Name:     "trash-retention",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				purged, err := services.TrashService.PurgeExpired()
+				if purged > 0 {
+					logger.Infof("🗑️ Purged %d expired materials from the trash", purged)
+				}
+				return err
+			},
+		}); err != nil {
+			log.Fatalf("failed to schedule trash retention: %v", err)
+		}
+	} else {
+		logger.Infof("🗑️ Trash retention is disabled")
+	}
+
+	// 購読したフィードのうち取得の時刻が来たものを1分ごとに取得して教材にする
+	if err := jobs.Add(scheduler.Job{
+		Name:     "feed-poll",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := services.FeedService.PollDue(ctx)
+			return err
+		},
+	}); err != nil {
+		log.Fatalf("failed to schedule feed polling: %v", err)
+	}
+	jobs.Start(context.Background())
 
 	// サーバー起動
 	port, err := strconv.Atoi(cfg.Port)
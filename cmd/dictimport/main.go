@@ -0,0 +1,117 @@
+// dictimport はオフライン辞書のダンプを dictionary_entries に取り込む。同じ項目は上書きする
+//
+//	go run ./cmd/dictimport -format wordnet dict/data.noun dict/data.verb dict/data.adj dict/data.adv
+//	go run ./cmd/dictimport -format wiktextract -lang de kaikki.org-dictionary-German.jsonl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/dictionary"
+	"github.com/yomek33/newln/internal/stores"
+
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	formatFlag := flag.String("format", "wiktextract", "dump format: wiktextract (JSONL) or wordnet (data.* files)")
+	langFlag := flag.String("lang", "en", "language of the headwords to import (wiktextract only; wordnet is English)")
+	batchFlag := flag.Int("batch", 1000, "entries per upsert")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		log.Fatalf("❌ no dump files given")
+	}
+
+	_ = godotenv.Load()
+	dsn := os.Getenv("SUPABASE_URI")
+	if dsn == "" {
+		log.Fatalf("❌ SUPABASE_URI is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		log.Fatalf("❌ failed to connect to the database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.DictionaryEntry{}); err != nil {
+		log.Fatalf("❌ failed to migrate dictionary_entries: %v", err)
+	}
+
+	// 同じ見出し語・品詞は複数の行 (語源や synset) に分かれているので、まとめてから書き込む
+	entries := make(map[string]*dictionary.Entry)
+	var keys []string
+	collect := func(e dictionary.Entry) error {
+		if existing, ok := entries[e.Key()]; ok {
+			existing.Merge(e)
+			return nil
+		}
+		entries[e.Key()] = &e
+		keys = append(keys, e.Key())
+		return nil
+	}
+
+	for _, path := range flag.Args() {
+		if err := readDump(path, *formatFlag, *langFlag, collect); err != nil {
+			log.Fatalf("❌ %s: %v", path, err)
+		}
+		log.Printf("📖 read %s (%d entries so far)", path, len(entries))
+	}
+
+	store := stores.NewDictionaryStore(db)
+	batch := make([]models.DictionaryEntry, 0, *batchFlag)
+	imported := 0
+	for _, key := range keys {
+		batch = append(batch, toModel(entries[key]))
+		if len(batch) == *batchFlag {
+			if err := store.UpsertEntries(batch); err != nil {
+				log.Fatalf("❌ failed to import entries: %v", err)
+			}
+			imported += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := store.UpsertEntries(batch); err != nil {
+		log.Fatalf("❌ failed to import entries: %v", err)
+	}
+	imported += len(batch)
+	log.Printf("✅ imported %d entries", imported)
+}
+
+func readDump(path, format, lang string, fn func(dictionary.Entry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch format {
+	case "wiktextract":
+		return dictionary.ReadWiktextract(f, lang, fn)
+	case "wordnet":
+		return dictionary.ReadWordNet(f, fn)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func toModel(e *dictionary.Entry) models.DictionaryEntry {
+	senses := make([]models.DictionarySense, 0, len(e.Senses))
+	for _, s := range e.Senses {
+		senses = append(senses, models.DictionarySense{Definition: s.Definition, Examples: s.Examples, Synonyms: s.Synonyms})
+	}
+	return models.DictionaryEntry{
+		Language:     e.Language,
+		Lemma:        e.Lemma,
+		Pos:          e.Pos,
+		Source:       e.Source,
+		IPA:          e.IPA,
+		Senses:       senses,
+		Translations: e.Translations,
+	}
+}
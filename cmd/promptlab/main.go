@@ -0,0 +1,131 @@
+// promptlab はプロンプトのバージョンをフィクスチャで評価し、バージョン間の差分を出す。
+// recorded プロバイダで応答が記録されていないバージョンは NONE と表示し、差分では比べない
+//
+//	go run ./cmd/promptlab -prompt generate_phrases -versions v1
+//	go run ./cmd/promptlab -prompt generate_words -versions v1,v2 -provider vertex
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/yomek33/newln/internal/pkg/vertex"
+	"github.com/yomek33/newln/internal/prompteval"
+	"github.com/yomek33/newln/internal/services/prompts"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	promptFlag := flag.String("prompt", string(prompts.GeneratePhrases), "prompt name (generate_phrases, generate_words, generate_meanings_phrases, generate_words_meanings)")
+	versionsFlag := flag.String("versions", prompts.DefaultVersion, "comma separated prompt versions; two versions produce a diff report")
+	fixturesFlag := flag.String("fixtures", "./cmd/promptlab/fixtures", "directory of *.txt fixtures")
+	providerFlag := flag.String("provider", "recorded", "provider: recorded, cassette, vertex, mock")
+	cassetteFlag := flag.String("cassette", "./cmd/promptlab/cassettes", "cassette directory for the cassette provider")
+	recordingsFlag := flag.String("recordings", "./cmd/promptlab/recordings", "directory of recorded responses")
+	recordFlag := flag.Bool("record", false, "save responses from the provider into -recordings")
+	minItemsFlag := flag.Int("min-items", 0, "minimum number of items (default: -count)")
+	minFromTextFlag := flag.Float64("min-from-text", 0.8, "minimum from_text accuracy")
+	nativeFlag := flag.String("native", "Japanese", "native language passed to the prompt")
+	levelFlag := flag.String("level", "intermediate", "CEFR level passed to the prompt")
+	countFlag := flag.Int("count", 20, "item count passed to the prompt")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	fixtures, err := prompteval.LoadFixtures(*fixturesFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	provider, err := newProvider(*providerFlag, *recordingsFlag, *cassetteFlag, *recordFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	opts := prompteval.DefaultOptions()
+	opts.MinItems = *minItemsFlag
+	opts.MinFromTextAccuracy = *minFromTextFlag
+	opts.Data = prompts.Data{NativeLanguage: *nativeFlag, Level: *levelFlag, Count: *countFlag}
+	runner := &prompteval.Runner{Provider: provider, Options: opts}
+
+	versions := strings.Split(*versionsFlag, ",")
+	if len(versions) > 2 {
+		log.Fatalf("❌ at most two versions can be compared")
+	}
+
+	ctx := context.Background()
+	name := prompts.Name(*promptFlag)
+	allPassed := true
+	var runs [][]prompteval.Result
+	for _, version := range versions {
+		results, err := runner.Run(ctx, name, strings.TrimSpace(version), fixtures)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		fmt.Printf("# %s@%s\n", name, version)
+		prompteval.WriteResults(os.Stdout, results)
+		fmt.Println()
+		for _, r := range results {
+			allPassed = allPassed && r.Passed()
+		}
+		runs = append(runs, results)
+	}
+
+	if len(runs) == 2 {
+		fmt.Println("# diff")
+		prompteval.WriteDiff(os.Stdout, prompteval.Compare(runs[0], runs[1]))
+	}
+
+	if !allPassed {
+		os.Exit(1)
+	}
+}
+
+func newProvider(name, recordings, cassette string, record bool) (prompteval.Provider, error) {
+	var provider prompteval.Provider
+	switch name {
+	case "recorded":
+		if record {
+			return nil, fmt.Errorf("-record cannot be used with the recorded provider")
+		}
+		return &prompteval.RecordedProvider{Dir: recordings}, nil
+	case "cassette":
+		// -record のときは本物の Vertex で記録し、それ以外は再生のみ
+		if !record {
+			client, err := vertex.NewCassetteClient(cassette, vertex.CassetteReplay, nil)
+			if err != nil {
+				return nil, err
+			}
+			return &prompteval.VertexProvider{Client: client}, nil
+		}
+		realClient, err := vertex.NewRealVertexClient()
+		if err != nil {
+			return nil, err
+		}
+		client, err := vertex.NewCassetteClient(cassette, vertex.CassetteRecord, realClient)
+		if err != nil {
+			return nil, err
+		}
+		return &prompteval.VertexProvider{Client: client}, nil
+	case "vertex":
+		client, err := vertex.NewRealVertexClient()
+		if err != nil {
+			return nil, err
+		}
+		provider = &prompteval.VertexProvider{Client: client}
+	case "mock":
+		provider = &prompteval.VertexProvider{Client: vertex.NewMockVertexClient()}
+	default:
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+
+	if record {
+		provider = &prompteval.RecordingProvider{Inner: provider, Store: &prompteval.RecordedProvider{Dir: recordings}}
+	}
+	return provider, nil
+}
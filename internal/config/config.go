@@ -1,11 +1,14 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/yomek33/newln/internal/pkg/scoring"
 )
 
 // Config holds the application configuration
@@ -16,6 +19,123 @@ type Config struct {
 	VertexAIAPIKey string
 	SupabaseURI    string
 	JwtSecret      []byte
+	Plans          Plans
+	Scoring        scoring.Thresholds
+	// ゴミ箱の教材を完全に削除するまでの期間。0 なら自動では削除しない
+	TrashRetention time.Duration
+	// 購読したフィードを取得し直す間隔
+	FeedPollInterval time.Duration
+}
+
+// Plan はユーザーごとの生成上限。0 は無制限
+type Plan struct {
+	Name                string `json:"name"`
+	MaterialsPerDay     int    `json:"materials_per_day"`
+	MaxCharsPerMaterial int    `json:"max_chars_per_material"`
+	MonthlyTokenBudget  int64  `json:"monthly_token_budget"`
+}
+
+type Plans map[string]Plan
+
+const DefaultPlanName = "free"
+
+// DefaultPlans は PLANS_JSON が未設定のときに使う
+var DefaultPlans = Plans{
+	"free": {
+		Name:                "free",
+		MaterialsPerDay:     5,
+		MaxCharsPerMaterial: 10000,
+		MonthlyTokenBudget:  2_000_000,
+	},
+	"pro": {
+		Name:                "pro",
+		MaterialsPerDay:     50,
+		MaxCharsPerMaterial: 50000,
+		MonthlyTokenBudget:  30_000_000,
+	},
+	"unlimited": {
+		Name: "unlimited",
+	},
+}
+
+// Get は未知のプランなら DefaultPlanName のプランを返す
+func (p Plans) Get(name string) Plan {
+	if plan, ok := p[name]; ok {
+		return plan
+	}
+	return p[DefaultPlanName]
+}
+
+// loadPlans は PLANS_JSON (JSON配列) があればそれを使う
+func loadPlans() (Plans, error) {
+	raw := os.Getenv("PLANS_JSON")
+	if raw == "" {
+		return DefaultPlans, nil
+	}
+
+	var list []Plan
+	if err := json.Unmarshal([]byte(raw), &list); err != nil {
+		return nil, fmt.Errorf("invalid PLANS_JSON: %w", err)
+	}
+	plans := make(Plans, len(list))
+	for _, plan := range list {
+		if plan.Name == "" {
+			return nil, fmt.Errorf("invalid PLANS_JSON: plan name is required")
+		}
+		plans[plan.Name] = plan
+	}
+	if _, ok := plans[DefaultPlanName]; !ok {
+		return nil, fmt.Errorf("invalid PLANS_JSON: %q plan is required", DefaultPlanName)
+	}
+	return plans, nil
+}
+
+// loadScoring は SCORING_JSON (JSONオブジェクト) の項目でデフォルトの閾値を上書きする
+func loadScoring() (scoring.Thresholds, error) {
+	thresholds := scoring.DefaultThresholds()
+	raw := os.Getenv("SCORING_JSON")
+	if raw == "" {
+		return thresholds, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &thresholds); err != nil {
+		return thresholds, fmt.Errorf("invalid SCORING_JSON: %w", err)
+	}
+	if thresholds.MediumImportance > thresholds.HighImportance {
+		return thresholds, fmt.Errorf("invalid SCORING_JSON: medium_importance must not exceed high_importance")
+	}
+	return thresholds, nil
+}
+
+// DefaultTrashRetentionDays は TRASH_RETENTION_DAYS が未設定のときに使う
+const DefaultTrashRetentionDays = 30
+
+// loadTrashRetention は TRASH_RETENTION_DAYS (日数、0 で無効) を読む
+func loadTrashRetention() (time.Duration, error) {
+	raw := os.Getenv("TRASH_RETENTION_DAYS")
+	if raw == "" {
+		return DefaultTrashRetentionDays * 24 * time.Hour, nil
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid TRASH_RETENTION_DAYS: %q", raw)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// DefaultFeedPollMinutes は FEED_POLL_INTERVAL_MINUTES が未設定のときに使う
+const DefaultFeedPollMinutes = 60
+
+// loadFeedPollInterval は FEED_POLL_INTERVAL_MINUTES (購読したフィードを取得し直す間隔、分) を読む
+func loadFeedPollInterval() (time.Duration, error) {
+	raw := os.Getenv("FEED_POLL_INTERVAL_MINUTES")
+	if raw == "" {
+		return DefaultFeedPollMinutes * time.Minute, nil
+	}
+	minutes, err := strconv.Atoi(raw)
+	if err != nil || minutes < 5 {
+		return 0, fmt.Errorf("invalid FEED_POLL_INTERVAL_MINUTES: %q (minimum 5)", raw)
+	}
+	return time.Duration(minutes) * time.Minute, nil
 }
 
 const (
@@ -37,6 +157,30 @@ func LoadConfig() (*Config, error) {
 	}
 	fmt.Println(os.Getenv("JWT_SECRET_KEY"))
 
+	plans, err := loadPlans()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Plans = plans
+
+	thresholds, err := loadScoring()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Scoring = thresholds
+
+	retention, err := loadTrashRetention()
+	if err != nil {
+		return nil, err
+	}
+	cfg.TrashRetention = retention
+
+	feedPollInterval, err := loadFeedPollInterval()
+	if err != nil {
+		return nil, err
+	}
+	cfg.FeedPollInterval = feedPollInterval
+
 	if cfg.Port == "" || cfg.UseSSL == "" || cfg.VertexAPIKey == "" || cfg.SupabaseURI == "" || cfg.JwtSecret == nil {
 		return nil, fmt.Errorf("one or more required environment variables are missing")
 	}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CollectionHandler struct {
+	CollectionService services.CollectionService
+}
+
+func NewCollectionHandler(collectionService services.CollectionService) *CollectionHandler {
+	return &CollectionHandler{CollectionService: collectionService}
+}
+
+type CreateCollectionRequest struct {
+	Name     string `json:"name"`
+	ParentID *uint  `json:"parent_id"`
+	Position int    `json:"position"`
+}
+
+// UpdateCollectionRequest は変更する項目だけを送る。parent_id に null を送ると最上位に移す
+type UpdateCollectionRequest struct {
+	Name     *string         `json:"name"`
+	ParentID json.RawMessage `json:"parent_id"`
+	Position *int            `json:"position"`
+}
+
+type SetCollectionMaterialsRequest struct {
+	MaterialULIDs []string `json:"material_ulids"`
+}
+
+// ListCollections はコレクションを木にして返す
+func (h *CollectionHandler) ListCollections(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	collections, err := h.CollectionService.ListCollections(UserID)
+	if err != nil {
+		return respondWithCollectionError(c, err)
+	}
+	return c.JSON(http.StatusOK, collections)
+}
+
+func (h *CollectionHandler) CreateCollection(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	var req CreateCollectionRequest
+	if err := c.Bind(&req); err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidCollection)
+	}
+
+	collection, err := h.CollectionService.CreateCollection(UserID, req.Name, req.ParentID, req.Position)
+	if err != nil {
+		return respondWithCollectionError(c, err)
+	}
+	return c.JSON(http.StatusCreated, collection)
+}
+
+// UpdateCollection はコレクションの名前・親・位置を変える
+func (h *CollectionHandler) UpdateCollection(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+	var req UpdateCollectionRequest
+	if err := c.Bind(&req); err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidCollection)
+	}
+
+	update := services.CollectionUpdate{Name: req.Name, Position: req.Position}
+	switch string(req.ParentID) {
+	case "":
+	case "null":
+		update.MoveToRoot = true
+	default:
+		var parentID uint
+		if err := json.Unmarshal(req.ParentID, &parentID); err != nil || parentID == 0 {
+			return respondWithError(c, http.StatusBadRequest, ErrInvalidCollection)
+		}
+		update.ParentID = &parentID
+	}
+
+	collection, err := h.CollectionService.UpdateCollection(UserID, id, update)
+	if err != nil {
+		return respondWithCollectionError(c, err)
+	}
+	return c.JSON(http.StatusOK, collection)
+}
+
+// DeleteCollection はコレクションを配下ごと削除する。中の教材は残る
+func (h *CollectionHandler) DeleteCollection(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+
+	if err := h.CollectionService.DeleteCollection(UserID, id); err != nil {
+		return respondWithCollectionError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListCollectionMaterials はコレクションの教材を並び順に返す (配下のコレクションは含めない)
+func (h *CollectionHandler) ListCollectionMaterials(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+
+	materials, err := h.CollectionService.ListMaterials(UserID, id)
+	if err != nil {
+		return respondWithCollectionError(c, err)
+	}
+	return c.JSON(http.StatusOK, materials)
+}
+
+// SetCollectionMaterials はコレクションの教材を material_ulids の順に置き換える
+func (h *CollectionHandler) SetCollectionMaterials(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+	var req SetCollectionMaterialsRequest
+	if err := c.Bind(&req); err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidCollection)
+	}
+
+	materials, err := h.CollectionService.SetMaterials(UserID, id, req.MaterialULIDs)
+	if err != nil {
+		return respondWithCollectionError(c, err)
+	}
+	return c.JSON(http.StatusOK, materials)
+}
+
+func respondWithCollectionError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidCollection):
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidCollection)
+	case errors.Is(err, services.ErrCollectionNotFound):
+		return respondWithError(c, http.StatusNotFound, ErrCollectionNotFound)
+	case errors.Is(err, services.ErrCollectionCycle):
+		return respondWithError(c, http.StatusConflict, ErrCollectionCycle)
+	case errors.Is(err, services.ErrUnknownMaterial):
+		return respondWithError(c, http.StatusBadRequest, ErrMaterialNotFound)
+	}
+	logger.Errorf("Failed to update collection: %v", err)
+	return respondWithError(c, http.StatusInternalServerError, ErrFailedUpdateCollection)
+}
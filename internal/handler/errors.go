@@ -15,4 +15,51 @@ const (
 	ErrFailedRetrieveMaterials = "failed to retrieve materials"
 	ErrFailedCreateMaterial    = "failed to create material"
 	ErrMaterialNotFound        = "material not found"
+	ErrFailedRetrieveUsage     = "failed to retrieve usage"
+	ErrInvalidDateRange        = "invalid date range, expected YYYY-MM-DD"
+	ErrForbiddenAdminOnly      = "admin only"
+	ErrFailedRetrieveQuota     = "failed to retrieve quota"
+	ErrFailedRetrieveWords     = "failed to retrieve words"
+	ErrUnsupportedLanguage     = "unsupported language"
+	ErrFailedUpdateUser        = "failed to update user"
+	ErrInvalidCursor           = "invalid cursor"
+	ErrInvalidListQuery        = "invalid list query"
+	ErrInvalidSearchQuery      = "invalid search query"
+	ErrFailedSearch            = "failed to search"
+	ErrVersionConflict         = "material was modified, reload and retry"
+	ErrVersionRequired         = "If-Match header or version is required"
+	ErrFailedRegenerate        = "failed to regenerate material"
+	ErrGenerationRunning       = "words and phrases are still being generated, retry when it finishes"
+	ErrInvalidRevision         = "invalid revision"
+	ErrRevisionNotFound        = "revision not found"
+	ErrFailedRetrieveRevisions = "failed to retrieve revisions"
+	ErrMaterialNotInTrash      = "material not found in trash"
+	ErrFailedPurgeMaterial     = "failed to purge material"
+	ErrInvalidTag              = "invalid tag"
+	ErrTagNotFound             = "tag not found"
+	ErrTagExists               = "tag already exists"
+	ErrFailedUpdateTags        = "failed to update tags"
+	ErrInvalidCollection       = "invalid collection"
+	ErrCollectionNotFound      = "collection not found"
+	ErrCollectionCycle         = "collection cannot be moved under itself"
+	ErrFailedUpdateCollection  = "failed to update collection"
+	ErrInvalidExportFormat     = "invalid export format, expected json or csv"
+	ErrFailedExport            = "failed to export materials"
+	ErrInvalidUpload           = "multipart field file is required"
+	ErrFileTooLarge            = "file is too large, the limit is 10 MB"
+	ErrUnsupportedFile         = "unsupported file format, expected PDF, EPUB, DOCX, Markdown, HTML, SRT, WebVTT or text"
+	ErrUnreadableFile          = "no text could be extracted from the file"
+	ErrInvalidImportURL        = "invalid URL, expected a public http or https URL"
+	ErrUnsupportedPage         = "page is not HTML or text"
+	ErrPageTooLarge            = "page is too large, the limit is 5 MB"
+	ErrUnreadablePage          = "no article text could be extracted from the page"
+	ErrFetchFailed             = "failed to fetch the page"
+	ErrNoSubtitles             = "material has no subtitles"
+	ErrFailedRetrieveSubtitles = "failed to retrieve subtitles"
+	ErrInvalidFeed             = "invalid feed subscription"
+	ErrFeedNotFound            = "feed subscription not found"
+	ErrFeedExists              = "feed is already subscribed"
+	ErrTooManyFeeds            = "too many feed subscriptions, the limit is 50"
+	ErrNotAFeed                = "URL is not an RSS or Atom feed"
+	ErrFailedUpdateFeed        = "failed to update feed subscription"
 )
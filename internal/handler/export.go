@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ExportMaterials は ?format=json|csv で教材と単語・フレーズを書き出す。
+// ?tag= と ?collection= で絞れ、訳は ?lang= (なければ母語) の言語
+func (h *MaterialHandler) ExportMaterials(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "csv" {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidExportFormat)
+	}
+	scope, err := parseMaterialScope(c)
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, err.Error())
+	}
+
+	materials, err := h.ExportService.ExportMaterials(UserID, scope, h.requestedLanguage(c, UserID))
+	if errors.Is(err, services.ErrInvalidListFilter) {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidListQuery)
+	}
+	if err != nil {
+		logger.Errorf("Failed to export materials: %v, UserID: %v", err, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedExport)
+	}
+
+	if format != "csv" {
+		return c.JSON(http.StatusOK, materials)
+	}
+	filename := fmt.Sprintf("newln-%s.csv", time.Now().UTC().Format("20060102"))
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	c.Response().WriteHeader(http.StatusOK)
+	return services.WriteExportCSV(c.Response(), materials)
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type FeedHandler struct {
+	FeedService services.FeedService
+}
+
+func NewFeedHandler(feedService services.FeedService) *FeedHandler {
+	return &FeedHandler{FeedService: feedService}
+}
+
+// CreateFeedRequest は購読の指定。max_per_day を省くと1日5件まで
+type CreateFeedRequest struct {
+	URL            string   `json:"url"`
+	Title          string   `json:"title"`
+	SourceLanguage string   `json:"source_language"`
+	Keywords       []string `json:"keywords"`
+	MaxLength      int      `json:"max_length"`
+	MaxPerDay      int      `json:"max_per_day"`
+	FullText       bool     `json:"full_text"`
+}
+
+// UpdateFeedRequest は変更する項目だけを送る。active を false にすると取得を止める
+type UpdateFeedRequest struct {
+	Title          *string   `json:"title"`
+	SourceLanguage *string   `json:"source_language"`
+	Keywords       *[]string `json:"keywords"`
+	MaxLength      *int      `json:"max_length"`
+	MaxPerDay      *int      `json:"max_per_day"`
+	FullText       *bool     `json:"full_text"`
+	Active         *bool     `json:"active"`
+}
+
+type FeedResponse struct {
+	ID             uint       `json:"id"`
+	URL            string     `json:"url"`
+	Title          string     `json:"title"`
+	SourceLanguage string     `json:"source_language"`
+	Keywords       []string   `json:"keywords"`
+	MaxLength      int        `json:"max_length"`
+	MaxPerDay      int        `json:"max_per_day"`
+	FullText       bool       `json:"full_text"`
+	Active         bool       `json:"active"`
+	NextPollAt     time.Time  `json:"next_poll_at"`
+	LastPolledAt   *time.Time `json:"last_polled_at"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type FeedEntryResponse struct {
+	Title        string    `json:"title"`
+	Link         string    `json:"link"`
+	Status       string    `json:"status"`
+	Reason       string    `json:"reason,omitempty"`
+	MaterialULID string    `json:"material_ulid,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newFeedResponse(subscription *models.FeedSubscription) FeedResponse {
+	keywords := subscription.Keywords
+	if keywords == nil {
+		keywords = []string{}
+	}
+	return FeedResponse{
+		ID:             subscription.ID,
+		URL:            subscription.URL,
+		Title:          subscription.Title,
+		SourceLanguage: subscription.SourceLanguage,
+		Keywords:       keywords,
+		MaxLength:      subscription.MaxLength,
+		MaxPerDay:      subscription.MaxPerDay,
+		FullText:       subscription.FullText,
+		Active:         subscription.Active,
+		NextPollAt:     subscription.NextPollAt,
+		LastPolledAt:   subscription.LastPolledAt,
+		LastError:      subscription.LastError,
+		CreatedAt:      subscription.CreatedAt,
+	}
+}
+
+func (h *FeedHandler) ListFeeds(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	subscriptions, err := h.FeedService.ListSubscriptions(UserID)
+	if err != nil {
+		return respondWithFeedError(c, err)
+	}
+	response := make([]FeedResponse, len(subscriptions))
+	for i := range subscriptions {
+		response[i] = newFeedResponse(&subscriptions[i])
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// CreateFeed はフィードを確かめて購読する。新しい記事は定期的な取得で教材になる
+func (h *FeedHandler) CreateFeed(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	var req CreateFeedRequest
+	if err := c.Bind(&req); err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidFeed)
+	}
+
+	subscription, err := h.FeedService.CreateSubscription(c.Request().Context(), UserID, services.FeedSubscriptionInput{
+		URL:            req.URL,
+		Title:          req.Title,
+		SourceLanguage: req.SourceLanguage,
+		Keywords:       req.Keywords,
+		MaxLength:      req.MaxLength,
+		MaxPerDay:      req.MaxPerDay,
+		FullText:       req.FullText,
+	})
+	if err != nil {
+		return respondWithFeedError(c, err)
+	}
+	return c.JSON(http.StatusCreated, newFeedResponse(subscription))
+}
+
+func (h *FeedHandler) UpdateFeed(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+	var req UpdateFeedRequest
+	if err := c.Bind(&req); err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidFeed)
+	}
+
+	subscription, err := h.FeedService.UpdateSubscription(UserID, id, services.FeedSubscriptionUpdate(req))
+	if err != nil {
+		return respondWithFeedError(c, err)
+	}
+	return c.JSON(http.StatusOK, newFeedResponse(subscription))
+}
+
+// DeleteFeed は購読をやめる。作った教材は残る
+func (h *FeedHandler) DeleteFeed(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+
+	if err := h.FeedService.DeleteSubscription(UserID, id); err != nil {
+		return respondWithFeedError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListFeedEntries は処理した記事と、教材にしたか・しなかった理由を新しい順に返す
+func (h *FeedHandler) ListFeedEntries(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+
+	entries, err := h.FeedService.ListEntries(UserID, id)
+	if err != nil {
+		return respondWithFeedError(c, err)
+	}
+	response := make([]FeedEntryResponse, len(entries))
+	for i, entry := range entries {
+		response[i] = FeedEntryResponse{
+			Title:        entry.Title,
+			Link:         entry.Link,
+			Status:       entry.Status,
+			Reason:       entry.Reason,
+			MaterialULID: entry.MaterialULID,
+			CreatedAt:    entry.CreatedAt,
+		}
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// PollFeed は次の定期的な取得を待たずにフィードを取得する
+func (h *FeedHandler) PollFeed(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+
+	result, err := h.FeedService.PollSubscription(c.Request().Context(), UserID, id)
+	if err != nil {
+		return respondWithFeedError(c, err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func respondWithFeedError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidFeed):
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidFeed)
+	case errors.Is(err, services.ErrUnsupportedLanguage):
+		return respondWithError(c, http.StatusBadRequest, ErrUnsupportedLanguage)
+	case errors.Is(err, services.ErrFeedNotFound):
+		return respondWithError(c, http.StatusNotFound, ErrFeedNotFound)
+	case errors.Is(err, services.ErrFeedExists):
+		return respondWithError(c, http.StatusConflict, ErrFeedExists)
+	case errors.Is(err, services.ErrTooManyFeeds):
+		return respondWithError(c, http.StatusConflict, ErrTooManyFeeds)
+	case errors.Is(err, services.ErrNotAFeed):
+		return respondWithError(c, http.StatusUnprocessableEntity, ErrNotAFeed)
+	case errors.Is(err, services.ErrInvalidImportURL), errors.Is(err, services.ErrBlockedURL),
+		errors.Is(err, services.ErrPageTooLarge), errors.Is(err, services.ErrFetchFailed):
+		return respondWithImportError(c, err)
+	}
+	logger.Errorf("Failed to update feed: %v", err)
+	return respondWithError(c, http.StatusInternalServerError, ErrFailedUpdateFeed)
+}
@@ -14,16 +14,30 @@ import (
 )
 
 type Handlers struct {
-	UserHandler     *UserHandler
-	MaterialHandler *MaterialHandler
-	jwtSecret       []byte
+	UserHandler       *UserHandler
+	MaterialHandler   *MaterialHandler
+	UsageHandler      *UsageHandler
+	WordHandler       *WordHandler
+	SearchHandler     *SearchHandler
+	TagHandler        *TagHandler
+	CollectionHandler *CollectionHandler
+	FeedHandler       *FeedHandler
+	userService       services.UserService
+	jwtSecret         []byte
 }
 
 func NewHandler(services *services.Services, jwtSecret []byte) *Handlers {
 	return &Handlers{
-		UserHandler:     NewUserHandler(services.UserService),
-		MaterialHandler: NewMaterialHandler(services.MaterialService, services.PhraseService, services.WordService, jwtSecret),
-		jwtSecret:       jwtSecret,
+		UserHandler:       NewUserHandler(services.UserService),
+		MaterialHandler:   NewMaterialHandler(services, jwtSecret),
+		UsageHandler:      NewUsageHandler(services.UsageService, services.MaterialService, services.QuotaService),
+		WordHandler:       NewWordHandler(services.WordService),
+		SearchHandler:     NewSearchHandler(services.SearchService),
+		TagHandler:        NewTagHandler(services.TagService),
+		CollectionHandler: NewCollectionHandler(services.CollectionService),
+		FeedHandler:       NewFeedHandler(services.FeedService),
+		userService:       services.UserService,
+		jwtSecret:         jwtSecret,
 	}
 }
 func (h *Handlers) SetDefault(e *echo.Echo) {
@@ -40,14 +54,59 @@ func (h *Handlers) SetAPIRoutes(e *echo.Echo) {
 
 	materialRoutes := api.Group("/materials")
 	materialRoutes.POST("", h.MaterialHandler.CreateMaterial)
-	materialRoutes.GET("", h.MaterialHandler.GetAllMaterials)
+	materialRoutes.GET("", h.MaterialHandler.ListMaterials)
+	materialRoutes.POST("/upload", h.MaterialHandler.UploadMaterial)
+	materialRoutes.POST("/import", h.MaterialHandler.ImportURL)
+	materialRoutes.GET("/trash", h.MaterialHandler.ListTrash)
+	materialRoutes.DELETE("/trash", h.MaterialHandler.EmptyTrash)
+	materialRoutes.DELETE("/trash/:ulid", h.MaterialHandler.PurgeMaterial)
 	materialRoutes.GET("/:ulid", h.MaterialHandler.GetMaterialByULID)
-	materialRoutes.PUT("/:ulid", h.MaterialHandler.UpdateMaterial)
+	materialRoutes.PATCH("/:ulid", h.MaterialHandler.PatchMaterial)
+	materialRoutes.POST("/:ulid/regenerate", h.MaterialHandler.RegenerateMaterial)
+	materialRoutes.POST("/:ulid/restore", h.MaterialHandler.RestoreMaterial)
+	materialRoutes.GET("/:ulid/revisions", h.MaterialHandler.ListRevisions)
+	materialRoutes.GET("/:ulid/revisions/diff", h.MaterialHandler.DiffRevisions)
+	materialRoutes.GET("/:ulid/revisions/:version", h.MaterialHandler.GetRevision)
+	materialRoutes.POST("/:ulid/revisions/:version/restore", h.MaterialHandler.RestoreRevision)
 	materialRoutes.DELETE("/:ulid", h.MaterialHandler.DeleteMaterial)
 	materialRoutes.GET("/:ulid/status", h.MaterialHandler.CheckMaterialStatus)
+	materialRoutes.GET("/:ulid/usage", h.UsageHandler.GetMaterialUsage)
+	materialRoutes.GET("/:ulid/subtitles", h.MaterialHandler.GetSubtitleTimeline)
+	materialRoutes.PUT("/:ulid/tags", h.TagHandler.SetMaterialTags)
 	// materialRoutes.GET("/:id/phrases", h.MaterialHandler.GetProcessedPhrases)
 	// materialRoutes.GET("/:id/chats", h.MaterialHandler.GetChatByMaterialID)
 
+	api.GET("/usage", h.UsageHandler.GetMyUsage)
+	api.GET("/quota", h.UsageHandler.GetMyQuota)
+	api.GET("/vocabulary", h.WordHandler.GetVocabulary)
+	api.GET("/search", h.SearchHandler.Search)
+	api.GET("/export", h.MaterialHandler.ExportMaterials)
+	api.GET("/tags", h.TagHandler.ListTags)
+	api.PATCH("/tags/:id", h.TagHandler.RenameTag)
+	api.DELETE("/tags/:id", h.TagHandler.DeleteTag)
+
+	collectionRoutes := api.Group("/collections")
+	collectionRoutes.GET("", h.CollectionHandler.ListCollections)
+	collectionRoutes.POST("", h.CollectionHandler.CreateCollection)
+	collectionRoutes.PATCH("/:id", h.CollectionHandler.UpdateCollection)
+	collectionRoutes.DELETE("/:id", h.CollectionHandler.DeleteCollection)
+	collectionRoutes.GET("/:id/materials", h.CollectionHandler.ListCollectionMaterials)
+	collectionRoutes.PUT("/:id/materials", h.CollectionHandler.SetCollectionMaterials)
+
+	feedRoutes := api.Group("/feeds")
+	feedRoutes.GET("", h.FeedHandler.ListFeeds)
+	feedRoutes.POST("", h.FeedHandler.CreateFeed)
+	feedRoutes.PATCH("/:id", h.FeedHandler.UpdateFeed)
+	feedRoutes.DELETE("/:id", h.FeedHandler.DeleteFeed)
+	feedRoutes.GET("/:id/entries", h.FeedHandler.ListFeedEntries)
+	feedRoutes.POST("/:id/poll", h.FeedHandler.PollFeed)
+
+	api.PUT("/me/native-language", h.UserHandler.UpdateNativeLanguage)
+
+	adminRoutes := api.Group("/admin")
+	adminRoutes.Use(h.AdminMiddleware)
+	adminRoutes.GET("/usage", h.UsageHandler.GetAdminUsage)
+
 	wsRoutes := e.Group("/api/materials")
 	wsRoutes.GET("/:ulid/progress", h.MaterialHandler.StreamMaterialProgressWS)
 }
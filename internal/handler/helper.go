@@ -6,9 +6,10 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/yomek33/newln/internal/logger"
-	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/services"
 
 	"github.com/go-playground/validator"
 	"github.com/golang-jwt/jwt"
@@ -77,24 +78,69 @@ func (h *Handlers) JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+// AdminMiddleware は JWTMiddleware の後に使う
+func (h *Handlers) AdminMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+		}
+
+		user, err := h.userService.GetUserByID(userID)
+		if err != nil || !user.IsAdmin {
+			return respondWithError(c, http.StatusForbidden, ErrForbiddenAdminOnly)
+		}
+
+		return next(c)
+	}
+}
+
 func respondWithError(c echo.Context, code int, message string) error {
 	return c.JSON(code, map[string]string{"error": message})
 }
 
-func bindAndValidateMaterial(c echo.Context, material *models.Material) error {
-	if err := c.Bind(material); err != nil {
+// respondWithQuotaError は上限超過を 402/429 と残り・リセット時刻で返す
+func respondWithQuotaError(c echo.Context, err error) error {
+	var quotaErr *services.QuotaError
+	if !errors.As(err, &quotaErr) {
+		logger.Errorf("Failed to check quota: %v", err)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveQuota)
+	}
+
+	code := http.StatusPaymentRequired
+	if quotaErr.Kind == services.QuotaKindRateLimit {
+		code = http.StatusTooManyRequests
+	}
+	if quotaErr.ResetAt != nil {
+		retryAfter := int(time.Until(*quotaErr.ResetAt).Seconds()) + 1
+		c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+
+	return c.JSON(code, map[string]interface{}{
+		"error":     quotaErr.Error(),
+		"limit":     quotaErr.Limit,
+		"plan":      quotaErr.Plan,
+		"max":       quotaErr.Max,
+		"used":      quotaErr.Used,
+		"remaining": quotaErr.Remaining,
+		"reset_at":  quotaErr.ResetAt,
+	})
+}
+
+func bindAndValidateMaterial(c echo.Context, req *CreateMaterialRequest) error {
+	if err := c.Bind(req); err != nil {
 		logger.Errorf("Error binding material: %v", err)
 		return errors.New(ErrInvalidMaterialData)
 	}
-	if err := validateMaterial(material); err != nil {
+	if err := validateMaterial(req); err != nil {
 		return err
 	}
 	return nil
 }
 
-func validateMaterial(material *models.Material) error {
+func validateMaterial(req *CreateMaterialRequest) error {
 	validate := validator.New()
-	if err := validate.Struct(material); err != nil {
+	if err := validate.Struct(req); err != nil {
 		var errorMessages []string
 		for _, err := range err.(validator.ValidationErrors) {
 			errorMessage := fmt.Sprintf("Error in field '%s': %s", strings.ToLower(err.Field()), err.Tag())
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/language"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// multipart の本体の上限。ファイルとフォームの項目の分
+const maxUploadRequestBytes = services.MaxUploadBytes + 1<<20
+
+const maxTitleLength = 255
+
+// UploadMaterial は multipart の file (PDF, EPUB, DOCX, Markdown, HTML, SRT, WebVTT, テキスト) から本文を取り出して教材を作る。
+// title がなければファイルのタイトル、source_language がなければ英語。字幕はキューの時刻も残す
+func (h *MaterialHandler) UploadMaterial(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxUploadRequestBytes)
+	fileHeader, err := c.FormFile("file")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return respondWithError(c, http.StatusRequestEntityTooLarge, ErrFileTooLarge)
+	}
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidUpload)
+	}
+	if fileHeader.Size > services.MaxUploadBytes {
+		return respondWithError(c, http.StatusRequestEntityTooLarge, ErrFileTooLarge)
+	}
+	sourceLanguage, ok := language.NormalizeSource(c.FormValue("source_language"))
+	if !ok {
+		return respondWithError(c, http.StatusBadRequest, ErrUnsupportedLanguage)
+	}
+	title := strings.TrimSpace(c.FormValue("title"))
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidMaterialData)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidUpload)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, services.MaxUploadBytes+1))
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidUpload)
+	}
+
+	imported, err := h.ImportService.ExtractFile(fileHeader.Filename, data)
+	if err != nil {
+		return respondWithImportError(c, err)
+	}
+	logger.Infof("📄 Extracted %d chars from %s (%s), UserID: %v", len(imported.Content), fileHeader.Filename, imported.Format, UserID)
+
+	material := &models.Material{
+		Title:          imported.Title,
+		Content:        imported.Content,
+		SourceLanguage: sourceLanguage,
+		SubtitleCues:   imported.Cues,
+	}
+	if title != "" {
+		material.Title = title
+	}
+	return h.createMaterial(c, UserID, material)
+}
+
+// ImportURLRequest は URL からの取り込みの指定。refresh ならキャッシュを使わずに取得し直す
+type ImportURLRequest struct {
+	URL            string `json:"url"`
+	Title          string `json:"title"`
+	SourceLanguage string `json:"source_language"`
+	Refresh        bool   `json:"refresh"`
+}
+
+// ImportURL はページを取得し、記事の本文から教材を作る。教材には元の URL を残す
+func (h *MaterialHandler) ImportURL(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	var req ImportURLRequest
+	if err := c.Bind(&req); err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidImportURL)
+	}
+	sourceLanguage, ok := language.NormalizeSource(req.SourceLanguage)
+	if !ok {
+		return respondWithError(c, http.StatusBadRequest, ErrUnsupportedLanguage)
+	}
+	title := strings.TrimSpace(req.Title)
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidMaterialData)
+	}
+
+	imported, err := h.ImportService.FetchURL(c.Request().Context(), req.URL, req.Refresh)
+	if err != nil {
+		return respondWithImportError(c, err)
+	}
+	logger.Infof("🌐 Imported %d chars from %s (cached: %v), UserID: %v", len(imported.Content), imported.SourceURL, imported.Cached, UserID)
+
+	material := &models.Material{
+		Title:          imported.Title,
+		Content:        imported.Content,
+		SourceLanguage: sourceLanguage,
+		SourceURL:      imported.SourceURL,
+	}
+	if title != "" {
+		material.Title = title
+	}
+	return h.createMaterial(c, UserID, material)
+}
+
+func respondWithImportError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrUnsupportedFile):
+		return respondWithError(c, http.StatusUnsupportedMediaType, ErrUnsupportedFile)
+	case errors.Is(err, services.ErrFileTooLarge):
+		return respondWithError(c, http.StatusRequestEntityTooLarge, ErrFileTooLarge)
+	case errors.Is(err, services.ErrUnreadableFile):
+		return respondWithError(c, http.StatusUnprocessableEntity, ErrUnreadableFile)
+	case errors.Is(err, services.ErrInvalidImportURL), errors.Is(err, services.ErrBlockedURL):
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidImportURL)
+	case errors.Is(err, services.ErrUnsupportedPage):
+		return respondWithError(c, http.StatusUnprocessableEntity, ErrUnsupportedPage)
+	case errors.Is(err, services.ErrPageTooLarge):
+		return respondWithError(c, http.StatusUnprocessableEntity, ErrPageTooLarge)
+	case errors.Is(err, services.ErrUnreadablePage):
+		return respondWithError(c, http.StatusUnprocessableEntity, ErrUnreadablePage)
+	case errors.Is(err, services.ErrFetchFailed):
+		logger.Errorf("Failed to fetch page: %v", err)
+		return respondWithError(c, http.StatusBadGateway, ErrFetchFailed)
+	}
+	logger.Errorf("Failed to import material: %v", err)
+	return respondWithError(c, http.StatusInternalServerError, ErrFailedCreateMaterial)
+}
@@ -1,37 +1,53 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/yomek33/newln/internal/logger"
 	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/language"
 	"github.com/yomek33/newln/internal/services"
 	"golang.org/x/net/websocket"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
 )
 
 type MaterialHandler struct {
-	MaterialService services.MaterialService
-	PhraseService   services.PhraseService
-	WordService     services.WordService
-	jwtSecret       []byte
+	MaterialService   services.MaterialService
+	PhraseService     services.PhraseService
+	WordService       services.WordService
+	QuotaService      services.QuotaService
+	GenerationService services.GenerationService
+	UserService       services.UserService
+	TrashService      services.TrashService
+	ExportService     services.ExportService
+	ImportService     services.ImportService
+	jwtSecret         []byte
 }
 
-func NewMaterialHandler(materialService services.MaterialService, phraseService services.PhraseService, wordService services.WordService, jwtSecret []byte) *MaterialHandler {
+func NewMaterialHandler(svc *services.Services, jwtSecret []byte) *MaterialHandler {
 	return &MaterialHandler{
-		MaterialService: materialService,
-		PhraseService:   phraseService,
-		WordService:     wordService,
-		jwtSecret:       jwtSecret,
+		MaterialService:   svc.MaterialService,
+		PhraseService:     svc.PhraseService,
+		WordService:       svc.WordService,
+		QuotaService:      svc.QuotaService,
+		GenerationService: svc.GenerationService,
+		UserService:       svc.UserService,
+		TrashService:      svc.TrashService,
+		ExportService:     svc.ExportService,
+		ImportService:     svc.ImportService,
+		jwtSecret:         jwtSecret,
 	}
 }
 
@@ -40,47 +56,81 @@ type MaterialResponse struct {
 	ULID                 string
 	Content              string
 	Title                string
+	SourceLanguage       string
+	SourceURL            string `json:",omitempty"`
 	Status               string
+	Version              int
 	CreatedAt            time.Time
 	UpdatedAt            time.Time
 	HasPendingPhraseList bool
 	HasPendingWordList   bool
 }
 
+// CreateMaterialRequest は手で入力した教材。キーは models.Material と同じ (Title, Content, SourceLanguage)。
+// 字幕・出典 URL・版はここからは設定できない。字幕はアップロードした字幕ファイルから、
+// 出典 URL は URL やフィードからの取り込みでだけ入り、版は 1 から始まる
+type CreateMaterialRequest struct {
+	Title          string
+	Content        string
+	SourceLanguage string
+}
+
 func (h *MaterialHandler) CreateMaterial(c echo.Context) error {
-	var material models.Material
-	if err := bindAndValidateMaterial(c, &material); err != nil {
+	var req CreateMaterialRequest
+	if err := bindAndValidateMaterial(c, &req); err != nil {
 		return respondWithError(c, http.StatusBadRequest, err.Error())
 	}
 
+	sourceLanguage, ok := language.NormalizeSource(req.SourceLanguage)
+	if !ok {
+		return respondWithError(c, http.StatusBadRequest, ErrUnsupportedLanguage)
+	}
+
 	UserID, err := getUserIDFromContext(c)
 	if err != nil {
 		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
 	}
 
+	return h.createMaterial(c, UserID, &models.Material{
+		Title:          req.Title,
+		Content:        req.Content,
+		SourceLanguage: sourceLanguage,
+	})
+}
+
+// createMaterial は上限を確かめて教材を作り、単語・フレーズの生成を始める。
+// material はハンドラが組み立てたもので、SourceURL は取り込みのときだけ入っている
+func (h *MaterialHandler) createMaterial(c echo.Context, UserID uuid.UUID, material *models.Material) error {
+	limit, err := h.QuotaService.CheckMaterialCreation(UserID, utf8.RuneCountInString(material.Content))
+	if err != nil {
+		return respondWithQuotaError(c, err)
+	}
+
 	material.UserID = UserID
 	material.Status = "draft"
 	material.ULID = ulid.Make().String()
 	material.HasPendingPhraseList = true
 	material.HasPendingWordList = true
 
-	createdMaterial, err := h.MaterialService.CreateMaterial(&material)
+	createdMaterial, err := h.MaterialService.CreateMaterial(material, limit)
+	var quotaErr *services.QuotaError
+	if errors.As(err, &quotaErr) {
+		return respondWithQuotaError(c, err)
+	}
 	if err != nil {
 		logger.Errorf("Error creating material: %v, UserID: %v", err, UserID)
 		return respondWithError(c, http.StatusInternalServerError, ErrFailedCreateMaterial)
 	}
-	go func() {
-		asyncCtx, cancel := context.WithTimeout(context.Background(), 300*time.Second) // 5分の独立した `ctx`
-		defer cancel()
-
-		h.processMaterialAsync(asyncCtx, createdMaterial.ID, createdMaterial.ULID, UserID)
-	}()
+	h.GenerationService.StartGeneration(createdMaterial)
 	logger.Infof("createdMaterial: %+v", createdMaterial)
 	response := MaterialResponse{
 		ULID:                 material.ULID,
 		Content:              material.Content,
 		Title:                material.Title,
+		SourceLanguage:       material.SourceLanguage,
+		SourceURL:            createdMaterial.SourceURL,
 		Status:               createdMaterial.Status,
+		Version:              createdMaterial.Version,
 		CreatedAt:            createdMaterial.CreatedAt,
 		UpdatedAt:            createdMaterial.UpdatedAt,
 		HasPendingPhraseList: true,
@@ -88,6 +138,7 @@ func (h *MaterialHandler) CreateMaterial(c echo.Context) error {
 	}
 
 	logger.Infof("Material created successfully: %+v", createdMaterial)
+	setMaterialETag(c, createdMaterial)
 	return c.JSON(http.StatusCreated, response)
 }
 
@@ -104,36 +155,226 @@ func (h *MaterialHandler) GetMaterialByULID(c echo.Context) error {
 		return respondWithError(c, http.StatusNotFound, ErrMaterialNotFound)
 	}
 
+	filterTranslations(material, h.requestedLanguage(c, UserID))
+	setMaterialETag(c, material)
 	return c.JSON(http.StatusOK, material)
 }
 
-func (h *MaterialHandler) UpdateMaterial(c echo.Context) error {
+// requestedLanguage は ?lang=、Accept-Language、ユーザーの母語の順に意味の言語を決める
+func (h *MaterialHandler) requestedLanguage(c echo.Context, userID uuid.UUID) string {
+	if code, ok := language.Normalize(c.QueryParam("lang")); ok {
+		return code
+	}
+	for _, tag := range strings.Split(c.Request().Header.Get("Accept-Language"), ",") {
+		tag, _, _ = strings.Cut(tag, ";")
+		if code, ok := language.Normalize(tag); ok {
+			return code
+		}
+	}
+	if user, err := h.UserService.GetUserByID(userID); err == nil {
+		if code, ok := language.Normalize(user.NativeLanguage); ok {
+			return code
+		}
+	}
+	return language.DefaultNative
+}
+
+// filterTranslations は単語・フレーズの訳を lang のものだけに絞る
+func filterTranslations(material *models.Material, lang string) {
+	keep := func(translations []models.Translation) []models.Translation {
+		filtered := make([]models.Translation, 0, 1)
+		for _, t := range translations {
+			if t.Language == lang {
+				filtered = append(filtered, t)
+			}
+		}
+		return filtered
+	}
+	for i := range material.WordLists {
+		for j := range material.WordLists[i].Words {
+			material.WordLists[i].Words[j].Translations = keep(material.WordLists[i].Words[j].Translations)
+		}
+	}
+	for i := range material.PhraseLists {
+		for j := range material.PhraseLists[i].Phrases {
+			material.PhraseLists[i].Phrases[j].Translations = keep(material.PhraseLists[i].Phrases[j].Translations)
+		}
+	}
+}
+
+// MaterialPatchRequest は PATCH で変更できるフィールド。これ以外のフィールドは 400 にする
+type MaterialPatchRequest struct {
+	Title          *string `json:"title"`
+	Content        *string `json:"content"`
+	SourceLanguage *string `json:"source_language"`
+	Status         *string `json:"status"`
+	// If-Match がないときの版
+	Version *int `json:"version"`
+	// 本文か言語が変わったら単語・フレーズをすぐに作り直す
+	Regenerate bool `json:"regenerate"`
+}
+
+type MaterialPatchResponse struct {
+	MaterialResponse
+	// 本文か言語が変わり、単語・フレーズのリストが古くなった
+	ContentChanged bool
+	// 作り直しを始めた。false なら POST /api/materials/:ulid/regenerate で作り直せる
+	Regenerating bool
+}
+
+// PatchMaterial は If-Match (または version) の版が一致するときだけ教材を更新する
+func (h *MaterialHandler) PatchMaterial(c echo.Context) error {
 	UserID, err := getUserIDFromContext(c)
 	if err != nil {
 		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
 	}
+	ulid := c.Param("ulid")
+
+	var req MaterialPatchRequest
+	decoder := json.NewDecoder(c.Request().Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		logger.Errorf("Error binding material patch: %v", err)
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidMaterialData)
+	}
+
+	version, ok := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if !ok {
+		return respondWithError(c, http.StatusPreconditionFailed, ErrVersionConflict)
+	}
+	if strings.TrimSpace(c.Request().Header.Get("If-Match")) == "" {
+		if req.Version == nil {
+			return respondWithError(c, http.StatusPreconditionRequired, ErrVersionRequired)
+		}
+		version = *req.Version
+	}
+
+	if req.Content != nil {
+		if err := h.QuotaService.CheckContentLength(UserID, utf8.RuneCountInString(*req.Content)); err != nil {
+			return respondWithQuotaError(c, err)
+		}
+	}
+	// 生成中は作り直せないので、更新する前に断る
+	if req.Regenerate && h.GenerationService.IsGenerating(ulid) {
+		return respondWithError(c, http.StatusConflict, ErrGenerationRunning)
+	}
+
+	material, contentChanged, err := h.MaterialService.PatchMaterial(ulid, UserID, version, services.MaterialPatch{
+		Title:          req.Title,
+		Content:        req.Content,
+		SourceLanguage: req.SourceLanguage,
+		Status:         req.Status,
+	})
+	if errors.Is(err, services.ErrVersionConflict) {
+		setMaterialETag(c, material)
+		return respondWithError(c, http.StatusPreconditionFailed, ErrVersionConflict)
+	}
+	if errors.Is(err, services.ErrInvalidMaterialPatch) {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidMaterialData)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return respondWithError(c, http.StatusNotFound, ErrMaterialNotFound)
+	}
+	if err != nil {
+		logger.Errorf("Failed to update material: %v, MaterialID: %v, UserID: %v", err, ulid, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedUpdateMaterial)
+	}
+
+	response := MaterialPatchResponse{ContentChanged: contentChanged}
+	if contentChanged && req.Regenerate {
+		if err := h.regenerate(material); err != nil {
+			return respondWithRegenerateError(c, material, err)
+		}
+		response.Regenerating = true
+	}
+	response.MaterialResponse = newMaterialResponse(material)
+
+	logger.Infof("Updated material, MaterialID: %v, UserID: %v, ContentChanged: %v", ulid, UserID, contentChanged)
+	setMaterialETag(c, material)
+	return c.JSON(http.StatusOK, response)
+}
 
+// RegenerateMaterial は単語・フレーズのリストを削除し、今の本文から作り直す
+func (h *MaterialHandler) RegenerateMaterial(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
 	ulid := c.Param("ulid")
+
 	material, err := h.MaterialService.GetMaterialByULID(ulid, UserID)
 	if err != nil {
 		return respondWithError(c, http.StatusNotFound, ErrMaterialNotFound)
 	}
+	if err := h.regenerate(material); err != nil {
+		return respondWithRegenerateError(c, material, err)
+	}
 
-	if err := bindAndValidateMaterial(c, material); err != nil {
-		return respondWithError(c, http.StatusBadRequest, err.Error())
+	logger.Infof("Regenerating material, MaterialID: %v, UserID: %v", ulid, UserID)
+	setMaterialETag(c, material)
+	return c.JSON(http.StatusAccepted, newMaterialResponse(material))
+}
+
+// regenerate はトークン予算を確かめてから単語・フレーズのリストを消し、生成をやり直す。
+// 生成中なら services.ErrGenerationRunning を返す
+func (h *MaterialHandler) regenerate(material *models.Material) error {
+	if err := h.QuotaService.CheckTokenBudget(material.UserID); err != nil {
+		return err
 	}
+	return h.GenerationService.Regenerate(material, func() error {
+		return h.MaterialService.ResetDerivedLists(material)
+	})
+}
 
-	if material.UserID != UserID {
-		return respondWithError(c, http.StatusForbidden, ErrForbiddenModify)
+func respondWithRegenerateError(c echo.Context, material *models.Material, err error) error {
+	var quotaErr *services.QuotaError
+	if errors.As(err, &quotaErr) {
+		return respondWithQuotaError(c, err)
 	}
+	if errors.Is(err, services.ErrGenerationRunning) {
+		return respondWithError(c, http.StatusConflict, ErrGenerationRunning)
+	}
+	logger.Errorf("Failed to regenerate material: %v, MaterialID: %v", err, material.ULID)
+	return respondWithError(c, http.StatusInternalServerError, ErrFailedRegenerate)
+}
 
-	if err := h.MaterialService.UpdateMaterial(ulid, material); err != nil {
-		logger.Errorf("Failed to update material: %v, MaterialID: %v, UserID: %v", err, ulid, UserID)
-		return respondWithError(c, http.StatusInternalServerError, ErrFailedUpdateMaterial)
+// setMaterialETag は教材の版を ETag にする。PATCH の If-Match にそのまま渡せる
+func setMaterialETag(c echo.Context, material *models.Material) {
+	if material != nil {
+		c.Response().Header().Set("ETag", fmt.Sprintf(`"%d"`, material.Version))
 	}
+}
 
-	logger.Infof("Updated material, MaterialID: %v, UserID: %v", ulid, UserID)
-	return c.JSON(http.StatusOK, material)
+// parseIfMatch は If-Match の版を返す。空か * なら 0 (版を確かめない)。
+// 版として読めない ETag は一致しないので false
+func parseIfMatch(header string) (int, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+func newMaterialResponse(material *models.Material) MaterialResponse {
+	return MaterialResponse{
+		ID:                   material.ID,
+		ULID:                 material.ULID,
+		Content:              material.Content,
+		Title:                material.Title,
+		SourceLanguage:       material.SourceLanguage,
+		SourceURL:            material.SourceURL,
+		Status:               material.Status,
+		Version:              material.Version,
+		CreatedAt:            material.CreatedAt,
+		UpdatedAt:            material.UpdatedAt,
+		HasPendingPhraseList: material.HasPendingPhraseList,
+		HasPendingWordList:   material.HasPendingWordList,
+	}
 }
 
 func (h *MaterialHandler) DeleteMaterial(c echo.Context) error {
@@ -153,173 +394,120 @@ func (h *MaterialHandler) DeleteMaterial(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
-func (h *MaterialHandler) GetAllMaterials(c echo.Context) error {
-	searchQuery := c.QueryParam("search")
-
+// ListMaterials は教材の一覧を1ページずつ返す。Content は含めない
+func (h *MaterialHandler) ListMaterials(c echo.Context) error {
 	UserID, err := getUserIDFromContext(c)
 	if err != nil {
 		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
 	}
 
-	materials, err := h.MaterialService.GetAllMaterials(searchQuery, UserID)
+	query, err := parseMaterialQuery(c)
 	if err != nil {
-		logger.Errorf("Failed to retrieve materials: %v, UserID: %v", err, UserID)
-		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveMaterials)
+		return respondWithError(c, http.StatusBadRequest, err.Error())
 	}
+	query.UserID = UserID
 
-	logger.Infof("Retrieved materials, MaterialCount: %v, UserID: %v", len(materials), UserID)
-	return c.JSON(http.StatusOK, materials)
-}
-
-func (h *MaterialHandler) CheckMaterialStatus(c echo.Context) error {
-	ulid := c.Param("ulid")
-
-	status, err := h.MaterialService.GetMaterialStatus(ulid)
+	page, err := h.MaterialService.ListMaterials(query, c.QueryParam("cursor"))
+	if errors.Is(err, services.ErrInvalidCursor) {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidCursor)
+	}
+	if errors.Is(err, services.ErrInvalidListFilter) {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidListQuery)
+	}
 	if err != nil {
-		logger.Errorf("Failed to get material status: %v, MaterialID: %v", err, ulid)
-		return respondWithError(c, http.StatusInternalServerError, err.Error())
+		logger.Errorf("Failed to retrieve materials: %v, UserID: %v", err, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveMaterials)
 	}
 
-	logger.Infof("Checked material status, MaterialID: %v, Status: %v", ulid, status)
-	return c.JSON(http.StatusOK, map[string]string{"status": status})
+	logger.Infof("Retrieved materials, MaterialCount: %v, UserID: %v", len(page.Items), UserID)
+	return c.JSON(http.StatusOK, page)
 }
 
-func (h *MaterialHandler) processMaterialAsync(ctx context.Context, materialID uint, materialULID string, userID uuid.UUID) {
-	logger.Infof("🚀 Starting async processing for materialID: %v, userID: %v", materialID, userID)
-	h.MaterialService.UpdateMaterialStatus(materialID, "processing")
-
-	// ✅ ステータス変更を SSE で送信
-	h.MaterialService.PublishMaterialUpdate(materialULID, `{"event": "processing"}`)
-
-	// ✅ PhraseList を作成
-	phraseList := models.PhraseList{
-		MaterialID: materialID,
-		Title:      "Default Phrase List",
+// parseMaterialQuery は ?search=&status=&from=&to=&generation=&sort=&order=&limit= を読む。
+// タグとコレクションでの絞り込み (?tag=&collection=) は parseMaterialScope で読む
+func parseMaterialQuery(c echo.Context) (models.MaterialQuery, error) {
+	query := models.MaterialQuery{
+		Search:     c.QueryParam("search"),
+		Generation: c.QueryParam("generation"),
+		Sort:       c.QueryParam("sort"),
 	}
-
-	// ✅ WordList を作成
-	wordList := models.WordList{
-		MaterialID: materialID,
-		Title:      "Default Word List",
+	for _, status := range strings.Split(c.QueryParam("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			query.Statuses = append(query.Statuses, status)
+		}
 	}
 
-	var wg sync.WaitGroup
-	errChan := make(chan error, 4)
+	switch c.QueryParam("order") {
+	case "", "desc":
+		query.Desc = true
+	case "asc":
+	default:
+		return query, errors.New(ErrInvalidListQuery)
+	}
 
-	// ✅ PhraseList を非同期で作成
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := h.PhraseService.CreatePhraseList(&phraseList); err != nil {
-			errChan <- fmt.Errorf("❌ failed to create phrase list: %w", err)
-		}
-	}()
-
-	// ✅ WordList を非同期で作成
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := h.WordService.CreateWordList(&wordList); err != nil {
-			errChan <- fmt.Errorf("❌ failed to create word list: %w", err)
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return query, errors.New(ErrInvalidListQuery)
 		}
-	}()
-
-	// ✅ `GeneratePhrases` を非同期で処理
-	phrasesChan := make(chan []models.Phrase, 1)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		phrases, err := h.PhraseService.GeneratePhrases(ctx, materialID)
+		query.Limit = limit
+	}
+
+	if v := c.QueryParam("from"); v != "" {
+		from, err := time.Parse(usageDateLayout, v)
 		if err != nil {
-			errChan <- fmt.Errorf("⚠️ No phrases generated: %w", err)
-			phrasesChan <- nil
-			return
+			return query, errors.New(ErrInvalidDateRange)
 		}
-		phrasesChan <- phrases
-		if err := h.MaterialService.UpdateHasPendingPhraseStatus(materialULID, false); err != nil {
-			logger.Errorf("❌ Failed to update HasPhraseList: %v", err)
-			errChan <- fmt.Errorf("❌ failed to update HasPhraseList: %w", err)
-		}
-	}()
-
-	// ✅ `GenerateWords` を非同期で処理
-	wordsChan := make(chan []models.Word, 1)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		words, err := h.WordService.GenerateWords(ctx, materialID)
+		query.CreatedFrom = &from
+	}
+	if v := c.QueryParam("to"); v != "" {
+		to, err := time.Parse(usageDateLayout, v)
 		if err != nil {
-			errChan <- fmt.Errorf("❌ failed to generate words: %w", err)
-			wordsChan <- nil
-			return
+			return query, errors.New(ErrInvalidDateRange)
 		}
-		wordsChan <- words
-		if err := h.MaterialService.UpdateHasPendingWordStatus(materialULID, false); err != nil {
-			logger.Errorf("❌ Failed to update HasPendingWordList: %v", err)
-			errChan <- fmt.Errorf("❌ failed to update HasPendingWordList: %w", err)
-		}
-	}()
-
-	wg.Wait()
-	close(errChan)
-	close(phrasesChan)
-	close(wordsChan)
-
-	// ✅ エラーチェック
-	hasError := false
-	for err := range errChan {
-		logger.Errorf("❌ Error occurred: %v, materialID: %v, userID: %v", err, materialID, userID)
-		h.MaterialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "error", "message": "%s"}`, err.Error()))
-		hasError = true
-	}
-
-	// ✅ フレーズの処理 & SSE 送信
-	phrases := <-phrasesChan
-	if phrases != nil && len(phrases) > 0 {
-		for i := range phrases {
-			phrases[i].PhraseListID = phraseList.ID
-		}
-		if err := h.PhraseService.BulkInsertPhrases(phrases); err != nil {
-			hasError = true
-			logger.Errorf("❌ Failed to store phrases: %v", err)
-			h.MaterialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "error", "message": "%s"}`, err.Error()))
-		} else {
-			// ✅ phrases を SSE で送信
-			phrasesJSON, _ := json.Marshal(phrases)
-			h.MaterialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "phrases_stored", "data": %s}`, phrasesJSON))
-		}
-	} else {
-		logger.Warnf("⚠️ No phrases were stored, materialULID: %v", materialULID)
+		to = to.AddDate(0, 0, 1)
+		query.CreatedTo = &to
+	}
+
+	scope, err := parseMaterialScope(c)
+	if err != nil {
+		return query, err
 	}
+	query.MaterialScope = scope
+	return query, nil
+}
 
-	// ✅ ワードの処理 & SSE 送信
-	words := <-wordsChan
-	if words != nil && len(words) > 0 {
-		for i := range words {
-			words[i].WordListID = wordList.ID
+// parseMaterialScope は ?tag=a,b (すべて付いた教材) と ?collection=<id> (配下を含む) を読む
+func parseMaterialScope(c echo.Context) (models.MaterialScope, error) {
+	var scope models.MaterialScope
+	for _, tag := range strings.Split(c.QueryParam("tag"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			scope.Tags = append(scope.Tags, tag)
 		}
-		if err := h.WordService.BulkInsertWords(words); err != nil {
-			hasError = true
-			logger.Errorf("❌ Failed to store words: %v", err)
-			h.MaterialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "error", "message": "%s"}`, err.Error()))
-		} else {
-			// ✅ words を SSE で送信
-			wordsJSON, _ := json.Marshal(words)
-			h.MaterialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "words_stored", "data": %s}`, wordsJSON))
+	}
+	if v := c.QueryParam("collection"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || id == 0 {
+			return scope, errors.New(ErrInvalidListQuery)
 		}
-	} else {
-		logger.Warnf("⚠️ No words were stored, materialULID: %v", materialULID)
+		scope.CollectionID = uint(id)
 	}
+	return scope, nil
+}
+
+func (h *MaterialHandler) CheckMaterialStatus(c echo.Context) error {
+	ulid := c.Param("ulid")
 
-	// ✅ 最終ステータス更新 & SSE 送信
-	if hasError {
-		h.MaterialService.UpdateMaterialStatus(materialID, "failed")
-		h.MaterialService.PublishMaterialUpdate(materialULID, `{"event": "failed"}`)
-	} else {
-		h.MaterialService.UpdateMaterialStatus(materialID, "completed")
-		h.MaterialService.PublishMaterialUpdate(materialULID, `{"event": "completed"}`)
+	status, err := h.MaterialService.GetMaterialStatus(ulid)
+	if err != nil {
+		logger.Errorf("Failed to get material status: %v, MaterialID: %v", err, ulid)
+		return respondWithError(c, http.StatusInternalServerError, err.Error())
 	}
+
+	logger.Infof("Checked material status, MaterialID: %v, Status: %v", ulid, status)
+	return c.JSON(http.StatusOK, map[string]string{"status": status})
 }
+
 func (h *MaterialHandler) StreamMaterialProgressWS(c echo.Context) error {
 	materialULID := c.Param("ulid")
 	tokenString := c.QueryParam("token")
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// RestoreRevisionRequest は版の復元の指定。本文か言語が変わったら regenerate で作り直す
+type RestoreRevisionRequest struct {
+	Regenerate bool `json:"regenerate"`
+}
+
+// ListRevisions は教材の版を新しい順に返す
+func (h *MaterialHandler) ListRevisions(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	ulid := c.Param("ulid")
+
+	revisions, err := h.MaterialService.ListRevisions(ulid, UserID)
+	if err != nil {
+		return respondWithRevisionError(c, ulid, err)
+	}
+	return c.JSON(http.StatusOK, revisions)
+}
+
+// GetRevision は版の本文と1つ前の版からの差分を返す
+func (h *MaterialHandler) GetRevision(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	ulid := c.Param("ulid")
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidRevision)
+	}
+
+	revision, err := h.MaterialService.GetRevision(ulid, UserID, version)
+	if err != nil {
+		return respondWithRevisionError(c, ulid, err)
+	}
+	return c.JSON(http.StatusOK, revision)
+}
+
+// DiffRevisions は ?from=&to= の版の差分を返す。to がなければ今の教材と比べる
+func (h *MaterialHandler) DiffRevisions(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	ulid := c.Param("ulid")
+
+	from, err := strconv.Atoi(c.QueryParam("from"))
+	if err != nil || from <= 0 {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidRevision)
+	}
+	to := 0
+	if v := c.QueryParam("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil || to <= 0 {
+			return respondWithError(c, http.StatusBadRequest, ErrInvalidRevision)
+		}
+	}
+
+	diff, err := h.MaterialService.DiffRevisions(ulid, UserID, from, to)
+	if err != nil {
+		return respondWithRevisionError(c, ulid, err)
+	}
+	return c.JSON(http.StatusOK, diff)
+}
+
+// RestoreRevision は版を新しい版として書き戻す。If-Match があれば版を確かめる
+func (h *MaterialHandler) RestoreRevision(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	ulid := c.Param("ulid")
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidRevision)
+	}
+
+	var req RestoreRevisionRequest
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return respondWithError(c, http.StatusBadRequest, ErrInvalidMaterialData)
+		}
+	}
+	expectedVersion, ok := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if !ok {
+		return respondWithError(c, http.StatusPreconditionFailed, ErrVersionConflict)
+	}
+	// 生成中は作り直せないので、復元する前に断る
+	if req.Regenerate && h.GenerationService.IsGenerating(ulid) {
+		return respondWithError(c, http.StatusConflict, ErrGenerationRunning)
+	}
+
+	material, contentChanged, err := h.MaterialService.RestoreRevision(ulid, UserID, version, expectedVersion)
+	if errors.Is(err, services.ErrVersionConflict) {
+		setMaterialETag(c, material)
+		return respondWithError(c, http.StatusPreconditionFailed, ErrVersionConflict)
+	}
+	if errors.Is(err, services.ErrInvalidMaterialPatch) {
+		return respondWithError(c, http.StatusUnprocessableEntity, ErrInvalidRevision)
+	}
+	if err != nil {
+		return respondWithRevisionError(c, ulid, err)
+	}
+
+	response := MaterialPatchResponse{ContentChanged: contentChanged}
+	if contentChanged && req.Regenerate {
+		if err := h.regenerate(material); err != nil {
+			return respondWithRegenerateError(c, material, err)
+		}
+		response.Regenerating = true
+	}
+	response.MaterialResponse = newMaterialResponse(material)
+
+	logger.Infof("Restored revision, MaterialID: %v, Version: %v, UserID: %v", ulid, version, UserID)
+	setMaterialETag(c, material)
+	return c.JSON(http.StatusOK, response)
+}
+
+func respondWithRevisionError(c echo.Context, ulid string, err error) error {
+	if errors.Is(err, services.ErrRevisionNotFound) {
+		return respondWithError(c, http.StatusNotFound, ErrRevisionNotFound)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return respondWithError(c, http.StatusNotFound, ErrMaterialNotFound)
+	}
+	logger.Errorf("Failed to retrieve revisions: %v, MaterialID: %v", err, ulid)
+	return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveRevisions)
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SearchHandler struct {
+	SearchService services.SearchService
+}
+
+func NewSearchHandler(searchService services.SearchService) *SearchHandler {
+	return &SearchHandler{SearchService: searchService}
+}
+
+// Search は ?q=&type=material,word,phrase&limit= で教材・単語・フレーズを検索し、順位の高い順に返す。
+// ?tag= と ?collection= で教材を絞れる
+func (h *SearchHandler) Search(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	var types []string
+	for _, t := range strings.Split(c.QueryParam("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	limit := 0
+	if v := c.QueryParam("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return respondWithError(c, http.StatusBadRequest, ErrInvalidSearchQuery)
+		}
+	}
+
+	scope, err := parseMaterialScope(c)
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidSearchQuery)
+	}
+
+	hits, err := h.SearchService.Search(UserID, c.QueryParam("q"), types, scope, limit)
+	if errors.Is(err, services.ErrEmptySearchQuery) || errors.Is(err, services.ErrInvalidSearchQuery) {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidSearchQuery)
+	}
+	if err != nil {
+		logger.Errorf("Failed to search: %v, UserID: %v", err, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedSearch)
+	}
+	return c.JSON(http.StatusOK, hits)
+}
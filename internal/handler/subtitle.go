@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// GetSubtitleTimeline は字幕の教材のキューと、単語・フレーズが現れるキューの時刻を返す
+func (h *MaterialHandler) GetSubtitleTimeline(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	ulid := c.Param("ulid")
+
+	timeline, err := h.MaterialService.GetSubtitleTimeline(ulid, UserID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return respondWithError(c, http.StatusNotFound, ErrMaterialNotFound)
+	case errors.Is(err, services.ErrNoSubtitles):
+		return respondWithError(c, http.StatusNotFound, ErrNoSubtitles)
+	case err != nil:
+		logger.Errorf("Failed to get subtitles: %v, ULID: %s", err, ulid)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveSubtitles)
+	}
+	return c.JSON(http.StatusOK, timeline)
+}
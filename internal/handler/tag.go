@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type TagHandler struct {
+	TagService services.TagService
+}
+
+func NewTagHandler(tagService services.TagService) *TagHandler {
+	return &TagHandler{TagService: tagService}
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name"`
+}
+
+type SetMaterialTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// ListTags はユーザーのタグを、付いている教材の数と名前順に返す
+func (h *TagHandler) ListTags(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	tags, err := h.TagService.ListTags(UserID)
+	if err != nil {
+		logger.Errorf("Failed to list tags: %v, UserID: %v", err, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedUpdateTags)
+	}
+	return c.JSON(http.StatusOK, tags)
+}
+
+func (h *TagHandler) RenameTag(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+	var req RenameTagRequest
+	if err := c.Bind(&req); err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidTag)
+	}
+
+	if err := h.TagService.RenameTag(UserID, id, req.Name); err != nil {
+		return respondWithTagError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeleteTag はタグを削除する。付いていた教材は残る
+func (h *TagHandler) DeleteTag(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidID)
+	}
+
+	if err := h.TagService.DeleteTag(UserID, id); err != nil {
+		return respondWithTagError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetMaterialTags は教材のタグを置き換える。まだないタグは作る
+func (h *TagHandler) SetMaterialTags(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	ulid := c.Param("ulid")
+	var req SetMaterialTagsRequest
+	if err := c.Bind(&req); err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidTag)
+	}
+
+	tags, err := h.TagService.SetMaterialTags(ulid, UserID, req.Tags)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return respondWithError(c, http.StatusNotFound, ErrMaterialNotFound)
+	}
+	if err != nil {
+		return respondWithTagError(c, err)
+	}
+	return c.JSON(http.StatusOK, SetMaterialTagsRequest{Tags: tags})
+}
+
+func respondWithTagError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidTag):
+		return respondWithError(c, http.StatusBadRequest, ErrInvalidTag)
+	case errors.Is(err, services.ErrTagNotFound):
+		return respondWithError(c, http.StatusNotFound, ErrTagNotFound)
+	case errors.Is(err, services.ErrTagExists):
+		return respondWithError(c, http.StatusConflict, ErrTagExists)
+	}
+	logger.Errorf("Failed to update tags: %v", err)
+	return respondWithError(c, http.StatusInternalServerError, ErrFailedUpdateTags)
+}
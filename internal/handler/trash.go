@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ListTrash はゴミ箱の教材を返す
+func (h *MaterialHandler) ListTrash(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	materials, err := h.TrashService.ListTrash(UserID)
+	if err != nil {
+		logger.Errorf("Failed to retrieve trash: %v, UserID: %v", err, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveMaterials)
+	}
+	return c.JSON(http.StatusOK, materials)
+}
+
+// RestoreMaterial はゴミ箱の教材を戻す
+func (h *MaterialHandler) RestoreMaterial(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	ulid := c.Param("ulid")
+
+	if err := h.TrashService.RestoreMaterial(ulid, UserID); err != nil {
+		return respondWithTrashError(c, ulid, err)
+	}
+
+	logger.Infof("Restored material, MaterialID: %v, UserID: %v", ulid, UserID)
+	material, err := h.MaterialService.GetMaterialByULID(ulid, UserID)
+	if err != nil {
+		return respondWithError(c, http.StatusNotFound, ErrMaterialNotFound)
+	}
+	setMaterialETag(c, material)
+	return c.JSON(http.StatusOK, newMaterialResponse(material))
+}
+
+// PurgeMaterial はゴミ箱の教材を完全に削除する
+func (h *MaterialHandler) PurgeMaterial(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+	ulid := c.Param("ulid")
+
+	if err := h.TrashService.PurgeMaterial(ulid, UserID); err != nil {
+		return respondWithTrashError(c, ulid, err)
+	}
+
+	logger.Infof("Purged material, MaterialID: %v, UserID: %v", ulid, UserID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// EmptyTrash はゴミ箱の教材をすべて完全に削除する。今日作った教材は残る
+func (h *MaterialHandler) EmptyTrash(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	purged, err := h.TrashService.EmptyTrash(UserID)
+	if err != nil {
+		logger.Errorf("Failed to empty trash: %v, UserID: %v", err, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedPurgeMaterial)
+	}
+
+	logger.Infof("Emptied trash, MaterialCount: %v, UserID: %v", purged, UserID)
+	return c.JSON(http.StatusOK, map[string]int{"purged": purged})
+}
+
+func respondWithTrashError(c echo.Context, ulid string, err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotInTrash):
+		return respondWithError(c, http.StatusNotFound, ErrMaterialNotInTrash)
+	}
+	logger.Errorf("Failed to update trash: %v, MaterialID: %v", err, ulid)
+	return respondWithError(c, http.StatusInternalServerError, ErrFailedPurgeMaterial)
+}
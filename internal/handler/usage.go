@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	usageDateLayout       = "2006-01-02"
+	defaultUsageRangeDays = 30
+)
+
+type UsageHandler struct {
+	UsageService    services.UsageService
+	MaterialService services.MaterialService
+	QuotaService    services.QuotaService
+}
+
+func NewUsageHandler(usageService services.UsageService, materialService services.MaterialService, quotaService services.QuotaService) *UsageHandler {
+	return &UsageHandler{
+		UsageService:    usageService,
+		MaterialService: materialService,
+		QuotaService:    quotaService,
+	}
+}
+
+type AdminUsageResponse struct {
+	From   time.Time
+	To     time.Time
+	Totals *models.UsageTotals
+	Users  []models.UserUsage
+}
+
+// GetMyUsage はログインユーザーの日別使用量を返す
+func (h *UsageHandler) GetMyUsage(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, err.Error())
+	}
+
+	usages, err := h.UsageService.GetDailyUsage(UserID, from, to)
+	if err != nil {
+		logger.Errorf("Failed to retrieve usage: %v, UserID: %v", err, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveUsage)
+	}
+	return c.JSON(http.StatusOK, usages)
+}
+
+// GetMyQuota はプランの上限と残りを返す
+func (h *UsageHandler) GetMyQuota(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	status, err := h.QuotaService.GetQuotaStatus(UserID)
+	if err != nil {
+		logger.Errorf("Failed to retrieve quota: %v, UserID: %v", err, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveQuota)
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// GetMaterialUsage は教材ごとの操作別使用量を返す
+func (h *UsageHandler) GetMaterialUsage(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	material, err := h.MaterialService.GetMaterialByULID(c.Param("ulid"), UserID)
+	if err != nil {
+		return respondWithError(c, http.StatusNotFound, ErrMaterialNotFound)
+	}
+
+	usages, err := h.UsageService.GetMaterialUsage(material.ID)
+	if err != nil {
+		logger.Errorf("Failed to retrieve material usage: %v, MaterialID: %v", err, material.ULID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveUsage)
+	}
+	return c.JSON(http.StatusOK, usages)
+}
+
+// GetAdminUsage は全体とユーザー別の合計を返す
+func (h *UsageHandler) GetAdminUsage(c echo.Context) error {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		return respondWithError(c, http.StatusBadRequest, err.Error())
+	}
+
+	totals, err := h.UsageService.GetUsageTotals(from, to)
+	if err != nil {
+		logger.Errorf("Failed to retrieve usage totals: %v", err)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveUsage)
+	}
+	users, err := h.UsageService.GetUsageTotalsByUser(from, to)
+	if err != nil {
+		logger.Errorf("Failed to retrieve usage by user: %v", err)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveUsage)
+	}
+
+	return c.JSON(http.StatusOK, AdminUsageResponse{
+		From:   from,
+		To:     to,
+		Totals: totals,
+		Users:  users,
+	})
+}
+
+// parseDateRange は ?from=YYYY-MM-DD&to=YYYY-MM-DD を読む。to はその日を含む
+func parseDateRange(c echo.Context) (time.Time, time.Time, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -defaultUsageRangeDays+1)
+	to := today.AddDate(0, 0, 1)
+
+	if v := c.QueryParam("from"); v != "" {
+		t, err := time.Parse(usageDateLayout, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New(ErrInvalidDateRange)
+		}
+		from = t
+	}
+	if v := c.QueryParam("to"); v != "" {
+		t, err := time.Parse(usageDateLayout, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New(ErrInvalidDateRange)
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New(ErrInvalidDateRange)
+	}
+	return from, to, nil
+}
@@ -78,6 +78,33 @@ func (h *UserHandler) LoginUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"token": token})
 }
 
+type NativeLanguageRequest struct {
+	NativeLanguage string `json:"native_language" validate:"required"`
+}
+
+// UpdateNativeLanguage はログインユーザーの母語を変更する。以降に生成する意味の言語になる
+func (h *UserHandler) UpdateNativeLanguage(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	var req NativeLanguageRequest
+	if err := c.Bind(&req); err != nil {
+		return respondWithError(c, http.StatusBadRequest, ErrUnsupportedLanguage)
+	}
+
+	code, err := h.Service.UpdateNativeLanguage(UserID, req.NativeLanguage)
+	if errors.Is(err, services.ErrUnsupportedLanguage) {
+		return respondWithError(c, http.StatusBadRequest, ErrUnsupportedLanguage)
+	}
+	if err != nil {
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedUpdateUser)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"native_language": code})
+}
+
 // 　util
 func validateEmail(email string) error {
 	_, err := mail.ParseAddress(email)
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type WordHandler struct {
+	WordService services.WordService
+}
+
+func NewWordHandler(wordService services.WordService) *WordHandler {
+	return &WordHandler{WordService: wordService}
+}
+
+// GetVocabulary はログインユーザーの全教材の単語を見出し語ごとにまとめて返す
+func (h *WordHandler) GetVocabulary(c echo.Context) error {
+	UserID, err := getUserIDFromContext(c)
+	if err != nil {
+		return respondWithError(c, http.StatusUnauthorized, ErrInvalidUserToken)
+	}
+
+	entries, err := h.WordService.GetVocabulary(UserID)
+	if err != nil {
+		logger.Errorf("Failed to retrieve vocabulary: %v, UserID: %v", err, UserID)
+		return respondWithError(c, http.StatusInternalServerError, ErrFailedRetrieveWords)
+	}
+	return c.JSON(http.StatusOK, entries)
+}
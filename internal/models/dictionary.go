@@ -0,0 +1,25 @@
+package models
+
+import "gorm.io/gorm"
+
+// DictionaryEntry はオフライン辞書 (WordNet, Wiktextract) から取り込んだ見出し語・品詞ごとの項目
+type DictionaryEntry struct {
+	gorm.Model
+	// 見出し語の言語 (ISO 639-1)
+	Language string `gorm:"type:varchar(16);not null;uniqueIndex:idx_dictionary_entries_key"`
+	// 見出し語 (小文字)
+	Lemma  string            `gorm:"type:varchar(255);not null;uniqueIndex:idx_dictionary_entries_key"`
+	Pos    string            `gorm:"type:varchar(32);not null;uniqueIndex:idx_dictionary_entries_key"`
+	Source string            `gorm:"type:varchar(32);not null;uniqueIndex:idx_dictionary_entries_key"`
+	IPA    string            `gorm:"type:varchar(128)"`
+	Senses []DictionarySense `gorm:"type:jsonb;serializer:json"`
+	// 言語コード -> 訳語
+	Translations map[string][]string `gorm:"type:jsonb;serializer:json"`
+}
+
+// DictionarySense は1つの語義
+type DictionarySense struct {
+	Definition string
+	Examples   []string
+	Synonyms   []string
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	FeedEntryCreated   = "created"
+	FeedEntrySkipped   = "skipped"
+	FeedEntryDuplicate = "duplicate"
+)
+
+// FeedSubscription は定期的に取得して新しい記事を教材にする RSS / Atom のフィード。URL はユーザーごとに一意
+type FeedSubscription struct {
+	gorm.Model
+	UserID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_feed_subscriptions_user_url"`
+	URL            string    `gorm:"type:text;not null;uniqueIndex:idx_feed_subscriptions_user_url"`
+	Title          string    `gorm:"type:varchar(255)"`
+	SourceLanguage string    `gorm:"type:varchar(16);not null;default:'en'"`
+	// どれかを含む記事だけを教材にする (大文字小文字を区別しない)。空ならすべて
+	Keywords []string `gorm:"type:jsonb;serializer:json"`
+	// 本文の文字数の上限。0 なら上限なし
+	MaxLength int `gorm:"type:int;not null;default:0"`
+	// 1日 (UTC) に作る教材の数の上限
+	MaxPerDay int `gorm:"type:int;not null;default:5"`
+	// フィードに要約しかないとき、記事のページから本文を取り出す
+	FullText     bool      `gorm:"type:boolean;not null;default:false"`
+	Active       bool      `gorm:"type:boolean;not null;default:true"`
+	NextPollAt   time.Time `gorm:"not null;index"`
+	LastPolledAt *time.Time
+	LastError    string      `gorm:"type:text"`
+	Entries      []FeedEntry `gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE"`
+}
+
+// FeedEntry は処理したフィードの記事。GUID (なければリンク) と本文のハッシュで重複を見分ける
+type FeedEntry struct {
+	ID             uint      `gorm:"primaryKey"`
+	SubscriptionID uint      `gorm:"not null;uniqueIndex:idx_feed_entries_subscription_guid"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;index:idx_feed_entries_user_hash"`
+	GUID           string    `gorm:"type:varchar(512);not null;uniqueIndex:idx_feed_entries_subscription_guid"`
+	// 整えた本文の SHA-256 (16進)
+	ContentHash string `gorm:"type:varchar(64);not null;index:idx_feed_entries_user_hash"`
+	Title       string `gorm:"type:varchar(255)"`
+	Link        string `gorm:"type:text"`
+	Status      string `gorm:"type:varchar(16);not null"`
+	// skipped のときの理由 (keywords, too_long, empty)
+	Reason       string `gorm:"type:varchar(32)"`
+	MaterialID   *uint  `gorm:"index"`
+	MaterialULID string `gorm:"type:varchar(255)"`
+	CreatedAt    time.Time
+}
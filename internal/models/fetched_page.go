@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// FetchedPage は URL から取り込んだページの本文のキャッシュ。URL は正規化したもの
+type FetchedPage struct {
+	ID  uint   `gorm:"primaryKey"`
+	URL string `gorm:"type:text;not null;uniqueIndex"`
+	// リダイレクトの後の URL
+	FinalURL  string `gorm:"type:text"`
+	Title     string `gorm:"type:varchar(255)"`
+	Content   string `gorm:"type:text"`
+	FetchedAt time.Time
+}
@@ -17,7 +17,11 @@ type Material struct {
 	ULID                 string       `gorm:"type:varchar(255);not null;index;unique"`
 	Title                string       `gorm:"type:varchar(255);not null"`
 	Content              string       `gorm:"type:text"`
+	// 教材の言語 (ISO 639-1)。プロンプトと見出し語の規則がこの言語で決まる
+	SourceLanguage       string       `gorm:"type:varchar(16);not null;default:'en'"`
 	Status               string       `gorm:"type:material_status;default:'draft'"`
+	// 更新のたびに1つ増やす。PATCH の楽観ロック (If-Match) に使う
+	Version              int          `gorm:"type:int;not null;default:1"`
 	HasPendingWordList   bool         `gorm:"type:boolean;default:true"`
 	HasPendingPhraseList bool         `gorm:"type:boolean;default:true"`
 	WordsCount   int `gorm:"type:int;default:0"`
@@ -27,6 +31,10 @@ type Material struct {
 	ChatLists            []ChatList   `gorm:"foreignKey:MaterialID;constraint:OnDelete:CASCADE"`
 	Summary 			string       `gorm:"type:text"`
 	WordCount 			int          `gorm:"type:int;default:0"`
+	// URL から取り込んだ教材の元のページ
+	SourceURL            string       `gorm:"type:text"`
+	// 字幕から作った教材のキュー (本文の位置と時刻)
+	SubtitleCues         []SubtitleCue `gorm:"foreignKey:MaterialID;constraint:OnDelete:CASCADE"`
 }
 
 
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 一覧の並び順
+const (
+	MaterialSortCreated   = "created"
+	MaterialSortUpdated   = "updated"
+	MaterialSortTitle     = "title"
+	MaterialSortWordCount = "word_count"
+)
+
+// 一覧の生成状態の絞り込み
+const (
+	GenerationPending = "pending"
+	GenerationReady   = "ready"
+)
+
+// MaterialSummary は一覧用の射影。Content は含めない
+type MaterialSummary struct {
+	ID                   uint
+	ULID                 string
+	Title                string
+	SourceLanguage       string
+	Status               string
+	Summary              string
+	WordCount            int
+	WordsCount           int
+	PhrasesCount         int
+	HasPendingWordList   bool
+	HasPendingPhraseList bool
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Tags                 []string `gorm:"-"`
+}
+
+// TrashedMaterial はゴミ箱の教材。PurgeAt を過ぎると完全に削除される
+type TrashedMaterial struct {
+	MaterialSummary
+	DeletedAt time.Time
+	PurgeAt   time.Time `gorm:"-"`
+}
+
+// MaterialCursor は前のページの最後の行。Value は Sort の列の値
+type MaterialCursor struct {
+	Value string
+	ULID  string
+}
+
+// MaterialQuery は一覧の絞り込み・並び順・ページ。タグでの絞り込みは MaterialScope で行う
+type MaterialQuery struct {
+	MaterialScope
+	UserID      uuid.UUID
+	Search      string
+	Statuses    []string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Generation  string
+	Sort        string
+	Desc        bool
+	Limit       int
+	After       *MaterialCursor
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaterialRevision はタイトル・本文・言語が変わったときの教材の版。
+// Version は変更後の Material.Version。Diff は1つ前の版からの本文の差分 (unified 形式)
+type MaterialRevision struct {
+	gorm.Model
+	MaterialID     uint      `gorm:"not null;uniqueIndex:idx_material_revisions_version"`
+	Version        int       `gorm:"type:int;not null;uniqueIndex:idx_material_revisions_version"`
+	AuthorID       uuid.UUID `gorm:"type:uuid;not null"`
+	Title          string    `gorm:"type:varchar(255);not null"`
+	Content        string    `gorm:"type:text"`
+	SourceLanguage string    `gorm:"type:varchar(16);not null;default:'en'"`
+	Diff           string    `gorm:"type:text"`
+	Additions      int       `gorm:"type:int;default:0"`
+	Deletions      int       `gorm:"type:int;default:0"`
+	// 復元でできた版なら復元元の Version
+	RestoredFrom int `gorm:"type:int;default:0"`
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// CreateMaterialListIndexes は教材一覧のキーセット用の索引を作り、未設定の word_count を埋める。
+// materials テーブルを AutoMigrate した後に呼ぶ
+func CreateMaterialListIndexes(db *gorm.DB) error {
+	statements := []string{
+		`CREATE INDEX IF NOT EXISTS idx_materials_user_created ON materials (user_id, created_at, ul_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_materials_user_updated ON materials (user_id, updated_at, ul_id)`,
+		`UPDATE materials SET word_count = CASE
+			WHEN source_language IN ('ja', 'zh') THEN length(regexp_replace(content, '[^[:alnum:]]', '', 'g'))
+			ELSE coalesce(array_length(regexp_split_to_array(btrim(content), '\s+'), 1), 0)
+		END
+		WHERE word_count = 0 AND content <> ''`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to prepare material list: %w", err)
+		}
+	}
+	return nil
+}
+
+// BackfillMaterialCreations は作成の記録がない教材 (削除済みを含む) の記録を作る。
+// material_creations テーブルを AutoMigrate した後に呼ぶ
+func BackfillMaterialCreations(db *gorm.DB) error {
+	err := db.Exec(`INSERT INTO material_creations (user_id, material_id, created_at)
+		SELECT m.user_id, m.id, m.created_at FROM materials m
+		WHERE NOT EXISTS (SELECT 1 FROM material_creations c WHERE c.material_id = m.id)`).Error
+	if err != nil {
+		return fmt.Errorf("failed to backfill material creations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// MaterialSearchConfig は教材の言語に合う全文検索の設定。検索側も同じ式を使う
+const MaterialSearchConfig = `(CASE source_language WHEN 'en' THEN 'english'::regconfig WHEN 'de' THEN 'german'::regconfig ELSE 'simple'::regconfig END)`
+
+// ItemSearchConfig は単語・フレーズの全文検索の設定。語形の揺れより短い語句の一致を優先する
+const ItemSearchConfig = `'simple'::regconfig`
+
+// TranslationSearchVector は訳語 (translations.meaning) の tsvector。
+// 生成列は他の表を参照できないので、単語・フレーズの検索はこの式で訳語を別に引く。索引と同じ式を使う
+const TranslationSearchVector = `to_tsvector(` + ItemSearchConfig + `, coalesce(meaning, ''))`
+
+// CreateSearchIndexes は materials・words・phrases に検索用の tsvector 生成列と GIN 索引を作り、
+// translations の訳語に式の GIN 索引を作る。
+// 生成列はモデルに持たせない (gorm が書き込もうとするため)。AutoMigrate の後に呼ぶ
+func CreateSearchIndexes(db *gorm.DB) error {
+	columns := []struct {
+		Table      string
+		Expression string
+	}{
+		{"materials", `setweight(to_tsvector(` + MaterialSearchConfig + `, coalesce(title, '')), 'A') ||
+			setweight(to_tsvector(` + MaterialSearchConfig + `, coalesce(summary, '')), 'B') ||
+			setweight(to_tsvector(` + MaterialSearchConfig + `, coalesce(content, '')), 'C')`},
+		{"words", `setweight(to_tsvector(` + ItemSearchConfig + `, coalesce(text, '') || ' ' || coalesce(lemma, '')), 'A') ||
+			setweight(to_tsvector(` + ItemSearchConfig + `, coalesce(meaning, '')), 'B')`},
+		{"phrases", `setweight(to_tsvector(` + ItemSearchConfig + `, coalesce(text, '')), 'A') ||
+			setweight(to_tsvector(` + ItemSearchConfig + `, coalesce(meaning, '')), 'B') ||
+			setweight(to_tsvector(` + ItemSearchConfig + `, coalesce(example, '')), 'C')`},
+	}
+	for _, column := range columns {
+		statements := []string{
+			`ALTER TABLE ` + column.Table + ` ADD COLUMN IF NOT EXISTS search_vector tsvector
+				GENERATED ALWAYS AS (` + column.Expression + `) STORED`,
+			`CREATE INDEX IF NOT EXISTS idx_` + column.Table + `_search_vector ON ` + column.Table + ` USING GIN (search_vector)`,
+		}
+		for _, statement := range statements {
+			if err := db.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to create search index on %s: %w", column.Table, err)
+			}
+		}
+	}
+	statement := `CREATE INDEX IF NOT EXISTS idx_translations_search_vector ON translations USING GIN (` + TranslationSearchVector + `)`
+	if err := db.Exec(statement).Error; err != nil {
+		return fmt.Errorf("failed to create search index on translations: %w", err)
+	}
+	return nil
+}
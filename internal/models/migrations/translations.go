@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// MoveJPMeaningsToTranslations は旧 jp_meaning 列の値を translations (ja) に移して列を削除する。
+// translations テーブルを AutoMigrate した後に呼ぶ。列がなければ何もしない
+func MoveJPMeaningsToTranslations(db *gorm.DB) error {
+	for _, table := range []string{"words", "phrases"} {
+		if !db.Migrator().HasColumn(table, "jp_meaning") {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`
+				INSERT INTO translations (created_at, updated_at, item_id, item_type, language, meaning)
+				SELECT NOW(), NOW(), id, ?, 'ja', jp_meaning FROM `+table+`
+				WHERE jp_meaning IS NOT NULL AND jp_meaning <> ''
+				ON CONFLICT (item_id, item_type, language) DO NOTHING
+			`, table).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(table, "jp_meaning")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to move %s.jp_meaning to translations: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -5,7 +5,7 @@ import (
 )
 
 const (
-	Advance = "advance"
+	Advance = "advanced"
 	Intemediate = "intermediate"
 	Easy = "easy"
 )
@@ -15,10 +15,13 @@ type Phrase struct {
 	Text         string `gorm:"type:varchar(255);not null"`
 	Importance   string `gorm:"type:importance_level;default:'medium'"`
 	Meaning 	string `gorm:"type:text"`
-	JPMeaning 	string `gorm:"type:text"`
+	// 母語での意味。言語ごとに1つ
+	Translations []Translation `gorm:"polymorphic:Item"`
 	Example 	string `gorm:"type:text"`
 	FromText 	bool `gorm:"type:boolean;default:false"`
 	Difficulty 	string `gorm:"type:difficulty_level;default:'easy'"`
+	// 生成に使ったプロンプトの ID (prompts.Prompt.ID、例: generate_words@v3/de)
+	PromptVersion string `gorm:"type:varchar(64)"`
 }
 type PhraseList struct {
 	gorm.Model
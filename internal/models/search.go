@@ -0,0 +1,26 @@
+package models
+
+// 検索結果の種類
+const (
+	SearchTypeMaterial = "material"
+	SearchTypeWord     = "word"
+	SearchTypePhrase   = "phrase"
+)
+
+// 検索ストアが抜粋の一致箇所を囲む区切り。本文に現れない私用領域の文字を使い、
+// サービスが HTML をエスケープしてから <mark> に置き換える
+const (
+	SnippetStart = "\uE000"
+	SnippetStop  = "\uE001"
+)
+
+// SearchHit は全文検索の1件。Snippet は一致箇所を <mark> で囲んだ抜粋で、ほかの部分は HTML エスケープ済み
+type SearchHit struct {
+	Type          string
+	ItemID        uint
+	MaterialULID  string
+	MaterialTitle string
+	Text          string
+	Snippet       string
+	Rank          float64
+}
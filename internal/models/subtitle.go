@@ -0,0 +1,14 @@
+package models
+
+// SubtitleCue は字幕から作った教材の1つのキュー。
+// オフセットは Material.Content の文字 (rune) 単位で [StartOffset, EndOffset)
+type SubtitleCue struct {
+	ID          uint   `gorm:"primaryKey"`
+	MaterialID  uint   `gorm:"not null;index"`
+	Index       int    `gorm:"type:int;not null"`
+	StartMs     int64  `gorm:"type:bigint;not null"`
+	EndMs       int64  `gorm:"type:bigint;not null"`
+	StartOffset int    `gorm:"type:int;not null"`
+	EndOffset   int    `gorm:"type:int;not null"`
+	Text        string `gorm:"type:text"`
+}
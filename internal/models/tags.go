@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tag はユーザーが教材に付けるラベル。名前はユーザーごとに一意
+type Tag struct {
+	gorm.Model
+	UserID       uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_tags_user_name"`
+	Name         string        `gorm:"type:varchar(64);not null;uniqueIndex:idx_tags_user_name"`
+	MaterialTags []MaterialTag `gorm:"foreignKey:TagID;constraint:OnDelete:CASCADE"`
+}
+
+// MaterialTag は教材とタグの多対多
+type MaterialTag struct {
+	MaterialID uint `gorm:"primaryKey;autoIncrement:false"`
+	TagID      uint `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt  time.Time
+}
+
+// TagCount はタグと付いている教材の数
+type TagCount struct {
+	ID            uint
+	Name          string
+	MaterialCount int
+}
+
+// Collection は教材をまとめるフォルダ。ParentID で入れ子にでき、兄弟の中では Position 順
+type Collection struct {
+	gorm.Model
+	UserID   uuid.UUID            `gorm:"type:uuid;not null;index"`
+	ParentID *uint                `gorm:"index"`
+	Name     string               `gorm:"type:varchar(255);not null"`
+	Position int                  `gorm:"type:int;not null;default:0"`
+	Children []Collection         `gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE"`
+	Items    []CollectionMaterial `gorm:"foreignKey:CollectionID;constraint:OnDelete:CASCADE"`
+}
+
+// CollectionMaterial はコレクションの中の教材。コレクションの中では Position 順
+type CollectionMaterial struct {
+	CollectionID uint `gorm:"primaryKey;autoIncrement:false"`
+	MaterialID   uint `gorm:"primaryKey;autoIncrement:false;index"`
+	Position     int  `gorm:"type:int;not null;default:0"`
+	CreatedAt    time.Time
+}
+
+// MaterialScope は一覧・検索・書き出しに共通の絞り込み。
+// Tags はすべてのタグが付いた教材、CollectionID はそのコレクションと配下のコレクションの教材
+type MaterialScope struct {
+	Tags         []string
+	CollectionID uint
+}
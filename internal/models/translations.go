@@ -0,0 +1,19 @@
+package models
+
+import "gorm.io/gorm"
+
+// Translation は単語・フレーズの学習者の母語での意味。(ItemType, ItemID, Language) ごとに1つ
+type Translation struct {
+	gorm.Model
+	ItemID   uint   `gorm:"not null;uniqueIndex:idx_translations_item_language"`
+	ItemType string `gorm:"type:varchar(16);not null;uniqueIndex:idx_translations_item_language"`
+	// 言語コード (ISO 639-1)
+	Language string `gorm:"type:varchar(16);not null;uniqueIndex:idx_translations_item_language"`
+	Meaning  string `gorm:"type:text"`
+}
+
+// Translation.ItemType の値
+const (
+	TranslationItemWord   = "words"
+	TranslationItemPhrase = "phrases"
+)
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LLMUsage は1回のLLM呼び出しの記録
+type LLMUsage struct {
+	gorm.Model
+	UserID         uuid.UUID `gorm:"type:uuid;index"`
+	MaterialID     uint      `gorm:"index"`
+	Operation      string    `gorm:"type:varchar(64)"`
+	ModelName      string    `gorm:"type:varchar(64)"`
+	PromptTokens   int       `gorm:"type:int;default:0"`
+	ResponseTokens int       `gorm:"type:int;default:0"`
+	TotalTokens    int       `gorm:"type:int;default:0"`
+	LatencyMs      int64     `gorm:"type:bigint;default:0"`
+	Outcome        string    `gorm:"type:varchar(32)"`
+	ErrorMessage   string    `gorm:"type:text"`
+	CostUSD        float64   `gorm:"type:double precision;default:0"`
+}
+
+// MaterialCreation は教材を作った記録。教材を完全に削除しても残し、1日の作成数を数えるのに使う
+type MaterialCreation struct {
+	ID         uint      `gorm:"primarykey"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index:idx_material_creations_user_created"`
+	MaterialID uint
+	CreatedAt  time.Time `gorm:"index:idx_material_creations_user_created"`
+}
+
+// UsageTotals は集計値
+type UsageTotals struct {
+	Calls          int64
+	FailedCalls    int64
+	PromptTokens   int64
+	ResponseTokens int64
+	TotalTokens    int64
+	CostUSD        float64
+}
+
+type DailyUsage struct {
+	Day time.Time
+	UsageTotals
+}
+
+type UserUsage struct {
+	UserID uuid.UUID
+	UsageTotals
+}
+
+type OperationUsage struct {
+	Operation string
+	UsageTotals
+}
@@ -17,6 +17,10 @@ type User struct {
 	Materials []Material   `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 	Email     string       `gorm:"type:varchar(255);unique"`
 	Password  string       `gorm:"type:varchar(255)"`
+	IsAdmin   bool         `gorm:"type:boolean;default:false"`
+	Plan      string       `gorm:"type:varchar(32);default:'free'"`
+	// 意味を生成する言語 (ISO 639-1)
+	NativeLanguage string `gorm:"type:varchar(16);default:'ja'"`
 }
 
 type Progress struct {
@@ -1,15 +1,46 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Word struct {
 	gorm.Model
 	WordListID uint   `gorm:"not null;index"`
 	Text       string `gorm:"type:varchar(255);not null"`
-	Importance string `gorm:"type:importance_level;default:'medium'"`
-	Level      string `gorm:"type:word_level;default:'beginner'"`
-	Meaning    string `gorm:"type:varchar(255)"`
-	JPMeaning  string `gorm:"type:varchar(255)"`
+	// 見出し語 (原形、小文字)。1つの教材で同じ見出し語は1つだけ
+	Lemma string `gorm:"type:varchar(255);index"`
+	// 本文に現れた語形 (小文字)
+	Forms      []string `gorm:"type:jsonb;serializer:json"`
+	Pos        string   `gorm:"type:varchar(32)"`
+	Importance string   `gorm:"type:importance_level;default:'medium'"`
+	Level      string   `gorm:"type:word_level;default:'easy'"`
+	Meaning    string   `gorm:"type:varchar(255)"`
+	// 母語での意味。言語ごとに1つ
+	Translations []Translation `gorm:"polymorphic:Item"`
+	// 辞書から取れたときだけ入る
+	IPA      string   `gorm:"type:varchar(128)"`
+	Synonyms []string `gorm:"type:jsonb;serializer:json"`
+	Example  string   `gorm:"type:text"`
+	// フィールド名 (meaning, translation, ipa, synonyms, example) -> 出典 (wordnet, wiktextract, llm)
+	Sources map[string]string `gorm:"type:jsonb;serializer:json"`
+	// 生成に使ったプロンプトの ID (prompts.Prompt.ID、例: generate_words@v3/de)
+	PromptVersion string           `gorm:"type:varchar(64)"`
+	Occurrences   []WordOccurrence `gorm:"foreignKey:WordID;constraint:OnDelete:CASCADE"`
+}
+
+// WordOccurrence は本文中で単語 (活用形を含む) が現れた位置。
+// オフセットは Material.Content の文字 (rune) 単位で [StartOffset, EndOffset)
+type WordOccurrence struct {
+	gorm.Model
+	WordID        uint   `gorm:"not null;index"`
+	MaterialID    uint   `gorm:"not null;index"`
+	StartOffset   int    `gorm:"type:int;not null"`
+	EndOffset     int    `gorm:"type:int;not null"`
+	SentenceIndex int    `gorm:"type:int;not null"`
+	Surface       string `gorm:"type:varchar(255)"`
 }
 
 type WordList struct {
@@ -19,3 +50,12 @@ type WordList struct {
 	Words          []Word `gorm:"foreignKey:WordListID;constraint:OnDelete:CASCADE"`
 	GenerateStatus string `gorm:"type:word_list_status;default:'pending'"`
 }
+
+// VocabularyEntry はユーザーの全教材の単語を見出し語でまとめたもの
+type VocabularyEntry struct {
+	Lemma         string
+	MaterialCount int
+	Occurrences   int
+	FirstSeenAt   time.Time
+	LastSeenAt    time.Time
+}
@@ -0,0 +1,92 @@
+// Package dictionary はオフライン辞書のダンプ (Wiktextract の JSONL、WordNet の data ファイル) を読む。
+// 読んだ項目は見出し語・品詞ごとにまとめて DB に取り込む
+package dictionary
+
+import (
+	"slices"
+	"strings"
+)
+
+// 取り込み元の名前。単語の各フィールドの出典として記録する
+const (
+	SourceWiktextract = "wiktextract"
+	SourceWordNet     = "wordnet"
+)
+
+// Entry は1つの見出し語・品詞の項目
+type Entry struct {
+	// 見出し語の言語 (ISO 639-1)
+	Language string
+	// 見出し語 (小文字)
+	Lemma string
+	// noun, verb, adjective, adverb など
+	Pos    string
+	IPA    string
+	Senses []Sense
+	// 言語コード -> 訳語
+	Translations map[string][]string
+	Source       string
+}
+
+// Sense は1つの語義
+type Sense struct {
+	Definition string
+	Examples   []string
+	Synonyms   []string
+}
+
+// Key は取り込み時に同じ項目をまとめるためのキー
+func (e *Entry) Key() string {
+	return e.Language + "\x00" + e.Lemma + "\x00" + e.Pos + "\x00" + e.Source
+}
+
+// Merge は同じ見出し語・品詞の別の項目 (別の語源や synset) を e にまとめる
+func (e *Entry) Merge(other Entry) {
+	if e.IPA == "" {
+		e.IPA = other.IPA
+	}
+	for _, sense := range other.Senses {
+		if !slices.ContainsFunc(e.Senses, func(s Sense) bool { return s.Definition == sense.Definition }) {
+			e.Senses = append(e.Senses, sense)
+		}
+	}
+	for lang, words := range other.Translations {
+		if e.Translations == nil {
+			e.Translations = make(map[string][]string)
+		}
+		for _, word := range words {
+			if !slices.Contains(e.Translations[lang], word) {
+				e.Translations[lang] = append(e.Translations[lang], word)
+			}
+		}
+	}
+}
+
+// NormalizePos は辞書ごとの品詞名 (n, adj, s など) を抽出時の品詞名にそろえる
+func NormalizePos(pos string) string {
+	switch p := strings.ToLower(strings.TrimSpace(pos)); p {
+	case "n", "name":
+		return "noun"
+	case "v":
+		return "verb"
+	case "a", "s", "adj":
+		return "adjective"
+	case "r", "adv":
+		return "adverb"
+	case "prep":
+		return "preposition"
+	case "conj":
+		return "conjunction"
+	case "pron":
+		return "pronoun"
+	case "det":
+		return "determiner"
+	default:
+		return p
+	}
+}
+
+// NormalizeLemma は照合に使う見出し語 (小文字、空白1つ区切り) にする
+func NormalizeLemma(word string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(word, "_", " "))), " ")
+}
@@ -0,0 +1,67 @@
+package dictionary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadWiktextract(t *testing.T) {
+	dump := `{"word": "resolve", "pos": "verb", "lang_code": "en", "sounds": [{"ipa": "/ɹɪˈzɒlv/"}], "senses": [{"glosses": ["To find a solution to."], "examples": [{"text": "to resolve a problem"}], "synonyms": [{"word": "solve"}]}, {"glosses": ["To make a firm decision."]}], "translations": [{"lang_code": "ja", "word": "解決する"}, {"code": "de", "word": "lösen"}]}
+{"word": "resolved", "pos": "verb", "lang_code": "en", "senses": [{"glosses": ["simple past of resolve"], "tags": ["form-of"]}]}
+{"word": "Vertrag", "pos": "noun", "lang_code": "de", "senses": [{"glosses": ["contract"]}]}
+`
+	var entries []Entry
+	err := ReadWiktextract(strings.NewReader(dump), "en", func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadWiktextract failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the English entry with senses, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Lemma != "resolve" || e.Pos != "verb" || e.IPA != "/ɹɪˈzɒlv/" || e.Source != SourceWiktextract {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if len(e.Senses) != 2 || e.Senses[0].Examples[0] != "to resolve a problem" || e.Senses[0].Synonyms[0] != "solve" {
+		t.Errorf("unexpected senses: %+v", e.Senses)
+	}
+	if e.Translations["ja"][0] != "解決する" || e.Translations["de"][0] != "lösen" {
+		t.Errorf("unexpected translations: %v", e.Translations)
+	}
+}
+
+func TestReadWordNet(t *testing.T) {
+	data := `  1 This software and database is being provided to you, the LICENSEE, by
+00674607 31 v 02 resolve 0 settle 3 002 @ 00630380 v 0000 + 05785508 n 0202 01 + 08 00 | bring to an end; settle conclusively; "The dispute was resolved"
+01864234 00 s 02 determined(a) 0 resolved 0 001 & 01863860 a 0000 | characterized by determination
+`
+	entries := make(map[string]*Entry)
+	err := ReadWordNet(strings.NewReader(data), func(e Entry) error {
+		if existing, ok := entries[e.Key()]; ok {
+			existing.Merge(e)
+			return nil
+		}
+		entries[e.Key()] = &e
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadWordNet failed: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected an entry per synset member, got %d", len(entries))
+	}
+	resolve := entries[(&Entry{Language: "en", Lemma: "resolve", Pos: "verb", Source: SourceWordNet}).Key()]
+	if resolve == nil {
+		t.Fatalf("resolve not found: %v", entries)
+	}
+	sense := resolve.Senses[0]
+	if sense.Definition != "bring to an end; settle conclusively" || sense.Examples[0] != "The dispute was resolved" || sense.Synonyms[0] != "settle" {
+		t.Errorf("unexpected sense: %+v", sense)
+	}
+	if entries[(&Entry{Language: "en", Lemma: "determined", Pos: "adjective", Source: SourceWordNet}).Key()] == nil {
+		t.Error("expected the adjective marker to be stripped")
+	}
+}
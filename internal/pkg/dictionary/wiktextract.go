@@ -0,0 +1,130 @@
+package dictionary
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// wiktextract の1行 (https://github.com/tatuylonen/wiktextract) のうち使うフィールド
+type wiktextractLine struct {
+	Word     string `json:"word"`
+	Pos      string `json:"pos"`
+	LangCode string `json:"lang_code"`
+	Senses   []struct {
+		Glosses  []string `json:"glosses"`
+		Tags     []string `json:"tags"`
+		Examples []struct {
+			Text string `json:"text"`
+		} `json:"examples"`
+		Synonyms []wiktextractWord `json:"synonyms"`
+	} `json:"senses"`
+	Sounds []struct {
+		IPA string `json:"ipa"`
+	} `json:"sounds"`
+	Synonyms     []wiktextractWord `json:"synonyms"`
+	Translations []struct {
+		LangCode string `json:"lang_code"`
+		// 古いダンプは code
+		Code string `json:"code"`
+		Word string `json:"word"`
+	} `json:"translations"`
+}
+
+type wiktextractWord struct {
+	Word string `json:"word"`
+}
+
+// ReadWiktextract は Wiktextract の JSONL を1行ずつ読み、lang の項目を fn に渡す。
+// lang が空ならすべての言語。活用形だけの語義 (form-of) は飛ばす
+func ReadWiktextract(r io.Reader, lang string, fn func(Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	// 1行が長い項目がある
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := scanner.Bytes()
+		if len(strings.TrimSpace(string(raw))) == 0 {
+			continue
+		}
+		var w wiktextractLine
+		if err := json.Unmarshal(raw, &w); err != nil {
+			return fmt.Errorf("wiktextract line %d: %w", line, err)
+		}
+		if lang != "" && w.LangCode != lang {
+			continue
+		}
+		entry, ok := w.entry()
+		if !ok {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (w wiktextractLine) entry() (Entry, bool) {
+	entry := Entry{
+		Language: w.LangCode,
+		Lemma:    NormalizeLemma(w.Word),
+		Pos:      NormalizePos(w.Pos),
+		Source:   SourceWiktextract,
+	}
+	if entry.Lemma == "" {
+		return entry, false
+	}
+	for _, sound := range w.Sounds {
+		if sound.IPA != "" {
+			entry.IPA = sound.IPA
+			break
+		}
+	}
+
+	var entrySynonyms []string
+	for _, s := range w.Synonyms {
+		entrySynonyms = append(entrySynonyms, s.Word)
+	}
+	for _, s := range w.Senses {
+		if len(s.Glosses) == 0 || slices.Contains(s.Tags, "form-of") {
+			continue
+		}
+		sense := Sense{Definition: s.Glosses[len(s.Glosses)-1]}
+		for _, example := range s.Examples {
+			sense.Examples = append(sense.Examples, example.Text)
+		}
+		for _, synonym := range s.Synonyms {
+			sense.Synonyms = append(sense.Synonyms, synonym.Word)
+		}
+		// 語義ごとの類義語がなければ項目全体の類義語を使う
+		if len(sense.Synonyms) == 0 {
+			sense.Synonyms = entrySynonyms
+		}
+		entry.Senses = append(entry.Senses, sense)
+	}
+	if len(entry.Senses) == 0 {
+		return entry, false
+	}
+
+	for _, t := range w.Translations {
+		code := t.LangCode
+		if code == "" {
+			code = t.Code
+		}
+		if code == "" || t.Word == "" {
+			continue
+		}
+		if entry.Translations == nil {
+			entry.Translations = make(map[string][]string)
+		}
+		if !slices.Contains(entry.Translations[code], t.Word) {
+			entry.Translations[code] = append(entry.Translations[code], t.Word)
+		}
+	}
+	return entry, true
+}
@@ -0,0 +1,101 @@
+package dictionary
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadWordNet は WordNet の data ファイル (data.noun, data.verb, data.adj, data.adv) を読み、
+// synset の各語を1語義の英語の項目として fn に渡す。同じ語の項目は呼び出し側で Merge する
+func ReadWordNet(r io.Reader, fn func(Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		// 先頭のライセンス表記は空白で始まる
+		if text == "" || text[0] == ' ' {
+			continue
+		}
+		entries, err := parseSynset(text)
+		if err != nil {
+			return fmt.Errorf("wordnet line %d: %w", line, err)
+		}
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// parseSynset は "offset lex_filenum ss_type w_cnt word lex_id ... | gloss" の1行を読む
+func parseSynset(text string) ([]Entry, error) {
+	head, gloss, _ := strings.Cut(text, " | ")
+	fields := strings.Fields(head)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("too few fields")
+	}
+	count, err := strconv.ParseInt(fields[3], 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid word count %q: %w", fields[3], err)
+	}
+	if len(fields) < 4+2*int(count) {
+		return nil, fmt.Errorf("expected %d words", count)
+	}
+
+	var words []string
+	for i := 0; i < int(count); i++ {
+		words = append(words, NormalizeLemma(stripMarker(fields[4+2*i])))
+	}
+	definition, examples := parseGloss(gloss)
+	pos := NormalizePos(fields[2])
+
+	entries := make([]Entry, 0, len(words))
+	for _, word := range words {
+		var synonyms []string
+		for _, other := range words {
+			if other != word {
+				synonyms = append(synonyms, other)
+			}
+		}
+		entries = append(entries, Entry{
+			Language: "en",
+			Lemma:    word,
+			Pos:      pos,
+			Senses:   []Sense{{Definition: definition, Examples: examples, Synonyms: synonyms}},
+			Source:   SourceWordNet,
+		})
+	}
+	return entries, nil
+}
+
+// stripMarker は形容詞の位置の印 (a), (p), (ip) を外す
+func stripMarker(word string) string {
+	if i := strings.IndexByte(word, '('); i > 0 && strings.HasSuffix(word, ")") {
+		return word[:i]
+	}
+	return word
+}
+
+// parseGloss は `definition; "example"; "example"` を定義と例文に分ける
+func parseGloss(gloss string) (string, []string) {
+	var definition []string
+	var examples []string
+	for _, part := range strings.Split(strings.TrimSpace(gloss), "; ") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, `"`) {
+			examples = append(examples, strings.Trim(part, `" `))
+			continue
+		}
+		if part != "" {
+			definition = append(definition, part)
+		}
+	}
+	return strings.Join(definition, "; "), examples
+}
@@ -0,0 +1,235 @@
+package extract
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// class や id から本文らしさを判断する (Readability と同じ考え方)
+var (
+	articlePositive = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|page|post|text|blog|story`)
+	articleNegative = regexp.MustCompile(`(?i)comment|combx|contact|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|social|tags|tool|widget|advert|\bads?\b|cookie|banner|breadcrumb|menu|newsletter|subscribe|popup|modal|nav`)
+	// タイトルの後ろのサイト名 ("記事 | サイト")
+	titleSeparator = regexp.MustCompile(`\s+[|\-–—»:]\s+`)
+)
+
+// 本文の候補から外す要素
+var boilerplateElements = map[atom.Atom]bool{
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+}
+
+// 段落として点数を付ける最小の文字数
+const minParagraphLength = 25
+
+// Article はニュースやブログのページから本文とタイトルを取り出す。
+// 段落の点数を親要素に集め、いちばん点数の高い要素を本文にする
+func Article(r io.Reader) (*Document, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	title := articleTitle(root)
+	removeBoilerplate(root)
+
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre || n.DataAtom == atom.Td || n.DataAtom == atom.Blockquote) {
+			text := cleanLine(nodeText(n))
+			if utf8.RuneCountInString(text) >= minParagraphLength {
+				score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "、")) + min(float64(utf8.RuneCountInString(text))/100, 3)
+				for i, ancestor := 0, n.Parent; i < 3 && ancestor != nil && ancestor.Type == html.ElementNode; i, ancestor = i+1, ancestor.Parent {
+					if _, ok := scores[ancestor]; !ok {
+						scores[ancestor] = initialScore(ancestor)
+						candidates = append(candidates, ancestor)
+					}
+					// 親は全部、祖父は半分、その上は 1/3
+					scores[ancestor] += score / float64(i+1)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, candidate := range candidates {
+		score := scores[candidate] * (1 - linkDensity(candidate))
+		if best == nil || score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+
+	doc := &Document{Title: title}
+	if best == nil {
+		// 段落のないページは全体を使う
+		if body := findElement(root, atom.Body); body != nil {
+			doc.Text = joinParagraphs(HTMLParagraphs(body))
+		}
+		return doc, nil
+	}
+	doc.Text = joinParagraphs(articleParagraphs(best, scores, bestScore))
+	return doc, nil
+}
+
+// articleParagraphs は本文の要素と、点数の近い兄弟要素 (本文が分かれているページ) の段落
+func articleParagraphs(best *html.Node, scores map[*html.Node]float64, bestScore float64) []string {
+	if best.Parent == nil {
+		return HTMLParagraphs(best)
+	}
+	threshold := max(10, bestScore*0.2)
+	var paragraphs []string
+	for sibling := best.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+		include := sibling == best
+		if !include {
+			if score, ok := scores[sibling]; ok && score*(1-linkDensity(sibling)) >= threshold {
+				include = true
+			} else if sibling.DataAtom == atom.P {
+				text := cleanLine(nodeText(sibling))
+				include = utf8.RuneCountInString(text) > 80 && linkDensity(sibling) < 0.25
+			}
+		}
+		if include {
+			paragraphs = append(paragraphs, HTMLParagraphs(sibling)...)
+		}
+	}
+	return paragraphs
+}
+
+func initialScore(n *html.Node) float64 {
+	score := 0.0
+	switch n.DataAtom {
+	case atom.Article:
+		score += 10
+	case atom.Div, atom.Main, atom.Section:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+	return score + classWeight(n)
+}
+
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, attr := range n.Attr {
+		if attr.Key != "class" && attr.Key != "id" {
+			continue
+		}
+		if articleNegative.MatchString(attr.Val) {
+			weight -= 25
+		}
+		if articlePositive.MatchString(attr.Val) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity はリンクの文字の割合
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(cleanLine(nodeText(n)))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			linked += utf8.RuneCountInString(cleanLine(nodeText(n)))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(linked) / float64(total)
+}
+
+// removeBoilerplate はナビゲーション・広告・コメント欄などを木から外す
+func removeBoilerplate(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || c.Type == html.ElementNode && isBoilerplate(c) {
+			n.RemoveChild(c)
+		} else {
+			removeBoilerplate(c)
+		}
+		c = next
+	}
+}
+
+func isBoilerplate(n *html.Node) bool {
+	if skippedElements[n.DataAtom] && n.DataAtom != atom.Head || boilerplateElements[n.DataAtom] || hiddenElement(n) {
+		return true
+	}
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		return false
+	}
+	for _, attr := range n.Attr {
+		if attr.Key == "role" && (attr.Val == "navigation" || attr.Val == "complementary" || attr.Val == "banner" || attr.Val == "contentinfo") {
+			return true
+		}
+		if (attr.Key == "class" || attr.Key == "id") && articleNegative.MatchString(attr.Val) && !articlePositive.MatchString(attr.Val) {
+			return true
+		}
+	}
+	return false
+}
+
+// articleTitle は og:title、<title> (サイト名を除く)、最初の <h1> の順に探す
+func articleTitle(root *html.Node) string {
+	var ogTitle string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if ogTitle != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Meta {
+			var property, content string
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "property", "name":
+					property = attr.Val
+				case "content":
+					content = attr.Val
+				}
+			}
+			if property == "og:title" || property == "twitter:title" {
+				ogTitle = cleanLine(content)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	if ogTitle != "" {
+		return ogTitle
+	}
+
+	title := htmlTitle(root)
+	if loc := titleSeparator.FindAllStringIndex(title, -1); len(loc) > 0 {
+		// 最後の区切りの前が十分に長ければサイト名を外す
+		if head := title[:loc[len(loc)-1][0]]; len(strings.Fields(head)) >= 3 || utf8.RuneCountInString(head) >= 10 {
+			title = head
+		}
+	}
+	return title
+}
@@ -0,0 +1,151 @@
+// Package extract はアップロードされたファイル (PDF, EPUB, DOCX, Markdown, HTML) から
+// 段落を保ったテキストとタイトルを取り出す
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// ファイルの形式
+const (
+	FormatPDF      = "pdf"
+	FormatEPUB     = "epub"
+	FormatDOCX     = "docx"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatText     = "text"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+	// 画像だけの PDF など、文字が取れなかった
+	ErrNoText  = errors.New("no text found in file")
+	ErrCorrupt = errors.New("file is corrupt or encrypted")
+	// 展開後のサイズが上限を超えた (zip bomb など)
+	ErrTooLarge = errors.New("file expands beyond the size limit")
+)
+
+// 展開・デコードしたデータの合計の上限
+const maxExpandedBytes = 64 << 20
+
+// Document は取り出したテキスト。段落は空行で区切る
+type Document struct {
+	Title  string
+	Text   string
+	Format string
+}
+
+// Detect は拡張子と中身からファイルの形式を決める。分からなければ空文字列
+func Detect(filename string, data []byte) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".pdf":
+		return FormatPDF
+	case ".epub":
+		return FormatEPUB
+	case ".docx":
+		return FormatDOCX
+	case ".md", ".markdown":
+		return FormatMarkdown
+	case ".html", ".htm", ".xhtml":
+		return FormatHTML
+	case ".txt":
+		return FormatText
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return FormatPDF
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		// EPUB は先頭に mimetype が入っている
+		if bytes.Contains(data[:min(len(data), 100)], []byte("application/epub+zip")) {
+			return FormatEPUB
+		}
+		return FormatDOCX
+	}
+	switch contentType := http.DetectContentType(data); {
+	case strings.HasPrefix(contentType, "text/html"):
+		return FormatHTML
+	case strings.HasPrefix(contentType, "text/plain"):
+		return FormatText
+	}
+	return ""
+}
+
+// Extract は data を filename の形式として読み、テキストを取り出す
+func Extract(filename string, data []byte) (*Document, error) {
+	format := Detect(filename, data)
+	var doc *Document
+	var err error
+	switch format {
+	case FormatPDF:
+		doc, err = extractPDF(data)
+	case FormatEPUB:
+		doc, err = extractEPUB(data)
+	case FormatDOCX:
+		doc, err = extractDOCX(data)
+	case FormatMarkdown:
+		doc = extractMarkdown(string(data))
+	case FormatHTML:
+		doc, err = HTML(bytes.NewReader(data))
+	case FormatText:
+		doc = &Document{Text: joinParagraphs(splitParagraphs(string(data)))}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(doc.Text) == "" {
+		return nil, ErrNoText
+	}
+	doc.Format = format
+	doc.Title = cleanLine(doc.Title)
+	return doc, nil
+}
+
+// cleanLine は連続した空白と制御文字を1つの空白にまとめる
+func cleanLine(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || r == '�'
+	}), " ")
+}
+
+// splitParagraphs は空行で区切られた塊を段落にし、段落の中の改行を空白にする
+func splitParagraphs(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var paragraphs []string
+	for _, block := range strings.Split(text, "\n\n") {
+		if p := cleanLine(block); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return paragraphs
+}
+
+// joinParagraphs は空の段落を除いて空行でつなぐ
+func joinParagraphs(paragraphs []string) string {
+	kept := paragraphs[:0:0]
+	for _, p := range paragraphs {
+		if p = cleanLine(p); p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, "\n\n")
+}
+
+// readLimited は r を上限まで読む。超えたら ErrTooLarge
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,212 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExtractHTML(t *testing.T) {
+	page := `<html><head><title>Business  email</title><style>p{}</style></head>
+<body><nav>Home | About</nav><h1>Writing emails</h1>
+<p>Dear team,<br>thanks for the <b>update</b>.</p><script>var x = 1;</script>
+<ul><li>First point</li><li>Second point</li></ul><div hidden>secret</div></body></html>`
+	doc, err := Extract("mail.html", []byte(page))
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	want := "Writing emails\n\nDear team, thanks for the update.\n\nFirst point\n\nSecond point"
+	if doc.Title != "Business email" || doc.Text != want || doc.Format != FormatHTML {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
+
+func TestExtractMarkdown(t *testing.T) {
+	text := "---\ntitle: \"Tech news\"\n---\n# Heading\n\nSome *emphasis* and a [link](https://example.com)\ncontinued line.\n\n- item `one`\n- item two\n\n```go\nfmt.Println()\n```\n"
+	doc, err := Extract("news.md", []byte(text))
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	want := "Heading\n\nSome emphasis and a link continued line.\n\nitem one\n\nitem two\n\nfmt.Println()"
+	if doc.Title != "Tech news" || doc.Text != want {
+		t.Errorf("unexpected document: %q / %q", doc.Title, doc.Text)
+	}
+}
+
+func buildZip(t *testing.T, files [][2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f[1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractDOCX(t *testing.T) {
+	data := buildZip(t, [][2]string{
+		{"[Content_Types].xml", `<Types/>`},
+		{"word/document.xml", `<w:document xmlns:w="w"><w:body>
+<w:p><w:r><w:t>Quarterly</w:t></w:r><w:r><w:t xml:space="preserve"> report</w:t></w:r></w:p>
+<w:p></w:p>
+<w:p><w:r><w:t>Sales</w:t><w:tab/><w:t>grew.</w:t></w:r></w:p></w:body></w:document>`},
+		{"docProps/core.xml", `<cp:coreProperties xmlns:cp="cp" xmlns:dc="dc"><dc:title>Report Q3</dc:title></cp:coreProperties>`},
+	})
+	doc, err := Extract("report.docx", data)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if doc.Title != "Report Q3" || doc.Text != "Quarterly report\n\nSales grew." {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
+
+func TestExtractEPUB(t *testing.T) {
+	data := buildZip(t, [][2]string{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`},
+		{"OEBPS/content.opf", `<package><metadata xmlns:dc="dc"><dc:title>A Short Book</dc:title></metadata>
+<manifest><item id="c2" href="ch%202.xhtml" media-type="application/xhtml+xml"/><item id="c1" href="ch1.xhtml" media-type="application/xhtml+xml"/><item id="css" href="s.css" media-type="text/css"/></manifest>
+<spine><itemref idref="c1"/><itemref idref="c2"/></spine></package>`},
+		{"OEBPS/ch1.xhtml", `<html><body><h1>One</h1><p>It began.</p></body></html>`},
+		{"OEBPS/ch 2.xhtml", `<html><body><h1>Two</h1><p>It ended.</p></body></html>`},
+	})
+	if format := Detect("book.bin", data); format != FormatEPUB {
+		t.Errorf("Detect = %q, expected epub", format)
+	}
+	doc, err := Extract("book.epub", data)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if doc.Title != "A Short Book" || doc.Text != "One\n\nIt began.\n\nTwo\n\nIt ended." {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
+
+// buildPDF は objects を 1 0 obj から順に並べた PDF を作る (xref 表は省く)
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R /Info 2 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func stream(dict, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func flateStream(data string) string {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte(data))
+	zw.Close()
+	return stream("/Filter /FlateDecode", buf.String())
+}
+
+func TestExtractPDF(t *testing.T) {
+	content := `BT /F1 12 Tf 72 720 Td (The quick brown fox jumps over the lazy) Tj
+0 -14 Td [(dog and keeps run-)] TJ 0 -14 Td (ning.) Tj
+0 -40 Td [(New)-300(paragraph)] TJ (\050here\051) ' ET`
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 3 0 R >>",
+		"<< /Title (Fox \\226 story) >>",
+		"<< /Type /Pages /Kids [4 0 R] /Count 1 /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 3 0 R /Contents 6 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		flateStream(content),
+	)
+	doc, err := Extract("fox.pdf", data)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	want := "The quick brown fox jumps over the lazy dog and keeps running.\n\nNew paragraph (here)"
+	if doc.Title != "Fox – story" || doc.Text != want {
+		t.Errorf("unexpected document: %q / %q", doc.Title, doc.Text)
+	}
+}
+
+func TestExtractPDF_ToUnicode(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin 12 dict begin begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar <0001> <0048> <0002> <FB01> endbfchar
+1 beginbfrange <0010> <0012> <0061> endbfrange
+endcmap CMapName currentdict /CMap defineresource pop end end`
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 3 0 R >>",
+		"<< /Title <FEFF30CB30E530FC30B9> >>",
+		"<< /Type /Pages /Kids [4 0 R] /Count 1 >>",
+		"<< /Type /Page /Contents 6 0 R /Resources << /Font << /F2 5 0 R >> >> >>",
+		"<< /Type /Font /Subtype /Type0 /ToUnicode 7 0 R >>",
+		flateStream("BT /F2 10 Tf 1 0 0 1 50 700 Tm <000100100011000200120099> Tj ET"),
+		flateStream(cmap),
+	)
+	doc, err := Extract("cid.pdf", data)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if doc.Title != "ニュース" || doc.Text != "Habfic" {
+		t.Errorf("unexpected document: %q / %q", doc.Title, doc.Text)
+	}
+}
+
+func TestExtract_Errors(t *testing.T) {
+	if _, err := Extract("image.png", []byte("\x89PNG\r\n\x1a\n")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+	if _, err := Extract("empty.html", []byte("<html><body><script>x</script></body></html>")); !errors.Is(err, ErrNoText) {
+		t.Errorf("expected ErrNoText, got %v", err)
+	}
+	if _, err := Extract("broken.docx", []byte("PK\x03\x04 not a zip")); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt, got %v", err)
+	}
+	encrypted := []byte(strings.Replace(string(buildPDF("<< /Type /Catalog >>")), "<< /Root", "<< /Encrypt 9 0 R /Root", 1))
+	if _, err := Extract("secret.pdf", encrypted); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt for an encrypted PDF, got %v", err)
+	}
+}
+
+func TestArticle(t *testing.T) {
+	page := `<html><head><title>Rates rise again as inflation persists | Daily News</title></head><body>
+<header><a href="/">Daily News</a><nav><a href="/world">World</a> <a href="/tech">Tech</a></nav></header>
+<div class="sidebar"><p>Most read: a very long list of other stories you might like, and more.</p></div>
+<div class="layout">
+  <article class="story-body">
+    <h1>Rates rise again</h1>
+    <p>The central bank raised interest rates on Tuesday, citing inflation that has stayed high for months.</p>
+    <div class="share-tools"><a href="#">Share on social media, email and more</a></div>
+    <p>Economists had expected the move, although some warned that borrowing costs could slow growth.</p>
+    <p>Markets, which had priced in the increase, were little changed by the afternoon.</p>
+  </article>
+  <div class="comments"><p>Great article, thanks for writing this, really helpful to understand.</p></div>
+</div>
+<footer><p>Copyright Daily News, all rights reserved, 2025.</p></footer>
+</body></html>`
+	doc, err := Article(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Article failed: %v", err)
+	}
+	if doc.Title != "Rates rise again as inflation persists" {
+		t.Errorf("unexpected title: %q", doc.Title)
+	}
+	want := "Rates rise again\n\n" +
+		"The central bank raised interest rates on Tuesday, citing inflation that has stayed high for months.\n\n" +
+		"Economists had expected the move, although some warned that borrowing costs could slow growth.\n\n" +
+		"Markets, which had priced in the increase, were little changed by the afternoon."
+	if doc.Text != want {
+		t.Errorf("unexpected text:\n%s", doc.Text)
+	}
+}
@@ -0,0 +1,142 @@
+package extract
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 本文ではない要素。中のテキストは捨てる
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Math: true, atom.Iframe: true, atom.Object: true,
+	atom.Head: true, atom.Nav: true, atom.Button: true, atom.Select: true,
+	atom.Form: true, atom.Canvas: true, atom.Audio: true, atom.Video: true,
+}
+
+// 段落の区切りになる要素
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Li: true, atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Table: true, atom.Tr: true,
+	atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Figure: true,
+	atom.Figcaption: true, atom.Hr: true, atom.Address: true, atom.Details: true,
+	atom.Summary: true, atom.Body: true, atom.Caption: true,
+}
+
+// HTML は HTML の本文を段落ごとに取り出す。タイトルは <title>、なければ最初の <h1>
+func HTML(r io.Reader) (*Document, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	doc := &Document{Title: htmlTitle(root)}
+	doc.Text = joinParagraphs(HTMLParagraphs(root))
+	return doc, nil
+}
+
+// htmlTitle は <title> のテキスト。なければ最初の <h1>
+func htmlTitle(root *html.Node) string {
+	if title := findElement(root, atom.Title); title != nil {
+		if text := cleanLine(nodeText(title)); text != "" {
+			return text
+		}
+	}
+	if h1 := findElement(root, atom.H1); h1 != nil {
+		return cleanLine(nodeText(h1))
+	}
+	return ""
+}
+
+// HTMLParagraphs は n の下のテキストをブロック要素ごとの段落にする
+func HTMLParagraphs(n *html.Node) []string {
+	var paragraphs []string
+	var current strings.Builder
+	flush := func() {
+		if p := cleanLine(current.String()); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		current.Reset()
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			current.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if skippedElements[n.DataAtom] || hiddenElement(n) {
+				return
+			}
+			switch n.DataAtom {
+			case atom.Br:
+				current.WriteString(" ")
+				return
+			case atom.Td, atom.Th:
+				current.WriteString(" ")
+			case atom.Img:
+				return
+			}
+		}
+		block := n.Type == html.ElementNode && blockElements[n.DataAtom]
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			flush()
+		}
+	}
+	walk(n)
+	flush()
+	return paragraphs
+}
+
+// hiddenElement は hidden 属性や aria-hidden の付いた要素
+func hiddenElement(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		switch {
+		case attr.Key == "hidden":
+			return true
+		case attr.Key == "aria-hidden" && attr.Val == "true":
+			return true
+		}
+	}
+	return false
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// nodeText は n の下のテキストをすべてつなぐ
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && skippedElements[n.DataAtom] && n.DataAtom != atom.Head {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
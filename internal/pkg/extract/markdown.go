@@ -0,0 +1,132 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdRefLink    = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	mdLinkDef    = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s+\S+`)
+	mdAutolink   = regexp.MustCompile(`<(https?://[^>]+)>`)
+	mdTag        = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	mdEmphasis   = regexp.MustCompile(`(\*\*\*|\*\*|\*|___|__|~~)(\S(?:.*?\S)?)(\*\*\*|\*\*|\*|___|__|~~)`)
+	mdUnderscore = regexp.MustCompile(`(^|\W)_(\S(?:.*?\S)?)_(\W|$)`)
+	mdCode       = regexp.MustCompile("`+([^`]+)`+")
+	mdHeading    = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdListItem   = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?`)
+	mdQuote      = regexp.MustCompile(`^\s{0,3}(?:>\s?)+`)
+	mdRule       = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	mdSetext     = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+	mdTableRule  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdEscape     = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!|>~])`)
+)
+
+// extractMarkdown は記法を外したテキストを返す。見出し・リストの項目は1つの段落にする。
+// タイトルは front matter の title、なければ最初の見出し
+func extractMarkdown(text string) *Document {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	doc := &Document{}
+
+	// front matter (--- で囲まれた YAML)
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			line := strings.TrimSpace(lines[i])
+			if line == "---" || line == "..." {
+				lines = lines[i+1:]
+				break
+			}
+			if value, ok := strings.CutPrefix(line, "title:"); ok {
+				doc.Title = strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+		}
+	}
+
+	var paragraphs []string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			paragraphs = append(paragraphs, strings.Join(current, " "))
+			current = nil
+		}
+	}
+	inFence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		// コードブロックの中身はそのまま1段落にする
+		if inFence != "" {
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+				flush()
+				continue
+			}
+			current = append(current, trimmed)
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			flush()
+			inFence = trimmed[:3]
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flush()
+			continue
+		case mdRule.MatchString(line) && len(current) == 0, mdLinkDef.MatchString(line), mdTableRule.MatchString(line) && strings.Contains(line, "-") && strings.Contains(line, "|"):
+			flush()
+			continue
+		case mdSetext.MatchString(line) && len(current) > 0:
+			// 前の行が見出し (Setext)
+			heading := current[len(current)-1]
+			current = current[:len(current)-1]
+			flush()
+			paragraphs = append(paragraphs, heading)
+			if doc.Title == "" && strings.HasPrefix(trimmed, "=") {
+				doc.Title = heading
+			}
+			continue
+		}
+
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			flush()
+			heading := markdownInline(m[2])
+			paragraphs = append(paragraphs, heading)
+			if doc.Title == "" && (len(m[1]) == 1 || i == 0) {
+				doc.Title = heading
+			}
+			continue
+		}
+		if mdListItem.MatchString(line) {
+			flush()
+			line = mdListItem.ReplaceAllString(line, "")
+		}
+		line = mdQuote.ReplaceAllString(line, "")
+		if strings.Contains(line, "|") && strings.HasPrefix(strings.TrimSpace(line), "|") {
+			line = strings.ReplaceAll(line, "|", " ")
+		}
+		current = append(current, markdownInline(line))
+	}
+	flush()
+
+	doc.Text = joinParagraphs(paragraphs)
+	return doc
+}
+
+// markdownInline は行の中の記法 (リンク・強調・コード・HTML タグ) を外す
+func markdownInline(line string) string {
+	line = mdImage.ReplaceAllString(line, "$1")
+	line = mdLink.ReplaceAllString(line, "$1")
+	line = mdRefLink.ReplaceAllString(line, "$1")
+	line = mdAutolink.ReplaceAllString(line, "$1")
+	line = mdCode.ReplaceAllString(line, "$1")
+	line = mdTag.ReplaceAllString(line, "")
+	for range 2 {
+		line = mdEmphasis.ReplaceAllString(line, "$2")
+	}
+	line = mdUnderscore.ReplaceAllString(line, "$1$2$3")
+	line = mdEscape.ReplaceAllString(line, "$1")
+	return strings.TrimSpace(line)
+}
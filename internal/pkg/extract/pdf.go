@@ -0,0 +1,381 @@
+package extract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// 読むページ数の上限
+const maxPDFPages = 2000
+
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+var errPDFFilter = errors.New("unsupported pdf filter")
+
+// pdfFile は PDF のオブジェクト。xref 表は使わず、ファイルを先頭から読んで "n g obj" を集める。
+// 壊れた xref の PDF も読め、後から追記されたオブジェクトが前のものを上書きする
+type pdfFile struct {
+	objects  map[int]any
+	trailer  pdfDict
+	expanded int64
+}
+
+func parsePDF(data []byte) (*pdfFile, error) {
+	f := &pdfFile{objects: make(map[int]any)}
+	var trailers []pdfDict
+
+	pos := 0
+	for pos < len(data) {
+		loc := pdfObjectHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num := atoiBytes(data[pos+loc[2] : pos+loc[3]])
+		l := &pdfLexer{data: data, pos: pos + loc[1]}
+		value, err := l.object()
+		if err != nil {
+			pos += loc[1]
+			continue
+		}
+		l.skipSpace()
+		if dict, ok := value.(pdfDict); ok && bytes.HasPrefix(data[l.pos:], []byte("stream")) {
+			stream, end := readPDFStream(data, l.pos+len("stream"), dict)
+			value, l.pos = stream, end
+			if dict["Type"] == pdfName("XRef") {
+				trailers = append(trailers, dict)
+			}
+		}
+		f.objects[num] = value
+		pos = l.pos
+	}
+
+	// 古い形式の trailer 辞書
+	for i := 0; ; {
+		idx := bytes.Index(data[i:], []byte("trailer"))
+		if idx < 0 {
+			break
+		}
+		l := &pdfLexer{data: data, pos: i + idx + len("trailer")}
+		if dict, err := l.object(); err == nil {
+			if d, ok := dict.(pdfDict); ok {
+				trailers = append(trailers, d)
+			}
+		}
+		i += idx + len("trailer")
+	}
+	for _, trailer := range trailers {
+		if _, ok := trailer["Root"]; ok {
+			f.trailer = trailer
+		}
+	}
+	if f.trailer == nil {
+		return nil, ErrCorrupt
+	}
+	if _, encrypted := f.trailer["Encrypt"]; encrypted {
+		return nil, ErrCorrupt
+	}
+	f.loadObjectStreams()
+	return f, nil
+}
+
+func atoiBytes(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n*10 + int(c-'0')
+		if n > 1<<30 {
+			return -1
+		}
+	}
+	return n
+}
+
+// readPDFStream は "stream" の後ろの中身を読み、stream と endstream の後ろの位置を返す
+func readPDFStream(data []byte, start int, dict pdfDict) (*pdfStream, int) {
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+	// Length が正しければそれを使い、違えば endstream を探す
+	if length, ok := dict["Length"].(float64); ok && length >= 0 {
+		end := start + int(length)
+		if end <= len(data) {
+			rest := bytes.TrimLeft(data[end:], "\r\n \t")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				after := len(data) - len(rest) + len("endstream")
+				return &pdfStream{dict: dict, raw: data[start:end]}, after
+			}
+		}
+	}
+	idx := bytes.Index(data[start:], []byte("endstream"))
+	if idx < 0 {
+		return &pdfStream{dict: dict, raw: data[start:]}, len(data)
+	}
+	raw := bytes.TrimRight(data[start:start+idx], "\r\n")
+	return &pdfStream{dict: dict, raw: raw}, start + idx + len("endstream")
+}
+
+// loadObjectStreams は圧縮されたオブジェクト (ObjStm) を展開する
+func (f *pdfFile) loadObjectStreams() {
+	var streams []*pdfStream
+	for _, v := range f.objects {
+		if s, ok := v.(*pdfStream); ok && s.dict["Type"] == pdfName("ObjStm") {
+			streams = append(streams, s)
+		}
+	}
+	for _, s := range streams {
+		data, err := f.decode(s)
+		if err != nil {
+			continue
+		}
+		n, _ := s.dict["N"].(float64)
+		first, _ := s.dict["First"].(float64)
+		header := &pdfLexer{data: data}
+		for i := 0; i < int(n); i++ {
+			num, err1 := header.token()
+			offset, err2 := header.token()
+			if err1 != nil || err2 != nil {
+				break
+			}
+			objNum, ok1 := num.(float64)
+			objOffset, ok2 := offset.(float64)
+			if !ok1 || !ok2 {
+				break
+			}
+			if _, exists := f.objects[int(objNum)]; exists {
+				continue
+			}
+			l := &pdfLexer{data: data, pos: int(first) + int(objOffset)}
+			if l.pos < 0 || l.pos >= len(data) {
+				continue
+			}
+			if value, err := l.object(); err == nil {
+				f.objects[int(objNum)] = value
+			}
+		}
+	}
+}
+
+// resolve は参照をたどって値を返す
+func (f *pdfFile) resolve(v any) any {
+	for i := 0; i < 16; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.objects[ref.num]
+	}
+	return nil
+}
+
+func (f *pdfFile) dict(v any) pdfDict {
+	switch d := f.resolve(v).(type) {
+	case pdfDict:
+		return d
+	case *pdfStream:
+		return d.dict
+	}
+	return nil
+}
+
+// decode はフィルタを順にかけて stream の中身を返す
+func (f *pdfFile) decode(s *pdfStream) ([]byte, error) {
+	var filters []any
+	switch filter := f.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{filter}
+	case pdfArray:
+		filters = filter
+	}
+
+	data := s.raw
+	for _, filter := range filters {
+		var r io.Reader
+		switch f.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				r = flate.NewReader(bytes.NewReader(data))
+			} else {
+				r = zr
+			}
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			digits := bytes.Map(func(r rune) rune {
+				if isPDFSpace(byte(r)) || r == '>' {
+					return -1
+				}
+				return r
+			}, data)
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			r = hex.NewDecoder(bytes.NewReader(digits))
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+			if end := bytes.Index(data, []byte("~>")); end >= 0 {
+				data = data[:end]
+			}
+			r = ascii85.NewDecoder(bytes.NewReader(data))
+		default:
+			return nil, errPDFFilter
+		}
+
+		decoded, err := io.ReadAll(io.LimitReader(r, maxExpandedBytes-f.expanded+1))
+		// 途中で切れた stream も読めたところまで使う
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && len(decoded) == 0 {
+			return nil, ErrCorrupt
+		}
+		f.expanded += int64(len(decoded))
+		if f.expanded > maxExpandedBytes {
+			return nil, ErrTooLarge
+		}
+		data = decoded
+	}
+	return data, nil
+}
+
+// pages はページの辞書と、親から引き継いだ Resources を順に返す
+func (f *pdfFile) pages() []pdfPage {
+	root := f.dict(f.trailer["Root"])
+	if root == nil {
+		return nil
+	}
+	var pages []pdfPage
+	visited := map[int]bool{}
+	var walk func(v any, resources pdfDict, depth int)
+	walk = func(v any, resources pdfDict, depth int) {
+		if ref, ok := v.(pdfRef); ok {
+			if visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+		}
+		node := f.dict(v)
+		if node == nil || depth > maxPDFDepth || len(pages) >= maxPDFPages {
+			return
+		}
+		if r := f.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		kids, isTree := f.resolve(node["Kids"]).(pdfArray)
+		if !isTree {
+			pages = append(pages, pdfPage{dict: node, resources: resources})
+			return
+		}
+		for _, kid := range kids {
+			walk(kid, resources, depth+1)
+		}
+	}
+	walk(root["Pages"], nil, 0)
+	return pages
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// contents はページの内容 (複数の stream ならつないだもの)
+func (f *pdfFile) contents(page pdfPage) []byte {
+	var streams []any
+	switch c := f.resolve(page.dict["Contents"]).(type) {
+	case *pdfStream:
+		streams = []any{c}
+	case pdfArray:
+		streams = c
+	}
+	var buf bytes.Buffer
+	for _, v := range streams {
+		s, ok := f.resolve(v).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := f.decode(s)
+		if err != nil {
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// textString は文書情報などの文字列 (UTF-16BE か PDFDocEncoding) を UTF-8 にする
+func textString(s pdfString) string {
+	b := []byte(s)
+	switch {
+	case bytes.HasPrefix(b, []byte{0xfe, 0xff}):
+		return decodeUTF16BE(b[2:])
+	case bytes.HasPrefix(b, []byte{0xef, 0xbb, 0xbf}):
+		return string(b[3:])
+	}
+	return decodeWinAnsi(b)
+}
+
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// WinAnsiEncoding で Latin-1 と違う文字
+var winAnsi = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡', 0x88: 'ˆ',
+	0x89: '‰', 0x8a: 'Š', 0x8b: '‹', 0x8c: 'Œ', 0x8e: 'Ž', 0x91: '‘', 0x92: '’', 0x93: '“',
+	0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—', 0x98: '˜', 0x99: '™', 0x9a: 'š', 0x9b: '›',
+	0x9c: 'œ', 0x9e: 'ž', 0x9f: 'Ÿ',
+}
+
+func decodeWinAnsi(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		if r, ok := winAnsi[c]; ok {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String()
+}
+
+// 合字を普通の文字に戻す
+var ligatures = strings.NewReplacer("ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl", "ﬅ", "st", "ﬆ", "st", "\u00ad", "")
+
+// extractPDF はページの文字を順に取り出す。タイトルは文書情報の Title
+func extractPDF(data []byte) (*Document, error) {
+	f, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{}
+	if info := f.dict(f.trailer["Info"]); info != nil {
+		if title, ok := f.resolve(info["Title"]).(pdfString); ok {
+			doc.Title = textString(title)
+		}
+	}
+
+	fonts := map[any]*pdfFont{}
+	var paragraphs []string
+	for _, page := range f.pages() {
+		content := f.contents(page)
+		if f.expanded > maxExpandedBytes {
+			return nil, ErrTooLarge
+		}
+		w := &pdfTextWriter{file: f, resources: page.resources, fonts: fonts}
+		w.run(content)
+		paragraphs = append(paragraphs, w.paragraphs()...)
+	}
+	doc.Text = ligatures.Replace(joinParagraphs(paragraphs))
+	return doc, nil
+}
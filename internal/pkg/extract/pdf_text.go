@@ -0,0 +1,423 @@
+package extract
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TJ の間隔 (1/1000 em) がこれより大きく左に空いたら単語の区切りとみなす
+const pdfWordGap = 250
+
+// pdfFont は文字コードを Unicode にする方法。ToUnicode がなければ 1バイトの WinAnsi とみなす
+type pdfFont struct {
+	cmap *pdfCMap
+	// ToUnicode のない CID フォント。文字が取れない
+	composite bool
+}
+
+func (font *pdfFont) decode(s pdfString) string {
+	switch {
+	case font == nil:
+		return decodeWinAnsi([]byte(s))
+	case font.cmap != nil:
+		return font.cmap.decode([]byte(s))
+	case font.composite:
+		return ""
+	}
+	return decodeWinAnsi([]byte(s))
+}
+
+// pdfCMap は ToUnicode の対応表
+type pdfCMap struct {
+	m map[string]string
+	// 文字コードのバイト数 (短い順)
+	lengths []int
+}
+
+// 1つの bfrange で展開する文字コードの上限
+const maxCMapRange = 1 << 16
+
+func parseCMap(data []byte) *pdfCMap {
+	cmap := &pdfCMap{m: make(map[string]string)}
+	// codespacerange がなければ対応表の文字コードのバイト数を使う
+	codespace, seen := map[int]bool{}, map[int]bool{}
+	l := &pdfLexer{data: data}
+	var operands []any
+	for {
+		v, err := l.object()
+		if err != nil {
+			break
+		}
+		keyword, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+		switch keyword {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].(pdfString); ok && len(lo) > 0 {
+					codespace[len(lo)] = true
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					cmap.m[string(src)] = decodeUTF16BE([]byte(dst))
+					seen[len(src)] = true
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+					continue
+				}
+				cmap.addRange([]byte(lo), []byte(hi), operands[i+2])
+				seen[len(lo)] = true
+			}
+		}
+		operands = operands[:0]
+	}
+	if len(codespace) == 0 {
+		codespace = seen
+	}
+	for n := range codespace {
+		cmap.lengths = append(cmap.lengths, n)
+	}
+	if len(cmap.lengths) == 0 {
+		cmap.lengths = []int{1}
+	}
+	sort.Ints(cmap.lengths)
+	return cmap
+}
+
+func (cmap *pdfCMap) addRange(lo, hi []byte, dst any) {
+	start, end := bytesToInt(lo), bytesToInt(hi)
+	if end < start || end-start >= maxCMapRange {
+		return
+	}
+	for code := start; code <= end; code++ {
+		src := intToBytes(code, len(lo))
+		switch d := dst.(type) {
+		case pdfString:
+			// 最後の UTF-16 の単位を code - start だけ進める
+			b := []byte(d)
+			if len(b) < 2 {
+				continue
+			}
+			units := make([]byte, len(b))
+			copy(units, b)
+			last := int(units[len(units)-2])<<8 | int(units[len(units)-1])
+			last += code - start
+			units[len(units)-2], units[len(units)-1] = byte(last>>8), byte(last)
+			cmap.m[string(src)] = decodeUTF16BE(units)
+		case pdfArray:
+			if i := code - start; i < len(d) {
+				if s, ok := d[i].(pdfString); ok {
+					cmap.m[string(src)] = decodeUTF16BE([]byte(s))
+				}
+			}
+		}
+	}
+}
+
+func bytesToInt(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<8 | int(c)
+	}
+	return n
+}
+
+func intToBytes(n, size int) []byte {
+	b := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	return b
+}
+
+// decode は文字コードを短い順に表で引く。ない文字は飛ばす
+func (cmap *pdfCMap) decode(b []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(b); {
+		matched := false
+		for _, n := range cmap.lengths {
+			if i+n > len(b) {
+				break
+			}
+			if s, ok := cmap.m[string(b[i:i+n])]; ok {
+				sb.WriteString(s)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			i += cmap.lengths[0]
+		}
+	}
+	return sb.String()
+}
+
+// pdfTextWriter はページの内容を実行し、文字の位置から行と段落を組み立てる
+type pdfTextWriter struct {
+	file      *pdfFile
+	resources pdfDict
+	fonts     map[any]*pdfFont
+
+	font     *pdfFont
+	fontSize float64
+	leading  float64
+	// テキスト行列の位置と縦の倍率
+	x, y, scale float64
+	// 最後に文字を書いた行の y と文字の大きさ
+	lastY, lastSize float64
+	written         bool
+	moved           bool
+
+	done    []string
+	lines   []string
+	current strings.Builder
+}
+
+func (w *pdfTextWriter) run(content []byte) {
+	l := &pdfLexer{data: content}
+	var operands []any
+	for {
+		v, err := l.object()
+		if err != nil {
+			if l.pos >= len(content) {
+				break
+			}
+			l.pos++
+			operands = operands[:0]
+			continue
+		}
+		keyword, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+		if keyword == "ID" {
+			skipInlineImage(l)
+		} else {
+			w.operator(string(keyword), operands)
+		}
+		operands = operands[:0]
+	}
+}
+
+// skipInlineImage は BI ... ID の後ろの画像データを EI まで飛ばす
+func skipInlineImage(l *pdfLexer) {
+	for i := l.pos; i+2 <= len(l.data); i++ {
+		if l.data[i] == 'E' && l.data[i+1] == 'I' && i > 0 && isPDFSpace(l.data[i-1]) &&
+			(i+2 == len(l.data) || isPDFSpace(l.data[i+2])) {
+			l.pos = i + 2
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
+
+func number(operands []any, i int) float64 {
+	if i < 0 || i >= len(operands) {
+		return 0
+	}
+	n, _ := operands[i].(float64)
+	return n
+}
+
+func (w *pdfTextWriter) operator(op string, operands []any) {
+	n := len(operands)
+	switch op {
+	case "BT":
+		w.x, w.y, w.scale = 0, 0, 1
+		w.moved = true
+	case "Tf":
+		if n >= 2 {
+			name, _ := operands[0].(pdfName)
+			w.font = w.loadFont(name)
+			w.fontSize = number(operands, 1)
+		}
+	case "TL":
+		w.leading = number(operands, n-1)
+	case "Td", "TD":
+		tx, ty := number(operands, n-2), number(operands, n-1)
+		w.x += tx * w.scale
+		w.y += ty * w.scale
+		if op == "TD" {
+			w.leading = -ty
+		}
+		w.moved = true
+	case "Tm":
+		if n >= 6 {
+			w.scale = math.Abs(number(operands, 3))
+			if w.scale == 0 {
+				w.scale = math.Abs(number(operands, 1))
+			}
+			if w.scale == 0 {
+				w.scale = 1
+			}
+			w.x, w.y = number(operands, 4), number(operands, 5)
+			w.moved = true
+		}
+	case "T*":
+		w.nextLine()
+	case "Tj":
+		if s, ok := lastString(operands); ok {
+			w.show(w.font.decode(s))
+		}
+	case "'", "\"":
+		w.nextLine()
+		if s, ok := lastString(operands); ok {
+			w.show(w.font.decode(s))
+		}
+	case "TJ":
+		array, _ := lastArray(operands)
+		for _, item := range array {
+			switch v := item.(type) {
+			case pdfString:
+				w.show(w.font.decode(v))
+			case float64:
+				if v < -pdfWordGap {
+					w.space()
+				}
+			}
+		}
+	}
+}
+
+func lastString(operands []any) (pdfString, bool) {
+	if len(operands) == 0 {
+		return "", false
+	}
+	s, ok := operands[len(operands)-1].(pdfString)
+	return s, ok
+}
+
+func lastArray(operands []any) (pdfArray, bool) {
+	if len(operands) == 0 {
+		return nil, false
+	}
+	a, ok := operands[len(operands)-1].(pdfArray)
+	return a, ok
+}
+
+func (w *pdfTextWriter) nextLine() {
+	leading := w.leading
+	if leading == 0 {
+		leading = w.fontSize * 1.2
+	}
+	w.y -= leading * w.scale
+	w.moved = true
+}
+
+// show は文字を書く。前に書いた位置から行が変わっていれば改行か段落の区切りを入れる
+func (w *pdfTextWriter) show(text string) {
+	if text == "" {
+		return
+	}
+	size := math.Abs(w.fontSize * w.scale)
+	if size == 0 {
+		size = 1
+	}
+	if w.written && w.moved {
+		gap := w.lastY - w.y
+		lineSize := math.Max(size, w.lastSize)
+		switch {
+		case math.Abs(gap) < lineSize*0.5:
+			// 同じ行の中で位置だけ動いた
+			w.space()
+		case gap > lineSize*1.6 || gap < -lineSize*3:
+			w.endParagraph()
+		default:
+			w.endLine()
+		}
+	}
+	w.current.WriteString(text)
+	w.written, w.moved = true, false
+	w.lastY, w.lastSize = w.y, size
+}
+
+func (w *pdfTextWriter) space() {
+	if s := w.current.String(); s != "" && !strings.HasSuffix(s, " ") {
+		w.current.WriteString(" ")
+	}
+}
+
+func (w *pdfTextWriter) endLine() {
+	if line := strings.TrimSpace(w.current.String()); line != "" {
+		w.lines = append(w.lines, line)
+	}
+	w.current.Reset()
+}
+
+func (w *pdfTextWriter) endParagraph() {
+	w.endLine()
+	if len(w.lines) > 0 {
+		w.done = append(w.done, joinPDFLines(w.lines))
+		w.lines = nil
+	}
+}
+
+func (w *pdfTextWriter) paragraphs() []string {
+	w.endParagraph()
+	return w.done
+}
+
+// joinPDFLines は行をつなぐ。行末の "exam-" と次の行の "ple" はハイフンを外してつなぐ
+func joinPDFLines(lines []string) string {
+	var sb strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := sb.String()
+			next, _ := utf8.DecodeRuneInString(line)
+			if strings.HasSuffix(prev, "-") && len(prev) > 1 && unicode.IsLetter(rune(prev[len(prev)-2])) && unicode.IsLower(next) {
+				sb.Reset()
+				sb.WriteString(prev[:len(prev)-1])
+			} else {
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// loadFont はページの Resources からフォントを引き、ToUnicode を読む
+func (w *pdfTextWriter) loadFont(name pdfName) *pdfFont {
+	fonts := w.file.dict(w.resources["Font"])
+	if fonts == nil {
+		return nil
+	}
+	ref := fonts[name]
+	key := any(ref)
+	if _, isRef := ref.(pdfRef); !isRef {
+		key = name
+	}
+	if font, ok := w.fonts[key]; ok {
+		return font
+	}
+
+	font := &pdfFont{}
+	if dict := w.file.dict(ref); dict != nil {
+		font.composite = dict["Subtype"] == pdfName("Type0")
+		if stream, ok := w.file.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+			if data, err := w.file.decode(stream); err == nil && bytes.Contains(data, []byte("begin")) {
+				font.cmap = parseCMap(data)
+			}
+		}
+	}
+	w.fonts[key] = font
+	return font
+}
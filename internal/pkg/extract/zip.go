@@ -0,0 +1,211 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// zipReader は展開したサイズの合計を数え、maxExpandedBytes を超えたら ErrTooLarge にする
+type zipReader struct {
+	files    map[string]*zip.File
+	expanded int64
+}
+
+func newZipReader(data []byte) (*zipReader, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	return &zipReader{files: files}, nil
+}
+
+// read は name のファイルを展開する。なければ nil
+func (z *zipReader) read(name string) ([]byte, error) {
+	f, ok := z.files[name]
+	if !ok {
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	defer rc.Close()
+	data, err := readLimited(rc, maxExpandedBytes-z.expanded)
+	if err != nil {
+		if err == ErrTooLarge {
+			return nil, err
+		}
+		return nil, ErrCorrupt
+	}
+	z.expanded += int64(len(data))
+	return data, nil
+}
+
+// xmlTitle は Dublin Core の <dc:title> (core.xml や OPF) の最初の値
+func xmlTitle(data []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	inTitle := false
+	var sb strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			inTitle = t.Name.Local == "title"
+		case xml.CharData:
+			if inTitle {
+				sb.Write(t)
+			}
+		case xml.EndElement:
+			if inTitle {
+				return sb.String()
+			}
+		}
+	}
+}
+
+// extractDOCX は word/document.xml の段落 (w:p) を取り出す。タイトルは docProps/core.xml
+func extractDOCX(data []byte) (*Document, error) {
+	z, err := newZipReader(data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := z.read("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return nil, ErrCorrupt
+	}
+	doc := &Document{}
+	if core, err := z.read("docProps/core.xml"); err == nil && core != nil {
+		doc.Title = xmlTitle(core)
+	}
+
+	var paragraphs []string
+	var current strings.Builder
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab", "br", "cr":
+				current.WriteString(" ")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				paragraphs = append(paragraphs, current.String())
+				current.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+	}
+	doc.Text = joinParagraphs(paragraphs)
+	return doc, nil
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef  string `xml:"idref,attr"`
+		Linear string `xml:"linear,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// extractEPUB は spine の順に章の XHTML を読む。タイトルは OPF の dc:title
+func extractEPUB(data []byte) (*Document, error) {
+	z, err := newZipReader(data)
+	if err != nil {
+		return nil, err
+	}
+	containerXML, err := z.read("META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	var container epubContainer
+	if containerXML == nil || xml.Unmarshal(containerXML, &container) != nil || len(container.Rootfiles) == 0 {
+		return nil, ErrCorrupt
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	opf, err := z.read(opfPath)
+	if err != nil {
+		return nil, err
+	}
+	var pkg epubPackage
+	if opf == nil || xml.Unmarshal(opf, &pkg) != nil {
+		return nil, ErrCorrupt
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		if strings.Contains(item.MediaType, "html") {
+			hrefs[item.ID] = item.Href
+		}
+	}
+	doc := &Document{Title: xmlTitle(opf)}
+	var paragraphs []string
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok || ref.Linear == "no" {
+			continue
+		}
+		href, _, _ = strings.Cut(href, "#")
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		chapter, err := z.read(path.Join(path.Dir(opfPath), href))
+		if err != nil {
+			return nil, err
+		}
+		if chapter == nil {
+			continue
+		}
+		chapterDoc, err := HTML(bytes.NewReader(chapter))
+		if err != nil {
+			continue
+		}
+		if chapterDoc.Text != "" {
+			paragraphs = append(paragraphs, chapterDoc.Text)
+		}
+	}
+	doc.Text = strings.Join(paragraphs, "\n\n")
+	return doc, nil
+}
@@ -0,0 +1,204 @@
+// Package feed は RSS 2.0 / RSS 1.0 (RDF) / Atom のフィードを読む
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrNotFeed = errors.New("not an RSS or Atom feed")
+
+// Feed はフィードのタイトルと記事
+type Feed struct {
+	Title string
+	Items []Item
+}
+
+// Item はフィードの1つの記事。Content は HTML (テキストのこともある)。
+// GUID がなければリンクを使い、どちらもなければ空文字列
+type Item struct {
+	GUID      string
+	Title     string
+	Link      string
+	Content   string
+	Published time.Time
+}
+
+// text は Atom のテキスト要素。type="xhtml" なら中身がそのままマークアップ
+type text struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t text) value() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+type rssItem struct {
+	About       string `xml:"about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     text       `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Content   text       `xml:"content"`
+	Summary   text       `xml:"summary"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+type document struct {
+	XMLName xml.Name
+	// RSS 2.0 は channel の中、RSS 1.0 は channel と並んで item がある
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items []rssItem `xml:"item"`
+	// Atom
+	Title   text        `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// Parse はフィードを読む。data は UTF-8 にしたもの。相対リンクは base から解決する
+func Parse(data []byte, base string) (*Feed, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	// 文字コードは取得したときに UTF-8 にしてあるので、宣言は無視する
+	decoder.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	var doc document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, ErrNotFeed
+	}
+	baseURL, _ := url.Parse(base)
+
+	feed := &Feed{}
+	switch doc.XMLName.Local {
+	case "rss", "RDF":
+		feed.Title = plainText(doc.Channel.Title)
+		for _, item := range append(doc.Channel.Items, doc.Items...) {
+			feed.Items = append(feed.Items, rssEntry(item, baseURL))
+		}
+	case "feed":
+		feed.Title = plainText(doc.Title.value())
+		for _, entry := range doc.Entries {
+			feed.Items = append(feed.Items, atomItem(entry, baseURL))
+		}
+	default:
+		return nil, ErrNotFeed
+	}
+	return feed, nil
+}
+
+func rssEntry(item rssItem, base *url.URL) Item {
+	link := resolve(base, item.Link)
+	content := item.Encoded
+	if strings.TrimSpace(content) == "" {
+		content = item.Description
+	}
+	guid := strings.TrimSpace(item.GUID)
+	if guid == "" {
+		guid = strings.TrimSpace(item.About)
+	}
+	if guid == "" {
+		guid = link
+	}
+	published := parseDate(item.PubDate)
+	if published.IsZero() {
+		published = parseDate(item.Date)
+	}
+	return Item{GUID: guid, Title: plainText(item.Title), Link: link, Content: strings.TrimSpace(content), Published: published}
+}
+
+func atomItem(entry atomEntry, base *url.URL) Item {
+	var link string
+	for _, l := range entry.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			link = resolve(base, l.Href)
+			break
+		}
+	}
+	content := entry.Content.value()
+	if content == "" {
+		content = entry.Summary.value()
+	}
+	guid := strings.TrimSpace(entry.ID)
+	if guid == "" {
+		guid = link
+	}
+	published := parseDate(entry.Published)
+	if published.IsZero() {
+		published = parseDate(entry.Updated)
+	}
+	return Item{GUID: guid, Title: plainText(entry.Title.value()), Link: link, Content: content, Published: published}
+}
+
+func resolve(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || base == nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// plainText はタイトルに入っているタグと文字参照を外す
+func plainText(s string) string {
+	s = html.UnescapeString(tagPattern.ReplaceAllString(s, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate は RSS (RFC 822) と Atom (RFC 3339) の日時を読む。読めなければゼロ値
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,86 @@
+package feed
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRSS(t *testing.T) {
+	data := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel><title>Tech &amp; News</title>
+<item><title>First &lt;b&gt;post&lt;/b&gt;</title><link>/posts/1</link><guid isPermaLink="false">post-1</guid>
+<description>Short summary</description><content:encoded><![CDATA[<p>Full <em>text</em></p>]]></content:encoded>
+<pubDate>Tue, 14 Oct 2025 08:30:00 +0000</pubDate></item>
+<item><title>Second</title><link>https://example.com/posts/2</link><description>Only&nbsp;summary</description>
+<pubDate>Wed, 15 Oct 2025 09:00:00 GMT</pubDate></item>
+</channel></rss>`
+	feed, err := Parse([]byte(data), "https://example.com/feed.xml")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if feed.Title != "Tech & News" || len(feed.Items) != 2 {
+		t.Fatalf("unexpected feed: %+v", feed)
+	}
+	first := feed.Items[0]
+	if first.GUID != "post-1" || first.Title != "First post" || first.Link != "https://example.com/posts/1" || first.Content != "<p>Full <em>text</em></p>" {
+		t.Errorf("unexpected first item: %+v", first)
+	}
+	if !first.Published.Equal(time.Date(2025, 10, 14, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date: %v", first.Published)
+	}
+	second := feed.Items[1]
+	if second.GUID != "https://example.com/posts/2" || second.Content != "Only summary" || second.Published.IsZero() {
+		t.Errorf("unexpected second item: %+v", second)
+	}
+}
+
+func TestParseRDF(t *testing.T) {
+	data := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="https://example.jp/"><title>Blog</title></channel>
+<item rdf:about="https://example.jp/a"><title>A</title><link>https://example.jp/a</link><description>Body</description><dc:date>2025-10-01T10:00:00+09:00</dc:date></item>
+</rdf:RDF>`
+	feed, err := Parse([]byte(data), "")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if feed.Title != "Blog" || len(feed.Items) != 1 || feed.Items[0].GUID != "https://example.jp/a" || feed.Items[0].Published.IsZero() {
+		t.Errorf("unexpected feed: %+v", feed)
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	data := `<feed xmlns="http://www.w3.org/2005/Atom"><title type="text">Atom blog</title>
+<entry><id>tag:example.com,2025:1</id><title type="html">Hello &amp;amp; welcome</title>
+<link rel="self" href="https://example.com/self"/><link rel="alternate" href="entries/1"/>
+<summary>Summary</summary><content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Rich</p></div></content>
+<updated>2025-10-02T12:00:00Z</updated></entry>
+<entry><link href="https://example.com/entries/2"/><title>No id</title><summary type="html">&lt;p&gt;Escaped&lt;/p&gt;</summary></entry>
+</feed>`
+	feed, err := Parse([]byte(data), "https://example.com/atom/")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if feed.Title != "Atom blog" || len(feed.Items) != 2 {
+		t.Fatalf("unexpected feed: %+v", feed)
+	}
+	first := feed.Items[0]
+	if first.GUID != "tag:example.com,2025:1" || first.Title != "Hello & welcome" || first.Link != "https://example.com/atom/entries/1" {
+		t.Errorf("unexpected first entry: %+v", first)
+	}
+	if first.Content != `<div xmlns="http://www.w3.org/1999/xhtml"><p>Rich</p></div>` || !first.Published.Equal(time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected first entry content: %+v", first)
+	}
+	if second := feed.Items[1]; second.GUID != "https://example.com/entries/2" || second.Content != "<p>Escaped</p>" {
+		t.Errorf("unexpected second entry: %+v", second)
+	}
+}
+
+func TestParseNotFeed(t *testing.T) {
+	for _, data := range []string{"<html><body>page</body></html>", "not xml at all", ""} {
+		if _, err := Parse([]byte(data), ""); !errors.Is(err, ErrNotFeed) {
+			t.Errorf("Parse(%q): expected ErrNotFeed, got %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package language は学習者の母語・教材の言語を表す言語コード (ISO 639-1) を扱う
+package language
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultNative は母語が未設定のユーザーに使う
+const DefaultNative = "ja"
+
+// DefaultSource は言語が未設定の教材に使う
+const DefaultSource = "en"
+
+// 教材の言語として対応しているもの。ja は日本語の教材から英語の言い方を学ぶ逆方向の学習
+var learnable = map[string]bool{
+	"en": true,
+	"de": true,
+	"ja": true,
+}
+
+// プロンプトに渡す英語の言語名
+var names = map[string]string{
+	"en": "English",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"es": "Spanish",
+	"de": "German",
+	"fr": "French",
+	"zh": "Chinese",
+	"pt": "Portuguese",
+	"it": "Italian",
+}
+
+// Normalize は "ES", "es-MX", "es_MX" を "es" にする。対応していない言語なら false
+func Normalize(code string) (string, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	_, ok := names[code]
+	return code, ok
+}
+
+// NormalizeSource は教材の言語コードを正規化する。空なら DefaultSource、対応していなければ false
+func NormalizeSource(code string) (string, bool) {
+	if strings.TrimSpace(code) == "" {
+		return DefaultSource, true
+	}
+	code, ok := Normalize(code)
+	return code, ok && learnable[code]
+}
+
+// Spaced は語を空白で区切って書く言語か。日本語・中国語は区切らない
+func Spaced(code string) bool {
+	return code != "ja" && code != "zh"
+}
+
+// Name は言語コードの英語名を返す。未知のコードならコードをそのまま返す
+func Name(code string) string {
+	if name, ok := names[code]; ok {
+		return name
+	}
+	return code
+}
+
+// Supported は対応している言語コードの一覧
+func Supported() []string {
+	codes := make([]string, 0, len(names))
+	for code := range names {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
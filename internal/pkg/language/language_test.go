@@ -0,0 +1,33 @@
+package language
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{"ja": "ja", "KO": "ko", "es-MX": "es", "pt_BR": "pt", " de ": "de"}
+	for input, expected := range tests {
+		if code, ok := Normalize(input); !ok || code != expected {
+			t.Errorf("Normalize(%q) = %q, %v; expected %q", input, code, ok, expected)
+		}
+	}
+	if _, ok := Normalize("xx"); ok {
+		t.Error("expected unknown language to be rejected")
+	}
+}
+
+func TestName(t *testing.T) {
+	if Name("ko") != "Korean" || Name("xx") != "xx" {
+		t.Errorf("unexpected names: %q %q", Name("ko"), Name("xx"))
+	}
+}
+
+func TestNormalizeSource(t *testing.T) {
+	tests := map[string]string{"": "en", "EN-us": "en", "de": "de", "ja": "ja"}
+	for input, expected := range tests {
+		if code, ok := NormalizeSource(input); !ok || code != expected {
+			t.Errorf("NormalizeSource(%q) = %q, %v; expected %q", input, code, ok, expected)
+		}
+	}
+	if _, ok := NormalizeSource("ko"); ok {
+		t.Error("expected a language without learning support to be rejected")
+	}
+}
@@ -0,0 +1,113 @@
+package lemma
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ドイツ語の不規則変化 (不定詞 -> 活用形)。語尾の規則で戻せない強変化・混合変化の動詞
+var germanIrregularForms = map[string][]string{
+	"sein":      {"bin", "bist", "ist", "sind", "seid", "war", "warst", "waren", "wart", "gewesen"},
+	"haben":     {"habe", "hast", "hat", "habt", "hatte", "hattest", "hatten", "gehabt"},
+	"werden":    {"werde", "wirst", "wird", "werdet", "wurde", "wurden", "geworden"},
+	"können":    {"kann", "kannst", "könnt", "konnte", "konnten", "gekonnt"},
+	"müssen":    {"muss", "musst", "müsst", "musste", "mussten", "gemusst"},
+	"wollen":    {"will", "willst", "wollte", "wollten", "gewollt"},
+	"dürfen":    {"darf", "darfst", "durfte", "durften", "gedurft"},
+	"mögen":     {"mag", "magst", "mochte", "mochten", "gemocht"},
+	"wissen":    {"weiß", "weißt", "wusste", "wussten", "gewusst"},
+	"gehen":     {"ging", "gingen", "gegangen"},
+	"kommen":    {"kam", "kamen", "gekommen"},
+	"geben":     {"gibt", "gibst", "gab", "gaben", "gegeben"},
+	"nehmen":    {"nimmt", "nimmst", "nahm", "nahmen", "genommen"},
+	"sehen":     {"sieht", "siehst", "sah", "sahen", "gesehen"},
+	"finden":    {"fand", "fanden", "gefunden"},
+	"bleiben":   {"blieb", "blieben", "geblieben"},
+	"stehen":    {"stand", "standen", "gestanden"},
+	"liegen":    {"lag", "lagen", "gelegen"},
+	"bringen":   {"brachte", "brachten", "gebracht"},
+	"denken":    {"dachte", "dachten", "gedacht"},
+	"fahren":    {"fährt", "fährst", "fuhr", "fuhren", "gefahren"},
+	"lesen":     {"liest", "las", "lasen", "gelesen"},
+	"sprechen":  {"spricht", "sprichst", "sprach", "sprachen", "gesprochen"},
+	"schreiben": {"schrieb", "schrieben", "geschrieben"},
+	"schließen": {"schließt", "schloss", "schlossen", "geschlossen"},
+	"treffen":   {"trifft", "triffst", "traf", "trafen", "getroffen"},
+	"helfen":    {"hilft", "hilfst", "half", "halfen", "geholfen"},
+	"beginnen":  {"begann", "begannen", "begonnen"},
+	"lassen":    {"lässt", "ließ", "ließen", "gelassen"},
+	"laufen":    {"läuft", "läufst", "lief", "liefen", "gelaufen"},
+	"halten":    {"hält", "hältst", "hielt", "hielten", "gehalten"},
+	"tun":       {"tue", "tust", "tut", "tat", "taten", "getan"},
+}
+
+var germanLemmaOf = func() map[string]string {
+	m := make(map[string]string)
+	for base, forms := range germanIrregularForms {
+		for _, form := range forms {
+			m[form] = base
+		}
+	}
+	return m
+}()
+
+// 動詞の現在形の語尾。長いものから外す
+var germanVerbEndings = []string{"est", "et", "st", "t", "e"}
+
+// 形容詞の格変化の語尾
+var germanAdjectiveEndings = []string{"en", "em", "er", "es", "e"}
+
+// lemmatizeGerman はドイツ語の語を辞書形に戻す。名詞は頭文字を大文字のまま残し、格変化は戻さない
+func lemmatizeGerman(w, pos string) string {
+	if pos == "noun" || pos == "" && startsUpper(w) {
+		return capitalize(w)
+	}
+	w = strings.ToLower(w)
+	if base, ok := germanLemmaOf[w]; ok {
+		return base
+	}
+
+	switch pos {
+	case "verb":
+		return germanVerb(w)
+	case "adjective", "adj":
+		return stripEnding(w, germanAdjectiveEndings)
+	}
+	return w
+}
+
+// germanVerb は現在形と規則的な過去分詞 (gemacht) を不定詞に戻す
+func germanVerb(w string) string {
+	if strings.HasSuffix(w, "en") || strings.HasSuffix(w, "ern") || strings.HasSuffix(w, "eln") {
+		return w
+	}
+	if stem, ok := strings.CutPrefix(w, "ge"); ok && strings.HasSuffix(stem, "t") && len(stem) > 3 {
+		return strings.TrimSuffix(strings.TrimSuffix(stem, "t"), "e") + "en"
+	}
+	for _, ending := range germanVerbEndings {
+		if stem, ok := strings.CutSuffix(w, ending); ok && len(stem) >= 3 && hasVowel(stem) {
+			return stem + "en"
+		}
+	}
+	return w
+}
+
+func stripEnding(w string, endings []string) string {
+	for _, ending := range endings {
+		if stem, ok := strings.CutSuffix(w, ending); ok && len(stem) >= 3 && hasVowel(stem) {
+			return stem
+		}
+	}
+	return w
+}
+
+func startsUpper(w string) bool {
+	r, _ := utf8.DecodeRuneInString(w)
+	return unicode.IsUpper(r)
+}
+
+func capitalize(w string) string {
+	r, size := utf8.DecodeRuneInString(w)
+	return string(unicode.ToUpper(r)) + w[size:]
+}
@@ -0,0 +1,175 @@
+package lemma
+
+// 不規則変化 (原形 -> 活用形)。語尾の規則では戻せない規則変化もここに置く
+var irregularForms = map[string][]string{
+	"be":         {"am", "is", "are", "was", "were", "been", "being"},
+	"have":       {"has", "had", "having"},
+	"do":         {"does", "did", "done", "doing"},
+	"go":         {"goes", "went", "gone", "going"},
+	"make":       {"made"},
+	"take":       {"took", "taken"},
+	"get":        {"got", "gotten"},
+	"come":       {"came"},
+	"see":        {"saw", "seen"},
+	"know":       {"knew", "known"},
+	"give":       {"gave", "given"},
+	"find":       {"found"},
+	"think":      {"thought"},
+	"tell":       {"told"},
+	"say":        {"said"},
+	"become":     {"became"},
+	"leave":      {"left"},
+	"feel":       {"felt"},
+	"bring":      {"brought"},
+	"begin":      {"began", "begun"},
+	"keep":       {"kept"},
+	"hold":       {"held"},
+	"write":      {"wrote", "written"},
+	"stand":      {"stood"},
+	"run":        {"ran"},
+	"buy":        {"bought"},
+	"pay":        {"paid"},
+	"meet":       {"met"},
+	"lead":       {"led"},
+	"understand": {"understood"},
+	"speak":      {"spoke", "spoken"},
+	"grow":       {"grew", "grown"},
+	"lose":       {"lost"},
+	"fall":       {"fell", "fallen"},
+	"send":       {"sent"},
+	"build":      {"built"},
+	"spend":      {"spent"},
+	"seek":       {"sought"},
+	"teach":      {"taught"},
+	"catch":      {"caught"},
+	"fight":      {"fought"},
+	"choose":     {"chose", "chosen"},
+	"rise":       {"rose", "risen"},
+	"drive":      {"drove", "driven"},
+	"break":      {"broke", "broken"},
+	"mean":       {"meant"},
+	"sell":       {"sold"},
+	"win":        {"won"},
+	"wear":       {"wore", "worn"},
+	"draw":       {"drew", "drawn"},
+	"fly":        {"flew", "flown"},
+	"eat":        {"ate", "eaten"},
+	"child":      {"children"},
+	"man":        {"men"},
+	"woman":      {"women"},
+	"person":     {"people"},
+	"foot":       {"feet"},
+	"tooth":      {"teeth"},
+	"mouse":      {"mice"},
+	"analysis":   {"analyses"},
+	"crisis":     {"crises"},
+	"criterion":  {"criteria"},
+	"phenomenon": {"phenomena"},
+	"good":       {"better", "best"},
+	"bad":        {"worse", "worst"},
+	"wolf":       {"wolves"},
+	"knife":      {"knives"},
+	"wife":       {"wives"},
+	"half":       {"halves"},
+	"shelf":      {"shelves"},
+	"thief":      {"thieves"},
+	"shoe":       {"shoes"},
+	"toe":        {"toes"},
+	"agree":      {"agreed"},
+	"free":       {"freed"},
+	"guarantee":  {"guaranteed"},
+	"die":        {"dies", "died", "dying"},
+	"lie":        {"lies", "lied", "lying"},
+	"tie":        {"ties", "tied", "tying"},
+	"use":        {"used", "uses", "using"},
+	"create":     {"created", "creating"},
+	"complete":   {"completed", "completing"},
+	"delete":     {"deleted", "deleting"},
+	"compete":    {"competed", "competing"},
+	"cite":       {"cited", "citing"},
+	"unite":      {"united", "uniting"},
+	"focus":      {"focused", "focusing", "focuses"},
+	"bus":        {"buses"},
+	"quiz":       {"quizzes"},
+	"sing":       {"sang", "sung"},
+	"ring":       {"rang", "rung"},
+	"swim":       {"swam", "swum"},
+	"sit":        {"sat"},
+	"hear":       {"heard"},
+	"forget":     {"forgot", "forgotten"},
+	"hide":       {"hid", "hidden"},
+	"shake":      {"shook", "shaken"},
+	"steal":      {"stole", "stolen"},
+	"wake":       {"woke", "woken"},
+	"bite":       {"bit", "bitten"},
+	"ride":       {"rode", "ridden"},
+	"freeze":     {"froze", "frozen"},
+	"sleep":      {"slept"},
+	"sweep":      {"swept"},
+	"lend":       {"lent"},
+	"bend":       {"bent"},
+	"dig":        {"dug"},
+	"stick":      {"stuck"},
+	"strike":     {"struck"},
+	"hang":       {"hung"},
+	"shine":      {"shone"},
+	"shoot":      {"shot"},
+	"bind":       {"bound"},
+	"feed":       {"fed"},
+	"flee":       {"fled"},
+	"deal":       {"dealt"},
+	"light":      {"lit"},
+	"slide":      {"slid"},
+	"swing":      {"swung"},
+	"tear":       {"tore", "torn"},
+	"throw":      {"threw", "thrown"},
+	"blow":       {"blew", "blown"},
+	"show":       {"shown"},
+	"forgive":    {"forgave", "forgiven"},
+	"arise":      {"arose", "arisen"},
+	"undertake":  {"undertook", "undertaken"},
+	"overcome":   {"overcame"},
+	"withdraw":   {"withdrew", "withdrawn"},
+	"explore":    {"explored", "exploring"},
+	"ignore":     {"ignored", "ignoring"},
+	"store":      {"stored", "storing"},
+	"restore":    {"restored", "restoring"},
+	"score":      {"scored", "scoring"},
+	"control":    {"controlled", "controlling"},
+	"travel":     {"travelled", "travelling"},
+	"cancel":     {"cancelled", "cancelling"},
+	"label":      {"labelled", "labelling"},
+	"movie":      {"movies"},
+	"cookie":     {"cookies"},
+	"calorie":    {"calories"},
+	"type":       {"typed", "typing"},
+	"escape":     {"escaped", "escaping"},
+	"guide":      {"guided", "guiding"},
+}
+
+// lemmaOf は irregularForms を活用形から引けるようにしたもの
+var lemmaOf = func() map[string]string {
+	m := make(map[string]string)
+	for base, forms := range irregularForms {
+		for _, form := range forms {
+			m[form] = base
+		}
+	}
+	return m
+}()
+
+// IrregularForms は原形 base の不規則な活用形を返す
+func IrregularForms(base string) []string {
+	return irregularForms[base]
+}
+
+// IrregularFormsIn は言語 lang の原形 base の不規則な活用形を返す
+func IrregularFormsIn(lang, base string) []string {
+	switch lang {
+	case "", "en":
+		return irregularForms[base]
+	case "de":
+		return germanIrregularForms[base]
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Package lemma は単語を見出し語 (原形) に戻す。
+// 不規則変化は表で引き、それ以外は語尾の規則で戻す。英語とドイツ語に対応する
+package lemma
+
+import "strings"
+
+// 語尾が s, ed, ing でも活用形ではない語
+var invariant = map[string]bool{
+	"news": true, "series": true, "species": true, "physics": true, "economics": true,
+	"mathematics": true, "politics": true, "always": true, "perhaps": true, "this": true,
+	"yes": true, "thus": true, "plus": true, "gas": true, "lens": true, "its": true,
+	"his": true, "us": true, "was": true, "is": true, "has": true, "does": true,
+	"analysis": true, "basis": true, "crisis": true, "status": true, "campus": true,
+	"bonus": true, "virus": true, "census": true, "focus": true, "chaos": true,
+	"whereas": true, "across": true, "unless": true, "nevertheless": true,
+	"during": true, "morning": true, "evening": true, "nothing": true, "something": true,
+	"anything": true, "everything": true, "ceiling": true, "hundred": true, "sacred": true,
+}
+
+// 語尾が er / est でも比較級・最上級ではない形容詞
+var notComparative = map[string]bool{
+	"clever": true, "proper": true, "eager": true, "tender": true, "bitter": true,
+	"upper": true, "inner": true, "outer": true, "former": true, "latter": true,
+	"other": true, "sober": true, "over": true, "under": true, "honest": true,
+	"modest": true, "earnest": true, "west": true, "interest": true, "sinister": true,
+}
+
+// Lemmatize は word の見出し語を小文字で返す。pos (noun, verb, adjective など) が
+// 分かれば規則を絞る。複数語の場合は最初の語だけ戻す (took off -> take off)
+func Lemmatize(word, pos string) string {
+	fields := strings.Fields(strings.ToLower(word))
+	if len(fields) == 0 {
+		return ""
+	}
+	fields[0] = lemmatizeWord(fields[0], strings.ToLower(strings.TrimSpace(pos)))
+	return strings.Join(fields, " ")
+}
+
+// LemmatizeIn は教材の言語 lang (ISO 639-1) の規則で見出し語に戻す。
+// 規則のない言語 (ja など) は前後の空白を除いて小文字にするだけ
+func LemmatizeIn(lang, word, pos string) string {
+	switch lang {
+	case "", "en":
+		return Lemmatize(word, pos)
+	case "de":
+		fields := strings.Fields(word)
+		if len(fields) == 0 {
+			return ""
+		}
+		fields[0] = lemmatizeGerman(fields[0], strings.ToLower(strings.TrimSpace(pos)))
+		return strings.Join(fields, " ")
+	}
+	return strings.ToLower(strings.TrimSpace(word))
+}
+
+func lemmatizeWord(w, pos string) string {
+	w = strings.TrimSuffix(strings.TrimSuffix(w, "'s"), "’s")
+	if base, ok := lemmaOf[w]; ok {
+		return base
+	}
+	if invariant[w] || !hasVowel(w) || len(w) <= 3 {
+		return w
+	}
+
+	switch pos {
+	case "noun":
+		return plural(w)
+	case "adjective", "adj":
+		return comparative(w)
+	case "adverb", "adv", "preposition", "conjunction", "pronoun", "determiner":
+		return w
+	case "verb":
+		if base, ok := pastOrProgressive(w); ok {
+			return base
+		}
+		return plural(w)
+	}
+
+	if base, ok := pastOrProgressive(w); ok {
+		return base
+	}
+	return plural(w)
+}
+
+// plural は複数形・三単現の s を外す
+func plural(w string) string {
+	n := len(w)
+	switch {
+	case strings.HasSuffix(w, "ss"), strings.HasSuffix(w, "us"), strings.HasSuffix(w, "is"):
+		return w
+	case strings.HasSuffix(w, "ies") && n > 4:
+		return w[:n-3] + "y"
+	case strings.HasSuffix(w, "ies"):
+		return w[:n-1]
+	case strings.HasSuffix(w, "sses"), strings.HasSuffix(w, "xes"), strings.HasSuffix(w, "zzes"),
+		strings.HasSuffix(w, "ches"), strings.HasSuffix(w, "shes"), strings.HasSuffix(w, "oes"):
+		return w[:n-2]
+	case strings.HasSuffix(w, "s"):
+		return w[:n-1]
+	}
+	return w
+}
+
+// pastOrProgressive は ed / ing を外して原形に戻す
+func pastOrProgressive(w string) (string, bool) {
+	var stem string
+	switch {
+	case strings.HasSuffix(w, "ied") && len(w) > 4:
+		return w[:len(w)-3] + "y", true
+	case strings.HasSuffix(w, "eed"):
+		// need, proceed などは原形
+		return w, true
+	case strings.HasSuffix(w, "ed"):
+		stem = w[:len(w)-2]
+	case strings.HasSuffix(w, "ing"):
+		stem = w[:len(w)-3]
+	default:
+		return "", false
+	}
+	if len(stem) < 2 || !hasVowel(stem) {
+		return w, true
+	}
+	return restoreStem(stem), true
+}
+
+// comparative は比較級・最上級を外す
+func comparative(w string) string {
+	if notComparative[w] {
+		return w
+	}
+	var stem string
+	switch {
+	case strings.HasSuffix(w, "iest"):
+		return w[:len(w)-4] + "y"
+	case strings.HasSuffix(w, "ier"):
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "est"):
+		stem = w[:len(w)-3]
+	case strings.HasSuffix(w, "er"):
+		stem = w[:len(w)-2]
+	default:
+		return w
+	}
+	if len(stem) < 2 || !hasVowel(stem) {
+		return w
+	}
+	return restoreStem(stem)
+}
+
+// restoreStem は語尾を外した後の子音の重なりを戻し、落ちた e を補う
+// (stopp -> stop, innovat -> innovate, mak -> make)
+func restoreStem(s string) string {
+	n := len(s)
+	last := s[n-1]
+	if n >= 4 && last == s[n-2] && !isVowel(last) && strings.IndexByte("lsz", last) < 0 {
+		return s[:n-1]
+	}
+	if needsE(s) {
+		return s + "e"
+	}
+	return s
+}
+
+func needsE(s string) bool {
+	n := len(s)
+	last := s[n-1]
+	prev := s[n-2]
+	switch {
+	case strings.IndexByte("vcuz", last) >= 0:
+		// resolv, produc, argu, realiz
+		return true
+	case strings.HasSuffix(s, "rg"), strings.HasSuffix(s, "dg"), last == 'g' && isVowel(prev):
+		// charg, judg, manag
+		return true
+	case last == 's' && (n == 2 || isVowel(prev) && !strings.HasSuffix(s, "ous")):
+		// us, caus, rais, clos
+		return true
+	case strings.IndexByte("rnpl", prev) >= 0 && last == 's':
+		// revers, sens, collaps, puls
+		return true
+	case last == 'l' && strings.IndexByte("bpdtgkfz", prev) >= 0:
+		// enabl, simpl, handl
+		return true
+	case last == 'b' && prev == 'i':
+		// describ
+		return true
+	case n >= 3 && !isVowel(s[n-3]) && (last == 'l' && (prev == 'i' || prev == 'o') ||
+		last == 'd' && strings.IndexByte("aiou", prev) >= 0 ||
+		last == 'n' && prev == 'i' ||
+		last == 'm' && prev == 'u'):
+		// compil, consol, decid, includ, explod, defin, determin, assum
+		return true
+	case last == 'g' && prev == 'n' && n > 4 && (s[n-3] == 'a' || s[n-3] == 'e'):
+		// chang, arrang, challeng
+		return true
+	case (last == 't' || last == 'r') && isVowel(prev) && prev != 'e' && prev != 'o' && n >= 3 && !isVowel(s[n-3]):
+		// innovat, operat, compar, requir, secur
+		return prev != 'i' || last == 'r'
+	}
+	return isShortCVC(s)
+}
+
+// isShortCVC は子音 + 母音 + 子音だけの短い語幹 (mak, hop, stor)。w, x, y は除く
+func isShortCVC(s string) bool {
+	n := len(s)
+	if n < 3 || n > 4 {
+		return false
+	}
+	c1, v, c2 := s[n-3], s[n-2], s[n-1]
+	if isVowel(c1) || !isVowel(v) || isVowel(c2) || strings.IndexByte("wxy", c2) >= 0 {
+		return false
+	}
+	// 語幹の中で母音はこの1つだけ
+	for i := 0; i < n-2; i++ {
+		if isVowel(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiou", c) >= 0
+}
+
+func hasVowel(s string) bool {
+	return strings.ContainsAny(s, "aeiouy")
+}
@@ -0,0 +1,89 @@
+package lemma
+
+import "testing"
+
+func TestLemmatize(t *testing.T) {
+	tests := []struct {
+		word, pos, expected string
+	}{
+		{"innovate", "verb", "innovate"},
+		{"innovated", "verb", "innovate"},
+		{"Innovating", "", "innovate"},
+		{"resolves", "verb", "resolve"},
+		{"resolved", "", "resolve"},
+		{"stopped", "verb", "stop"},
+		{"running", "verb", "run"},
+		{"making", "", "make"},
+		{"hoped", "", "hope"},
+		{"studies", "", "study"},
+		{"applied", "verb", "apply"},
+		{"waiting", "verb", "wait"},
+		{"visited", "", "visit"},
+		{"needed", "", "need"},
+		{"agreed", "", "agree"},
+		{"seeing", "", "see"},
+		{"used", "", "use"},
+		{"caused", "", "cause"},
+		{"changed", "", "change"},
+		{"managed", "", "manage"},
+		{"included", "", "include"},
+		{"determined", "verb", "determine"},
+		{"described", "", "describe"},
+		{"enabled", "", "enable"},
+		{"created", "", "create"},
+		{"treated", "", "treat"},
+		{"filled", "", "fill"},
+		{"processing", "", "process"},
+		{"went", "verb", "go"},
+		{"children", "noun", "child"},
+		{"engineers", "noun", "engineer"},
+		{"boxes", "noun", "box"},
+		{"watches", "", "watch"},
+		{"cases", "noun", "case"},
+		{"wolves", "noun", "wolf"},
+		{"news", "noun", "news"},
+		{"analysis", "noun", "analysis"},
+		{"meeting", "noun", "meeting"},
+		{"interested", "adjective", "interested"},
+		{"bigger", "adjective", "big"},
+		{"larger", "adjective", "large"},
+		{"happiest", "adjective", "happy"},
+		{"clever", "adjective", "clever"},
+		{"partner", "", "partner"},
+		{"tirelessly", "adverb", "tirelessly"},
+		{"during", "", "during"},
+		{"thing", "", "thing"},
+		{"took off", "verb", "take off"},
+		{"engineer's", "noun", "engineer"},
+	}
+	for _, tt := range tests {
+		if got := Lemmatize(tt.word, tt.pos); got != tt.expected {
+			t.Errorf("Lemmatize(%q, %q) = %q, expected %q", tt.word, tt.pos, got, tt.expected)
+		}
+	}
+}
+
+func TestLemmatizeIn(t *testing.T) {
+	tests := []struct {
+		lang, word, pos, expected string
+	}{
+		{"en", "resolved", "verb", "resolve"},
+		{"de", "Verträge", "noun", "Verträge"},
+		{"de", "vertrag", "noun", "Vertrag"},
+		{"de", "Partner", "", "Partner"},
+		{"de", "arbeitet", "verb", "arbeiten"},
+		{"de", "machst", "verb", "machen"},
+		{"de", "gemacht", "verb", "machen"},
+		{"de", "gearbeitet", "verb", "arbeiten"},
+		{"de", "ist", "verb", "sein"},
+		{"de", "abgeschlossen", "verb", "abgeschlossen"},
+		{"de", "neuen", "adjective", "neu"},
+		{"de", "schnelle", "adjective", "schnell"},
+		{"ja", " 契約を結ぶ ", "verb", "契約を結ぶ"},
+	}
+	for _, tt := range tests {
+		if got := LemmatizeIn(tt.lang, tt.word, tt.pos); got != tt.expected {
+			t.Errorf("LemmatizeIn(%q, %q, %q) = %q, expected %q", tt.lang, tt.word, tt.pos, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package occurrence
+
+import (
+	"strings"
+
+	"github.com/yomek33/newln/internal/pkg/lemma"
+)
+
+// Inflections は英語の原形から考えられる活用形を返す (原形を含む、すべて小文字)。
+// 規則変化は多めに作る。本文に現れない形は一致しないだけなので害はない。
+// 複数語の場合は最初の語だけ活用させる (take off -> took off)
+func Inflections(word string) []string {
+	fields := strings.Fields(strings.ToLower(word))
+	if len(fields) == 0 {
+		return nil
+	}
+	head, rest := fields[0], strings.Join(fields[1:], " ")
+
+	seen := make(map[string]bool)
+	var forms []string
+	add := func(form string) {
+		if rest != "" {
+			form += " " + rest
+		}
+		if !seen[form] {
+			seen[form] = true
+			forms = append(forms, form)
+		}
+	}
+
+	add(head)
+	for _, form := range lemma.IrregularForms(head) {
+		add(form)
+	}
+	for _, form := range regularForms(head) {
+		add(form)
+	}
+	return forms
+}
+
+// InflectionsIn は言語 lang の規則で活用形を返す。規則のない言語は小文字にした word だけ
+func InflectionsIn(lang, word string) []string {
+	switch lang {
+	case "", "en":
+		return Inflections(word)
+	case "de":
+		return germanInflections(word)
+	}
+	if w := strings.ToLower(strings.TrimSpace(word)); w != "" {
+		return []string{w}
+	}
+	return nil
+}
+
+// germanInflections はドイツ語の動詞の人称変化・過去分詞と、名詞・形容詞の語尾を付けた形を作る
+func germanInflections(word string) []string {
+	fields := strings.Fields(strings.ToLower(word))
+	if len(fields) == 0 {
+		return nil
+	}
+	head, rest := fields[0], strings.Join(fields[1:], " ")
+
+	seen := make(map[string]bool)
+	var forms []string
+	add := func(form string) {
+		if rest != "" {
+			form += " " + rest
+		}
+		if !seen[form] {
+			seen[form] = true
+			forms = append(forms, form)
+		}
+	}
+
+	add(head)
+	for _, form := range lemma.IrregularFormsIn("de", head) {
+		add(form)
+	}
+	if stem, ok := strings.CutSuffix(head, "en"); ok && len(stem) >= 2 {
+		// 語幹が t, d で終わる語 (arbeiten) は e を挟む形も作る
+		for _, ending := range []string{"", "e", "st", "t", "et", "est", "te", "ten", "test", "tet", "ete", "eten"} {
+			add(stem + ending)
+		}
+		add("ge" + stem + "t")
+		add("ge" + stem + "et")
+	}
+	for _, ending := range []string{"e", "en", "n", "s", "es", "er", "em", "ern"} {
+		add(head + ending)
+	}
+	return forms
+}
+
+func regularForms(w string) []string {
+	n := len(w)
+	if n < 2 {
+		return nil
+	}
+	last := w[n-1]
+	forms := []string{w + "'s", w + "’s"}
+
+	// 複数形・三単現
+	switch {
+	case strings.HasSuffix(w, "s"), strings.HasSuffix(w, "x"), strings.HasSuffix(w, "z"),
+		strings.HasSuffix(w, "ch"), strings.HasSuffix(w, "sh"), strings.HasSuffix(w, "o"):
+		forms = append(forms, w+"es")
+	case last == 'y' && !isVowel(w[n-2]):
+		forms = append(forms, w[:n-1]+"ies")
+	case strings.HasSuffix(w, "fe"):
+		forms = append(forms, w+"s", w[:n-2]+"ves")
+	case last == 'f':
+		forms = append(forms, w+"s", w[:n-1]+"ves")
+	default:
+		forms = append(forms, w+"s")
+	}
+
+	// 過去形・進行形・比較級
+	switch {
+	case strings.HasSuffix(w, "ie"):
+		forms = append(forms, w+"d", w[:n-2]+"ying", w+"r", w+"st")
+	case last == 'e':
+		forms = append(forms, w+"d", w[:n-1]+"ing", w+"r", w+"st")
+	case last == 'y' && !isVowel(w[n-2]):
+		forms = append(forms, w[:n-1]+"ied", w+"ing", w[:n-1]+"ier", w[:n-1]+"iest")
+	default:
+		forms = append(forms, w+"ed", w+"ing", w+"er", w+"est")
+		// stop -> stopped, run -> running
+		if isCVC(w) {
+			d := w + string(last)
+			forms = append(forms, d+"ed", d+"ing", d+"er", d+"est")
+		}
+	}
+	return forms
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiou", c) >= 0
+}
+
+// isCVC は子音 + 母音 + 子音で終わるか (w, x, y で終わるものは重ねない)
+func isCVC(w string) bool {
+	n := len(w)
+	if n < 3 {
+		return false
+	}
+	c1, v, c2 := w[n-3], w[n-2], w[n-1]
+	return !isVowel(c1) && isVowel(v) && !isVowel(c2) && strings.IndexByte("wxy", c2) < 0
+}
@@ -0,0 +1,191 @@
+// Package occurrence は本文中で単語 (活用形を含む) が現れる位置を探す
+package occurrence
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yomek33/newln/internal/pkg/language"
+	"github.com/yomek33/newln/internal/pkg/segment"
+)
+
+// Token は本文中の1語。Start/End は文字 (rune) 単位のオフセット
+type Token struct {
+	Text     string
+	Lower    string
+	Start    int
+	End      int
+	Sentence int
+
+	// content 上のバイト位置
+	from, to int
+}
+
+// Occurrence は見つかった位置。複数語なら最初の語の先頭から最後の語の末尾まで
+type Occurrence struct {
+	Start    int
+	End      int
+	Sentence int
+	Surface  string
+}
+
+// Index は1つの本文を語に分けたもの。同じ本文で何度も Find するときに使い回す
+type Index struct {
+	content string
+	lang    string
+	tokens  []Token
+}
+
+// NewIndex は英語の本文の Index を作る
+func NewIndex(content string) *Index {
+	return NewIndexIn(content, language.DefaultSource)
+}
+
+// NewIndexIn は言語 lang (ISO 639-1) の本文の Index を作る
+func NewIndexIn(content, lang string) *Index {
+	return &Index{content: content, lang: lang, tokens: Tokenize(content)}
+}
+
+func (idx *Index) Tokens() []Token {
+	return idx.tokens
+}
+
+// Tokenize は content を語に分け、文番号を振る
+func Tokenize(content string) []Token {
+	sentences := segment.Sentences(content)
+	sentenceOf := func(byteOffset int) int {
+		i := sort.Search(len(sentences), func(i int) bool { return sentences[i].Start > byteOffset })
+		if i == 0 {
+			return 0
+		}
+		return i - 1
+	}
+
+	var tokens []Token
+	runeIndex := 0
+	for i := 0; i < len(content); {
+		r, size := utf8.DecodeRuneInString(content[i:])
+		if !isWordRune(r) {
+			i += size
+			runeIndex++
+			continue
+		}
+
+		start, startRune := i, runeIndex
+		for i < len(content) {
+			r, size = utf8.DecodeRuneInString(content[i:])
+			if !isWordRune(r) && !isJoiner(content, i, r, size) {
+				break
+			}
+			i += size
+			runeIndex++
+		}
+		text := content[start:i]
+		tokens = append(tokens, Token{
+			Text:     text,
+			Lower:    strings.ToLower(text),
+			Start:    startRune,
+			End:      runeIndex,
+			Sentence: sentenceOf(start),
+			from:     start,
+			to:       i,
+		})
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// isJoiner は語中のアポストロフィとハイフン (don't, well-known) を語の一部として扱う
+func isJoiner(content string, i int, r rune, size int) bool {
+	if r != '\'' && r != '’' && r != '-' {
+		return false
+	}
+	if i+size >= len(content) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(content[i+size:])
+	return isWordRune(next)
+}
+
+// Find は word かその活用形が現れる位置をすべて返す。複数語は連続する語として探す。
+// 分かち書きしない言語は語の境界が分からないので文字列として探す
+func (idx *Index) Find(word string) []Occurrence {
+	if !language.Spaced(idx.lang) {
+		return idx.findSubstring(word)
+	}
+
+	var patterns [][]string
+	for _, form := range InflectionsIn(idx.lang, word) {
+		patterns = append(patterns, strings.Fields(form))
+	}
+
+	var occurrences []Occurrence
+	for i := range idx.tokens {
+		for _, pattern := range patterns {
+			if !idx.matchAt(i, pattern) {
+				continue
+			}
+			first, last := idx.tokens[i], idx.tokens[i+len(pattern)-1]
+			occurrences = append(occurrences, Occurrence{
+				Start:    first.Start,
+				End:      last.End,
+				Sentence: first.Sentence,
+				Surface:  idx.surface(first, last),
+			})
+			break
+		}
+	}
+	return occurrences
+}
+
+func (idx *Index) matchAt(i int, pattern []string) bool {
+	if len(pattern) == 0 || i+len(pattern) > len(idx.tokens) {
+		return false
+	}
+	for j, p := range pattern {
+		if idx.tokens[i+j].Lower != p {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *Index) surface(first, last Token) string {
+	return idx.content[first.from:last.to]
+}
+
+func (idx *Index) findSubstring(word string) []Occurrence {
+	word = strings.TrimSpace(word)
+	if word == "" {
+		return nil
+	}
+	sentences := segment.Sentences(idx.content)
+
+	var occurrences []Occurrence
+	for from := 0; ; {
+		i := strings.Index(idx.content[from:], word)
+		if i < 0 {
+			break
+		}
+		start := from + i
+		end := start + len(word)
+		sentence := sort.Search(len(sentences), func(i int) bool { return sentences[i].Start > start })
+		if sentence > 0 {
+			sentence--
+		}
+		startRune := utf8.RuneCountInString(idx.content[:start])
+		occurrences = append(occurrences, Occurrence{
+			Start:    startRune,
+			End:      startRune + utf8.RuneCountInString(word),
+			Sentence: sentence,
+			Surface:  idx.content[start:end],
+		})
+		from = end
+	}
+	return occurrences
+}
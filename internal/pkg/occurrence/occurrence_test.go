@@ -0,0 +1,92 @@
+package occurrence
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInflections(t *testing.T) {
+	tests := map[string][]string{
+		"resolve":  {"resolves", "resolved", "resolving"},
+		"stop":     {"stops", "stopped", "stopping"},
+		"study":    {"studies", "studied", "studying"},
+		"go":       {"goes", "went", "gone"},
+		"box":      {"boxes"},
+		"child":    {"children"},
+		"take off": {"took off", "taking off"},
+	}
+	for word, expected := range tests {
+		forms := strings.Join(Inflections(word), ",")
+		for _, form := range expected {
+			if !strings.Contains(","+forms+",", ","+form+",") {
+				t.Errorf("Inflections(%q) = %s, missing %q", word, forms, form)
+			}
+		}
+	}
+}
+
+func TestIndexFind(t *testing.T) {
+	content := "Engineers resolve issues. The issue was resolved quickly! Résumé: they’re resolving it."
+	idx := NewIndex(content)
+
+	occurrences := idx.Find("resolve")
+	if len(occurrences) != 3 {
+		t.Fatalf("expected 3 occurrences, got %+v", occurrences)
+	}
+	expectedSentences := []int{0, 1, 2}
+	runes := []rune(content)
+	for i, o := range occurrences {
+		if o.Sentence != expectedSentences[i] {
+			t.Errorf("occurrence %d sentence = %d, expected %d", i, o.Sentence, expectedSentences[i])
+		}
+		if string(runes[o.Start:o.End]) != o.Surface {
+			t.Errorf("occurrence %d offsets %d-%d do not match surface %q", i, o.Start, o.End, o.Surface)
+		}
+	}
+	if occurrences[2].Surface != "resolving" {
+		t.Errorf("unexpected surface %q", occurrences[2].Surface)
+	}
+
+	// 複数語
+	if got := idx.Find("the issue"); len(got) != 1 || got[0].Surface != "The issue" {
+		t.Errorf("unexpected multi-word occurrences: %+v", got)
+	}
+	// 語の一部には一致しない
+	if got := idx.Find("solve"); len(got) != 0 {
+		t.Errorf("expected no occurrences of a substring, got %+v", got)
+	}
+}
+
+func TestTokenize_Joiners(t *testing.T) {
+	tokens := Tokenize("A well-known engineer's plan - done.")
+	var texts []string
+	for _, tok := range tokens {
+		texts = append(texts, tok.Text)
+	}
+	if got := strings.Join(texts, "|"); got != "A|well-known|engineer's|plan|done" {
+		t.Errorf("Tokenize() = %s", got)
+	}
+}
+
+func TestIndex_FindIn(t *testing.T) {
+	idx := NewIndexIn("Wir arbeiten jeden Tag. Sie arbeitet am Vertrag und hat gestern gearbeitet.", "de")
+	var surfaces []string
+	for _, o := range idx.Find("arbeiten") {
+		surfaces = append(surfaces, o.Surface)
+	}
+	if got := strings.Join(surfaces, "|"); got != "arbeiten|arbeitet|gearbeitet" {
+		t.Errorf("unexpected German occurrences: %s", got)
+	}
+
+	content := "チームは契約を結ぶために働いた。新しい契約を結ぶ。"
+	runes := []rune(content)
+	occurrences := NewIndexIn(content, "ja").Find("契約を結ぶ")
+	if len(occurrences) != 2 {
+		t.Fatalf("expected 2 Japanese occurrences, got %+v", occurrences)
+	}
+	for i, o := range occurrences {
+		if string(runes[o.Start:o.End]) != "契約を結ぶ" || o.Sentence != i {
+			t.Errorf("unexpected occurrence %d: %+v", i, o)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package scheduler は定期的なジョブを決まった間隔で動かす。
+// 同じジョブは重ねて動かさず、前の実行が終わってから次の間隔を待つ
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yomek33/newln/internal/logger"
+)
+
+// Job は定期的に動かす処理。Run のエラーはログに残し、次の実行は続ける
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    []Job
+	started bool
+	wg      sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add はジョブを登録する。Start の後には登録できない
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Interval <= 0 || job.Run == nil {
+		return fmt.Errorf("invalid job %q", job.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("scheduler already started, cannot add %q", job.Name)
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+// Start はジョブごとに goroutine を起こし、すぐに1回動かしてから Interval ごとに動かす。
+// ctx が終わると止まる。止まるのを待つには Wait を呼ぶ
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+}
+
+// Wait は ctx が終わってすべてのジョブが止まるまで待つ
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		runOnce(ctx, job)
+		timer.Reset(job.Interval)
+	}
+}
+
+// runOnce はジョブを1回動かす。panic しても他のジョブと次の実行は止めない
+func runOnce(ctx context.Context, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("❌ Scheduled job %s panicked: %v", job.Name, r)
+		}
+	}()
+	if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+		logger.Errorf("❌ Scheduled job %s failed: %v", job.Name, err)
+	}
+}
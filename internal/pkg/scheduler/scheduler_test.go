@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler_RunsJobsUntilCancelled(t *testing.T) {
+	var ticks, failures, panics atomic.Int32
+	s := New()
+	jobs := []Job{
+		{Name: "tick", Interval: 5 * time.Millisecond, Run: func(ctx context.Context) error {
+			ticks.Add(1)
+			return nil
+		}},
+		{Name: "fail", Interval: 5 * time.Millisecond, Run: func(ctx context.Context) error {
+			failures.Add(1)
+			return errors.New("boom")
+		}},
+		{Name: "panic", Interval: 5 * time.Millisecond, Run: func(ctx context.Context) error {
+			panics.Add(1)
+			panic("boom")
+		}},
+	}
+	for _, job := range jobs {
+		if err := s.Add(job); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for ticks.Load() < 3 || failures.Load() < 3 || panics.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not keep running: ticks %d, failures %d, panics %d", ticks.Load(), failures.Load(), panics.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	s.Wait()
+
+	stopped := ticks.Load()
+	time.Sleep(20 * time.Millisecond)
+	if ticks.Load() != stopped {
+		t.Errorf("job kept running after cancel")
+	}
+	if err := s.Add(Job{Name: "late", Interval: time.Second, Run: func(context.Context) error { return nil }}); err == nil {
+		t.Errorf("expected Add after Start to fail")
+	}
+}
+
+func TestScheduler_InvalidJob(t *testing.T) {
+	s := New()
+	if err := s.Add(Job{Name: "zero", Run: func(context.Context) error { return nil }}); err == nil {
+		t.Errorf("expected error for zero interval")
+	}
+	if err := s.Add(Job{Name: "nil", Interval: time.Second}); err == nil {
+		t.Errorf("expected error for nil Run")
+	}
+}
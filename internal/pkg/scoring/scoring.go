@@ -0,0 +1,268 @@
+// Package scoring は単語・フレーズの難易度 (level) と重要度 (importance) を決める。
+// 一般的な使用頻度、モデルが返した CEFR レベル、教材中の出現回数を組み合わせる
+package scoring
+
+import (
+	"bufio"
+	_ "embed"
+	"strings"
+	"sync"
+)
+
+// word_level / difficulty_level の値
+const (
+	LevelEasy         = "easy"
+	LevelIntermediate = "intermediate"
+	LevelAdvanced     = "advanced"
+)
+
+// importance_level の値
+const (
+	ImportanceLow    = "low"
+	ImportanceMedium = "medium"
+	ImportanceHigh   = "high"
+)
+
+// 英語の高頻度語リスト (1行1語、頻度の高い順)
+//
+//go:embed data/frequency_en.txt
+var frequencyData string
+
+// Thresholds は採点の重みと閾値
+type Thresholds struct {
+	// CEFR が分からないとき、頻度順位がこれ以下なら easy
+	EasyMaxRank int `json:"easy_max_rank"`
+	// CEFR が分からないとき、頻度順位がこれ以下なら intermediate。リストにない語は advanced
+	IntermediateMaxRank int `json:"intermediate_max_rank"`
+	// 出現回数がこれ以上なら出現の点数は満点
+	OccurrenceCap int `json:"occurrence_cap"`
+
+	FrequencyWeight  float64 `json:"frequency_weight"`
+	OccurrenceWeight float64 `json:"occurrence_weight"`
+	LevelWeight      float64 `json:"level_weight"`
+
+	// 重要度の点数 (0〜1) がこれ以上なら high / medium
+	HighImportance   float64 `json:"high_importance"`
+	MediumImportance float64 `json:"medium_importance"`
+}
+
+// DefaultThresholds は SCORING_JSON が未設定のときに使う
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		EasyMaxRank:         300,
+		IntermediateMaxRank: 800,
+		OccurrenceCap:       5,
+		FrequencyWeight:     0.35,
+		OccurrenceWeight:    0.4,
+		LevelWeight:         0.25,
+		HighImportance:      0.6,
+		MediumImportance:    0.35,
+	}
+}
+
+// Input は採点する語の情報。CEFR と Level は分かる方だけでよい
+type Input struct {
+	Text        string
+	CEFR        string
+	Level       string
+	Occurrences int
+	// 教材の言語。頻度リストは英語なので、空と "en" 以外では順位を使わない
+	Language string
+}
+
+type Result struct {
+	Level      string
+	Importance string
+	// 重要度の点数 (0〜1)
+	Score float64
+	// 頻度リスト上の順位 (1始まり)。リストにない語は 0
+	Rank int
+}
+
+type Scorer struct {
+	thresholds Thresholds
+	ranks      map[string]int
+	size       int
+}
+
+// New は埋め込みの頻度リストを使う Scorer を作る。ゼロ値の Thresholds なら DefaultThresholds
+func New(thresholds Thresholds) *Scorer {
+	if thresholds == (Thresholds{}) {
+		thresholds = DefaultThresholds()
+	}
+	ranks := parseFrequencyList(frequencyData)
+	return &Scorer{thresholds: thresholds, ranks: ranks, size: len(ranks)}
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultScorer *Scorer
+)
+
+// Default は DefaultThresholds の Scorer を返す
+func Default() *Scorer {
+	defaultOnce.Do(func() {
+		defaultScorer = New(DefaultThresholds())
+	})
+	return defaultScorer
+}
+
+func parseFrequencyList(data string) map[string]int {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		// 重複している語は最初の順位を使う
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = len(ranks) + 1
+		}
+	}
+	return ranks
+}
+
+// Rank は word の頻度順位を返す。複数語なら最も珍しい語の順位、リストにない語を含めば 0
+func (s *Scorer) Rank(word string) int {
+	fields := strings.Fields(strings.ToLower(word))
+	if len(fields) == 0 {
+		return 0
+	}
+	worst := 0
+	for _, f := range fields {
+		rank, ok := s.lookup(strings.Trim(f, ".,;:!?\"'()"))
+		if !ok {
+			return 0
+		}
+		if rank > worst {
+			worst = rank
+		}
+	}
+	return worst
+}
+
+// lookup は活用形なら簡単な語尾の除去で原形を探す (engineers -> engineer)
+func (s *Scorer) lookup(word string) (int, bool) {
+	if rank, ok := s.ranks[word]; ok {
+		return rank, true
+	}
+	for _, base := range baseCandidates(word) {
+		if rank, ok := s.ranks[base]; ok {
+			return rank, true
+		}
+	}
+	return 0, false
+}
+
+func baseCandidates(word string) []string {
+	var bases []string
+	for _, rule := range []struct{ suffix, replace string }{
+		{"ies", "y"}, {"ied", "y"}, {"es", ""}, {"s", ""},
+		{"ed", "e"}, {"ed", ""}, {"ing", "e"}, {"ing", ""}, {"ly", ""},
+	} {
+		if base, ok := strings.CutSuffix(word, rule.suffix); ok && len(base) >= 2 {
+			bases = append(bases, base+rule.replace)
+		}
+	}
+	return bases
+}
+
+func (s *Scorer) Score(in Input) Result {
+	t := s.thresholds
+	rank := 0
+	if in.Language == "" || in.Language == "en" {
+		rank = s.Rank(in.Text)
+	}
+
+	level, ok := LevelFromCEFR(in.CEFR)
+	if !ok {
+		level, ok = normalizeLevel(in.Level)
+	}
+	if !ok {
+		level = s.levelFromRank(rank)
+	}
+
+	score := t.FrequencyWeight*s.frequencyScore(rank) +
+		t.OccurrenceWeight*occurrenceScore(in.Occurrences, t.OccurrenceCap) +
+		t.LevelWeight*levelScore(level)
+	if total := t.FrequencyWeight + t.OccurrenceWeight + t.LevelWeight; total > 0 {
+		score /= total
+	}
+
+	importance := ImportanceLow
+	switch {
+	case score >= t.HighImportance:
+		importance = ImportanceHigh
+	case score >= t.MediumImportance:
+		importance = ImportanceMedium
+	}
+	return Result{Level: level, Importance: importance, Score: score, Rank: rank}
+}
+
+func (s *Scorer) levelFromRank(rank int) string {
+	switch {
+	case rank == 0:
+		return LevelAdvanced
+	case rank <= s.thresholds.EasyMaxRank:
+		return LevelEasy
+	case rank <= s.thresholds.IntermediateMaxRank:
+		return LevelIntermediate
+	default:
+		return LevelAdvanced
+	}
+}
+
+// frequencyScore はよく使われる語ほど高い。リストにない語も覚える価値はあるので 0 にはしない
+func (s *Scorer) frequencyScore(rank int) float64 {
+	if rank == 0 || s.size == 0 {
+		return 0.2
+	}
+	return 1 - float64(rank-1)/float64(s.size)
+}
+
+func occurrenceScore(occurrences, limit int) float64 {
+	if limit <= 0 {
+		limit = 1
+	}
+	if occurrences > limit {
+		occurrences = limit
+	}
+	if occurrences < 0 {
+		occurrences = 0
+	}
+	return float64(occurrences) / float64(limit)
+}
+
+// levelScore は中級の学習者にとっての覚えどき。易しすぎる語は低い
+func levelScore(level string) float64 {
+	switch level {
+	case LevelEasy:
+		return 0.3
+	case LevelAdvanced:
+		return 0.7
+	default:
+		return 1
+	}
+}
+
+// LevelFromCEFR は A1/A2 -> easy, B1/B2 -> intermediate, C1/C2 -> advanced
+func LevelFromCEFR(cefr string) (string, bool) {
+	switch strings.ToUpper(strings.TrimSpace(cefr)) {
+	case "A1", "A2":
+		return LevelEasy, true
+	case "B1", "B2":
+		return LevelIntermediate, true
+	case "C1", "C2":
+		return LevelAdvanced, true
+	}
+	return "", false
+}
+
+func normalizeLevel(level string) (string, bool) {
+	switch l := strings.ToLower(strings.TrimSpace(level)); l {
+	case LevelEasy, LevelIntermediate, LevelAdvanced:
+		return l, true
+	}
+	return "", false
+}
@@ -0,0 +1,75 @@
+package scoring
+
+import "testing"
+
+func TestLevelFromCEFR(t *testing.T) {
+	tests := map[string]string{
+		"A1": LevelEasy, "a2": LevelEasy,
+		"B1": LevelIntermediate, " B2 ": LevelIntermediate,
+		"C1": LevelAdvanced, "C2": LevelAdvanced,
+	}
+	for cefr, expected := range tests {
+		if level, ok := LevelFromCEFR(cefr); !ok || level != expected {
+			t.Errorf("LevelFromCEFR(%q) = %q, %v; expected %q", cefr, level, ok, expected)
+		}
+	}
+	if _, ok := LevelFromCEFR("expert"); ok {
+		t.Error("expected unknown CEFR to be rejected")
+	}
+}
+
+func TestScorer_Rank(t *testing.T) {
+	s := Default()
+	if s.Rank("the") != 1 {
+		t.Errorf("Rank(the) = %d, expected 1", s.Rank("the"))
+	}
+	if s.Rank("engineers") != s.Rank("engineer") || s.Rank("engineer") == 0 {
+		t.Errorf("expected inflected form to share the rank: %d vs %d", s.Rank("engineers"), s.Rank("engineer"))
+	}
+	if s.Rank("used") != s.Rank("use") {
+		t.Errorf("Rank(used) = %d, expected %d", s.Rank("used"), s.Rank("use"))
+	}
+	if s.Rank("serendipity") != 0 {
+		t.Error("expected unknown word to have rank 0")
+	}
+	// 複数語は最も珍しい語の順位
+	if s.Rank("close a deal") != s.Rank("deal") {
+		t.Errorf("Rank(close a deal) = %d, expected %d", s.Rank("close a deal"), s.Rank("deal"))
+	}
+}
+
+func TestScorer_Score(t *testing.T) {
+	s := Default()
+
+	tests := []struct {
+		name       string
+		in         Input
+		level      string
+		importance string
+	}{
+		{"cefr wins over frequency", Input{Text: "the", CEFR: "C1"}, LevelAdvanced, ImportanceMedium},
+		{"frequent word repeated in the material", Input{Text: "resolve", CEFR: "B2", Occurrences: 4}, LevelIntermediate, ImportanceHigh},
+		{"rare word seen once", Input{Text: "serendipity", Occurrences: 1}, LevelAdvanced, ImportanceLow},
+		{"difficulty as level", Input{Text: "in operation", Level: "Intermediate", Occurrences: 2}, LevelIntermediate, ImportanceMedium},
+		{"level from frequency", Input{Text: "people", Occurrences: 1}, LevelEasy, ImportanceMedium},
+		{"no frequency list for other languages", Input{Text: "people", Occurrences: 1, Language: "de"}, LevelAdvanced, ImportanceLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := s.Score(tt.in)
+			if r.Level != tt.level || r.Importance != tt.importance {
+				t.Errorf("Score(%+v) = %+v; expected level %q and importance %q", tt.in, r, tt.level, tt.importance)
+			}
+		})
+	}
+}
+
+func TestScorer_CustomThresholds(t *testing.T) {
+	thresholds := DefaultThresholds()
+	thresholds.HighImportance = 0.1
+	s := New(thresholds)
+
+	if r := s.Score(Input{Text: "serendipity", Occurrences: 1}); r.Importance != ImportanceHigh {
+		t.Errorf("expected lowered threshold to yield high importance, got %+v", r)
+	}
+}
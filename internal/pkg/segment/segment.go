@@ -0,0 +1,207 @@
+// Package segment は長いテキストを段落・文の境界を保ったまま重なり付きの区間に分ける
+package segment
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Segment は元テキストの [Start, End) (バイト位置) の区間
+type Segment struct {
+	Index int
+	Start int
+	End   int
+	Text  string
+}
+
+type Options struct {
+	// MaxChars は1区間の最大文字数 (rune)
+	MaxChars int
+	// Overlap は前の区間の末尾から引き継ぐ文字数の上限。文単位で引き継ぐ
+	Overlap int
+}
+
+func DefaultOptions() Options {
+	return Options{MaxChars: 6000, Overlap: 400}
+}
+
+type span struct{ start, end int }
+
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+// Split は text を MaxChars 以下の区間に分ける。短いテキストはそのまま1区間になる
+func Split(text string, opts Options) []Segment {
+	if opts.MaxChars <= 0 {
+		opts = DefaultOptions()
+	}
+	units := splitUnits(text, opts.MaxChars)
+	if len(units) == 0 {
+		return nil
+	}
+
+	var segments []Segment
+	for i := 0; i < len(units); {
+		j := i + 1
+		for j < len(units) && runeLen(text, units[i].start, units[j].end) <= opts.MaxChars {
+			j++
+		}
+		start, end := units[i].start, units[j-1].end
+		segments = append(segments, Segment{Index: len(segments), Start: start, End: end, Text: text[start:end]})
+		if j == len(units) {
+			break
+		}
+
+		// 末尾の文を次の区間の先頭に重ねる
+		k := j
+		for k-1 > i && runeLen(text, units[k-1].start, units[j-1].end) <= opts.Overlap {
+			k--
+		}
+		i = k
+	}
+	return segments
+}
+
+// splitUnits は段落、長すぎる段落は文、長すぎる文は単語の並びに分ける
+func splitUnits(text string, max int) []span {
+	var units []span
+	prev := 0
+	breaks := paragraphBreak.FindAllStringIndex(text, -1)
+	breaks = append(breaks, []int{len(text), len(text)})
+	for _, b := range breaks {
+		paragraph, ok := trim(text, span{prev, b[0]})
+		prev = b[1]
+		if !ok {
+			continue
+		}
+		if runeLen(text, paragraph.start, paragraph.end) <= max {
+			units = append(units, paragraph)
+			continue
+		}
+		for _, sentence := range sentenceSpans(text, paragraph.start, paragraph.end) {
+			if runeLen(text, sentence.start, sentence.end) <= max {
+				units = append(units, sentence)
+				continue
+			}
+			units = append(units, splitWords(text, sentence, max)...)
+		}
+	}
+	return units
+}
+
+// Sentences は text を文に分ける
+func Sentences(text string) []Segment {
+	var sentences []Segment
+	for _, s := range sentenceSpans(text, 0, len(text)) {
+		sentences = append(sentences, Segment{Index: len(sentences), Start: s.start, End: s.end, Text: text[s.start:s.end]})
+	}
+	return sentences
+}
+
+// sentenceSpans は [start, end) を文末記号で区切る
+func sentenceSpans(text string, start, end int) []span {
+	var sentences []span
+	from := start
+	for i := start; i < end; {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if !isSentenceEnd(r) {
+			continue
+		}
+		// 連続する終止符や閉じ括弧・引用符は文に含める
+		for i < end {
+			next, nextSize := utf8.DecodeRuneInString(text[i:])
+			if !isSentenceEnd(next) && !strings.ContainsRune(`"')]”’」』`, next) {
+				break
+			}
+			i += nextSize
+		}
+		// 英語などは後ろに空白があり、小文字で続かない場合だけ文末とみなす (e.g. や 3.14 を切らない)
+		if i < end && r < 0x3000 && !endsSentence(text[i:end]) {
+			continue
+		}
+		if s, ok := trim(text, span{from, i}); ok {
+			sentences = append(sentences, s)
+		}
+		from = i
+	}
+	if s, ok := trim(text, span{from, end}); ok {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+func endsSentence(rest string) bool {
+	next, _ := utf8.DecodeRuneInString(rest)
+	if !unicode.IsSpace(next) {
+		return false
+	}
+	following := strings.TrimLeftFunc(rest, unicode.IsSpace)
+	first, _ := utf8.DecodeRuneInString(following)
+	return following == "" || !unicode.IsLower(first)
+}
+
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '.', '!', '?', '。', '！', '？':
+		return true
+	}
+	return false
+}
+
+// splitWords は空白で区切って max 以下にまとめる。1語が max を超えるときは文字数で切る
+func splitWords(text string, s span, max int) []span {
+	var pieces []span
+	current := span{-1, -1}
+	for i := s.start; i < s.end; {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		wordStart := i
+		for i < s.end {
+			r, size = utf8.DecodeRuneInString(text[i:])
+			if unicode.IsSpace(r) || (i > wordStart && runeLen(text, wordStart, i) >= max) {
+				break
+			}
+			i += size
+		}
+		switch {
+		case current.start < 0:
+			current = span{wordStart, i}
+		case runeLen(text, current.start, i) <= max:
+			current.end = i
+		default:
+			pieces = append(pieces, current)
+			current = span{wordStart, i}
+		}
+	}
+	if current.start >= 0 {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+func trim(text string, s span) (span, bool) {
+	for s.start < s.end {
+		r, size := utf8.DecodeRuneInString(text[s.start:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		s.start += size
+	}
+	for s.end > s.start {
+		r, size := utf8.DecodeLastRuneInString(text[:s.end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		s.end -= size
+	}
+	return s, s.start < s.end
+}
+
+func runeLen(text string, start, end int) int {
+	return utf8.RuneCountInString(text[start:end])
+}
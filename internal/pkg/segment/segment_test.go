@@ -0,0 +1,87 @@
+package segment
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit_ShortTextIsOneSegment(t *testing.T) {
+	text := "  One sentence. Two sentences.  "
+	segments := Split(text, Options{MaxChars: 100, Overlap: 10})
+	if len(segments) != 1 || segments[0].Text != "One sentence. Two sentences." {
+		t.Fatalf("unexpected segments: %+v", segments)
+	}
+}
+
+func TestSplit_ParagraphsWithOverlap(t *testing.T) {
+	paragraphs := []string{
+		strings.Repeat("a", 40) + ".",
+		strings.Repeat("b", 40) + ".",
+		strings.Repeat("c", 40) + ".",
+	}
+	text := strings.Join(paragraphs, "\n\n")
+
+	segments := Split(text, Options{MaxChars: 90, Overlap: 45})
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 segments, got %d: %+v", len(segments), segments)
+	}
+	if segments[0].Text != paragraphs[0]+"\n\n"+paragraphs[1] {
+		t.Errorf("unexpected first segment: %q", segments[0].Text)
+	}
+	// 2段落目が重なって次の区間の先頭になる
+	if segments[1].Text != paragraphs[1]+"\n\n"+paragraphs[2] {
+		t.Errorf("unexpected second segment: %q", segments[1].Text)
+	}
+	for _, s := range segments {
+		if text[s.Start:s.End] != s.Text {
+			t.Errorf("segment %d offsets do not match its text", s.Index)
+		}
+	}
+}
+
+func TestSplit_LongParagraphSplitsOnSentences(t *testing.T) {
+	var sentences []string
+	for i := 0; i < 20; i++ {
+		sentences = append(sentences, "The engineers resolved the issue quickly.")
+	}
+	text := strings.Join(sentences, " ")
+
+	segments := Split(text, Options{MaxChars: 100, Overlap: 0})
+	if len(segments) < 2 {
+		t.Fatalf("expected several segments, got %d", len(segments))
+	}
+	covered := 0
+	for _, s := range segments {
+		if n := utf8.RuneCountInString(s.Text); n > 100 {
+			t.Errorf("segment %d has %d chars", s.Index, n)
+		}
+		if !strings.HasSuffix(s.Text, ".") {
+			t.Errorf("segment %d does not end on a sentence boundary: %q", s.Index, s.Text)
+		}
+		covered += strings.Count(s.Text, "resolved")
+	}
+	if covered != 20 {
+		t.Errorf("expected every sentence exactly once without overlap, got %d", covered)
+	}
+}
+
+func TestSplit_HardSplitsLongWords(t *testing.T) {
+	text := strings.Repeat("x", 250)
+	segments := Split(text, Options{MaxChars: 100})
+	if len(segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(segments))
+	}
+}
+
+func TestSentences(t *testing.T) {
+	text := `He said "Stop!" and left. Pi is 3.14 today. 今日は晴れ。明日は雨？`
+	var got []string
+	for _, s := range Sentences(text) {
+		got = append(got, s.Text)
+	}
+	expected := []string{`He said "Stop!" and left.`, "Pi is 3.14 today.", "今日は晴れ。", "明日は雨？"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Sentences() = %q, expected %q", got, expected)
+	}
+}
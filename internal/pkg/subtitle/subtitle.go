@@ -0,0 +1,245 @@
+// Package subtitle は SRT / WebVTT の字幕を読み、台詞だけのテキストと各キューの位置を作る
+package subtitle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"html"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	FormatSRT = "srt"
+	FormatVTT = "vtt"
+)
+
+var ErrNoCues = errors.New("no subtitle cues found")
+
+// キューの間がこれ以上空いたら段落を分ける
+const paragraphGap = 2 * time.Second
+
+// Cue は字幕の1つの表示。Text は整えた台詞
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Span は Build で作った本文の中のキューの位置。オフセットは文字 (rune) 単位で [StartOffset, EndOffset)
+type Span struct {
+	Cue
+	Index       int
+	StartOffset int
+	EndOffset   int
+}
+
+var (
+	timingLine = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})`)
+	markupTag  = regexp.MustCompile(`</?[a-zA-Z][^>]*>|<\d+:\d{2}[:.\d]*>`)
+	// ASS の上書き ({\an8} など)
+	assOverride = regexp.MustCompile(`\{\\[^}]*\}`)
+	// [Music] や (applause) のような音の説明
+	soundCue   = regexp.MustCompile(`^\s*[\[(][^\])]*[\])]\s*$|^[\s♪♫#]*$`)
+	soundInner = regexp.MustCompile(`[\[(](?:(?i:music|applause|laughter|laughs|cheering|sighs|silence|inaudible|noise)|[A-Z\s]+)[\])]`)
+	// 行頭の話者 ("JOHN:" "- ")
+	speakerPrefix = regexp.MustCompile(`^(?:-\s*|[A-Z][A-Z .'-]{1,30}:\s+)`)
+)
+
+// Detect は拡張子と先頭の "WEBVTT" で形式を決める。分からなければ空文字列
+func Detect(filename string, data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".vtt":
+		return FormatVTT
+	case ".srt":
+		return FormatSRT
+	}
+	if bytes.HasPrefix(data, []byte("WEBVTT")) {
+		return FormatVTT
+	}
+	if timingLine.Match(firstTiming(data)) {
+		return FormatSRT
+	}
+	return ""
+}
+
+func firstTiming(data []byte) []byte {
+	for _, line := range bytes.SplitN(data, []byte("\n"), 4) {
+		if bytes.Contains(line, []byte("-->")) {
+			return line
+		}
+	}
+	return nil
+}
+
+// Parse は SRT と WebVTT のキューを読む。どちらも空行で区切ったブロックの時間の行を探して読む
+func Parse(data []byte) ([]Cue, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		data = []byte(strings.ToValidUTF8(string(data), ""))
+	}
+	var cues []Cue
+	var current *Cue
+	var lines []string
+	skipBlock := false
+	flush := func() {
+		if current != nil {
+			if text := cleanCue(lines); text != "" {
+				current.Text = text
+				cues = append(cues, *current)
+			}
+		}
+		current, lines, skipBlock = nil, nil, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if skipBlock {
+			continue
+		}
+		if current == nil {
+			// WebVTT のヘッダー・コメント・スタイルは飛ばす
+			if strings.HasPrefix(line, "WEBVTT") || strings.HasPrefix(line, "NOTE") || line == "STYLE" || line == "REGION" {
+				skipBlock = true
+				continue
+			}
+			if m := timingLine.FindStringSubmatch(line); m != nil {
+				start, err1 := parseTimestamp(m[1])
+				end, err2 := parseTimestamp(m[2])
+				if err1 == nil && err2 == nil && end >= start {
+					current = &Cue{Start: start, End: end}
+				} else {
+					skipBlock = true
+				}
+			}
+			// それ以外 (SRT の番号や WebVTT の ID) は読み飛ばす
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 {
+		return nil, ErrNoCues
+	}
+	return dedupeRollingCues(cues), nil
+}
+
+// parseTimestamp は "01:02:03,456" "02:03.456" を読む
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	clock, fraction, _ := strings.Cut(s, ".")
+	parts := strings.Split(clock, ":")
+	var total time.Duration
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, err
+		}
+		total = total*60 + time.Duration(n)
+	}
+	total *= time.Second
+	for len(fraction) < 3 {
+		fraction += "0"
+	}
+	ms, err := strconv.Atoi(fraction[:3])
+	if err != nil {
+		return 0, err
+	}
+	return total + time.Duration(ms)*time.Millisecond, nil
+}
+
+// cleanCue はタグ・音の説明・話者名を外し、行を1つにつなぐ
+func cleanCue(lines []string) string {
+	var kept []string
+	for _, line := range lines {
+		line = assOverride.ReplaceAllString(line, "")
+		line = markupTag.ReplaceAllString(line, "")
+		line = html.UnescapeString(line)
+		line = soundInner.ReplaceAllString(line, "")
+		line = strings.Trim(line, " \t♪♫")
+		if soundCue.MatchString(line) {
+			continue
+		}
+		line = speakerPrefix.ReplaceAllString(line, "")
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// dedupeRollingCues は自動字幕のように前のキューの文を繰り返すキューから重なりを除く
+func dedupeRollingCues(cues []Cue) []Cue {
+	result := cues[:0]
+	prev := ""
+	for _, cue := range cues {
+		text := cue.Text
+		if len(result) > 0 {
+			switch {
+			case text == prev:
+				last := &result[len(result)-1]
+				last.End = max(last.End, cue.End)
+				continue
+			case strings.HasPrefix(text, prev+" "):
+				cue.Text = strings.TrimSpace(strings.TrimPrefix(text, prev))
+			}
+		}
+		prev = text
+		result = append(result, cue)
+	}
+	return result
+}
+
+// Build はキューをつないで本文を作る。間の空いたキューで段落を分ける
+func Build(cues []Cue) (string, []Span) {
+	var sb strings.Builder
+	spans := make([]Span, 0, len(cues))
+	offset := 0
+	for i, cue := range cues {
+		if i > 0 {
+			sep := " "
+			if cue.Start-cues[i-1].End >= paragraphGap {
+				sep = "\n\n"
+			}
+			sb.WriteString(sep)
+			offset += utf8.RuneCountInString(sep)
+		}
+		length := utf8.RuneCountInString(cue.Text)
+		spans = append(spans, Span{Cue: cue, Index: i, StartOffset: offset, EndOffset: offset + length})
+		sb.WriteString(cue.Text)
+		offset += length
+	}
+	return sb.String(), spans
+}
+
+// Find は offset (文字単位) を含むキューを返す。spans は StartOffset の順
+func Find(spans []Span, offset int) (Span, bool) {
+	lo, hi := 0, len(spans)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if spans[mid].EndOffset <= offset {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(spans) && spans[lo].StartOffset <= offset {
+		return spans[lo], true
+	}
+	return Span{}, false
+}
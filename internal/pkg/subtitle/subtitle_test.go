@@ -0,0 +1,95 @@
+package subtitle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSRT(t *testing.T) {
+	data := "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,500\r\n<i>Hello there.</i>\r\n\r\n" +
+		"2\r\n00:00:02,600 --> 00:00:04,000\r\n{\\an8}JOHN: How are\r\nyou &amp; Mary?\r\n\r\n" +
+		"3\r\n00:00:04,000 --> 00:00:05,000\r\n[Music]\r\n♪ ♪\r\n\r\n" +
+		"4\r\n00:00:08,000 --> 00:00:09,250\r\n- Fine, thanks. (LAUGHS)\r\n"
+	if got := Detect("episode.srt", []byte(data)); got != FormatSRT {
+		t.Fatalf("Detect = %q", got)
+	}
+	cues, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Cue{
+		{Start: time.Second, End: 2500 * time.Millisecond, Text: "Hello there."},
+		{Start: 2600 * time.Millisecond, End: 4 * time.Second, Text: "How are you & Mary?"},
+		{Start: 8 * time.Second, End: 9250 * time.Millisecond, Text: "Fine, thanks."},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("unexpected cues: %+v", cues)
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, cues[i], want[i])
+		}
+	}
+}
+
+func TestParseVTT(t *testing.T) {
+	data := "WEBVTT - lesson\nKind: captions\n\nNOTE this is\na comment\n\nSTYLE\n::cue { color: red }\n\n" +
+		"intro\n00:01.000 --> 00:02.000 align:start\n<v Teacher>Good <c.yellow>morning</c></v>\n\n" +
+		"01:00:02.000 --> 01:00:03.500\nGood morning<00:00:02.500> everyone\n\n" +
+		"01:00:03.500 --> 01:00:04.000\nGood morning everyone\n"
+	if got := Detect("lesson.txt", []byte(data)); got != FormatVTT {
+		t.Fatalf("Detect = %q", got)
+	}
+	cues, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(cues) != 2 {
+		t.Fatalf("unexpected cues: %+v", cues)
+	}
+	if cues[0].Text != "Good morning" || cues[0].Start != time.Second {
+		t.Errorf("unexpected first cue: %+v", cues[0])
+	}
+	// 前のキューを繰り返す部分は除き、同じキューはまとめる
+	if cues[1].Text != "everyone" || cues[1].Start != time.Hour+2*time.Second || cues[1].End != time.Hour+4*time.Second {
+		t.Errorf("unexpected second cue: %+v", cues[1])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse([]byte("WEBVTT\n\nNOTE nothing here\n")); !errors.Is(err, ErrNoCues) {
+		t.Errorf("expected ErrNoCues, got %v", err)
+	}
+	if got := Detect("notes.txt", []byte("just some text")); got != "" {
+		t.Errorf("Detect = %q", got)
+	}
+}
+
+func TestBuildAndFind(t *testing.T) {
+	cues := []Cue{
+		{Start: 0, End: time.Second, Text: "Ça va?"},
+		{Start: time.Second, End: 2 * time.Second, Text: "Oui."},
+		{Start: 5 * time.Second, End: 6 * time.Second, Text: "Bien."},
+	}
+	content, spans := Build(cues)
+	if content != "Ça va? Oui.\n\nBien." {
+		t.Fatalf("unexpected content: %q", content)
+	}
+	wantOffsets := [][2]int{{0, 6}, {7, 11}, {13, 18}}
+	for i, span := range spans {
+		if span.Index != i || span.StartOffset != wantOffsets[i][0] || span.EndOffset != wantOffsets[i][1] {
+			t.Errorf("span %d = %+v", i, span)
+		}
+	}
+	for offset, want := range map[int]int{0: 0, 5: 0, 7: 1, 13: 2, 17: 2} {
+		if span, ok := Find(spans, offset); !ok || span.Index != want {
+			t.Errorf("Find(%d) = %+v, %v", offset, span, ok)
+		}
+	}
+	for _, offset := range []int{6, 11, 12, 18} {
+		if _, ok := Find(spans, offset); ok {
+			t.Errorf("Find(%d) should miss", offset)
+		}
+	}
+}
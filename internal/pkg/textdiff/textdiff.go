@@ -0,0 +1,221 @@
+// Package textdiff は2つのテキストの行単位の差分 (Myers) を求め、unified 形式で書き出す
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Equal Kind = iota
+	Insert
+	Delete
+)
+
+// Line は差分の1行。Insert は b にだけ、Delete は a にだけある行
+type Line struct {
+	Kind Kind
+	Text string
+}
+
+// 編集距離がこれを超えたら最短の差分を諦め、全行の削除と追加にする
+const maxEditDistance = 4000
+
+// Lines は a を b にする行の編集を返す
+func Lines(a, b []string) []Line {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		lines = append(lines, Line{Kind: Equal, Text: text})
+	}
+	lines = append(lines, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, Line{Kind: Equal, Text: text})
+	}
+	return lines
+}
+
+// Text は a と b を改行で分けて Lines を求める
+func Text(a, b string) []Line {
+	return Lines(splitLines(a), splitLines(b))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// myers は最短の編集を求める。v[k] は対角線 k で届いた最も遠い x
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+	limit := n + m
+	if limit > maxEditDistance {
+		limit = maxEditDistance
+	}
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, offset)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+func backtrack(a, b []string, trace [][]int, offset int) []Line {
+	var reversed []Line
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Line{Kind: Equal, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, Line{Kind: Insert, Text: b[prevY]})
+			} else {
+				reversed = append(reversed, Line{Kind: Delete, Text: a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	lines := make([]Line, len(reversed))
+	for i, line := range reversed {
+		lines[len(reversed)-1-i] = line
+	}
+	return lines
+}
+
+func replaceAll(a, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a {
+		lines = append(lines, Line{Kind: Delete, Text: text})
+	}
+	for _, text := range b {
+		lines = append(lines, Line{Kind: Insert, Text: text})
+	}
+	return lines
+}
+
+// Stats は追加・削除された行数を返す
+func Stats(lines []Line) (added, deleted int) {
+	for _, line := range lines {
+		switch line.Kind {
+		case Insert:
+			added++
+		case Delete:
+			deleted++
+		}
+	}
+	return added, deleted
+}
+
+// Unified は lines を前後 context 行付きの unified 形式にする。差分がなければ空文字列
+func Unified(from, to string, lines []Line, context int) string {
+	var changes []int
+	for i, line := range lines {
+		if line.Kind != Equal {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", from, to)
+	for i := 0; i < len(changes); {
+		// 間の変わらない行が 2*context 以下なら同じ hunk にまとめる
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*context+1 {
+			j++
+		}
+		start := max(changes[i]-context, 0)
+		end := min(changes[j]+context+1, len(lines))
+		writeHunk(&sb, lines, start, end)
+		i = j + 1
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, lines []Line, start, end int) {
+	aStart, bStart := 1, 1
+	for _, line := range lines[:start] {
+		if line.Kind != Insert {
+			aStart++
+		}
+		if line.Kind != Delete {
+			bStart++
+		}
+	}
+	aCount, bCount := 0, 0
+	for _, line := range lines[start:end] {
+		if line.Kind != Insert {
+			aCount++
+		}
+		if line.Kind != Delete {
+			bCount++
+		}
+	}
+	// 空の側は直前の行番号を書く (diff -u と同じ)
+	if aCount == 0 {
+		aStart--
+	}
+	if bCount == 0 {
+		bStart--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, line := range lines[start:end] {
+		prefix := " "
+		switch line.Kind {
+		case Insert:
+			prefix = "+"
+		case Delete:
+			prefix = "-"
+		}
+		sb.WriteString(prefix + line.Text + "\n")
+	}
+}
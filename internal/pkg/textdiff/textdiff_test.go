@@ -0,0 +1,70 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+// apply は lines から a と b を組み立て直す
+func apply(lines []Line) (a, b []string) {
+	for _, line := range lines {
+		if line.Kind != Insert {
+			a = append(a, line.Text)
+		}
+		if line.Kind != Delete {
+			b = append(b, line.Text)
+		}
+	}
+	return a, b
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		a, b           string
+		added, deleted int
+	}{
+		{"a\nb\nc", "a\nb\nc", 0, 0},
+		{"a\nb\nc", "a\nx\nc", 1, 1},
+		{"", "a\nb", 2, 0},
+		{"a\nb", "", 0, 2},
+		{"a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc", 2, 3},
+		{"The quick fox\njumps\nover the dog", "The quick brown fox\njumps\nover the lazy dog\n", 3, 2},
+	}
+	for _, tt := range tests {
+		lines := Text(tt.a, tt.b)
+		a, b := apply(lines)
+		if strings.Join(a, "\n") != tt.a || strings.Join(b, "\n") != tt.b {
+			t.Errorf("Text(%q, %q) does not rebuild the inputs: %+v", tt.a, tt.b, lines)
+		}
+		added, deleted := Stats(lines)
+		if added != tt.added || deleted != tt.deleted {
+			t.Errorf("Text(%q, %q) = +%d -%d, expected +%d -%d", tt.a, tt.b, added, deleted, tt.added, tt.deleted)
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+	got := Unified("v1", "v2", Text(a, b), 2)
+	want := `--- v1
++++ v2
+@@ -1,5 +1,5 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+@@ -11,2 +11,3 @@
+ 11
+ 12
++13
+`
+	if got != want {
+		t.Errorf("Unified =\n%s\nexpected\n%s", got, want)
+	}
+	if Unified("v1", "v2", Text(a, a), 2) != "" {
+		t.Error("expected no output for equal texts")
+	}
+}
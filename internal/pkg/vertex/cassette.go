@@ -0,0 +1,259 @@
+package vertex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+type CassetteMode string
+
+const (
+	// CassetteReplay は記録済みの応答だけを返す。未記録ならエラー
+	CassetteReplay CassetteMode = "replay"
+	// CassetteRecord は常に Inner を呼び出して応答を上書き保存する
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplayOrRecord は記録があれば返し、なければ Inner を呼んで保存する
+	CassetteReplayOrRecord CassetteMode = "auto"
+)
+
+var ErrCassetteMiss = errors.New("no recorded response for prompt")
+
+// cassetteEntry は1つの記録ファイルの中身
+type cassetteEntry struct {
+	Prompt   string          `json:"prompt"`
+	Schema   *genai.Schema   `json:"schema,omitempty"`
+	Response json.RawMessage `json:"response"`
+}
+
+// CassetteClient は正規化したプロンプトとスキーマをキーに応答をファイルへ記録・再生する
+type CassetteClient struct {
+	Dir    string
+	Mode   CassetteMode
+	Inner  VertexService
+	Faults []*Fault
+
+	mu       sync.Mutex
+	calls    int
+	recorder UsageRecorder
+}
+
+func NewCassetteClient(dir string, mode CassetteMode, inner VertexService) (*CassetteClient, error) {
+	if err := checkCassetteMode(mode); err != nil {
+		return nil, err
+	}
+	if mode != CassetteReplay && inner == nil {
+		return nil, fmt.Errorf("cassette mode %q requires an inner client", mode)
+	}
+	return &CassetteClient{Dir: dir, Mode: mode, Inner: inner}, nil
+}
+
+// checkCassetteMode は未知のモードをエラーにする (綴りを間違えたまま Inner を呼ばせない)
+func checkCassetteMode(mode CassetteMode) error {
+	switch mode {
+	case CassetteReplay, CassetteRecord, CassetteReplayOrRecord:
+		return nil
+	}
+	return fmt.Errorf("unknown cassette mode %q (expected %s, %s or %s)", mode, CassetteReplay, CassetteRecord, CassetteReplayOrRecord)
+}
+
+func (c *CassetteClient) IsMock() bool {
+	return c.Mode == CassetteReplay || c.Inner.IsMock()
+}
+
+// SetUsageRecorder は Inner にも渡す。Inner を呼んだ分は Inner が記録する
+func (c *CassetteClient) SetUsageRecorder(recorder UsageRecorder) {
+	c.recorder = recorder
+	if c.Inner != nil {
+		c.Inner.SetUsageRecorder(recorder)
+	}
+}
+
+// WithFaults はスクリプト化した障害を追加する
+func (c *CassetteClient) WithFaults(faults ...*Fault) *CassetteClient {
+	c.Faults = append(c.Faults, faults...)
+	return c
+}
+
+func (c *CassetteClient) GenerateJsonContent(ctx context.Context, prompt string, jsonSchema *genai.Schema) (json.RawMessage, error) {
+	start := time.Now()
+	response, fromInner, err := c.generate(ctx, prompt, jsonSchema)
+
+	if c.recorder != nil && !fromInner {
+		c.recorder.RecordUsage(ctx, Usage{
+			Scope:          UsageScopeFrom(ctx),
+			Model:          "cassette",
+			PromptTokens:   EstimateTokens(prompt),
+			ResponseTokens: EstimateTokens(string(response)),
+			Latency:        time.Since(start),
+			Outcome:        outcomeOf(ctx, err),
+			Err:            err,
+		})
+	}
+	return response, err
+}
+
+// generate は応答と、それが Inner を呼んで得たものかを返す
+func (c *CassetteClient) generate(ctx context.Context, prompt string, jsonSchema *genai.Schema) (json.RawMessage, bool, error) {
+	c.mu.Lock()
+	c.calls++
+	fault := c.matchFault(c.calls, prompt)
+	c.mu.Unlock()
+
+	if fault != nil {
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			}
+		}
+		if fault.Err != nil {
+			return nil, false, fault.Err
+		}
+		if fault.Response != nil {
+			return fault.Response, false, nil
+		}
+	}
+
+	key := CassetteKey(prompt, jsonSchema)
+	if c.Mode != CassetteRecord {
+		entry, err := c.load(key)
+		if err == nil {
+			return entry.Response, false, nil
+		}
+		if c.Mode == CassetteReplay || !errors.Is(err, os.ErrNotExist) {
+			return nil, false, fmt.Errorf("%w (key %s): %v", ErrCassetteMiss, key, err)
+		}
+	}
+
+	response, err := c.Inner.GenerateJsonContent(ctx, prompt, jsonSchema)
+	if err != nil {
+		return nil, true, err
+	}
+	if err := c.save(key, cassetteEntry{Prompt: prompt, Schema: jsonSchema, Response: response}); err != nil {
+		return nil, true, fmt.Errorf("failed to record cassette %s: %w", key, err)
+	}
+	return response, true, nil
+}
+
+func (c *CassetteClient) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+func (c *CassetteClient) load(key string) (*cassetteEntry, error) {
+	raw, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, err
+	}
+	var entry cassetteEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, fmt.Errorf("corrupt cassette %s: %w", key, err)
+	}
+	return &entry, nil
+}
+
+func (c *CassetteClient) save(key string, entry cassetteEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path(key), raw, 0o644)
+}
+
+// CassetteKey は空白を正規化したプロンプトとスキーマから記録のキーを作る
+func CassetteKey(prompt string, jsonSchema *genai.Schema) string {
+	h := sha256.New()
+	h.Write([]byte(normalizePrompt(prompt)))
+	h.Write([]byte{0})
+	if jsonSchema != nil {
+		schemaJSON, _ := json.Marshal(jsonSchema)
+		h.Write(schemaJSON)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:20]
+}
+
+func normalizePrompt(prompt string) string {
+	return strings.Join(strings.Fields(prompt), " ")
+}
+
+// Fault はスクリプト化した障害。条件に合う呼び出しで Latency 待ってから Err か Response を返す
+type Fault struct {
+	// Match はプロンプトに含まれる文字列。空なら全呼び出しが対象
+	Match string
+	// Call は n 回目 (1始まり) の呼び出しだけに限定する。0 なら限定しない
+	Call int
+	// Times は発火回数の上限。0 なら無制限
+	Times int
+
+	Err      error
+	Latency  time.Duration
+	Response json.RawMessage
+
+	fired int
+}
+
+func FailWith(err error) *Fault {
+	return &Fault{Err: err}
+}
+
+func DelayBy(latency time.Duration) *Fault {
+	return &Fault{Latency: latency}
+}
+
+// MalformedJSON は途中で切れたJSONを返す
+func MalformedJSON() *Fault {
+	return &Fault{Response: json.RawMessage(`[{"phrase": "truncated", "from_text": tr`)}
+}
+
+// OnCall は n 回目の呼び出しだけに限定する
+func (f *Fault) OnCall(n int) *Fault {
+	f.Call = n
+	return f
+}
+
+// Matching はプロンプトに s を含む呼び出しだけに限定する
+func (f *Fault) Matching(s string) *Fault {
+	f.Match = s
+	return f
+}
+
+// Once は1回だけ発火させる
+func (f *Fault) Once() *Fault {
+	f.Times = 1
+	return f
+}
+
+// matchFault は c.mu を保持した状態で呼ぶ
+func (c *CassetteClient) matchFault(call int, prompt string) *Fault {
+	for _, f := range c.Faults {
+		if f.Call != 0 && f.Call != call {
+			continue
+		}
+		if f.Match != "" && !strings.Contains(prompt, f.Match) {
+			continue
+		}
+		if f.Times != 0 && f.fired >= f.Times {
+			continue
+		}
+		f.fired++
+		return f
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package vertex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+func TestCassetteClient_RecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	schema := &genai.Schema{Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}}
+
+	recorder, err := NewCassetteClient(dir, CassetteRecord, NewMockVertexClient(json.RawMessage(`["a","b"]`)))
+	if err != nil {
+		t.Fatalf("NewCassetteClient failed: %v", err)
+	}
+	if _, err := recorder.GenerateJsonContent(context.Background(), "list  two\nletters", schema); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+
+	replayer, err := NewCassetteClient(dir, CassetteReplay, nil)
+	if err != nil {
+		t.Fatalf("NewCassetteClient failed: %v", err)
+	}
+
+	// 空白の違いは同じキーになる
+	got, err := replayer.GenerateJsonContent(context.Background(), "list two letters", schema)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, got); err != nil || compact.String() != `["a","b"]` {
+		t.Errorf("replayed response = %s", got)
+	}
+
+	// スキーマが違えば別のキー
+	_, err = replayer.GenerateJsonContent(context.Background(), "list two letters", &genai.Schema{Type: genai.TypeString})
+	if !errors.Is(err, ErrCassetteMiss) {
+		t.Errorf("expected ErrCassetteMiss, got %v", err)
+	}
+}
+
+func TestNewCassetteClient_RejectsUnknownMode(t *testing.T) {
+	if _, err := NewCassetteClient(t.TempDir(), CassetteMode("replay-only"), NewMockVertexClient()); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	if _, err := NewCassetteClient(t.TempDir(), CassetteRecord, nil); err == nil {
+		t.Error("expected an error for record mode without an inner client")
+	}
+}
+
+func TestCassetteClient_RecordsUsageOnce(t *testing.T) {
+	dir := t.TempDir()
+	usages := &recordedUsages{}
+
+	client, err := NewCassetteClient(dir, CassetteReplayOrRecord, NewMockVertexClient(json.RawMessage(`["a"]`)))
+	if err != nil {
+		t.Fatalf("NewCassetteClient failed: %v", err)
+	}
+	client.SetUsageRecorder(usages)
+
+	// 1回目は Inner が記録し、2回目は記録済みの応答を返したカセットが記録する
+	for i := 0; i < 2; i++ {
+		if _, err := client.GenerateJsonContent(context.Background(), "prompt", nil); err != nil {
+			t.Fatalf("GenerateJsonContent failed: %v", err)
+		}
+	}
+	if len(usages.usages) != 2 {
+		t.Fatalf("expected 2 usages, got %+v", usages.usages)
+	}
+	if usages.usages[0].Model != "mock" || usages.usages[1].Model != "cassette" {
+		t.Errorf("unexpected usage models: %q, %q", usages.usages[0].Model, usages.usages[1].Model)
+	}
+}
+
+func TestCassetteClient_Faults(t *testing.T) {
+	boom := errors.New("429 Too Many Requests")
+	client, _ := NewCassetteClient(t.TempDir(), CassetteReplayOrRecord, NewMockVertexClient(json.RawMessage(`["ok"]`)))
+	client.WithFaults(
+		FailWith(boom).OnCall(2),
+		MalformedJSON().Matching("broken").Once(),
+	)
+
+	ctx := context.Background()
+	if _, err := client.GenerateJsonContent(ctx, "first", nil); err != nil {
+		t.Fatalf("call 1 failed: %v", err)
+	}
+	if _, err := client.GenerateJsonContent(ctx, "second", nil); !errors.Is(err, boom) {
+		t.Errorf("call 2: expected injected error, got %v", err)
+	}
+
+	got, err := client.GenerateJsonContent(ctx, "broken prompt", nil)
+	if err != nil {
+		t.Fatalf("call 3 failed: %v", err)
+	}
+	if json.Valid(got) {
+		t.Errorf("expected malformed JSON, got %s", got)
+	}
+
+	// Once なので2回目は通常の応答
+	got, err = client.GenerateJsonContent(ctx, "broken prompt", nil)
+	if err != nil || string(got) != `["ok"]` {
+		t.Errorf("call 4 = %s, %v", got, err)
+	}
+}
+
+func TestCassetteClient_LatencyRespectsContext(t *testing.T) {
+	client, _ := NewCassetteClient(t.TempDir(), CassetteReplayOrRecord, NewMockVertexClient())
+	client.WithFaults(DelayBy(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.GenerateJsonContent(ctx, "slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -25,6 +25,7 @@ var semaphore = make(chan struct{}, 3)
 type VertexService interface {
 	GenerateJsonContent(ctx context.Context, prompt string, jsonSchema *genai.Schema) (json.RawMessage, error)
 	IsMock() bool
+	SetUsageRecorder(recorder UsageRecorder)
 }
 
 func NewVertexService() (VertexService, error) {
@@ -34,12 +35,34 @@ func NewVertexService() (VertexService, error) {
 		return NewMockVertexClient(), nil
 	}
 
+	// VERTEX_CASSETTE_DIR があれば記録・再生する (VERTEX_CASSETTE_MODE=replay|record|auto)
+	if dir := os.Getenv("VERTEX_CASSETTE_DIR"); dir != "" {
+		mode := CassetteMode(os.Getenv("VERTEX_CASSETTE_MODE"))
+		if mode == "" {
+			mode = CassetteReplay
+		}
+		if err := checkCassetteMode(mode); err != nil {
+			return nil, err
+		}
+		fmt.Printf("📼 Using CASSETTE Vertex Service (%s, %s)\n", dir, mode)
+		var inner VertexService
+		if mode != CassetteReplay {
+			real, err := NewRealVertexClient()
+			if err != nil {
+				return nil, err
+			}
+			inner = real
+		}
+		return NewCassetteClient(dir, mode, inner)
+	}
+
 	fmt.Println("🌍 Using REAL Vertex Service")
 	return NewRealVertexClient()
 }
 
 type RealVertexClient struct {
-	client *genai.Client
+	client   *genai.Client
+	recorder UsageRecorder
 }
 
 func NewRealVertexClient() (*RealVertexClient, error) {
@@ -55,6 +78,10 @@ func (c *RealVertexClient) IsMock() bool {
 	return false
 }
 
+func (c *RealVertexClient) SetUsageRecorder(recorder UsageRecorder) {
+	c.recorder = recorder
+}
+
 func retryWithBackoff(_ context.Context, maxRetries int, fn func() (json.RawMessage, error)) (json.RawMessage, error) {
 	var err error
 	var response json.RawMessage
@@ -86,16 +113,7 @@ func isRetryableError(err error) bool {
 
 func (c *RealVertexClient) GenerateJsonContent(ctx context.Context, prompt string, jsonSchema *genai.Schema) (json.RawMessage, error) {
 	log.Printf("🔍 calling GenerateJsonContent: ctx.Err() = %v", ctx.Err())
-	model := c.client.GenerativeModel(modelName)
-	config := genai.GenerationConfig{
-		MaxOutputTokens:  genai.Ptr(int32(8192)),
-		TopK:             genai.Ptr(int32(40)),
-		TopP:             genai.Ptr(float32(0.95)),
-		Temperature:      genai.Ptr(float32(1)),
-		ResponseMIMEType: "application/json",
-		ResponseSchema:   jsonSchema,
-	}
-	model.GenerationConfig = config
+	model := c.jsonModel(jsonSchema)
 
 	// 並列リクエストを制限
 	semaphore <- struct{}{}        // スロット確保
@@ -103,24 +121,69 @@ func (c *RealVertexClient) GenerateJsonContent(ctx context.Context, prompt strin
 
 	log.Printf("🚀 Sending request to Vertex API with prompt: %s", prompt)
 
-	return retryWithBackoff(ctx, 5, func() (json.RawMessage, error) {
+	// リトライしても各回のトークンは課金されるので、1回ごとに記録する
+	response, err := retryWithBackoff(ctx, 5, func() (json.RawMessage, error) {
 		log.Printf("🔍 Checking ctx.Err() before API call: %v", ctx.Err()) // 追加ログ
 
-		res, err := model.GenerateContent(ctx, genai.Text(prompt))
-		if err != nil {
-			log.Printf("❌ Failed to generate content: %v", err)
+		var usage *genai.UsageMetadata
+		start := time.Now()
+		response, err := func() (json.RawMessage, error) {
+			res, err := model.GenerateContent(ctx, genai.Text(prompt))
+			if err != nil {
+				log.Printf("❌ Failed to generate content: %v", err)
+				if ctx.Err() == context.Canceled {
+					log.Printf("❌ Context was canceled BEFORE API call: %v", ctx.Err()) // 追加ログ
+				}
+				return nil, fmt.Errorf("failed to generate content: %w", err)
+			}
+			usage = res.UsageMetadata
+
 			if ctx.Err() == context.Canceled {
-				log.Printf("❌ Context was canceled BEFORE API call: %v", ctx.Err()) // 追加ログ
+				log.Printf("❌ Context was canceled AFTER API call: %v", ctx.Err()) // 追加ログ
+				return nil, fmt.Errorf("context was canceled after API call")
 			}
-			return nil, fmt.Errorf("failed to generate content: %w", err)
-		}
 
-		if ctx.Err() == context.Canceled {
-			log.Printf("❌ Context was canceled AFTER API call: %v", ctx.Err()) // 追加ログ
-			return nil, fmt.Errorf("context was canceled after API call")
-		}
+			if len(res.Candidates) == 0 || res.Candidates[0].Content == nil || len(res.Candidates[0].Content.Parts) == 0 {
+				return nil, fmt.Errorf("no content generated")
+			}
 
-		log.Printf("✅ Successfully received response from Vertex API")
-		return json.RawMessage(res.Candidates[0].Content.Parts[0].(genai.Text)), nil
+			log.Printf("✅ Successfully received response from Vertex API")
+			return json.RawMessage(res.Candidates[0].Content.Parts[0].(genai.Text)), nil
+		}()
+		c.recordUsage(ctx, usage, time.Since(start), err)
+		return response, err
 	})
+	return response, err
+}
+
+// jsonModel は jsonSchema に沿った JSON を返すよう設定したモデル
+func (c *RealVertexClient) jsonModel(jsonSchema *genai.Schema) *genai.GenerativeModel {
+	model := c.client.GenerativeModel(modelName)
+	model.GenerationConfig = genai.GenerationConfig{
+		MaxOutputTokens:  genai.Ptr(int32(8192)),
+		TopK:             genai.Ptr(int32(40)),
+		TopP:             genai.Ptr(float32(0.95)),
+		Temperature:      genai.Ptr(float32(1)),
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   jsonSchema,
+	}
+	return model
+}
+
+func (c *RealVertexClient) recordUsage(ctx context.Context, metadata *genai.UsageMetadata, latency time.Duration, err error) {
+	if c.recorder == nil {
+		return
+	}
+	usage := Usage{
+		Scope:   UsageScopeFrom(ctx),
+		Model:   modelName,
+		Latency: latency,
+		Outcome: outcomeOf(ctx, err),
+		Err:     err,
+	}
+	if metadata != nil {
+		usage.PromptTokens = int(metadata.PromptTokenCount)
+		usage.ResponseTokens = int(metadata.CandidatesTokenCount)
+	}
+	c.recorder.RecordUsage(ctx, usage)
 }
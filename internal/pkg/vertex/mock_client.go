@@ -11,6 +11,7 @@ import (
 // MockVertexClient（テスト用）
 type MockVertexClient struct {
 	ResponseData json.RawMessage
+	recorder     UsageRecorder
 }
 
 func NewMockVertexClient(responseData ...json.RawMessage) *MockVertexClient {
@@ -28,12 +29,26 @@ func (m *MockVertexClient) GenerateJsonContent(ctx context.Context, prompt strin
 	fmt.Println("⚡ Using MOCK Vertex Service")
 
 	// 設定されたモックレスポンスを返す
-	if len(m.ResponseData) == 0 {
-		return json.RawMessage(`[]`), nil
+	response := m.ResponseData
+	if len(response) == 0 {
+		response = json.RawMessage(`[]`)
 	}
-	return m.ResponseData, nil
+	if m.recorder != nil {
+		m.recorder.RecordUsage(ctx, Usage{
+			Scope:          UsageScopeFrom(ctx),
+			Model:          "mock",
+			PromptTokens:   EstimateTokens(prompt),
+			ResponseTokens: EstimateTokens(string(response)),
+			Outcome:        UsageOutcomeSuccess,
+		})
+	}
+	return response, nil
 }
 
 func (m *MockVertexClient) IsMock() bool {
 	return true
 }
+
+func (m *MockVertexClient) SetUsageRecorder(recorder UsageRecorder) {
+	m.recorder = recorder
+}
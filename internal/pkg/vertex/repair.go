@@ -0,0 +1,114 @@
+package vertex
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+var ErrUnrepairableJSON = errors.New("JSON could not be repaired")
+
+// RepairJSON はモデルの応答によくある崩れを直す。
+// コードフェンスや前後の文章を取り除き、途中で切れたトップレベル配列は
+// 最後まで揃っている要素で閉じる。repaired は手を加えたかどうか
+func RepairJSON(raw json.RawMessage) (fixed json.RawMessage, repaired bool, err error) {
+	if json.Valid(raw) {
+		return raw, false, nil
+	}
+
+	trimmed := stripFences(raw)
+	if json.Valid(trimmed) {
+		return trimmed, true, nil
+	}
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, false, ErrUnrepairableJSON
+	}
+
+	end := lastCompleteElement(trimmed)
+	if end < 0 {
+		return nil, false, ErrUnrepairableJSON
+	}
+	fixed = append(append(json.RawMessage{}, trimmed[:end]...), ']')
+	if !json.Valid(fixed) {
+		return nil, false, ErrUnrepairableJSON
+	}
+	return fixed, true, nil
+}
+
+// DecodeRepairedJsonContent は DecodeJsonContent が失敗したときだけ RepairJSON を試す
+func DecodeRepairedJsonContent[T any](data json.RawMessage) (output T, repaired bool, err error) {
+	output, err = DecodeJsonContent[T](data)
+	if err == nil {
+		return output, false, nil
+	}
+	fixed, repaired, repairErr := RepairJSON(data)
+	if repairErr != nil || !repaired {
+		return output, false, err
+	}
+	output, err = DecodeJsonContent[T](fixed)
+	return output, err == nil, err
+}
+
+// stripFences は ```json ... ``` や説明文を除いて最初の [ か { から始める
+func stripFences(raw []byte) []byte {
+	s := bytes.TrimSpace(raw)
+	if bytes.HasPrefix(s, []byte("```")) {
+		if nl := bytes.IndexByte(s, '\n'); nl >= 0 {
+			s = s[nl+1:]
+		}
+		if i := bytes.LastIndex(s, []byte("```")); i >= 0 {
+			s = s[:i]
+		}
+	}
+	if start := bytes.IndexAny(s, "[{"); start > 0 {
+		s = s[start:]
+	}
+	return bytes.TrimSpace(s)
+}
+
+// lastCompleteElement はトップレベル配列で最後に閉じた要素の直後の位置を返す。なければ -1
+func lastCompleteElement(s []byte) int {
+	end := -1
+	depth := 0
+	inString, escaped := false, false
+	for i, c := range s {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 1 {
+				end = i + 1
+			}
+			if depth == 0 {
+				// 閉じているのに不正なら、ここから先は直せない
+				return end
+			}
+		case ',':
+			if depth == 1 {
+				end = trimRightSpace(s, i)
+			}
+		}
+	}
+	return end
+}
+
+func trimRightSpace(s []byte, i int) int {
+	for i > 0 && (s[i-1] == ' ' || s[i-1] == '\n' || s[i-1] == '\t' || s[i-1] == '\r') {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,59 @@
+package vertex
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		repaired bool
+		wantErr  bool
+	}{
+		{name: "valid", input: `[{"a":1}]`, expected: `[{"a":1}]`},
+		{name: "code fence", input: "```json\n[{\"a\":1}]\n```", expected: `[{"a":1}]`, repaired: true},
+		{name: "leading prose", input: `Here you go: [{"a":1}]`, expected: `[{"a":1}]`, repaired: true},
+		{name: "truncated object", input: `[{"a":1}, {"a":2}, {"a":`, expected: `[{"a":1}, {"a":2}]`, repaired: true},
+		{name: "truncated string with brackets", input: `[{"a":"x]}"}, {"a":"y`, expected: `[{"a":"x]}"}]`, repaired: true},
+		{name: "truncated primitives", input: `[1, 2, 3`, expected: `[1, 2]`, repaired: true},
+		{name: "nothing complete", input: `[{"phrase": "truncated", "from_text": tr`, wantErr: true},
+		{name: "truncated object root", input: `{"a":`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixed, repaired, err := RepairJSON(json.RawMessage(tt.input))
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnrepairableJSON) {
+					t.Fatalf("expected ErrUnrepairableJSON, got %v (%s)", err, fixed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RepairJSON() error = %v", err)
+			}
+			if string(fixed) != tt.expected || repaired != tt.repaired {
+				t.Errorf("RepairJSON() = %s, %v; expected %s, %v", fixed, repaired, tt.expected, tt.repaired)
+			}
+		})
+	}
+}
+
+func TestDecodeRepairedJsonContent(t *testing.T) {
+	type item struct {
+		A int `json:"a"`
+	}
+
+	items, repaired, err := DecodeRepairedJsonContent[[]item](json.RawMessage(`[{"a":1},{"a":`))
+	if err != nil || !repaired || len(items) != 1 || items[0].A != 1 {
+		t.Fatalf("unexpected result: %v %v %v", items, repaired, err)
+	}
+
+	if _, _, err := DecodeRepairedJsonContent[[]item](json.RawMessage(`[{"a":`)); err == nil {
+		t.Fatal("expected error for unrepairable JSON")
+	}
+}
@@ -0,0 +1,120 @@
+package vertex
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// SchemaError はスキーマ違反1件
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidateJSON は data が schema に合っているかを調べ、違反をすべて返す
+func ValidateJSON(data json.RawMessage, schema *genai.Schema) []error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []error{&SchemaError{Path: "$", Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	var errs []error
+	validateValue("$", value, schema, &errs)
+	return errs
+}
+
+func validateValue(path string, value interface{}, schema *genai.Schema, errs *[]error) {
+	if schema == nil || schema.Type == genai.TypeUnspecified {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, &SchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !schema.Nullable {
+			fail("null is not allowed")
+		}
+		return
+	}
+
+	switch schema.Type {
+	case genai.TypeString:
+		s, ok := value.(string)
+		if !ok {
+			fail("expected string, got %T", value)
+			return
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+			fail("%q is not one of %v", s, schema.Enum)
+		}
+		length := int64(utf8.RuneCountInString(s))
+		if schema.MinLength > 0 && length < schema.MinLength {
+			fail("string shorter than %d", schema.MinLength)
+		}
+		if schema.MaxLength > 0 && length > schema.MaxLength {
+			fail("string longer than %d", schema.MaxLength)
+		}
+	case genai.TypeInteger:
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			fail("expected integer, got %v", value)
+		}
+	case genai.TypeNumber:
+		if _, ok := value.(float64); !ok {
+			fail("expected number, got %T", value)
+		}
+	case genai.TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean, got %T", value)
+		}
+	case genai.TypeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("expected array, got %T", value)
+			return
+		}
+		if schema.MinItems > 0 && int64(len(items)) < schema.MinItems {
+			fail("expected at least %d items, got %d", schema.MinItems, len(items))
+		}
+		if schema.MaxItems > 0 && int64(len(items)) > schema.MaxItems {
+			fail("expected at most %d items, got %d", schema.MaxItems, len(items))
+		}
+		for i, item := range items {
+			validateValue(fmt.Sprintf("%s[%d]", path, i), item, schema.Items, errs)
+		}
+	case genai.TypeObject:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected object, got %T", value)
+			return
+		}
+		for _, name := range schema.Required {
+			if _, exists := obj[name]; !exists {
+				fail("missing required property %q", name)
+			}
+		}
+		for name, propSchema := range schema.Properties {
+			if v, exists := obj[name]; exists {
+				validateValue(path+"."+name, v, propSchema, errs)
+			}
+		}
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package vertex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/api/iterator"
+)
+
+// ElementHandler はトップレベル配列の要素が1つ揃うたびに呼ばれる。エラーを返すと生成を中断する
+type ElementHandler func(element json.RawMessage) error
+
+// StreamingService は応答を少しずつ受け取れるクライアント
+type StreamingService interface {
+	StreamJsonContent(ctx context.Context, prompt string, jsonSchema *genai.Schema, onElement ElementHandler) (json.RawMessage, error)
+}
+
+// StreamJsonContent は client がストリーミングに対応していればそれを使い、
+// 対応していなければ応答全体を受け取ってから要素ごとに onElement を呼ぶ
+func StreamJsonContent(ctx context.Context, client VertexService, prompt string, jsonSchema *genai.Schema, onElement ElementHandler) (json.RawMessage, error) {
+	if streamer, ok := client.(StreamingService); ok {
+		return streamer.StreamJsonContent(ctx, prompt, jsonSchema, onElement)
+	}
+
+	response, err := client.GenerateJsonContent(ctx, prompt, jsonSchema)
+	if err != nil {
+		return nil, err
+	}
+	stream := NewArrayStream()
+	for _, element := range stream.Write(response) {
+		if err := onElement(element); err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+func (c *RealVertexClient) StreamJsonContent(ctx context.Context, prompt string, jsonSchema *genai.Schema, onElement ElementHandler) (json.RawMessage, error) {
+	model := c.jsonModel(jsonSchema)
+
+	// 並列リクエストを制限
+	semaphore <- struct{}{}
+	defer func() { <-semaphore }()
+
+	log.Printf("🚀 Streaming request to Vertex API with prompt: %s", prompt)
+
+	// 途中まで要素を渡した後はやり直せないので、ストリーミングではリトライしない
+	var usage *genai.UsageMetadata
+	var full bytes.Buffer
+	start := time.Now()
+	err := func() error {
+		iter := model.GenerateContentStream(ctx, genai.Text(prompt))
+		stream := NewArrayStream()
+		for {
+			res, err := iter.Next()
+			if errors.Is(err, iterator.Done) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to stream content: %w", err)
+			}
+			if res.UsageMetadata != nil {
+				usage = res.UsageMetadata
+			}
+			if len(res.Candidates) == 0 || res.Candidates[0].Content == nil {
+				continue
+			}
+			for _, part := range res.Candidates[0].Content.Parts {
+				text, ok := part.(genai.Text)
+				if !ok {
+					continue
+				}
+				full.WriteString(string(text))
+				for _, element := range stream.Write([]byte(text)) {
+					if err := onElement(element); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}()
+	c.recordUsage(ctx, usage, time.Since(start), err)
+	if err == nil && full.Len() == 0 {
+		err = fmt.Errorf("no content generated")
+	}
+	return json.RawMessage(full.Bytes()), err
+}
+
+// ArrayStream は JSON 配列のテキストを少しずつ受け取り、閉じた要素を順に返す
+type ArrayStream struct {
+	buf      []byte
+	start    int
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+func NewArrayStream() *ArrayStream {
+	return &ArrayStream{start: -1}
+}
+
+// Write は p を追加し、新しく揃った要素を返す
+func (s *ArrayStream) Write(p []byte) []json.RawMessage {
+	var elements []json.RawMessage
+	for _, c := range p {
+		s.buf = append(s.buf, c)
+		i := len(s.buf) - 1
+
+		if s.inString {
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case c == '\\':
+				s.escaped = true
+			case c == '"':
+				s.inString = false
+				// 文字列だけの要素
+				if s.depth == 1 && s.start >= 0 && s.buf[s.start] == '"' {
+					elements = append(elements, s.take(i+1))
+				}
+			}
+			continue
+		}
+
+		switch c {
+		case ' ', '\n', '\t', '\r':
+		case '"':
+			s.inString = true
+			s.begin(i)
+		case '[', '{':
+			s.begin(i)
+			s.depth++
+		case ']', '}':
+			s.depth--
+			switch {
+			case s.depth == 1 && s.start >= 0:
+				elements = append(elements, s.take(i+1))
+			case s.depth == 0 && s.start >= 0:
+				// 配列の最後の数値・リテラル
+				elements = append(elements, s.take(i))
+			}
+		case ',':
+			if s.depth == 1 && s.start >= 0 {
+				elements = append(elements, s.take(i))
+			}
+		default:
+			s.begin(i)
+		}
+	}
+	return elements
+}
+
+func (s *ArrayStream) begin(i int) {
+	if s.depth == 1 && s.start < 0 {
+		s.start = i
+	}
+}
+
+func (s *ArrayStream) take(end int) json.RawMessage {
+	element := append(json.RawMessage{}, bytes.TrimSpace(s.buf[s.start:end])...)
+	s.start = -1
+	return element
+}
@@ -0,0 +1,56 @@
+package vertex
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestArrayStream_ByteByByte(t *testing.T) {
+	input := `[ {"a": "x,]}", "b": [1, 2]}, "str\"ing", 42, true, {"c": {}} ]`
+	expected := []string{`{"a": "x,]}", "b": [1, 2]}`, `"str\"ing"`, `42`, `true`, `{"c": {}}`}
+
+	stream := NewArrayStream()
+	var got []string
+	for i := 0; i < len(input); i++ {
+		for _, element := range stream.Write([]byte{input[i]}) {
+			if !json.Valid(element) {
+				t.Errorf("invalid element %s", element)
+			}
+			got = append(got, string(element))
+		}
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("element %d = %s, expected %s", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestArrayStream_TruncatedKeepsCompleteElements(t *testing.T) {
+	stream := NewArrayStream()
+	elements := stream.Write([]byte(`[{"a":1},{"a":2},{"a":`))
+	if len(elements) != 2 {
+		t.Fatalf("expected 2 complete elements, got %d", len(elements))
+	}
+}
+
+func TestStreamJsonContent_FallsBackToWholeResponse(t *testing.T) {
+	client := NewMockVertexClient(json.RawMessage(`[{"a":1},{"a":2},{"a":3}]`))
+
+	var count int
+	raw, err := StreamJsonContent(context.Background(), client, "prompt", nil, func(json.RawMessage) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamJsonContent failed: %v", err)
+	}
+	if count != 3 || string(raw) != `[{"a":1},{"a":2},{"a":3}]` {
+		t.Errorf("unexpected result: %d elements, raw %s", count, raw)
+	}
+}
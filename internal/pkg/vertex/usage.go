@@ -0,0 +1,96 @@
+package vertex
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UsageOutcomeSuccess  = "success"
+	UsageOutcomeError    = "error"
+	UsageOutcomeCanceled = "canceled"
+)
+
+// Usage は1回のLLM呼び出しの使用量
+type Usage struct {
+	Scope          UsageScope
+	Model          string
+	PromptTokens   int
+	ResponseTokens int
+	Latency        time.Duration
+	Outcome        string
+	Err            error
+}
+
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.ResponseTokens
+}
+
+// CostUSD はモデルの単価表から概算コストを計算する
+func (u Usage) CostUSD() float64 {
+	price, ok := modelPrices[u.Model]
+	if !ok {
+		return 0
+	}
+	return float64(u.PromptTokens)/1_000_000*price.InputPerMillion +
+		float64(u.ResponseTokens)/1_000_000*price.OutputPerMillion
+}
+
+type modelPrice struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// USD / 1M tokens
+var modelPrices = map[string]modelPrice{
+	"gemini-1.5-flash": {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-1.5-pro":   {InputPerMillion: 1.25, OutputPerMillion: 5.00},
+}
+
+// UsageRecorder はLLM呼び出しごとに使用量を受け取る
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, usage Usage)
+}
+
+// UsageScope は使用量を紐づけるユーザーと教材
+type UsageScope struct {
+	UserID     uuid.UUID
+	MaterialID uint
+	Operation  string
+}
+
+type usageScopeKey struct{}
+
+func WithUsageScope(ctx context.Context, scope UsageScope) context.Context {
+	return context.WithValue(ctx, usageScopeKey{}, scope)
+}
+
+// WithOperation は既存のスコープを引き継いで操作名だけを差し替える
+func WithOperation(ctx context.Context, operation string) context.Context {
+	scope := UsageScopeFrom(ctx)
+	scope.Operation = operation
+	return WithUsageScope(ctx, scope)
+}
+
+func UsageScopeFrom(ctx context.Context) UsageScope {
+	scope, _ := ctx.Value(usageScopeKey{}).(UsageScope)
+	return scope
+}
+
+func outcomeOf(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return UsageOutcomeSuccess
+	case ctx.Err() != nil:
+		return UsageOutcomeCanceled
+	default:
+		return UsageOutcomeError
+	}
+}
+
+// EstimateTokens はトークン数を文字数からざっくり見積もる (モック用)
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
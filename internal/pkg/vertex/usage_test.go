@@ -0,0 +1,54 @@
+package vertex
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type recordedUsages struct {
+	usages []Usage
+}
+
+func (r *recordedUsages) RecordUsage(_ context.Context, usage Usage) {
+	r.usages = append(r.usages, usage)
+}
+
+func TestUsageCostUSD(t *testing.T) {
+	usage := Usage{Model: "gemini-1.5-flash", PromptTokens: 1_000_000, ResponseTokens: 1_000_000}
+	if got := usage.CostUSD(); math.Abs(got-0.375) > 1e-9 {
+		t.Errorf("CostUSD() = %v, expected 0.375", got)
+	}
+
+	unknown := Usage{Model: "unknown", PromptTokens: 100}
+	if got := unknown.CostUSD(); got != 0 {
+		t.Errorf("CostUSD() for unknown model = %v, expected 0", got)
+	}
+}
+
+func TestMockVertexClient_RecordsUsageWithScope(t *testing.T) {
+	recorder := &recordedUsages{}
+	client := NewMockVertexClient()
+	client.SetUsageRecorder(recorder)
+
+	userID := uuid.New()
+	ctx := WithUsageScope(context.Background(), UsageScope{UserID: userID, MaterialID: 3})
+	ctx = WithOperation(ctx, "generate_words")
+
+	if _, err := client.GenerateJsonContent(ctx, "prompt text", nil); err != nil {
+		t.Fatalf("GenerateJsonContent failed: %v", err)
+	}
+
+	if len(recorder.usages) != 1 {
+		t.Fatalf("expected 1 usage, got %d", len(recorder.usages))
+	}
+	got := recorder.usages[0]
+	if got.Scope.UserID != userID || got.Scope.MaterialID != 3 || got.Scope.Operation != "generate_words" {
+		t.Errorf("unexpected scope: %+v", got.Scope)
+	}
+	if got.Outcome != UsageOutcomeSuccess || got.PromptTokens == 0 {
+		t.Errorf("unexpected usage: %+v", got)
+	}
+}
@@ -1,82 +1,343 @@
 package vertex
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/vertexai/genai"
 )
 
-// ジェネリックな JSON デコード関数
+// ジェネリックな JSON デコード関数。
+// マップはスキーマで {key, value} の配列にしているので、T に合わせてオブジェクトに戻してからデコードする
 func DecodeJsonContent[T any](data json.RawMessage) (T, error) {
 	var output T
+	if t := reflect.TypeOf(output); t != nil && hasMap(t, map[reflect.Type]bool{}) {
+		restored, err := restoreMaps(data, t)
+		if err != nil {
+			return output, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		}
+		data = restored
+	}
 	if err := json.Unmarshal(data, &output); err != nil {
 		return output, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
 	return output, nil
 }
 
-func GenerateSchema[T any]() *genai.Schema {
+// GenerateSchema は T の構造体タグからレスポンススキーマを作る。
+//
+//	json:"name,omitempty"          プロパティ名。omitempty なら必須にしない
+//	description:"..."              説明文
+//	schema:"enum=a|b,minItems=1"   制約 (enum, format, minItems, maxItems, minLength,
+//	                               maxLength, minimum, maximum, nullable, optional)
+//
+// json タグのないフィールドは、埋め込み構造体を除いて無視する。
+// Gemini の OBJECT はプロパティが決まっていないといけないので、マップは {key, value} の配列にする
+// (DecodeJsonContent がマップに戻す)。再帰的な型は maxSchemaDepth 段まで展開し、
+// その先の再帰するフィールドは省く (省けるよう omitempty か optional が要る)
+func GenerateSchema[T any]() (*genai.Schema, error) {
 	var t T
-	return generateSchema(reflect.TypeOf(t))
+	return generateSchema(reflect.TypeOf(t), map[reflect.Type]int{})
 }
 
+// 再帰的な型を展開する深さ
+const maxSchemaDepth = 3
+
+// errSchemaDepth は再帰的な型の展開を打ち切ったこと。フィールドごと省く
+var errSchemaDepth = errors.New("recursive type exceeds the schema depth")
+
+var timeType = reflect.TypeOf(time.Time{})
+
 // 型 (`reflect.Type`) を直接渡す
-func generateSchema(t reflect.Type) *genai.Schema {
+func generateSchema(t reflect.Type, visiting map[reflect.Type]int) (*genai.Schema, error) {
+	if t == nil {
+		return &genai.Schema{Type: genai.TypeUnspecified}, nil
+	}
+
 	// ポインタの場合はデリファレンス
 	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
+		s, err := generateSchema(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		s.Nullable = true
+		return s, nil
+	}
+	if t == timeType {
+		return &genai.Schema{Type: genai.TypeString, Format: "date-time"}, nil
 	}
 
 	switch t.Kind() {
-	case reflect.String:
-		return &genai.Schema{Type: genai.TypeString}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return &genai.Schema{Type: genai.TypeInteger}
-	case reflect.Float32, reflect.Float64:
-		return &genai.Schema{Type: genai.TypeNumber}
-	case reflect.Bool:
-		return &genai.Schema{Type: genai.TypeBoolean}
 	case reflect.Slice, reflect.Array:
-		return &genai.Schema{
-			Type:  genai.TypeArray,
-			Items: generateSchema(t.Elem()), //再帰
+		items, err := generateSchema(t.Elem(), visiting) //再帰
+		if err != nil {
+			return nil, err
 		}
+		return &genai.Schema{Type: genai.TypeArray, Items: items}, nil
+	case reflect.Map:
+		return mapSchema(t, visiting)
 	case reflect.Struct:
-		properties := make(map[string]*genai.Schema)
-		requiredFields := getRequiredFields(t)
+		if visiting[t] >= maxSchemaDepth {
+			return nil, errSchemaDepth
+		}
+		visiting[t]++
+		defer func() { visiting[t]-- }()
 
-		for i := 0; i < t.NumField(); i++ {
-			field := t.Field(i)
-			jsonTag := field.Tag.Get("json")
-			if jsonTag == "" || jsonTag == "-" {
+		schema := &genai.Schema{
+			Type:       genai.TypeObject,
+			Properties: make(map[string]*genai.Schema),
+		}
+		if err := addStructFields(schema, t, visiting); err != nil {
+			return nil, err
+		}
+		return schema, nil
+	default:
+		return &genai.Schema{Type: goTypeToGenaiType(t)}, nil
+	}
+}
+
+// mapSchema はマップを {key, value} の配列にする。キーは JSON のオブジェクトのキーになれる文字列か整数
+func mapSchema(t reflect.Type, visiting map[reflect.Type]int) (*genai.Schema, error) {
+	key := goTypeToGenaiType(t.Key())
+	if key != genai.TypeString && key != genai.TypeInteger {
+		return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+	}
+	value, err := generateSchema(t.Elem(), visiting)
+	if err != nil {
+		return nil, err
+	}
+	return &genai.Schema{
+		Type:        genai.TypeArray,
+		Description: fmt.Sprintf("entries of a map from %s to %s", t.Key().Kind(), t.Elem().Kind()),
+		Items: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"key":   {Type: key},
+				"value": value,
+			},
+			Required: []string{"key", "value"},
+		},
+	}, nil
+}
+
+// addStructFields は埋め込み構造体のフィールドを親に平坦化しながら追加する
+func addStructFields(schema *genai.Schema, t reflect.Type, visiting map[reflect.Type]int) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitempty, tagged := parseJSONTag(field)
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && !tagged {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addStructFields(schema, embedded, visiting); err != nil {
+					return err
+				}
 				continue
 			}
-			properties[jsonTag] = generateSchema(field.Type)
+		}
+		if !tagged || !field.IsExported() {
+			continue
 		}
 
-		return &genai.Schema{
-			Type:       genai.TypeObject,
-			Properties: properties,
-			Required:   requiredFields,
+		property, err := generateSchema(field.Type, visiting)
+		truncated := errors.Is(err, errSchemaDepth)
+		if err != nil && !truncated {
+			return fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
 		}
-	default:
-		return &genai.Schema{Type: genai.TypeUnspecified}
+		if truncated {
+			property = &genai.Schema{}
+		}
+		optional := omitempty
+		if err := applySchemaTag(property, field, &optional); err != nil {
+			return fmt.Errorf("invalid schema tag on %s.%s: %w", t.Name(), field.Name, err)
+		}
+		if truncated {
+			// 打ち切った先は省く。必須のフィールドは省けない
+			if !optional {
+				return fmt.Errorf("%s.%s: recursive type exceeds the schema depth (make it omitempty or optional)", t.Name(), field.Name)
+			}
+			continue
+		}
+
+		if _, exists := schema.Properties[name]; !exists && !optional {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
 	}
+	return nil
 }
 
-// 必須フィールドを取得
-func getRequiredFields(t reflect.Type) []string {
-	var required []string
+// hasMap は t のどこかにマップがあるかを調べる
+func hasMap(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return hasMap(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasMap(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// restoreMaps は t のマップに当たる {key, value} の配列をオブジェクトに戻す
+func restoreMaps(data json.RawMessage, t reflect.Type) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(restoreValue(value, t))
+}
+
+func restoreValue(value interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		restored := make(map[string]interface{})
+		switch v := value.(type) {
+		case []interface{}:
+			for _, e := range v {
+				entry, ok := e.(map[string]interface{})
+				if !ok || entry["key"] == nil {
+					continue
+				}
+				restored[fmt.Sprint(entry["key"])] = restoreValue(entry["value"], t.Elem())
+			}
+		case map[string]interface{}:
+			for k, e := range v {
+				restored[k] = restoreValue(e, t.Elem())
+			}
+		default:
+			return value
+		}
+		return restored
+	case reflect.Slice, reflect.Array:
+		if v, ok := value.([]interface{}); ok {
+			for i := range v {
+				v[i] = restoreValue(v[i], t.Elem())
+			}
+		}
+	case reflect.Struct:
+		if v, ok := value.(map[string]interface{}); ok {
+			restoreFields(v, t)
+		}
+	}
+	return value
+}
+
+// restoreFields は構造体のフィールドの値を戻す。埋め込み構造体は同じオブジェクトに平坦化されている
+func restoreFields(object map[string]interface{}, t reflect.Type) {
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		jsonTag := field.Tag.Get("json")
-		if jsonTag != "" && jsonTag != "-" {
-			required = append(required, jsonTag)
+		name, _, tagged := parseJSONTag(field)
+		if field.Anonymous && !tagged {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				restoreFields(object, embedded)
+				continue
+			}
 		}
+		if !tagged || name == "-" {
+			continue
+		}
+		if value, ok := object[name]; ok {
+			object[name] = restoreValue(value, field.Type)
+		}
+	}
+}
+
+// parseJSONTag は "name,omitempty" を分解する。タグなしなら tagged=false
+func parseJSONTag(field reflect.StructField) (name string, omitempty bool, tagged bool) {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok || tag == "" {
+		return "", false, false
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	if name == "" {
+		name = field.Name
 	}
-	return required
+	return name, omitempty, true
+}
+
+func applySchemaTag(s *genai.Schema, field reflect.StructField, optional *bool) error {
+	if description := field.Tag.Get("description"); description != "" {
+		s.Description = description
+	}
+
+	tag := field.Tag.Get("schema")
+	if tag == "" {
+		return nil
+	}
+
+	for _, opt := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		var err error
+		switch key {
+		case "":
+		case "optional":
+			*optional = true
+		case "required":
+			*optional = false
+		case "nullable":
+			s.Nullable = true
+		case "enum":
+			s.Enum = strings.Split(value, "|")
+			s.Format = "enum"
+		case "format":
+			s.Format = value
+		case "minItems":
+			s.MinItems, err = strconv.ParseInt(value, 10, 64)
+		case "maxItems":
+			s.MaxItems, err = strconv.ParseInt(value, 10, 64)
+		case "minLength":
+			s.MinLength, err = strconv.ParseInt(value, 10, 64)
+		case "maxLength":
+			s.MaxLength, err = strconv.ParseInt(value, 10, 64)
+		case "minimum":
+			s.Minimum, err = strconv.ParseFloat(value, 64)
+		case "maximum":
+			s.Maximum, err = strconv.ParseFloat(value, 64)
+		default:
+			return fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return fmt.Errorf("option %q: %w", key, err)
+		}
+	}
+	return nil
 }
 
 // goTypeToGenaiType: Go の型を genai の型に変換
@@ -84,7 +345,8 @@ func goTypeToGenaiType(t reflect.Type) genai.Type {
 	switch t.Kind() {
 	case reflect.String:
 		return genai.TypeString
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return genai.TypeInteger
 	case reflect.Float32, reflect.Float64:
 		return genai.TypeNumber
@@ -92,7 +354,7 @@ func goTypeToGenaiType(t reflect.Type) genai.Type {
 		return genai.TypeBoolean
 	case reflect.Slice, reflect.Array:
 		return genai.TypeArray
-	case reflect.Struct:
+	case reflect.Struct, reflect.Map:
 		return genai.TypeObject
 	default:
 		return genai.TypeUnspecified
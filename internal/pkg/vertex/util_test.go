@@ -2,8 +2,12 @@ package vertex
 
 import (
 	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/vertexai/genai"
 )
@@ -15,52 +19,57 @@ type TestStruct struct {
 	Valid bool    `json:"valid"`
 }
 
+// mustSchema はテスト用に GenerateSchema のエラーで止める
+func mustSchema[T any](t *testing.T) *genai.Schema {
+	t.Helper()
+	schema, err := GenerateSchema[T]()
+	if err != nil {
+		t.Fatalf("GenerateSchema failed: %v", err)
+	}
+	return schema
+}
+
 func TestGenerateSchema(t *testing.T) {
 	tests := []struct {
 		name     string
-		expected map[string]*genai.Schema
+		result   *genai.Schema
+		expected *genai.Schema
 	}{
 		{
-			name: "Valid struct",
-			expected: map[string]*genai.Schema{
-				"name":  {Type: genai.TypeString},
-				"age":   {Type: genai.TypeInteger},
-				"score": {Type: genai.TypeNumber},
-				"valid": {Type: genai.TypeBoolean},
+			name:   "Valid struct",
+			result: mustSchema[TestStruct](t),
+			expected: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"name":  {Type: genai.TypeString},
+					"age":   {Type: genai.TypeInteger},
+					"score": {Type: genai.TypeNumber},
+					"valid": {Type: genai.TypeBoolean},
+				},
+				Required: []string{"name", "age", "score", "valid"},
 			},
 		},
 		{
 			name: "Struct with array",
-			expected: map[string]*genai.Schema{
-				"items": {Type: genai.TypeArray},
+			result: mustSchema[struct {
+				Items []TestStruct `json:"items"`
+			}](t),
+			expected: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"items": {Type: genai.TypeArray, Items: mustSchema[TestStruct](t)},
+				},
+				Required: []string{"items"},
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var result map[string]*genai.Schema
-			if tt.name == "Valid struct" {
-				result = map[string]*genai.Schema{
-					"name":  {Type: genai.TypeString},
-					"age":   {Type: genai.TypeInteger},
-					"score": {Type: genai.TypeNumber},
-					"valid": {Type: genai.TypeBoolean},
-				}
-			} else if tt.name == "Struct with array" {
-				result = map[string]*genai.Schema{
-					"items": GenerateSchema[struct {
-						Items []TestStruct `json:"items"`
-					}](),
-				}
-			}
-
-			if !reflect.DeepEqual(result, tt.expected) {
-				t.Errorf("GenerateSchema() = %v, expected %v", result, tt.expected)
-			} else {
-				t.Logf("GenerateSchema() = %v, as expected", result)
+			if !reflect.DeepEqual(tt.result, tt.expected) {
+				t.Errorf("GenerateSchema() = %v, expected %v", tt.result, tt.expected)
 			}
-			schemaJSON, err := json.MarshalIndent(result, "", "  ")
+			schemaJSON, err := json.MarshalIndent(tt.result, "", "  ")
 			if err != nil {
 				t.Errorf("Error marshaling schema: %v", err)
 				return
@@ -71,6 +80,127 @@ func TestGenerateSchema(t *testing.T) {
 	}
 }
 
+var updateGolden = flag.Bool("update", false, "update golden files in testdata/schema")
+
+type schemaBase struct {
+	ID        int       `json:"id" description:"sequential id"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+type schemaTagged struct {
+	schemaBase
+	Phrase     string            `json:"phrase" schema:"minLength=1,maxLength=255"`
+	Difficulty string            `json:"difficulty" schema:"enum=easy|intermediate|advanced" description:"CEFR-like difficulty"`
+	Note       *string           `json:"note,omitempty"`
+	Examples   []string          `json:"examples" schema:"minItems=1,maxItems=3"`
+	Score      float64           `json:"score" schema:"minimum=0,maximum=1,optional"`
+	Extra      map[string]string `json:"extra,omitempty"`
+	Ignored    string            `json:"-"`
+	untagged   string
+}
+
+type schemaNode struct {
+	Name     string        `json:"name"`
+	Children []*schemaNode `json:"children,omitempty"`
+}
+
+func TestGenerateSchema_Golden(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *genai.Schema
+	}{
+		{"tagged", mustSchema[schemaTagged](t)},
+		{"tagged_list", mustSchema[[]schemaTagged](t)},
+		{"recursive", mustSchema[schemaNode](t)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(tt.schema, "", "  ")
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", "schema", tt.name+".golden")
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden (run with -update to create): %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("schema mismatch for %s\ngot:\n%s\nwant:\n%s", tt.name, got, want)
+			}
+		})
+	}
+}
+
+func TestGenerateSchema_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func() error
+	}{
+		{"unknown schema option", func() error {
+			_, err := GenerateSchema[struct {
+				Name string `json:"name" schema:"bogus=1"`
+			}]()
+			return err
+		}},
+		{"map key", func() error {
+			_, err := GenerateSchema[struct {
+				Extra map[bool]string `json:"extra"`
+			}]()
+			return err
+		}},
+		{"required recursive field", func() error {
+			type node struct {
+				Next *node `json:"next"`
+			}
+			_, err := GenerateSchema[node]()
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.generate(); err == nil {
+				t.Fatalf("expected an error for %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestDecodeJsonContent_RestoresMaps(t *testing.T) {
+	type item struct {
+		Name   string            `json:"name"`
+		Extra  map[string]string `json:"extra"`
+		Counts map[int]int       `json:"counts"`
+	}
+	raw := json.RawMessage(`[{"name": "a", "extra": [{"key": "lang", "value": "de"}], "counts": [{"key": 1, "value": 2}]},
+		{"name": "b", "extra": {"lang": "en"}}]`)
+
+	items, err := DecodeJsonContent[[]item](raw)
+	if err != nil {
+		t.Fatalf("DecodeJsonContent failed: %v", err)
+	}
+	if len(items) != 2 || items[0].Extra["lang"] != "de" || items[0].Counts[1] != 2 || items[1].Extra["lang"] != "en" {
+		t.Errorf("maps were not restored: %+v", items)
+	}
+
+	// 生成したスキーマに {key, value} の配列で合う
+	schema := mustSchema[[]item](t)
+	if errs := ValidateJSON(json.RawMessage(`[{"name": "a", "extra": [{"key": "lang", "value": "de"}], "counts": []}]`), schema); len(errs) != 0 {
+		t.Errorf("entries do not match the schema: %v", errs)
+	}
+}
+
 func TestGoTypeToGenaiType(t *testing.T) {
 	tests := []struct {
 		name     string
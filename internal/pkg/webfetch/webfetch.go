@@ -0,0 +1,234 @@
+// Package webfetch は利用者が指定した URL のページを取得する。
+// 内部のネットワーク (プライベート・ループバックなど) への接続を拒み、時間と大きさを制限する
+package webfetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrInvalidURL = errors.New("invalid URL, expected http or https")
+	// 接続先がプライベートなアドレスなど、外に公開されていない
+	ErrBlockedAddress         = errors.New("address is not allowed")
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+	ErrTooLarge               = errors.New("response is too large")
+	ErrTooManyRedirects       = errors.New("too many redirects")
+)
+
+// StatusError は 2xx 以外の応答
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
+}
+
+type Options struct {
+	Timeout      time.Duration
+	MaxBytes     int64
+	MaxRedirects int
+	// 受け付ける Content-Type (パラメータなし)
+	ContentTypes []string
+	// AllowPrivate ならプライベートなアドレスにも接続する (テスト用)
+	AllowPrivate bool
+	UserAgent    string
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Timeout:      15 * time.Second,
+		MaxBytes:     5 << 20,
+		MaxRedirects: 5,
+		ContentTypes: []string{"text/html", "application/xhtml+xml", "text/plain"},
+		UserAgent:    "newln/1.0 (+https://github.com/yomek33/newln)",
+	}
+}
+
+// Page は取得したページ。Body は UTF-8 に変換してある
+type Page struct {
+	// リダイレクトの後の URL
+	URL         string
+	ContentType string
+	Body        []byte
+}
+
+type Fetcher struct {
+	opts   Options
+	client *http.Client
+}
+
+func New(opts Options) *Fetcher {
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		// 名前解決の後の実際の接続先を確かめる (DNS rebinding も防ぐ)
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !opts.AllowPrivate && !PublicIP(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		// 環境変数のプロキシを通すと接続先を確かめられない
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidURL
+			}
+			return nil
+		},
+	}
+	return &Fetcher{opts: opts, client: client}
+}
+
+// NormalizeURL は http(s) の絶対 URL だけを受け付け、フラグメントを外してホストを小文字にする
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return "", ErrInvalidURL
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String(), nil
+}
+
+// Fetch は rawURL を GET し、本体を UTF-8 にして返す
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	target, err := NormalizeURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", f.opts.UserAgent)
+	req.Header.Set("Accept", strings.Join(f.opts.ContentTypes, ", "))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrBlockedAddress):
+			return nil, ErrBlockedAddress
+		case errors.Is(err, ErrTooManyRedirects):
+			return nil, ErrTooManyRedirects
+		case errors.Is(err, ErrInvalidURL):
+			return nil, ErrInvalidURL
+		}
+		return nil, fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	// 本体を読む前に Content-Type を確かめる。なければ中身から決める
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !f.acceptable(contentType) {
+		return nil, ErrUnsupportedContentType
+	}
+	if resp.ContentLength > f.opts.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.opts.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", target, err)
+	}
+	if int64(len(body)) > f.opts.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+		if !f.acceptable(contentType) {
+			return nil, ErrUnsupportedContentType
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return &Page{URL: resp.Request.URL.String(), ContentType: mediaType, Body: toUTF8(body, contentType)}, nil
+}
+
+func (f *Fetcher) acceptable(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && slices.Contains(f.opts.ContentTypes, mediaType)
+}
+
+// toUTF8 は Content-Type や <meta charset> の文字コードから UTF-8 に変換する
+func toUTF8(body []byte, contentType string) []byte {
+	r, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return body
+	}
+	converted, err := io.ReadAll(r)
+	if err != nil {
+		return body
+	}
+	return converted
+}
+
+// 公開されていないアドレスの範囲 (RFC 6890 など)
+var blockedNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+		"172.16.0.0/12", "192.0.0.0/24", "192.0.2.0/24", "192.168.0.0/16", "198.18.0.0/15",
+		"198.51.100.0/24", "203.0.113.0/24", "224.0.0.0/4", "240.0.0.0/4",
+		"::/128", "::1/128", "64:ff9b::/96", "100::/64", "2001:db8::/32", "fc00::/7", "fe80::/10", "ff00::/8",
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// PublicIP は ip がインターネットに公開されたアドレスか
+func PublicIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package prompteval
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yomek33/newln/internal/pkg/vertex"
+	"github.com/yomek33/newln/internal/services"
+	"github.com/yomek33/newln/internal/services/prompts"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+var validDifficulties = []string{"easy", "intermediate", "advanced"}
+
+// Spec はプロンプトごとのスキーマと検査
+type Spec struct {
+	Schema *genai.Schema
+	Check  func(result *Result, fixture Fixture, raw json.RawMessage, opts Options)
+}
+
+func SpecFor(name prompts.Name) (Spec, error) {
+	switch name {
+	case prompts.GeneratePhrases:
+		return specOf[[]services.PhraseResponse](checkPhrases)
+	case prompts.GenerateWords:
+		return specOf[[]services.WordResponse](checkWords)
+	case prompts.GeneratePhraseMeanings:
+		return specOf[[]services.PhraseWithMeaning](checkPhraseMeanings)
+	case prompts.GenerateWordsMeanings:
+		return specOf[[]services.WordWithMeaning](checkWordMeanings)
+	default:
+		return Spec{}, fmt.Errorf("no evaluation spec for prompt %s", name)
+	}
+}
+
+func specOf[T any](check func(result *Result, fixture Fixture, raw json.RawMessage, opts Options)) (Spec, error) {
+	schema, err := vertex.GenerateSchema[T]()
+	if err != nil {
+		return Spec{}, err
+	}
+	return Spec{Schema: schema, Check: check}, nil
+}
+
+// checkSchema はスキーマ違反を Failures に積み、デコードできたかを返す
+func checkSchema[T any](result *Result, raw json.RawMessage) (T, bool) {
+	schema, err := vertex.GenerateSchema[T]()
+	if err != nil {
+		result.Failures = append(result.Failures, "schema: "+err.Error())
+		return *new(T), false
+	}
+	for _, err := range vertex.ValidateJSON(raw, schema) {
+		result.Failures = append(result.Failures, "schema: "+err.Error())
+	}
+	decoded, err := vertex.DecodeJsonContent[T](raw)
+	if err != nil {
+		result.Failures = append(result.Failures, "decode: "+err.Error())
+		return decoded, false
+	}
+	return decoded, true
+}
+
+func checkMinItems(result *Result, count, min int) {
+	result.Metrics["items"] = float64(count)
+	if count < min {
+		result.Failures = append(result.Failures, fmt.Sprintf("expected at least %d items, got %d", min, count))
+	}
+}
+
+func checkDifficulty(result *Result, label, difficulty string) {
+	if !contains(validDifficulties, difficulty) {
+		result.Failures = append(result.Failures, fmt.Sprintf("%q: invalid difficulty %q", label, difficulty))
+	}
+}
+
+func checkPhrases(result *Result, fixture Fixture, raw json.RawMessage, opts Options) {
+	phrases, ok := checkSchema[[]services.PhraseResponse](result, raw)
+	if !ok {
+		return
+	}
+	checkMinItems(result, len(phrases), opts.MinItems)
+
+	text := strings.ToLower(fixture.Text)
+	claimed, accurate := 0, 0
+	for _, phrase := range phrases {
+		result.Items = append(result.Items, phrase.Phrase)
+		checkDifficulty(result, phrase.Phrase, phrase.Difficulty)
+		if phrase.FromText {
+			claimed++
+			if strings.Contains(text, strings.ToLower(phrase.Phrase)) {
+				accurate++
+			}
+		}
+	}
+
+	if claimed > 0 {
+		accuracy := float64(accurate) / float64(claimed)
+		result.Metrics["from_text_accuracy"] = accuracy
+		if accuracy < opts.MinFromTextAccuracy {
+			result.Failures = append(result.Failures, fmt.Sprintf("from_text accuracy %.2f below %.2f", accuracy, opts.MinFromTextAccuracy))
+		}
+	}
+}
+
+func checkWords(result *Result, fixture Fixture, raw json.RawMessage, opts Options) {
+	words, ok := checkSchema[[]services.WordResponse](result, raw)
+	if !ok {
+		return
+	}
+	checkMinItems(result, len(words), opts.MinItems)
+
+	text := strings.ToLower(fixture.Text)
+	inText := 0
+	for _, word := range words {
+		result.Items = append(result.Items, word.Word)
+		if strings.TrimSpace(word.Word) == "" {
+			result.Failures = append(result.Failures, fmt.Sprintf("item %d: empty word", word.ID))
+		}
+		if strings.TrimSpace(word.Pos) == "" {
+			result.Failures = append(result.Failures, fmt.Sprintf("%q: empty pos", word.Word))
+		}
+		if strings.Contains(text, strings.ToLower(word.Word)) {
+			inText++
+		}
+	}
+	if len(words) > 0 {
+		result.Metrics["in_text_ratio"] = float64(inText) / float64(len(words))
+	}
+}
+
+// 意味生成プロンプトはフィクスチャをカンマ区切りの入力リストとして扱う
+func inputItems(fixture Fixture) []string {
+	var items []string
+	for _, item := range strings.Split(fixture.Text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func checkPhraseMeanings(result *Result, fixture Fixture, raw json.RawMessage, opts Options) {
+	phrases, ok := checkSchema[[]services.PhraseWithMeaning](result, raw)
+	if !ok {
+		return
+	}
+	checkMinItems(result, len(phrases), len(inputItems(fixture)))
+	for _, phrase := range phrases {
+		result.Items = append(result.Items, phrase.Phrase)
+		checkDifficulty(result, phrase.Phrase, phrase.Difficulty)
+		if strings.TrimSpace(phrase.Meaning) == "" || strings.TrimSpace(phrase.Translation) == "" {
+			result.Failures = append(result.Failures, fmt.Sprintf("%q: missing meaning", phrase.Phrase))
+		}
+	}
+}
+
+func checkWordMeanings(result *Result, fixture Fixture, raw json.RawMessage, opts Options) {
+	words, ok := checkSchema[[]services.WordWithMeaning](result, raw)
+	if !ok {
+		return
+	}
+	checkMinItems(result, len(words), len(inputItems(fixture)))
+	for _, word := range words {
+		result.Items = append(result.Items, word.Word)
+		if strings.TrimSpace(word.Meaning) == "" || strings.TrimSpace(word.Translation) == "" {
+			result.Failures = append(result.Failures, fmt.Sprintf("%q: missing meaning", word.Word))
+		}
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
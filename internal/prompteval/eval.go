@@ -0,0 +1,148 @@
+// Package prompteval はプロンプトのバージョンをフィクスチャのテキストで評価する
+package prompteval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yomek33/newln/internal/services/prompts"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// Fixture は評価に使う入力テキスト
+type Fixture struct {
+	Name string
+	Text string
+}
+
+// LoadFixtures は dir 直下の *.txt を名前順に読み込む
+func LoadFixtures(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var fixtures []Fixture
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", path, err)
+		}
+		fixtures = append(fixtures, Fixture{
+			Name: strings.TrimSuffix(filepath.Base(path), ".txt"),
+			Text: strings.TrimSpace(string(raw)),
+		})
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+	return fixtures, nil
+}
+
+// Request はプロバイダに渡す1回分の生成リクエスト
+type Request struct {
+	Fixture Fixture
+	Prompt  *prompts.Prompt
+	Text    string
+	Schema  *genai.Schema
+}
+
+// Provider はプロンプトを実行してJSONを返す
+type Provider interface {
+	Generate(ctx context.Context, req Request) (json.RawMessage, error)
+}
+
+// Options は評価のしきい値
+type Options struct {
+	// MinItems が 0 のときは Data.Count を使う
+	MinItems int
+	// from_text=true の項目のうち本文に実際に現れる割合の下限
+	MinFromTextAccuracy float64
+	Data                prompts.Data
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MinFromTextAccuracy: 0.8,
+		Data:                prompts.NewData(""),
+	}
+}
+
+// Result は1フィクスチャ × 1バージョンの評価結果
+type Result struct {
+	Fixture  string
+	PromptID string
+	Items    []string
+	Metrics  map[string]float64
+	Failures []string
+	Latency  time.Duration
+	Err      error
+}
+
+func (r Result) Passed() bool {
+	return r.Err == nil && len(r.Failures) == 0
+}
+
+// NoRecording は記録済みの応答がなく、評価できなかったかを返す
+func (r Result) NoRecording() bool {
+	return errors.Is(r.Err, ErrNoRecording)
+}
+
+type Runner struct {
+	Provider Provider
+	Options  Options
+}
+
+// Run は name の version を全フィクスチャに対して実行する
+func (r *Runner) Run(ctx context.Context, name prompts.Name, version string, fixtures []Fixture) ([]Result, error) {
+	spec, err := SpecFor(name)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := prompts.LoadVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(fixtures))
+	for _, fixture := range fixtures {
+		results = append(results, r.runOne(ctx, spec, prompt, fixture))
+	}
+	return results, nil
+}
+
+func (r *Runner) runOne(ctx context.Context, spec Spec, prompt *prompts.Prompt, fixture Fixture) Result {
+	result := Result{Fixture: fixture.Name, PromptID: prompt.ID(), Metrics: map[string]float64{}}
+
+	data := r.Options.Data
+	data.Text = fixture.Text
+	text, err := prompt.Render(data)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	start := time.Now()
+	raw, err := r.Provider.Generate(ctx, Request{Fixture: fixture, Prompt: prompt, Text: text, Schema: spec.Schema})
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = fmt.Errorf("generation failed: %w", err)
+		return result
+	}
+
+	opts := r.Options
+	if opts.MinItems == 0 {
+		opts.MinItems = data.Count
+	}
+	spec.Check(&result, fixture, raw, opts)
+	return result
+}
@@ -0,0 +1,103 @@
+package prompteval
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yomek33/newln/internal/services/prompts"
+)
+
+type stubProvider struct {
+	responses map[string]string
+}
+
+func (p *stubProvider) Generate(_ context.Context, req Request) (json.RawMessage, error) {
+	return json.RawMessage(p.responses[req.Fixture.Name]), nil
+}
+
+func TestRunner_Phrases(t *testing.T) {
+	fixtures := []Fixture{
+		{Name: "good", Text: "They closed a deal in operation."},
+		{Name: "bad", Text: "They closed a deal in operation."},
+		{Name: "broken", Text: "anything"},
+	}
+	provider := &stubProvider{responses: map[string]string{
+		"good": `[{"phrase":"closed a deal","from_text":true,"example":"e","difficulty":"easy"},
+		          {"phrase":"in operation","from_text":true,"example":"e","difficulty":"advanced"}]`,
+		"bad": `[{"phrase":"make a call","from_text":true,"example":"e","difficulty":"hard"},
+		         {"phrase":"in operation","from_text":true,"example":"e","difficulty":"easy"}]`,
+		"broken": `[{"phrase":"x","from_text":"yes"`,
+	}}
+
+	runner := &Runner{Provider: provider, Options: DefaultOptions()}
+	runner.Options.MinItems = 2
+	results, err := runner.Run(context.Background(), prompts.GeneratePhrases, prompts.DefaultVersion, fixtures)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !results[0].Passed() {
+		t.Errorf("expected good fixture to pass, failures: %v", results[0].Failures)
+	}
+
+	bad := strings.Join(results[1].Failures, "\n")
+	if !strings.Contains(bad, `invalid difficulty "hard"`) || !strings.Contains(bad, "from_text accuracy 0.50") {
+		t.Errorf("unexpected failures for bad fixture: %v", results[1].Failures)
+	}
+
+	if results[2].Passed() || !strings.Contains(strings.Join(results[2].Failures, "\n"), "invalid JSON") {
+		t.Errorf("expected broken fixture to fail schema validation, got %v", results[2].Failures)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := []Result{{Fixture: "a", Items: []string{"close a deal", "in operation"}}}
+	candidate := []Result{{Fixture: "a", Items: []string{"Close a deal", "resolve issues"}}}
+
+	diffs := Compare(base, candidate)
+	if len(diffs) != 1 {
+		t.Fatalf("expected 1 diff, got %d", len(diffs))
+	}
+	if got := diffs[0].Added; len(got) != 1 || got[0] != "resolve issues" {
+		t.Errorf("Added = %v", got)
+	}
+	if got := diffs[0].Removed; len(got) != 1 || got[0] != "in operation" {
+		t.Errorf("Removed = %v", got)
+	}
+}
+
+func TestRecordedProvider_NoRecording(t *testing.T) {
+	dir := t.TempDir()
+	fixtures := []Fixture{{Name: "memo", Text: "They closed a deal."}}
+	provider := &RecordedProvider{Dir: dir}
+	v1 := &prompts.Prompt{Name: prompts.GeneratePhrases, Version: "v1"}
+	if err := provider.Save(Request{Fixture: fixtures[0], Prompt: v1}, json.RawMessage(`[{"phrase":"closed a deal","from_text":true,"example":"e","difficulty":"easy"}]`)); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	runner := &Runner{Provider: provider, Options: DefaultOptions()}
+	runner.Options.MinItems = 1
+	base, err := runner.Run(context.Background(), prompts.GeneratePhrases, "v1", fixtures)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	candidate, err := runner.Run(context.Background(), prompts.GeneratePhrases, prompts.DefaultVersion, fixtures)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if base[0].NoRecording() || !base[0].Passed() {
+		t.Errorf("expected recorded version to pass: %+v", base[0])
+	}
+	if !candidate[0].NoRecording() || candidate[0].Passed() {
+		t.Errorf("expected missing recording: %+v", candidate[0])
+	}
+
+	var report strings.Builder
+	WriteResults(&report, candidate)
+	WriteDiff(&report, Compare(base, candidate))
+	if !strings.Contains(report.String(), "NONE  memo") || !strings.Contains(report.String(), "not compared: no recording for generate_phrases@"+prompts.DefaultVersion) {
+		t.Errorf("unexpected report:\n%s", report.String())
+	}
+}
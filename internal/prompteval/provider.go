@@ -0,0 +1,71 @@
+package prompteval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/yomek33/newln/internal/pkg/vertex"
+)
+
+// VertexProvider は vertex.VertexService (本番・モック) でプロンプトを実行する
+type VertexProvider struct {
+	Client vertex.VertexService
+}
+
+func (p *VertexProvider) Generate(ctx context.Context, req Request) (json.RawMessage, error) {
+	return p.Client.GenerateJsonContent(ctx, req.Text, req.Schema)
+}
+
+// ErrNoRecording はそのバージョンの応答がまだ記録されていないときに返す
+var ErrNoRecording = errors.New("no recording")
+
+// RecordedProvider は <Dir>/<version>/<prompt>/<fixture>.json に保存済みの応答を返す
+type RecordedProvider struct {
+	Dir string
+}
+
+func (p *RecordedProvider) path(req Request) string {
+	return filepath.Join(p.Dir, req.Prompt.Version, string(req.Prompt.Name), req.Fixture.Name+".json")
+}
+
+func (p *RecordedProvider) Generate(_ context.Context, req Request) (json.RawMessage, error) {
+	raw, err := os.ReadFile(p.path(req))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s/%s", ErrNoRecording, req.Prompt.ID(), req.Fixture.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording for %s/%s: %w", req.Prompt.ID(), req.Fixture.Name, err)
+	}
+	return json.RawMessage(raw), nil
+}
+
+// Save は応答を記録する (RecordingProvider から使う)
+func (p *RecordedProvider) Save(req Request, raw json.RawMessage) error {
+	path := p.path(req)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o644)
+}
+
+// RecordingProvider は Inner の応答を Store に保存しながら返す
+type RecordingProvider struct {
+	Inner Provider
+	Store *RecordedProvider
+}
+
+func (p *RecordingProvider) Generate(ctx context.Context, req Request) (json.RawMessage, error) {
+	raw, err := p.Inner.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Store.Save(req, raw); err != nil {
+		return nil, fmt.Errorf("failed to save recording: %w", err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,165 @@
+package prompteval
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// FixtureDiff は同じフィクスチャに対する2バージョンの差分
+type FixtureDiff struct {
+	Fixture   string
+	Base      Result
+	Candidate Result
+	Added     []string
+	Removed   []string
+}
+
+// Compare はフィクスチャ名で結果を突き合わせる
+func Compare(base, candidate []Result) []FixtureDiff {
+	byFixture := make(map[string]Result, len(candidate))
+	for _, r := range candidate {
+		byFixture[r.Fixture] = r
+	}
+
+	var diffs []FixtureDiff
+	for _, b := range base {
+		c, ok := byFixture[b.Fixture]
+		if !ok {
+			continue
+		}
+		added, removed := diffItems(b.Items, c.Items)
+		diffs = append(diffs, FixtureDiff{
+			Fixture:   b.Fixture,
+			Base:      b,
+			Candidate: c,
+			Added:     added,
+			Removed:   removed,
+		})
+	}
+	return diffs
+}
+
+// diffItems は大文字小文字を無視して集合の差をとる
+func diffItems(base, candidate []string) (added, removed []string) {
+	baseSet := make(map[string]bool, len(base))
+	for _, item := range base {
+		baseSet[strings.ToLower(item)] = true
+	}
+	candidateSet := make(map[string]bool, len(candidate))
+	for _, item := range candidate {
+		key := strings.ToLower(item)
+		candidateSet[key] = true
+		if !baseSet[key] {
+			added = append(added, item)
+		}
+	}
+	for _, item := range base {
+		if !candidateSet[strings.ToLower(item)] {
+			removed = append(removed, item)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// WriteResults は1バージョン分の結果を書き出す
+func WriteResults(w io.Writer, results []Result) {
+	passed := 0
+	for _, r := range results {
+		if r.NoRecording() {
+			fmt.Fprintf(w, "NONE  %-24s %s  no recording (record it with -provider vertex -record)\n", r.Fixture, r.PromptID)
+			continue
+		}
+		status := "PASS"
+		if !r.Passed() {
+			status = "FAIL"
+		} else {
+			passed++
+		}
+		fmt.Fprintf(w, "%s  %-24s %s  (%d items, %v)%s\n", status, r.Fixture, r.PromptID, len(r.Items), r.Latency.Round(1e6), formatMetrics(r.Metrics))
+		if r.Err != nil {
+			fmt.Fprintf(w, "      error: %v\n", r.Err)
+		}
+		for _, failure := range r.Failures {
+			fmt.Fprintf(w, "      - %s\n", failure)
+		}
+	}
+	fmt.Fprintf(w, "\n%d/%d fixtures passed\n", passed, len(results))
+}
+
+// WriteDiff は2バージョンの差分レポートを書き出す。どちらかの記録がないフィクスチャは比べない
+func WriteDiff(w io.Writer, diffs []FixtureDiff) {
+	for _, d := range diffs {
+		fmt.Fprintf(w, "## %s: %s -> %s\n", d.Fixture, d.Base.PromptID, d.Candidate.PromptID)
+		if d.Base.NoRecording() || d.Candidate.NoRecording() {
+			fmt.Fprintf(w, "not compared: %s\n\n", noRecordingLabel(d))
+			continue
+		}
+		fmt.Fprintf(w, "status: %s -> %s\n", passLabel(d.Base), passLabel(d.Candidate))
+		fmt.Fprintf(w, "items:  %d -> %d\n", len(d.Base.Items), len(d.Candidate.Items))
+
+		for _, key := range metricKeys(d.Base.Metrics, d.Candidate.Metrics) {
+			if key == "items" {
+				continue
+			}
+			b, c := d.Base.Metrics[key], d.Candidate.Metrics[key]
+			fmt.Fprintf(w, "%s: %.2f -> %.2f (%+.2f)\n", key, b, c, c-b)
+		}
+		for _, item := range d.Added {
+			fmt.Fprintf(w, "+ %s\n", item)
+		}
+		for _, item := range d.Removed {
+			fmt.Fprintf(w, "- %s\n", item)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func noRecordingLabel(d FixtureDiff) string {
+	var missing []string
+	for _, r := range []Result{d.Base, d.Candidate} {
+		if r.NoRecording() {
+			missing = append(missing, r.PromptID)
+		}
+	}
+	return "no recording for " + strings.Join(missing, " and ")
+}
+
+func passLabel(r Result) string {
+	if r.Passed() {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+func metricKeys(maps ...map[string]float64) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatMetrics(metrics map[string]float64) string {
+	var parts []string
+	for _, key := range metricKeys(metrics) {
+		if key == "items" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%.2f", key, metrics[key]))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, " ")
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/stores"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxCollectionNameLength = 255
+	maxCollectionMaterials  = 500
+)
+
+var (
+	ErrInvalidCollection  = errors.New("invalid collection")
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrUnknownMaterial    = errors.New("material not found")
+	// 自分自身や配下のコレクションを親にはできない
+	ErrCollectionCycle = errors.New("collection cannot be moved under itself")
+)
+
+// CollectionNode はコレクションの木の1節
+type CollectionNode struct {
+	ID       uint              `json:"id"`
+	ParentID *uint             `json:"parent_id"`
+	Name     string            `json:"name"`
+	Position int               `json:"position"`
+	Children []*CollectionNode `json:"children"`
+}
+
+// CollectionUpdate は変更する項目だけを持つ。MoveToRoot なら ParentID を無視して最上位に移す
+type CollectionUpdate struct {
+	Name       *string
+	ParentID   *uint
+	MoveToRoot bool
+	Position   *int
+}
+
+type CollectionService interface {
+	ListCollections(userID uuid.UUID) ([]*CollectionNode, error)
+	CreateCollection(userID uuid.UUID, name string, parentID *uint, position int) (*CollectionNode, error)
+	UpdateCollection(userID uuid.UUID, id uint, update CollectionUpdate) (*CollectionNode, error)
+	DeleteCollection(userID uuid.UUID, id uint) error
+	ListMaterials(userID uuid.UUID, id uint) ([]models.MaterialSummary, error)
+	SetMaterials(userID uuid.UUID, id uint, ulids []string) ([]models.MaterialSummary, error)
+}
+
+type collectionService struct {
+	store         stores.CollectionStore
+	materialStore stores.MaterialStore
+}
+
+func NewCollectionService(s stores.CollectionStore, materialStore stores.MaterialStore) CollectionService {
+	return &collectionService{store: s, materialStore: materialStore}
+}
+
+func normalizeCollectionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxCollectionNameLength {
+		return "", ErrInvalidCollection
+	}
+	return name, nil
+}
+
+func newCollectionNode(collection models.Collection) *CollectionNode {
+	return &CollectionNode{
+		ID:       collection.ID,
+		ParentID: collection.ParentID,
+		Name:     collection.Name,
+		Position: collection.Position,
+		Children: []*CollectionNode{},
+	}
+}
+
+// buildCollectionTree は親・位置の順に並んだ collections を木にする
+func buildCollectionTree(collections []models.Collection) []*CollectionNode {
+	nodes := make(map[uint]*CollectionNode, len(collections))
+	for _, collection := range collections {
+		nodes[collection.ID] = newCollectionNode(collection)
+	}
+	roots := []*CollectionNode{}
+	for _, collection := range collections {
+		node := nodes[collection.ID]
+		if collection.ParentID != nil {
+			if parent, ok := nodes[*collection.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+func (s *collectionService) ListCollections(userID uuid.UUID) ([]*CollectionNode, error) {
+	collections, err := s.store.ListCollections(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	return buildCollectionTree(collections), nil
+}
+
+func (s *collectionService) CreateCollection(userID uuid.UUID, name string, parentID *uint, position int) (*CollectionNode, error) {
+	name, err := normalizeCollectionName(name)
+	if err != nil {
+		return nil, err
+	}
+	if position < 0 {
+		return nil, ErrInvalidCollection
+	}
+	if parentID != nil {
+		if _, err := s.getCollection(userID, *parentID); err != nil {
+			return nil, err
+		}
+	}
+	collection := &models.Collection{UserID: userID, ParentID: parentID, Name: name, Position: position}
+	if err := s.store.CreateCollection(collection); err != nil {
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+	return newCollectionNode(*collection), nil
+}
+
+func (s *collectionService) UpdateCollection(userID uuid.UUID, id uint, update CollectionUpdate) (*CollectionNode, error) {
+	collection, err := s.getCollection(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if update.Name != nil {
+		if collection.Name, err = normalizeCollectionName(*update.Name); err != nil {
+			return nil, err
+		}
+	}
+	if update.Position != nil {
+		if *update.Position < 0 {
+			return nil, ErrInvalidCollection
+		}
+		collection.Position = *update.Position
+	}
+	switch {
+	case update.MoveToRoot:
+		collection.ParentID = nil
+	case update.ParentID != nil:
+		if err := s.checkParent(userID, id, *update.ParentID); err != nil {
+			return nil, err
+		}
+		collection.ParentID = update.ParentID
+	}
+	if err := s.store.UpdateCollection(collection); err != nil {
+		return nil, fmt.Errorf("failed to update collection: %w", err)
+	}
+	return newCollectionNode(*collection), nil
+}
+
+// checkParent は parentID が id 自身やその配下でないことを確かめる
+func (s *collectionService) checkParent(userID uuid.UUID, id, parentID uint) error {
+	collections, err := s.store.ListCollections(userID)
+	if err != nil {
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+	parents := make(map[uint]*uint, len(collections))
+	for _, collection := range collections {
+		parents[collection.ID] = collection.ParentID
+	}
+	if _, ok := parents[parentID]; !ok {
+		return ErrCollectionNotFound
+	}
+	// 親を根までたどり、途中で id に当たったら循環する
+	for current := &parentID; current != nil; current = parents[*current] {
+		if *current == id {
+			return ErrCollectionCycle
+		}
+	}
+	return nil
+}
+
+// DeleteCollection はコレクションを配下ごと削除する。中の教材は残る
+func (s *collectionService) DeleteCollection(userID uuid.UUID, id uint) error {
+	err := s.store.DeleteCollection(userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCollectionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	return nil
+}
+
+func (s *collectionService) ListMaterials(userID uuid.UUID, id uint) ([]models.MaterialSummary, error) {
+	if _, err := s.getCollection(userID, id); err != nil {
+		return nil, err
+	}
+	materials, err := s.store.ListCollectionMaterials(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collection materials: %w", err)
+	}
+	if materials == nil {
+		materials = []models.MaterialSummary{}
+	}
+	return materials, nil
+}
+
+// SetMaterials はコレクションの教材を ulids の順に置き換える。見つからない教材があれば ErrUnknownMaterial
+func (s *collectionService) SetMaterials(userID uuid.UUID, id uint, ulids []string) ([]models.MaterialSummary, error) {
+	if len(ulids) > maxCollectionMaterials {
+		return nil, ErrInvalidCollection
+	}
+	if _, err := s.getCollection(userID, id); err != nil {
+		return nil, err
+	}
+	found, err := s.materialStore.GetMaterialIDs(ulids, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get materials: %w", err)
+	}
+	ids := make([]uint, 0, len(ulids))
+	seen := make(map[string]bool, len(ulids))
+	for _, ulid := range ulids {
+		materialID, ok := found[ulid]
+		if !ok {
+			return nil, ErrUnknownMaterial
+		}
+		if !seen[ulid] {
+			seen[ulid] = true
+			ids = append(ids, materialID)
+		}
+	}
+	if err := s.store.SetCollectionMaterials(id, ids); err != nil {
+		return nil, fmt.Errorf("failed to set collection materials: %w", err)
+	}
+	return s.ListMaterials(userID, id)
+}
+
+func (s *collectionService) getCollection(userID uuid.UUID, id uint) (*models.Collection, error) {
+	collection, err := s.store.GetCollection(userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	return collection, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/yomek33/newln/internal/models"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+	"gorm.io/gorm"
+)
+
+func TestCollectionService(t *testing.T) {
+	userID := uuid.New()
+	newService := func() (*collectionService, *stores_mock.MockCollectionStore) {
+		materials := stores_mock.NewMockMaterialStore()
+		materials.Materials[1] = &models.Material{Model: gorm.Model{ID: 1}, UserID: userID, ULID: "ted", Title: "TED"}
+		materials.Materials[2] = &models.Material{Model: gorm.Model{ID: 2}, UserID: userID, ULID: "mail", Title: "Mail"}
+		materials.Materials[3] = &models.Material{Model: gorm.Model{ID: 3}, UserID: uuid.New(), ULID: "other", Title: "Other"}
+		store := stores_mock.NewMockCollectionStore(materials)
+		return NewCollectionService(store, materials).(*collectionService), store
+	}
+	create := func(t *testing.T, svc *collectionService, name string, parentID *uint, position int) uint {
+		t.Helper()
+		node, err := svc.CreateCollection(userID, name, parentID, position)
+		if err != nil {
+			t.Fatalf("CreateCollection(%q) failed: %v", name, err)
+		}
+		return node.ID
+	}
+
+	t.Run("tree", func(t *testing.T) {
+		svc, _ := newService()
+		work := create(t, svc, "Work", nil, 1)
+		create(t, svc, "Talks", nil, 0)
+		create(t, svc, "Email", &work, 1)
+		create(t, svc, "Meetings", &work, 0)
+
+		tree, err := svc.ListCollections(userID)
+		if err != nil {
+			t.Fatalf("ListCollections failed: %v", err)
+		}
+		if len(tree) != 2 || tree[0].Name != "Talks" || tree[1].Name != "Work" {
+			t.Fatalf("unexpected roots: %+v", tree)
+		}
+		children := tree[1].Children
+		if len(children) != 2 || children[0].Name != "Meetings" || children[1].Name != "Email" {
+			t.Errorf("unexpected children: %+v", children)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		svc, _ := newService()
+		if _, err := svc.CreateCollection(userID, "  ", nil, 0); !errors.Is(err, ErrInvalidCollection) {
+			t.Errorf("expected ErrInvalidCollection, got %v", err)
+		}
+		missing := uint(99)
+		if _, err := svc.CreateCollection(userID, "Orphan", &missing, 0); !errors.Is(err, ErrCollectionNotFound) {
+			t.Errorf("expected ErrCollectionNotFound, got %v", err)
+		}
+	})
+
+	t.Run("move without cycles", func(t *testing.T) {
+		svc, store := newService()
+		a := create(t, svc, "A", nil, 0)
+		b := create(t, svc, "B", &a, 0)
+		c := create(t, svc, "C", &b, 0)
+
+		for _, parent := range []uint{a, c} {
+			if _, err := svc.UpdateCollection(userID, a, CollectionUpdate{ParentID: &parent}); !errors.Is(err, ErrCollectionCycle) {
+				t.Errorf("moving A under %d: expected ErrCollectionCycle, got %v", parent, err)
+			}
+		}
+		if _, err := svc.UpdateCollection(userID, c, CollectionUpdate{ParentID: &a}); err != nil {
+			t.Fatalf("UpdateCollection failed: %v", err)
+		}
+		if parent := store.Collections[c].ParentID; parent == nil || *parent != a {
+			t.Errorf("expected C under A, got %v", parent)
+		}
+		if _, err := svc.UpdateCollection(userID, b, CollectionUpdate{MoveToRoot: true}); err != nil {
+			t.Fatalf("UpdateCollection failed: %v", err)
+		}
+		if store.Collections[b].ParentID != nil {
+			t.Error("expected B at the root")
+		}
+	})
+
+	t.Run("set materials", func(t *testing.T) {
+		svc, _ := newService()
+		id := create(t, svc, "Study", nil, 0)
+
+		materials, err := svc.SetMaterials(userID, id, []string{"mail", "ted", "mail"})
+		if err != nil {
+			t.Fatalf("SetMaterials failed: %v", err)
+		}
+		if len(materials) != 2 || materials[0].ULID != "mail" || materials[1].ULID != "ted" {
+			t.Errorf("unexpected materials: %+v", materials)
+		}
+		if _, err := svc.SetMaterials(userID, id, []string{"ted", "other"}); !errors.Is(err, ErrUnknownMaterial) {
+			t.Errorf("expected ErrUnknownMaterial, got %v", err)
+		}
+		if _, err := svc.SetMaterials(uuid.New(), id, nil); !errors.Is(err, ErrCollectionNotFound) {
+			t.Errorf("expected ErrCollectionNotFound, got %v", err)
+		}
+	})
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{" TED  talks ", "news", "TED talks"})
+	if err != nil {
+		t.Fatalf("normalizeTags failed: %v", err)
+	}
+	if len(tags) != 2 || tags[0] != "TED talks" || tags[1] != "news" {
+		t.Errorf("unexpected tags: %q", tags)
+	}
+	for _, invalid := range [][]string{{""}, {"   "}, {string(make([]rune, maxTagLength+1))}} {
+		if _, err := normalizeTags(invalid); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("normalizeTags(%q): expected ErrInvalidTag, got %v", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/stores"
+
+	"github.com/google/uuid"
+)
+
+// ExportedItem は書き出す単語・フレーズ。Translation は指定した言語の訳
+type ExportedItem struct {
+	Text        string `json:"text"`
+	Meaning     string `json:"meaning"`
+	Translation string `json:"translation,omitempty"`
+	Example     string `json:"example,omitempty"`
+}
+
+type ExportedMaterial struct {
+	ULID           string         `json:"ulid"`
+	Title          string         `json:"title"`
+	SourceLanguage string         `json:"source_language"`
+	Tags           []string       `json:"tags"`
+	Content        string         `json:"content"`
+	CreatedAt      time.Time      `json:"created_at"`
+	Words          []ExportedItem `json:"words"`
+	Phrases        []ExportedItem `json:"phrases"`
+}
+
+type ExportService interface {
+	ExportMaterials(userID uuid.UUID, scope models.MaterialScope, lang string) ([]ExportedMaterial, error)
+}
+
+type exportService struct {
+	materialStore stores.MaterialStore
+	tagStore      stores.TagStore
+}
+
+func NewExportService(materialStore stores.MaterialStore, tagStore stores.TagStore) ExportService {
+	return &exportService{materialStore: materialStore, tagStore: tagStore}
+}
+
+// ExportMaterials は scope の教材を、単語・フレーズと lang の訳付きで作成順に返す
+func (s *exportService) ExportMaterials(userID uuid.UUID, scope models.MaterialScope, lang string) ([]ExportedMaterial, error) {
+	if err := normalizeScope(&scope); err != nil {
+		return nil, ErrInvalidListFilter
+	}
+	materials, err := s.materialStore.ExportMaterials(userID, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export materials: %w", err)
+	}
+
+	exported := make([]ExportedMaterial, 0, len(materials))
+	for _, material := range materials {
+		tags, err := s.tagStore.GetMaterialTags(material.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tags: %w", err)
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		item := ExportedMaterial{
+			ULID:           material.ULID,
+			Title:          material.Title,
+			SourceLanguage: material.SourceLanguage,
+			Tags:           tags,
+			Content:        material.Content,
+			CreatedAt:      material.CreatedAt,
+			Words:          []ExportedItem{},
+			Phrases:        []ExportedItem{},
+		}
+		for _, list := range material.WordLists {
+			for _, word := range list.Words {
+				item.Words = append(item.Words, ExportedItem{
+					Text:        word.Text,
+					Meaning:     word.Meaning,
+					Translation: translationIn(word.Translations, lang),
+					Example:     word.Example,
+				})
+			}
+		}
+		for _, list := range material.PhraseLists {
+			for _, phrase := range list.Phrases {
+				item.Phrases = append(item.Phrases, ExportedItem{
+					Text:        phrase.Text,
+					Meaning:     phrase.Meaning,
+					Translation: translationIn(phrase.Translations, lang),
+					Example:     phrase.Example,
+				})
+			}
+		}
+		exported = append(exported, item)
+	}
+	return exported, nil
+}
+
+func translationIn(translations []models.Translation, lang string) string {
+	for _, t := range translations {
+		if t.Language == lang {
+			return t.Meaning
+		}
+	}
+	return ""
+}
+
+// WriteExportCSV は単語・フレーズを1行ずつ CSV に書く
+func WriteExportCSV(w io.Writer, materials []ExportedMaterial) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"material_ulid", "material_title", "tags", "type", "text", "meaning", "translation", "example"}); err != nil {
+		return err
+	}
+	for _, material := range materials {
+		tags := strings.Join(material.Tags, ";")
+		rows := []struct {
+			kind  string
+			items []ExportedItem
+		}{{models.SearchTypeWord, material.Words}, {models.SearchTypePhrase, material.Phrases}}
+		for _, row := range rows {
+			for _, item := range row.items {
+				record := []string{material.ULID, material.Title, tags, row.kind, item.Text, item.Meaning, item.Translation, item.Example}
+				if err := cw.Write(record); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteExportCSV(t *testing.T) {
+	materials := []ExportedMaterial{{
+		ULID:    "01",
+		Title:   "Email, formal",
+		Tags:    []string{"business", "email"},
+		Words:   []ExportedItem{{Text: "regard", Meaning: "consider", Translation: "みなす"}},
+		Phrases: []ExportedItem{{Text: "as per", Meaning: "according to"}},
+	}}
+	var sb strings.Builder
+	if err := WriteExportCSV(&sb, materials); err != nil {
+		t.Fatalf("WriteExportCSV failed: %v", err)
+	}
+	want := "material_ulid,material_title,tags,type,text,meaning,translation,example\n" +
+		"01,\"Email, formal\",business;email,word,regard,consider,みなす,\n" +
+		"01,\"Email, formal\",business;email,phrase,as per,according to,,\n"
+	if sb.String() != want {
+		t.Errorf("WriteExportCSV =\n%s\nexpected\n%s", sb.String(), want)
+	}
+}
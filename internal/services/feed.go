@@ -0,0 +1,595 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/extract"
+	"github.com/yomek33/newln/internal/pkg/feed"
+	"github.com/yomek33/newln/internal/pkg/language"
+	"github.com/yomek33/newln/internal/pkg/webfetch"
+	"github.com/yomek33/newln/internal/stores"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultFeedPollInterval は購読を取得し直す間隔 (FEED_POLL_INTERVAL_MINUTES が未設定のとき)
+	DefaultFeedPollInterval = time.Hour
+	maxFeedsPerUser         = 50
+	maxFeedKeywords         = 20
+	maxFeedKeywordLength    = 64
+	defaultFeedMaxPerDay    = 5
+	maxFeedMaxPerDay        = 50
+	// 1回の PollDue で取得する購読の数
+	feedPollBatchSize = 50
+	maxFeedEntries    = 100
+)
+
+// 教材にしなかった理由
+const (
+	FeedSkipKeywords = "keywords"
+	FeedSkipTooLong  = "too_long"
+	FeedSkipEmpty    = "empty"
+)
+
+var (
+	ErrInvalidFeed  = errors.New("invalid feed subscription")
+	ErrFeedNotFound = errors.New("feed subscription not found")
+	ErrFeedExists   = errors.New("feed is already subscribed")
+	ErrTooManyFeeds = errors.New("too many feed subscriptions")
+	ErrNotAFeed     = errors.New("URL is not an RSS or Atom feed")
+)
+
+// FeedSubscriptionInput は購読の作成の指定。MaxPerDay が 0 なら defaultFeedMaxPerDay
+type FeedSubscriptionInput struct {
+	URL            string
+	Title          string
+	SourceLanguage string
+	Keywords       []string
+	MaxLength      int
+	MaxPerDay      int
+	FullText       bool
+}
+
+// FeedSubscriptionUpdate は購読の変更。nil のフィールドは変更しない
+type FeedSubscriptionUpdate struct {
+	Title          *string
+	SourceLanguage *string
+	Keywords       *[]string
+	MaxLength      *int
+	MaxPerDay      *int
+	FullText       *bool
+	Active         *bool
+}
+
+// FeedPollResult は1回の取得で作った教材と、作らなかった記事の数。
+// Deferred は1日やプランの上限に達したため次の取得に回した新しい記事 (処理済みの記事は含めない)
+type FeedPollResult struct {
+	Created    []string `json:"created"`
+	Skipped    int      `json:"skipped"`
+	Duplicates int      `json:"duplicates"`
+	Deferred   int      `json:"deferred"`
+}
+
+type FeedService interface {
+	ListSubscriptions(userID uuid.UUID) ([]models.FeedSubscription, error)
+	CreateSubscription(ctx context.Context, userID uuid.UUID, input FeedSubscriptionInput) (*models.FeedSubscription, error)
+	UpdateSubscription(userID uuid.UUID, id uint, update FeedSubscriptionUpdate) (*models.FeedSubscription, error)
+	DeleteSubscription(userID uuid.UUID, id uint) error
+	ListEntries(userID uuid.UUID, id uint) ([]models.FeedEntry, error)
+	PollSubscription(ctx context.Context, userID uuid.UUID, id uint) (*FeedPollResult, error)
+	PollDue(ctx context.Context) (int, error)
+}
+
+type feedService struct {
+	store             stores.FeedStore
+	materialService   MaterialService
+	generationService GenerationService
+	quotaService      QuotaService
+	importService     ImportService
+	fetcher           *webfetch.Fetcher
+	interval          time.Duration
+	now               func() time.Time
+	// 同じ記事から教材を2つ作らないよう、同じユーザーの取得は1つずつ行う
+	// (本文のハッシュでの重複はユーザー単位で確かめる)。locks は mu で守る
+	mu    sync.Mutex
+	locks map[uuid.UUID]*feedPollLock
+}
+
+// feedPollLock はユーザーごとの取得のロック。待っている取得がなくなったら捨てる
+type feedPollLock struct {
+	sync.Mutex
+	waiters int
+}
+
+func NewFeedService(store stores.FeedStore, materialService MaterialService, generationService GenerationService, quotaService QuotaService, importService ImportService, fetcher *webfetch.Fetcher, interval time.Duration) FeedService {
+	if interval <= 0 {
+		interval = DefaultFeedPollInterval
+	}
+	return &feedService{
+		store:             store,
+		materialService:   materialService,
+		generationService: generationService,
+		quotaService:      quotaService,
+		importService:     importService,
+		fetcher:           fetcher,
+		interval:          interval,
+		now:               time.Now,
+		locks:             make(map[uuid.UUID]*feedPollLock),
+	}
+}
+
+// FeedFetchOptions はフィードを取得するときの webfetch の設定
+func FeedFetchOptions() webfetch.Options {
+	opts := webfetch.DefaultOptions()
+	opts.ContentTypes = []string{"application/rss+xml", "application/atom+xml", "application/rdf+xml", "application/xml", "text/xml"}
+	return opts
+}
+
+func (s *feedService) ListSubscriptions(userID uuid.UUID) ([]models.FeedSubscription, error) {
+	subscriptions, err := s.store.ListSubscriptions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feed subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// CreateSubscription はフィードを1回取得して確かめてから購読する。タイトルがなければフィードのタイトル。
+// 最初の取得は次の PollDue で行う
+func (s *feedService) CreateSubscription(ctx context.Context, userID uuid.UUID, input FeedSubscriptionInput) (*models.FeedSubscription, error) {
+	target, err := webfetch.NormalizeURL(input.URL)
+	if err != nil || len(target) > maxImportURLLength {
+		return nil, ErrInvalidImportURL
+	}
+	subscription := &models.FeedSubscription{
+		UserID:     userID,
+		URL:        target,
+		MaxLength:  input.MaxLength,
+		MaxPerDay:  cmp.Or(input.MaxPerDay, defaultFeedMaxPerDay),
+		FullText:   input.FullText,
+		Active:     true,
+		NextPollAt: s.now(),
+	}
+	if err := s.applySettings(subscription, input.Title, input.SourceLanguage, input.Keywords); err != nil {
+		return nil, err
+	}
+	if err := validateFeedLimits(subscription); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.store.ListSubscriptions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feed subscriptions: %w", err)
+	}
+	if len(existing) >= maxFeedsPerUser {
+		return nil, ErrTooManyFeeds
+	}
+	if slices.ContainsFunc(existing, func(f models.FeedSubscription) bool { return f.URL == target }) {
+		return nil, ErrFeedExists
+	}
+
+	parsed, err := s.fetchFeed(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Title == "" {
+		subscription.Title = truncateTitle(cmp.Or(parsed.Title, hostname(target)))
+	}
+	if err := s.store.CreateSubscription(subscription); err != nil {
+		return nil, fmt.Errorf("failed to create feed subscription: %w", err)
+	}
+	logger.Infof("📡 Subscribed to feed %s (%d items), UserID: %v", target, len(parsed.Items), userID)
+	return subscription, nil
+}
+
+func (s *feedService) UpdateSubscription(userID uuid.UUID, id uint, update FeedSubscriptionUpdate) (*models.FeedSubscription, error) {
+	subscription, err := s.store.GetSubscription(userID, id)
+	if err != nil {
+		return nil, feedError(err)
+	}
+	title, lang, keywords := subscription.Title, subscription.SourceLanguage, subscription.Keywords
+	if update.Title != nil {
+		title = *update.Title
+	}
+	if update.SourceLanguage != nil {
+		lang = *update.SourceLanguage
+	}
+	if update.Keywords != nil {
+		keywords = *update.Keywords
+	}
+	if err := s.applySettings(subscription, title, lang, keywords); err != nil {
+		return nil, err
+	}
+	if update.MaxLength != nil {
+		subscription.MaxLength = *update.MaxLength
+	}
+	if update.MaxPerDay != nil {
+		subscription.MaxPerDay = *update.MaxPerDay
+	}
+	if update.FullText != nil {
+		subscription.FullText = *update.FullText
+	}
+	if update.Active != nil {
+		// 再開したらすぐに取得する
+		if *update.Active && !subscription.Active {
+			subscription.NextPollAt = s.now()
+		}
+		subscription.Active = *update.Active
+	}
+	if err := validateFeedLimits(subscription); err != nil {
+		return nil, err
+	}
+	if err := s.store.UpdateSubscription(subscription); err != nil {
+		return nil, fmt.Errorf("failed to update feed subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+// applySettings はタイトル・言語・キーワードを整えて subscription に入れる
+func (s *feedService) applySettings(subscription *models.FeedSubscription, title, lang string, keywords []string) error {
+	title = strings.Join(strings.Fields(title), " ")
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return ErrInvalidFeed
+	}
+	code, ok := language.NormalizeSource(lang)
+	if !ok {
+		return ErrUnsupportedLanguage
+	}
+	normalized, err := normalizeKeywords(keywords)
+	if err != nil {
+		return err
+	}
+	subscription.Title = title
+	subscription.SourceLanguage = code
+	subscription.Keywords = normalized
+	return nil
+}
+
+func validateFeedLimits(subscription *models.FeedSubscription) error {
+	if subscription.MaxLength < 0 || subscription.MaxPerDay < 1 || subscription.MaxPerDay > maxFeedMaxPerDay {
+		return ErrInvalidFeed
+	}
+	return nil
+}
+
+// normalizeKeywords は空白を整えて小文字にし、重複を除く
+func normalizeKeywords(keywords []string) ([]string, error) {
+	normalized := []string{}
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(strings.Join(strings.Fields(keyword), " "))
+		if keyword == "" || slices.Contains(normalized, keyword) {
+			continue
+		}
+		if utf8.RuneCountInString(keyword) > maxFeedKeywordLength {
+			return nil, ErrInvalidFeed
+		}
+		normalized = append(normalized, keyword)
+	}
+	if len(normalized) > maxFeedKeywords {
+		return nil, ErrInvalidFeed
+	}
+	return normalized, nil
+}
+
+func (s *feedService) DeleteSubscription(userID uuid.UUID, id uint) error {
+	if err := s.store.DeleteSubscription(userID, id); err != nil {
+		return feedError(err)
+	}
+	return nil
+}
+
+// ListEntries は購読で処理した記事を新しい順に返す
+func (s *feedService) ListEntries(userID uuid.UUID, id uint) ([]models.FeedEntry, error) {
+	if _, err := s.store.GetSubscription(userID, id); err != nil {
+		return nil, feedError(err)
+	}
+	entries, err := s.store.ListEntries(id, maxFeedEntries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feed entries: %w", err)
+	}
+	return entries, nil
+}
+
+// PollSubscription は購読をすぐに取得する。止めている購読も取得する
+func (s *feedService) PollSubscription(ctx context.Context, userID uuid.UUID, id uint) (*FeedPollResult, error) {
+	subscription, err := s.store.GetSubscription(userID, id)
+	if err != nil {
+		return nil, feedError(err)
+	}
+	return s.pollAndSave(ctx, subscription)
+}
+
+// PollDue は取得の時刻が来た購読を取得する。購読ごとのエラーは LastError に残して続ける
+func (s *feedService) PollDue(ctx context.Context) (int, error) {
+	subscriptions, err := s.store.ListDueSubscriptions(s.now(), feedPollBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due feeds: %w", err)
+	}
+	polled := 0
+	for i := range subscriptions {
+		if ctx.Err() != nil {
+			return polled, ctx.Err()
+		}
+		result, err := s.pollAndSave(ctx, &subscriptions[i])
+		polled++
+		if err != nil {
+			logger.Errorf("❌ Failed to poll feed %s: %v", subscriptions[i].URL, err)
+			continue
+		}
+		if len(result.Created) > 0 {
+			logger.Infof("📡 Created %d materials from feed %s", len(result.Created), subscriptions[i].URL)
+		}
+	}
+	return polled, nil
+}
+
+// pollAndSave は取得の結果 (次の取得の時刻とエラー) だけを購読に保存する。取得の間に変わった設定は残す
+func (s *feedService) pollAndSave(ctx context.Context, subscription *models.FeedSubscription) (*FeedPollResult, error) {
+	unlock := s.lockUser(subscription.UserID)
+	defer unlock()
+
+	result, pollErr := s.poll(ctx, subscription)
+	now := s.now()
+	subscription.LastPolledAt = &now
+	subscription.NextPollAt = now.Add(s.interval)
+	subscription.LastError = ""
+	if pollErr != nil {
+		subscription.LastError = pollErr.Error()
+	}
+	if err := s.store.SavePollState(subscription); err != nil {
+		logger.Errorf("Failed to save feed state: %v, feed: %d", err, subscription.ID)
+	}
+	return result, pollErr
+}
+
+// lockUser はユーザーの取得のロックを取り、外す関数を返す。ほかのユーザーの取得は待たせない
+func (s *feedService) lockUser(userID uuid.UUID) func() {
+	s.mu.Lock()
+	lock, ok := s.locks[userID]
+	if !ok {
+		lock = &feedPollLock{}
+		s.locks[userID] = lock
+	}
+	lock.waiters++
+	s.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(s.locks, userID)
+		}
+	}
+}
+
+// poll はフィードの新しい記事を古い順に教材にする。
+// GUID か本文のハッシュが処理済みの記事は重複として扱い、条件に合わない記事は理由を残して飛ばす
+func (s *feedService) poll(ctx context.Context, subscription *models.FeedSubscription) (*FeedPollResult, error) {
+	parsed, err := s.fetchFeed(ctx, subscription.URL)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Title == "" {
+		subscription.Title = truncateTitle(parsed.Title)
+	}
+	created, err := s.store.CountCreatedSince(subscription.ID, s.startOfDay())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count feed materials: %w", err)
+	}
+
+	result := &FeedPollResult{Created: []string{}}
+	// 上限に達したら、残りの新しい記事は数えるだけにして次の取得に回す
+	full := false
+	for _, item := range oldestFirst(parsed.Items) {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if item.GUID != "" {
+			seen, err := s.store.HasEntry(subscription.ID, truncateRunes(item.GUID, 512))
+			if err != nil {
+				return result, fmt.Errorf("failed to check feed entry: %w", err)
+			}
+			if seen {
+				result.Duplicates++
+				continue
+			}
+		}
+		if full || int(created) >= subscription.MaxPerDay {
+			// GUID のない記事は本文を取るまで処理済みか分からないので、新しい記事として数える
+			full = true
+			result.Deferred++
+			continue
+		}
+
+		content := s.itemText(ctx, subscription, item)
+		hash := contentHash(content)
+		entry := &models.FeedEntry{
+			SubscriptionID: subscription.ID,
+			UserID:         subscription.UserID,
+			GUID:           truncateRunes(cmp.Or(item.GUID, hash), 512),
+			ContentHash:    hash,
+			Title:          truncateTitle(item.Title),
+			Link:           item.Link,
+			Status:         models.FeedEntrySkipped,
+			CreatedAt:      s.now(),
+		}
+		duplicate, err := s.store.HasContentHash(subscription.UserID, hash)
+		if err != nil {
+			return result, fmt.Errorf("failed to check feed entry: %w", err)
+		}
+
+		switch {
+		case duplicate && item.GUID == "":
+			// GUID のない記事はハッシュを GUID にしているので、記録し直さない
+			result.Duplicates++
+			continue
+		case duplicate:
+			entry.Status = models.FeedEntryDuplicate
+			result.Duplicates++
+		case content == "":
+			entry.Reason = FeedSkipEmpty
+			result.Skipped++
+		case !matchesKeywords(subscription.Keywords, item.Title, content):
+			entry.Reason = FeedSkipKeywords
+			result.Skipped++
+		case subscription.MaxLength > 0 && utf8.RuneCountInString(content) > subscription.MaxLength:
+			entry.Reason = FeedSkipTooLong
+			result.Skipped++
+		default:
+			limit, err := s.quotaService.CheckMaterialCreation(subscription.UserID, utf8.RuneCountInString(content))
+			var quotaErr *QuotaError
+			switch {
+			case errors.As(err, &quotaErr) && quotaErr.Limit == LimitMaxCharsPerMaterial:
+				entry.Reason = FeedSkipTooLong
+				result.Skipped++
+			case errors.As(err, &quotaErr):
+				// プランの上限に達したら残りは次の取得に回す
+				full = true
+				result.Deferred++
+				continue
+			case err != nil:
+				return result, err
+			default:
+				material, err := s.createMaterial(subscription, item, content, limit)
+				if errors.As(err, &quotaErr) {
+					// 確かめた後に他の作成で上限に達した
+					full = true
+					result.Deferred++
+					continue
+				}
+				if err != nil {
+					return result, err
+				}
+				entry.Status = models.FeedEntryCreated
+				entry.MaterialID = &material.ID
+				entry.MaterialULID = material.ULID
+				result.Created = append(result.Created, material.ULID)
+				created++
+			}
+		}
+		if err := s.store.CreateEntry(entry); err != nil {
+			return result, fmt.Errorf("failed to save feed entry: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// createMaterial は記事から教材を作り、生成を始める
+func (s *feedService) createMaterial(subscription *models.FeedSubscription, item feed.Item, content string, limit CreationLimit) (*models.Material, error) {
+	material := &models.Material{
+		UserID:               subscription.UserID,
+		ULID:                 ulid.Make().String(),
+		Title:                truncateTitle(cmp.Or(item.Title, subscription.Title, hostname(item.Link), "Untitled")),
+		Content:              content,
+		SourceLanguage:       subscription.SourceLanguage,
+		SourceURL:            item.Link,
+		Status:               models.StatusDraft,
+		HasPendingWordList:   true,
+		HasPendingPhraseList: true,
+	}
+	created, err := s.materialService.CreateMaterial(material, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create material from feed: %w", err)
+	}
+	s.generationService.StartGeneration(created)
+	return created, nil
+}
+
+func (s *feedService) fetchFeed(ctx context.Context, target string) (*feed.Feed, error) {
+	page, err := s.fetcher.Fetch(ctx, target)
+	if errors.Is(err, webfetch.ErrUnsupportedContentType) {
+		return nil, ErrNotAFeed
+	}
+	if err != nil {
+		return nil, fetchError(err)
+	}
+	parsed, err := feed.Parse(page.Body, page.URL)
+	if err != nil {
+		return nil, ErrNotAFeed
+	}
+	return parsed, nil
+}
+
+// itemText は記事の本文をテキストにする。FullText なら記事のページから取り出し、失敗したらフィードの本文を使う
+func (s *feedService) itemText(ctx context.Context, subscription *models.FeedSubscription, item feed.Item) string {
+	if subscription.FullText && item.Link != "" {
+		imported, err := s.importService.FetchURL(ctx, item.Link, false)
+		if err == nil {
+			return imported.Content
+		}
+		logger.Errorf("Failed to fetch feed article: %v, URL: %s", err, item.Link)
+	}
+	if item.Content == "" {
+		return ""
+	}
+	doc, err := extract.HTML(strings.NewReader(item.Content))
+	if err != nil {
+		return ""
+	}
+	return doc.Text
+}
+
+// startOfDay は1日の上限を数え始める時刻 (quotaService と同じ UTC の0時)
+func (s *feedService) startOfDay() time.Time {
+	now := s.now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// oldestFirst は記事を公開日時の古い順に並べる。日時のない記事があればフィードの逆順 (フィードは新しい順のことが多い)
+func oldestFirst(items []feed.Item) []feed.Item {
+	sorted := slices.Clone(items)
+	slices.Reverse(sorted)
+	if slices.ContainsFunc(sorted, func(item feed.Item) bool { return item.Published.IsZero() }) {
+		return sorted
+	}
+	slices.SortStableFunc(sorted, func(a, b feed.Item) int { return a.Published.Compare(b.Published) })
+	return sorted
+}
+
+// contentHash は空白と大文字小文字の違いを除いた本文の SHA-256
+func contentHash(content string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(content), " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// matchesKeywords はキーワードがなければ true、あればどれかがタイトルか本文に含まれるかを返す
+func matchesKeywords(keywords []string, title, content string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	text := strings.ToLower(title + "\n" + content)
+	return slices.ContainsFunc(keywords, func(keyword string) bool { return strings.Contains(text, keyword) })
+}
+
+func hostname(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Hostname()
+	}
+	return ""
+}
+
+func feedError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrFeedNotFound
+	}
+	return fmt.Errorf("failed to get feed subscription: %w", err)
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/webfetch"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+
+	"github.com/google/uuid"
+)
+
+// feedQuota は maxChars を超える本文だけを断る
+type feedQuota struct {
+	QuotaService
+	maxChars int
+}
+
+func (q *feedQuota) CheckMaterialCreation(userID uuid.UUID, contentLength int) (CreationLimit, error) {
+	if contentLength > q.maxChars {
+		return CreationLimit{}, &QuotaError{Kind: QuotaKindPlanLimit, Limit: LimitMaxCharsPerMaterial}
+	}
+	return CreationLimit{}, nil
+}
+
+// feedGeneration は生成を始めた教材を記録する
+type feedGeneration struct {
+	GenerationService
+	started []string
+	// 取得の途中で呼ぶ
+	onStart func()
+}
+
+func (g *feedGeneration) StartGeneration(material *models.Material) {
+	g.started = append(g.started, material.ULID)
+	if g.onStart != nil {
+		g.onStart()
+	}
+}
+
+type feedTest struct {
+	server     *httptest.Server
+	store      *stores_mock.MockFeedStore
+	materials  *stores_mock.MockMaterialStore
+	generation *feedGeneration
+	svc        *feedService
+	now        time.Time
+}
+
+// newFeedTest は testdata/feeds を返すフィードのサーバーを立てる
+func newFeedTest(t *testing.T) *feedTest {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("testdata/feeds")))
+	mux.HandleFunc("/broken.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	opts := FeedFetchOptions()
+	opts.AllowPrivate = true
+	pageOpts := webfetch.DefaultOptions()
+	pageOpts.AllowPrivate = true
+
+	ft := &feedTest{
+		server:     server,
+		store:      stores_mock.NewMockFeedStore(),
+		materials:  stores_mock.NewMockMaterialStore(),
+		generation: &feedGeneration{},
+		now:        time.Date(2025, 10, 7, 10, 0, 0, 0, time.UTC),
+	}
+	importService := NewImportService(stores_mock.NewMockPageCacheStore(), webfetch.New(pageOpts))
+	ft.svc = NewFeedService(ft.store, NewMaterialService(ft.materials), ft.generation, &feedQuota{maxChars: 1000},
+		importService, webfetch.New(opts), time.Hour).(*feedService)
+	ft.svc.now = func() time.Time { return ft.now }
+	return ft
+}
+
+func TestFeedService_CreateSubscription(t *testing.T) {
+	ft := newFeedTest(t)
+	userID := uuid.New()
+	ctx := context.Background()
+
+	subscription, err := ft.svc.CreateSubscription(ctx, userID, FeedSubscriptionInput{
+		URL:      ft.server.URL + "/news.xml",
+		Keywords: []string{" Compiler ", "compiler", "LINKER"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	if subscription.Title != "Compiler Weekly" || subscription.SourceLanguage != "en" || subscription.MaxPerDay != defaultFeedMaxPerDay ||
+		len(subscription.Keywords) != 2 || subscription.Keywords[1] != "linker" || !subscription.NextPollAt.Equal(ft.now) {
+		t.Errorf("unexpected subscription: %+v", subscription)
+	}
+
+	tests := []struct {
+		name  string
+		input FeedSubscriptionInput
+		want  error
+	}{
+		{"duplicate", FeedSubscriptionInput{URL: ft.server.URL + "/news.xml"}, ErrFeedExists},
+		{"html page", FeedSubscriptionInput{URL: ft.server.URL + "/reading.html"}, ErrNotAFeed},
+		{"invalid URL", FeedSubscriptionInput{URL: "ftp://example.com/feed"}, ErrInvalidImportURL},
+		{"language", FeedSubscriptionInput{URL: ft.server.URL + "/atom.xml", SourceLanguage: "xx"}, ErrUnsupportedLanguage},
+		{"max per day", FeedSubscriptionInput{URL: ft.server.URL + "/atom.xml", MaxPerDay: maxFeedMaxPerDay + 1}, ErrInvalidFeed},
+		{"negative length", FeedSubscriptionInput{URL: ft.server.URL + "/atom.xml", MaxLength: -1}, ErrInvalidFeed},
+		{"server error", FeedSubscriptionInput{URL: ft.server.URL + "/broken.xml"}, ErrFetchFailed},
+	}
+	for _, tt := range tests {
+		if _, err := ft.svc.CreateSubscription(ctx, userID, tt.input); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+	if len(ft.store.Subscriptions) != 1 {
+		t.Errorf("expected 1 subscription, got %d", len(ft.store.Subscriptions))
+	}
+}
+
+func TestFeedService_Poll(t *testing.T) {
+	ft := newFeedTest(t)
+	userID := uuid.New()
+	ctx := context.Background()
+
+	subscription, err := ft.svc.CreateSubscription(ctx, userID, FeedSubscriptionInput{
+		URL:       ft.server.URL + "/news.xml",
+		Keywords:  []string{"compiler"},
+		MaxLength: 100,
+		MaxPerDay: 2,
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	// 古い順に処理し、2件作ったところで1日の上限に達する
+	result, err := ft.svc.PollSubscription(ctx, userID, subscription.ID)
+	if err != nil {
+		t.Fatalf("PollSubscription failed: %v", err)
+	}
+	if len(result.Created) != 2 || result.Skipped != 2 || result.Duplicates != 1 || result.Deferred != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(ft.generation.started) != 2 || ft.generation.started[0] != result.Created[0] {
+		t.Errorf("generation not started for created materials: %v", ft.generation.started)
+	}
+	first, err := ft.materials.GetMaterialByULID(result.Created[0], userID)
+	if err != nil {
+		t.Fatalf("material not created: %v", err)
+	}
+	if first.Title != "New compiler release" || first.Content != "A new compiler release is out today." ||
+		first.SourceURL != ft.server.URL+"/posts/1" || first.SourceLanguage != "en" || !first.HasPendingWordList {
+		t.Errorf("unexpected material: %+v", first)
+	}
+	second, _ := ft.materials.GetMaterialByULID(result.Created[1], userID)
+	if second == nil || second.Content != "The compiler now caches incremental builds." {
+		t.Errorf("unexpected second material: %+v", second)
+	}
+
+	entries, err := ft.svc.ListEntries(userID, subscription.ID)
+	if err != nil {
+		t.Fatalf("ListEntries failed: %v", err)
+	}
+	statuses := map[string]string{}
+	for _, entry := range entries {
+		statuses[entry.Title] = entry.Status + "/" + entry.Reason
+	}
+	want := map[string]string{
+		"New compiler release":           "created/",
+		"A very long compiler deep dive": "skipped/too_long",
+		"New compiler release (repost)":  "duplicate/",
+		"Gardening tips":                 "skipped/keywords",
+		"Faster incremental builds":      "created/",
+	}
+	for title, status := range want {
+		if statuses[title] != status {
+			t.Errorf("entry %q = %q, want %q", title, statuses[title], status)
+		}
+	}
+	if len(entries) != len(want) {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+
+	saved := ft.store.Subscriptions[subscription.ID]
+	if saved.LastPolledAt == nil || !saved.NextPollAt.Equal(ft.now.Add(time.Hour)) || saved.LastError != "" {
+		t.Errorf("unexpected poll state: %+v", saved)
+	}
+
+	// 同じ日のうちは残りの記事も上限で待つ
+	result, err = ft.svc.PollSubscription(ctx, userID, subscription.ID)
+	if err != nil || len(result.Created) != 0 || result.Duplicates != 5 || result.Deferred != 1 {
+		t.Fatalf("unexpected second poll: %+v, %v", result, err)
+	}
+
+	// 翌日の定期的な取得で残りの記事が教材になる
+	ft.now = ft.now.Add(24 * time.Hour)
+	polled, err := ft.svc.PollDue(ctx)
+	if err != nil || polled != 1 {
+		t.Fatalf("PollDue = %d, %v", polled, err)
+	}
+	if len(ft.generation.started) != 3 {
+		t.Errorf("expected 3 materials, got %v", ft.generation.started)
+	}
+	if polled, _ := ft.svc.PollDue(ctx); polled != 0 {
+		t.Errorf("expected no due feeds right after polling, got %d", polled)
+	}
+
+	// 止めた購読は定期的には取得しない
+	inactive := false
+	if _, err := ft.svc.UpdateSubscription(userID, subscription.ID, FeedSubscriptionUpdate{Active: &inactive}); err != nil {
+		t.Fatalf("UpdateSubscription failed: %v", err)
+	}
+	ft.now = ft.now.Add(2 * time.Hour)
+	if polled, _ := ft.svc.PollDue(ctx); polled != 0 {
+		t.Errorf("inactive feed was polled")
+	}
+}
+
+func TestFeedService_PollDefersOnlyNewItems(t *testing.T) {
+	ft := newFeedTest(t)
+	userID := uuid.New()
+	ctx := context.Background()
+
+	subscription, err := ft.svc.CreateSubscription(ctx, userID, FeedSubscriptionInput{URL: ft.server.URL + "/news.xml", MaxPerDay: 1})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	// 新しい2件は処理済み
+	for _, guid := range []string{"post-5", "post-6"} {
+		if err := ft.store.CreateEntry(&models.FeedEntry{SubscriptionID: subscription.ID, UserID: userID, GUID: guid, Status: models.FeedEntrySkipped}); err != nil {
+			t.Fatalf("CreateEntry failed: %v", err)
+		}
+	}
+
+	// 上限の後ろにある処理済みの記事は次の取得に回す数に入れない
+	result, err := ft.svc.PollSubscription(ctx, userID, subscription.ID)
+	if err != nil || len(result.Created) != 1 || result.Deferred != 3 || result.Duplicates != 2 {
+		t.Fatalf("unexpected result: %+v, %v", result, err)
+	}
+}
+
+func TestFeedService_PollKeepsSettingsChangedDuringPoll(t *testing.T) {
+	ft := newFeedTest(t)
+	userID := uuid.New()
+	ctx := context.Background()
+
+	subscription, err := ft.svc.CreateSubscription(ctx, userID, FeedSubscriptionInput{URL: ft.server.URL + "/news.xml", MaxPerDay: 1})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	// 取得の途中で購読を止め、キーワードを変える
+	inactive, keywords := false, []string{"linker"}
+	ft.generation.onStart = func() {
+		if _, err := ft.svc.UpdateSubscription(userID, subscription.ID, FeedSubscriptionUpdate{Active: &inactive, Keywords: &keywords}); err != nil {
+			t.Errorf("UpdateSubscription failed: %v", err)
+		}
+	}
+
+	if _, err := ft.svc.PollSubscription(ctx, userID, subscription.ID); err != nil {
+		t.Fatalf("PollSubscription failed: %v", err)
+	}
+	saved := ft.store.Subscriptions[subscription.ID]
+	if saved.Active || len(saved.Keywords) != 1 || saved.Keywords[0] != "linker" {
+		t.Errorf("settings changed during the poll were reverted: %+v", saved)
+	}
+	if saved.LastPolledAt == nil || !saved.NextPollAt.Equal(ft.now.Add(time.Hour)) {
+		t.Errorf("poll state was not saved: %+v", saved)
+	}
+}
+
+func TestFeedService_LockUser(t *testing.T) {
+	ft := newFeedTest(t)
+	first, second := uuid.New(), uuid.New()
+
+	unlock := ft.svc.lockUser(first)
+	// ほかのユーザーの取得は待たない
+	done := make(chan struct{})
+	go func() {
+		ft.svc.lockUser(second)()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("polling another user was blocked")
+	}
+
+	waiting := make(chan struct{})
+	go func() {
+		ft.svc.lockUser(first)()
+		close(waiting)
+	}()
+	select {
+	case <-waiting:
+		t.Fatal("polling the same user was not serialized")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-waiting
+
+	ft.svc.mu.Lock()
+	defer ft.svc.mu.Unlock()
+	if len(ft.svc.locks) != 0 {
+		t.Errorf("locks were not released: %v", ft.svc.locks)
+	}
+}
+
+func TestFeedService_PollFullTextAndErrors(t *testing.T) {
+	ft := newFeedTest(t)
+	userID := uuid.New()
+	ctx := context.Background()
+
+	subscription, err := ft.svc.CreateSubscription(ctx, userID, FeedSubscriptionInput{URL: ft.server.URL + "/atom.xml", FullText: true})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	result, err := ft.svc.PollSubscription(ctx, userID, subscription.ID)
+	if err != nil || len(result.Created) != 1 {
+		t.Fatalf("unexpected result: %+v, %v", result, err)
+	}
+	material, _ := ft.materials.GetMaterialByULID(result.Created[0], userID)
+	want := "Reading a little every day builds vocabulary faster than long sessions once a week.\n\nPick material slightly above your level and keep going."
+	if material == nil || material.Content != want || material.Title != "Reading every day" {
+		t.Errorf("unexpected material: %+v", material)
+	}
+
+	// 取得に失敗したらエラーを残し、次の間隔で取得し直す
+	ft.store.Subscriptions[subscription.ID].URL = ft.server.URL + "/broken.xml"
+	if _, err := ft.svc.PollSubscription(ctx, userID, subscription.ID); !errors.Is(err, ErrFetchFailed) {
+		t.Errorf("expected ErrFetchFailed, got %v", err)
+	}
+	saved := ft.store.Subscriptions[subscription.ID]
+	if saved.LastError == "" || !saved.NextPollAt.Equal(ft.now.Add(time.Hour)) {
+		t.Errorf("unexpected poll state: %+v", saved)
+	}
+
+	if _, err := ft.svc.PollSubscription(ctx, uuid.New(), subscription.ID); !errors.Is(err, ErrFeedNotFound) {
+		t.Errorf("expected ErrFeedNotFound for another user, got %v", err)
+	}
+	if err := ft.svc.DeleteSubscription(userID, subscription.ID); err != nil {
+		t.Fatalf("DeleteSubscription failed: %v", err)
+	}
+	if len(ft.store.Entries) != 0 {
+		t.Errorf("entries should be deleted with the subscription")
+	}
+}
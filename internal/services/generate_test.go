@@ -2,7 +2,14 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/yomek33/newln/internal/models"
@@ -11,27 +18,52 @@ import (
 	"gorm.io/gorm"
 )
 
-func TestPhraseService_GeneratePhrases_RealVertex(t *testing.T) {
+const testMaterialContent = "The team worked tirelessly to close a deal with the new partner. " +
+	"The new system has been in operation since July, and engineers can resolve issues quickly."
+
+// newTestVertexClient は testdata/cassettes/<name> を再生する。
+// RECORD_CASSETTES=true のときは .env を読んで本物の Vertex で記録し直す
+func newTestVertexClient(t *testing.T, name string) *vertex.CassetteClient {
+	t.Helper()
+	dir := filepath.Join("testdata", "cassettes", name)
+
+	if os.Getenv("RECORD_CASSETTES") != "true" {
+		client, err := vertex.NewCassetteClient(dir, vertex.CassetteReplay, nil)
+		if err != nil {
+			t.Fatalf("failed to create cassette client: %v", err)
+		}
+		return client
+	}
+
 	if err := godotenv.Load("./../../.env"); err != nil {
 		t.Fatalf("error loading .env file: %v", err)
 	}
-
-	ctx := context.Background()
-
-	vertexClient, err := vertex.NewRealVertexClient()
+	realClient, err := vertex.NewRealVertexClient()
 	if err != nil {
 		t.Fatalf("failed to create Vertex client: %v", err)
 	}
+	client, err := vertex.NewCassetteClient(dir, vertex.CassetteRecord, realClient)
+	if err != nil {
+		t.Fatalf("failed to create cassette client: %v", err)
+	}
+	return client
+}
 
+func newTestMaterialStore() *stores_mock.MockMaterialStore {
 	materialStore := stores_mock.NewMockMaterialStore()
 	materialStore.Materials[1] = &models.Material{
 		Model:   gorm.Model{ID: 1},
-		Content: "hello",
+		Content: testMaterialContent,
 	}
+	return materialStore
+}
+
+func TestPhraseService_GeneratePhrases_Cassette(t *testing.T) {
+	ctx := context.Background()
 
 	service := &phraseService{
-		materialStore: materialStore,
-		vertexClient:  vertexClient,
+		materialStore: newTestMaterialStore(),
+		vertexClient:  newTestVertexClient(t, "generate_phrases"),
 	}
 
 	materialID := uint(1)
@@ -46,6 +78,11 @@ func TestPhraseService_GeneratePhrases_RealVertex(t *testing.T) {
 	if len(phrases) == 0 {
 		t.Fatalf("Expected phrases, but got empty response")
 	}
+	for _, phrase := range phrases {
+		if phrase.Meaning == "" || len(phrase.Translations) != 1 || phrase.Translations[0].Language != "ja" {
+			t.Errorf("phrase %q has no meaning", phrase.Text)
+		}
+	}
 
 	t.Logf("Success! Generated phrases: %+v", phrases)
 }
@@ -53,7 +90,11 @@ func TestPhraseService_GeneratePhrases_RealVertex(t *testing.T) {
 func TestPhraseService_GeneratePhrases_MockVertex(t *testing.T) {
 	ctx := context.Background()
 
-	vertexClient := vertex.NewMockVertexClient()
+	// モックは全てのプロンプトに同じ応答を返すので、抽出と意味生成の両方のスキーマを満たす応答にする
+	vertexClient := vertex.NewMockVertexClient(json.RawMessage(`[{
+		"phrase": "close a deal", "from_text": true, "example": "They closed a deal.",
+		"difficulty": "intermediate", "meaning": "to agree a business arrangement", "translation": "契約を結ぶ"
+	}]`))
 	materialStore := stores_mock.NewMockMaterialStore()
 	materialStore.Materials[1] = &models.Material{
 		Model:   gorm.Model{ID: 1},
@@ -80,3 +121,167 @@ func TestPhraseService_GeneratePhrases_MockVertex(t *testing.T) {
 
 	t.Logf("Success! Generated phrases: %+v", phrases)
 }
+
+func TestWordService_GenerateWords_Cassette(t *testing.T) {
+	ctx := context.Background()
+
+	service := &wordService{
+		materialStore: newTestMaterialStore(),
+		vertexClient:  newTestVertexClient(t, "generate_words"),
+	}
+
+	words, err := service.GenerateWords(ctx, 1)
+	if err != nil {
+		t.Fatalf("GenerateWords failed: %v", err)
+	}
+	if len(words) == 0 {
+		t.Fatalf("Expected words, but got empty response")
+	}
+	for _, word := range words {
+		if word.Meaning == "" {
+			t.Errorf("word %q has no meaning", word.Text)
+		}
+		if word.Pos == "" {
+			t.Errorf("word %q has no part of speech", word.Text)
+		}
+		// 難易度は抽出時の CEFR から決まる
+		if word.Text == "tirelessly" && word.Level != "advanced" {
+			t.Errorf("word %q: level = %q, expected advanced", word.Text, word.Level)
+		}
+		if word.Importance == "" {
+			t.Errorf("word %q has no importance", word.Text)
+		}
+		if word.Lemma == "" || len(word.Forms) == 0 {
+			t.Errorf("word %q has no lemma or forms: %q %v", word.Text, word.Lemma, word.Forms)
+		}
+		// 抽出した語は本文中に (活用形として) 現れる
+		if len(word.Occurrences) == 0 {
+			t.Errorf("word %q has no occurrences", word.Text)
+		}
+		runes := []rune(testMaterialContent)
+		for _, o := range word.Occurrences {
+			if string(runes[o.StartOffset:o.EndOffset]) != o.Surface || o.MaterialID != 1 {
+				t.Errorf("word %q has a wrong occurrence: %+v", word.Text, o)
+			}
+		}
+	}
+}
+
+func TestPhraseService_GeneratePhrases_Faults(t *testing.T) {
+	boom := errors.New("500 Internal Server Error")
+
+	tests := []struct {
+		name  string
+		fault *vertex.Fault
+		ctx   func() (context.Context, context.CancelFunc)
+	}{
+		{
+			name:  "error on meaning generation",
+			fault: vertex.FailWith(boom).OnCall(2),
+		},
+		{
+			name:  "malformed JSON on extraction",
+			fault: vertex.MalformedJSON().OnCall(1),
+		},
+		{
+			name:  "latency beyond deadline",
+			fault: vertex.DelayBy(time.Second),
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+
+			service := &phraseService{
+				materialStore: newTestMaterialStore(),
+				vertexClient:  newTestVertexClient(t, "generate_phrases").WithFaults(tt.fault),
+			}
+
+			if _, err := service.GeneratePhrases(ctx, 1); err == nil {
+				t.Fatalf("expected GeneratePhrases to fail")
+			}
+		})
+	}
+}
+
+func TestPhraseService_GeneratePhrasesInChunks(t *testing.T) {
+	var items []string
+	for _, phrase := range []string{"close a deal", "in operation", "resolve issues", "work tirelessly", "new partner", "since July", "engineers can"} {
+		items = append(items, `{"phrase": "`+phrase+`", "from_text": true, "example": "e", "difficulty": "easy", "meaning": "m", "translation": "意味"}`)
+	}
+	vertexClient := vertex.NewMockVertexClient(json.RawMessage("[" + strings.Join(items, ",") + "]"))
+
+	service := &phraseService{
+		materialStore: newTestMaterialStore(),
+		vertexClient:  vertexClient,
+	}
+
+	var mu sync.Mutex
+	var chunkSizes []int
+	phrases, err := service.GeneratePhrasesInChunks(context.Background(), 1, func(chunk []models.Phrase) error {
+		mu.Lock()
+		defer mu.Unlock()
+		chunkSizes = append(chunkSizes, len(chunk))
+		for i := range chunk {
+			chunk[i].ID = uint(100 + i)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GeneratePhrasesInChunks failed: %v", err)
+	}
+	if len(phrases) != 7 {
+		t.Fatalf("expected 7 phrases, got %d", len(phrases))
+	}
+	if len(chunkSizes) != 2 || chunkSizes[0] != streamFlushSize || chunkSizes[1] != 2 {
+		t.Errorf("expected chunks of %d and 2, got %v", streamFlushSize, chunkSizes)
+	}
+	if phrases[0].ID == 0 {
+		t.Errorf("IDs assigned in onChunk should be kept in the result")
+	}
+}
+
+func TestPhraseService_GeneratePhrasesInChunks_StopsOnChunkError(t *testing.T) {
+	vertexClient := vertex.NewMockVertexClient(json.RawMessage(`[{"phrase": "close a deal", "difficulty": "easy", "meaning": "m"}]`))
+	service := &phraseService{
+		materialStore: newTestMaterialStore(),
+		vertexClient:  vertexClient,
+	}
+
+	boom := errors.New("insert failed")
+	_, err := service.GeneratePhrasesInChunks(context.Background(), 1, func([]models.Phrase) error { return boom })
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected chunk error, got %v", err)
+	}
+}
+
+func TestGenerationService_RegenerateWhileRunning(t *testing.T) {
+	svc := &generationService{running: map[string]bool{"running": true}}
+
+	// 生成中の教材はリストを消さずに断る
+	reset := false
+	err := svc.Regenerate(&models.Material{ULID: "running"}, func() error {
+		reset = true
+		return nil
+	})
+	if !errors.Is(err, ErrGenerationRunning) || reset {
+		t.Fatalf("expected ErrGenerationRunning without reset, got %v (reset %v)", err, reset)
+	}
+
+	// リストを消せなければ生成中の印を外す
+	failed := errors.New("reset failed")
+	if err := svc.Regenerate(&models.Material{ULID: "idle"}, func() error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("expected the reset error, got %v", err)
+	}
+	if svc.IsGenerating("idle") || !svc.IsGenerating("running") {
+		t.Errorf("unexpected running set: %v", svc.running)
+	}
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/language"
+	"github.com/yomek33/newln/internal/pkg/vertex"
+	"github.com/yomek33/newln/internal/services/prompts"
+
+	"github.com/google/uuid"
+)
+
+const generationTimeout = 300 * time.Second
+
+// 意味の生成中、これだけ揃うごとに保存・送信する
+const streamFlushSize = 5
+
+var ErrGenerationRunning = errors.New("generation is already running")
+
+// GenerationService は教材からフレーズ・単語を生成するジョブパイプライン
+type GenerationService interface {
+	StartGeneration(material *models.Material)
+	Regenerate(material *models.Material, reset func() error) error
+	IsGenerating(materialULID string) bool
+	ProcessMaterial(ctx context.Context, materialID uint, materialULID string, userID uuid.UUID)
+}
+
+type generationService struct {
+	userService     UserService
+	materialService MaterialService
+	phraseService   PhraseService
+	wordService     WordService
+	quotaService    QuotaService
+
+	// 生成中の教材 (ULID)。running は mu で守る
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewGenerationService(userService UserService, materialService MaterialService, phraseService PhraseService, wordService WordService, quotaService QuotaService) GenerationService {
+	return &generationService{
+		userService:     userService,
+		materialService: materialService,
+		phraseService:   phraseService,
+		wordService:     wordService,
+		quotaService:    quotaService,
+		running:         make(map[string]bool),
+	}
+}
+
+type nativeLanguageKey struct{}
+
+// WithNativeLanguage は意味を生成する言語 (ISO 639-1) を ctx に載せる
+func WithNativeLanguage(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, nativeLanguageKey{}, code)
+}
+
+// nativeLanguage は ctx の言語コードを返す。なければ language.DefaultNative
+func nativeLanguage(ctx context.Context) string {
+	if code, ok := ctx.Value(nativeLanguageKey{}).(string); ok && code != "" {
+		return code
+	}
+	return language.DefaultNative
+}
+
+type sourceLanguageKey struct{}
+
+// WithSourceLanguage は教材の言語 (ISO 639-1) を ctx に載せる。
+// プロンプトのテンプレートと見出し語・出現位置の規則がこの言語で決まる
+func WithSourceLanguage(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, sourceLanguageKey{}, code)
+}
+
+// sourceLanguage は ctx の教材の言語コードを返す。なければ language.DefaultSource
+func sourceLanguage(ctx context.Context) string {
+	if code, ok := ctx.Value(sourceLanguageKey{}).(string); ok && code != "" {
+		return code
+	}
+	return language.DefaultSource
+}
+
+// materialLanguage は教材の言語を正規化する。未設定や未対応なら language.DefaultSource
+func materialLanguage(material *models.Material) string {
+	if code, ok := language.NormalizeSource(material.SourceLanguage); ok {
+		return code
+	}
+	return language.DefaultSource
+}
+
+// promptData はプロンプトに ctx の母語と教材の言語を渡す
+func promptData(ctx context.Context, text string) prompts.Data {
+	data := prompts.NewData(text)
+	data.NativeLanguage = language.Name(nativeLanguage(ctx))
+	data.SourceLanguage = language.Name(sourceLanguage(ctx))
+	return data
+}
+
+// translationsOf は生成した訳を ctx の言語の Translation にする
+func translationsOf(ctx context.Context, meaning string) []models.Translation {
+	if meaning == "" {
+		return nil
+	}
+	return []models.Translation{{Language: nativeLanguage(ctx), Meaning: meaning}}
+}
+
+// ownerLanguage は教材の所有者の母語を返す。取得できなければ language.DefaultNative
+func (s *generationService) ownerLanguage(userID uuid.UUID) string {
+	if s.userService == nil {
+		return language.DefaultNative
+	}
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		logger.Error(fmt.Errorf("failed to get owner language: %w", err))
+		return language.DefaultNative
+	}
+	if code, ok := language.Normalize(user.NativeLanguage); ok {
+		return code
+	}
+	return language.DefaultNative
+}
+
+// StartGeneration はリクエストとは独立した ctx でバックグラウンド処理を開始する
+func (s *generationService) StartGeneration(material *models.Material) {
+	s.mu.Lock()
+	s.running[material.ULID] = true
+	s.mu.Unlock()
+	s.start(material)
+}
+
+// Regenerate は生成中でなければ reset でリストを消してから生成をやり直す。
+// 生成中なら ErrGenerationRunning を返す (消したリストに古い生成が書き込み続けるため)
+func (s *generationService) Regenerate(material *models.Material, reset func() error) error {
+	s.mu.Lock()
+	if s.running[material.ULID] {
+		s.mu.Unlock()
+		return ErrGenerationRunning
+	}
+	s.running[material.ULID] = true
+	s.mu.Unlock()
+
+	if err := reset(); err != nil {
+		s.finish(material.ULID)
+		return err
+	}
+	s.start(material)
+	return nil
+}
+
+// IsGenerating は教材の生成が動いているかを返す
+func (s *generationService) IsGenerating(materialULID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running[materialULID]
+}
+
+func (s *generationService) start(material *models.Material) {
+	go func() {
+		defer s.finish(material.ULID)
+		asyncCtx, cancel := context.WithTimeout(context.Background(), generationTimeout) // 5分の独立した `ctx`
+		defer cancel()
+
+		s.ProcessMaterial(asyncCtx, material.ID, material.ULID, material.UserID)
+	}()
+}
+
+func (s *generationService) finish(materialULID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, materialULID)
+}
+
+func (s *generationService) ProcessMaterial(ctx context.Context, materialID uint, materialULID string, userID uuid.UUID) {
+	logger.Infof("🚀 Starting async processing for materialID: %v, userID: %v", materialID, userID)
+	ctx = vertex.WithUsageScope(ctx, vertex.UsageScope{UserID: userID, MaterialID: materialID})
+	// ✅ 検証で落とした・直した項目を SSE で送信
+	ctx = WithRejectionReporter(ctx, func(rejections []Rejection) {
+		rejectionsJSON, _ := json.Marshal(rejections)
+		s.materialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "items_rejected", "data": %s}`, rejectionsJSON))
+	})
+
+	// ✅ 意味は教材の所有者の母語で生成する
+	ctx = WithNativeLanguage(ctx, s.ownerLanguage(userID))
+
+	// ✅ 生成を始める前にトークン予算を確認
+	if err := s.quotaService.CheckTokenBudget(userID); err != nil {
+		s.failGeneration(materialID, materialULID, err)
+		return
+	}
+
+	s.materialService.UpdateMaterialStatus(materialID, "processing")
+
+	// ✅ ステータス変更を SSE で送信
+	s.materialService.PublishMaterialUpdate(materialULID, `{"event": "processing"}`)
+
+	// ✅ チャンクごとに保存できるよう、先に PhraseList と WordList を作成
+	phraseList := models.PhraseList{
+		MaterialID: materialID,
+		Title:      "Default Phrase List",
+	}
+	if err := s.phraseService.CreatePhraseList(&phraseList); err != nil {
+		s.failGeneration(materialID, materialULID, fmt.Errorf("❌ failed to create phrase list: %w", err))
+		return
+	}
+
+	wordList := models.WordList{
+		MaterialID: materialID,
+		Title:      "Default Word List",
+	}
+	if err := s.wordService.CreateWordList(&wordList); err != nil {
+		s.failGeneration(materialID, materialULID, fmt.Errorf("❌ failed to create word list: %w", err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, 4)
+
+	// ✅ `GeneratePhrases` を非同期で処理し、意味が揃ったチャンクから保存 & SSE 送信
+	phrasesChan := make(chan []models.Phrase, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		phrases, err := s.phraseService.GeneratePhrasesInChunks(ctx, materialID, func(chunk []models.Phrase) error {
+			return s.storePhraseChunk(materialULID, phraseList.ID, chunk)
+		})
+		phrasesChan <- phrases
+		if err != nil {
+			errChan <- fmt.Errorf("⚠️ No phrases generated: %w", err)
+			return
+		}
+		if err := s.materialService.UpdateHasPendingPhraseStatus(materialULID, false); err != nil {
+			logger.Errorf("❌ Failed to update HasPhraseList: %v", err)
+			errChan <- fmt.Errorf("❌ failed to update HasPhraseList: %w", err)
+		}
+	}()
+
+	// ✅ `GenerateWords` を非同期で処理し、意味が揃ったチャンクから保存 & SSE 送信
+	wordsChan := make(chan []models.Word, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		words, err := s.wordService.GenerateWordsInChunks(ctx, materialID, func(chunk []models.Word) error {
+			return s.storeWordChunk(materialULID, wordList.ID, chunk)
+		})
+		wordsChan <- words
+		if err != nil {
+			errChan <- fmt.Errorf("❌ failed to generate words: %w", err)
+			return
+		}
+		if err := s.materialService.UpdateHasPendingWordStatus(materialULID, false); err != nil {
+			logger.Errorf("❌ Failed to update HasPendingWordList: %v", err)
+			errChan <- fmt.Errorf("❌ failed to update HasPendingWordList: %w", err)
+		}
+	}()
+
+	wg.Wait()
+	close(errChan)
+	close(phrasesChan)
+	close(wordsChan)
+
+	// ✅ エラーチェック
+	hasError := false
+	for err := range errChan {
+		logger.Errorf("❌ Error occurred: %v, materialID: %v, userID: %v", err, materialID, userID)
+		var quotaErr *QuotaError
+		if errors.As(err, &quotaErr) {
+			s.materialService.PublishMaterialUpdate(materialULID, quotaErr.MarshalEvent())
+		} else {
+			s.materialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "error", "message": "%s"}`, err.Error()))
+		}
+		hasError = true
+	}
+
+	// ✅ 保存済みのフレーズ全体を SSE で送信
+	if phrases := <-phrasesChan; len(phrases) > 0 {
+		phrasesJSON, _ := json.Marshal(phrases)
+		s.materialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "phrases_stored", "data": %s}`, phrasesJSON))
+	} else {
+		logger.Warnf("⚠️ No phrases were stored, materialULID: %v", materialULID)
+	}
+
+	// ✅ 保存済みのワード全体を SSE で送信
+	if words := <-wordsChan; len(words) > 0 {
+		wordsJSON, _ := json.Marshal(words)
+		s.materialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "words_stored", "data": %s}`, wordsJSON))
+	} else {
+		logger.Warnf("⚠️ No words were stored, materialULID: %v", materialULID)
+	}
+
+	// ✅ 最終ステータス更新 & SSE 送信
+	if hasError {
+		s.materialService.UpdateMaterialStatus(materialID, "failed")
+		s.materialService.PublishMaterialUpdate(materialULID, `{"event": "failed"}`)
+	} else {
+		s.materialService.UpdateMaterialStatus(materialID, "completed")
+		s.materialService.PublishMaterialUpdate(materialULID, `{"event": "completed"}`)
+	}
+}
+
+// storePhraseChunk は意味まで揃ったフレーズをすぐ保存して SSE で送信する
+func (s *generationService) storePhraseChunk(materialULID string, phraseListID uint, phrases []models.Phrase) error {
+	for i := range phrases {
+		phrases[i].PhraseListID = phraseListID
+	}
+	if err := s.phraseService.BulkInsertPhrases(phrases); err != nil {
+		logger.Errorf("❌ Failed to store phrases: %v", err)
+		return fmt.Errorf("failed to store phrases: %w", err)
+	}
+	phrasesJSON, _ := json.Marshal(phrases)
+	s.materialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "phrases_chunk_stored", "data": %s}`, phrasesJSON))
+	return nil
+}
+
+// storeWordChunk は意味まで揃った単語をすぐ保存して SSE で送信する
+func (s *generationService) storeWordChunk(materialULID string, wordListID uint, words []models.Word) error {
+	for i := range words {
+		words[i].WordListID = wordListID
+	}
+	if err := s.wordService.BulkInsertWords(words); err != nil {
+		logger.Errorf("❌ Failed to store words: %v", err)
+		return fmt.Errorf("failed to store words: %w", err)
+	}
+	wordsJSON, _ := json.Marshal(words)
+	s.materialService.PublishMaterialUpdate(materialULID, fmt.Sprintf(`{"event": "words_chunk_stored", "data": %s}`, wordsJSON))
+	return nil
+}
+
+func (s *generationService) failGeneration(materialID uint, materialULID string, err error) {
+	logger.Errorf("❌ Generation aborted: %v, materialID: %v", err, materialID)
+	var quotaErr *QuotaError
+	if errors.As(err, &quotaErr) {
+		s.materialService.PublishMaterialUpdate(materialULID, quotaErr.MarshalEvent())
+	}
+	s.materialService.UpdateMaterialStatus(materialID, "failed")
+	s.materialService.PublishMaterialUpdate(materialULID, `{"event": "failed"}`)
+}
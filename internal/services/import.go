@@ -0,0 +1,207 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/extract"
+	"github.com/yomek33/newln/internal/pkg/subtitle"
+	"github.com/yomek33/newln/internal/pkg/webfetch"
+	"github.com/yomek33/newln/internal/stores"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// アップロードできるファイルの大きさの上限
+	MaxUploadBytes     = 10 << 20
+	maxImportURLLength = 2048
+	// 取得したページを使い回す期間
+	pageCacheTTL = 24 * time.Hour
+)
+
+var (
+	ErrUnsupportedFile = errors.New("unsupported file format, expected PDF, EPUB, DOCX, Markdown, HTML, SRT, WebVTT or text")
+	ErrUnreadableFile  = errors.New("no text could be extracted from the file")
+	ErrFileTooLarge    = errors.New("file is too large")
+
+	ErrInvalidImportURL = errors.New("invalid URL, expected an absolute http or https URL")
+	// プライベートなアドレスなど、取得を許していない接続先
+	ErrBlockedURL      = errors.New("URL points to an address that is not allowed")
+	ErrUnsupportedPage = errors.New("page is not HTML or text")
+	ErrPageTooLarge    = errors.New("page is too large")
+	ErrUnreadablePage  = errors.New("no article text could be extracted from the page")
+	ErrFetchFailed     = errors.New("failed to fetch the page")
+)
+
+// ImportedText は取り込んだ教材の本文とタイトル
+type ImportedText struct {
+	Title   string
+	Content string
+	Format  string
+	// URL から取り込んだときの元のページ
+	SourceURL string
+	// キャッシュから返した
+	Cached bool
+	// 字幕から取り込んだときのキュー
+	Cues []models.SubtitleCue
+}
+
+type ImportService interface {
+	ExtractFile(filename string, data []byte) (*ImportedText, error)
+	FetchURL(ctx context.Context, rawURL string, refresh bool) (*ImportedText, error)
+}
+
+type importService struct {
+	pageStore stores.PageCacheStore
+	fetcher   *webfetch.Fetcher
+	now       func() time.Time
+}
+
+func NewImportService(pageStore stores.PageCacheStore, fetcher *webfetch.Fetcher) ImportService {
+	return &importService{pageStore: pageStore, fetcher: fetcher, now: time.Now}
+}
+
+// ExtractFile はファイルから段落を保った本文を取り出す。タイトルがなければファイル名を使う。
+// 字幕 (SRT, WebVTT) は台詞を本文にし、キューの時刻と位置を Cues に返す
+func (s *importService) ExtractFile(filename string, data []byte) (*ImportedText, error) {
+	if len(data) > MaxUploadBytes {
+		return nil, ErrFileTooLarge
+	}
+	if format := subtitle.Detect(filename, data); format != "" {
+		return extractSubtitles(filename, format, data)
+	}
+	doc, err := extract.Extract(filename, data)
+	switch {
+	case errors.Is(err, extract.ErrUnsupportedFormat):
+		return nil, ErrUnsupportedFile
+	case errors.Is(err, extract.ErrTooLarge):
+		return nil, ErrFileTooLarge
+	case errors.Is(err, extract.ErrNoText), errors.Is(err, extract.ErrCorrupt):
+		return nil, ErrUnreadableFile
+	case err != nil:
+		return nil, fmt.Errorf("failed to extract text: %w", err)
+	}
+
+	return &ImportedText{Title: fileTitle(doc.Title, filename), Content: doc.Text, Format: doc.Format}, nil
+}
+
+func extractSubtitles(filename, format string, data []byte) (*ImportedText, error) {
+	cues, err := subtitle.Parse(data)
+	if errors.Is(err, subtitle.ErrNoCues) {
+		return nil, ErrUnreadableFile
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse subtitles: %w", err)
+	}
+	content, modelCues := buildSubtitleCues(cues)
+	return &ImportedText{Title: fileTitle("", filename), Content: content, Format: format, Cues: modelCues}, nil
+}
+
+// fileTitle は title がなければファイル名から拡張子を除いたものを使う
+func fileTitle(title, filename string) string {
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	}
+	if title == "" || title == "." {
+		title = "Untitled"
+	}
+	return truncateTitle(title)
+}
+
+// FetchURL はページを取得して記事の本文とタイトルを取り出す。
+// 24時間以内に取得したページはキャッシュを使う。refresh なら取得し直す
+func (s *importService) FetchURL(ctx context.Context, rawURL string, refresh bool) (*ImportedText, error) {
+	target, err := webfetch.NormalizeURL(rawURL)
+	if err != nil || len(target) > maxImportURLLength {
+		return nil, ErrInvalidImportURL
+	}
+
+	if !refresh {
+		page, err := s.pageStore.GetPage(target, s.now().Add(-pageCacheTTL))
+		if err == nil {
+			return &ImportedText{Title: page.Title, Content: page.Content, Format: extract.FormatHTML, SourceURL: page.FinalURL, Cached: true}, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Errorf("Failed to read page cache: %v, URL: %s", err, target)
+		}
+	}
+
+	fetched, err := s.fetcher.Fetch(ctx, target)
+	if err != nil {
+		return nil, fetchError(err)
+	}
+	imported, err := articleText(fetched)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.FetchedPage{
+		URL:       target,
+		FinalURL:  imported.SourceURL,
+		Title:     imported.Title,
+		Content:   imported.Content,
+		FetchedAt: s.now(),
+	}
+	if err := s.pageStore.SavePage(page); err != nil {
+		logger.Errorf("Failed to save page cache: %v, URL: %s", err, target)
+	}
+	return imported, nil
+}
+
+// articleText はページの本文を取り出す。タイトルがなければホスト名を使う
+func articleText(page *webfetch.Page) (*ImportedText, error) {
+	var doc *extract.Document
+	var err error
+	if page.ContentType == "text/plain" {
+		doc, err = extract.Extract("page.txt", page.Body)
+	} else {
+		doc, err = extract.Article(bytes.NewReader(page.Body))
+	}
+	if errors.Is(err, extract.ErrNoText) || err == nil && strings.TrimSpace(doc.Text) == "" {
+		return nil, ErrUnreadablePage
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract article: %w", err)
+	}
+
+	title := doc.Title
+	if title == "" {
+		if u, err := url.Parse(page.URL); err == nil {
+			title = u.Hostname()
+		}
+	}
+	return &ImportedText{Title: truncateTitle(title), Content: doc.Text, Format: extract.FormatHTML, SourceURL: page.URL}, nil
+}
+
+func fetchError(err error) error {
+	switch {
+	case errors.Is(err, webfetch.ErrInvalidURL):
+		return ErrInvalidImportURL
+	case errors.Is(err, webfetch.ErrBlockedAddress):
+		return ErrBlockedURL
+	case errors.Is(err, webfetch.ErrUnsupportedContentType):
+		return ErrUnsupportedPage
+	case errors.Is(err, webfetch.ErrTooLarge):
+		return ErrPageTooLarge
+	}
+	return fmt.Errorf("%w: %v", ErrFetchFailed, err)
+}
+
+// truncateTitle は空白を整え、maxTitleLength 文字に切り詰める
+func truncateTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if utf8.RuneCountInString(title) <= maxTitleLength {
+		return title
+	}
+	return string([]rune(title)[:maxTitleLength])
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yomek33/newln/internal/pkg/webfetch"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+)
+
+func TestImportService_ExtractFile(t *testing.T) {
+	svc := NewImportService(nil, nil)
+
+	imported, err := svc.ExtractFile("notes/weekly  update.md", []byte("Plain *markdown*\nwithout a heading."))
+	if err != nil {
+		t.Fatalf("ExtractFile failed: %v", err)
+	}
+	if imported.Title != "weekly update" || imported.Content != "Plain markdown without a heading." || imported.Format != "markdown" {
+		t.Errorf("unexpected import: %+v", imported)
+	}
+
+	tests := []struct {
+		filename string
+		data     []byte
+		want     error
+	}{
+		{"photo.png", []byte("\x89PNG\r\n\x1a\n"), ErrUnsupportedFile},
+		{"blank.html", []byte("<html><body>  </body></html>"), ErrUnreadableFile},
+		{"big.txt", make([]byte, MaxUploadBytes+1), ErrFileTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := svc.ExtractFile(tt.filename, tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("ExtractFile(%q): expected %v, got %v", tt.filename, tt.want, err)
+		}
+	}
+}
+
+func TestImportService_FetchURL(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/post":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><title>Learning in public - My Blog</title></head><body>
+<nav><a href="/">Home</a></nav><article><p>Writing about what you learn, even when it is rough, helps you remember it.</p></article></body></html>`))
+		case "/empty":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><script>app()</script></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	opts := webfetch.DefaultOptions()
+	opts.AllowPrivate = true
+	store := stores_mock.NewMockPageCacheStore()
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	svc := NewImportService(store, webfetch.New(opts)).(*importService)
+	svc.now = func() time.Time { return now }
+
+	imported, err := svc.FetchURL(context.Background(), server.URL+"/post", false)
+	if err != nil {
+		t.Fatalf("FetchURL failed: %v", err)
+	}
+	if imported.Title != "Learning in public" || imported.SourceURL != server.URL+"/post" || imported.Cached {
+		t.Errorf("unexpected import: %+v", imported)
+	}
+	if imported.Content != "Writing about what you learn, even when it is rough, helps you remember it." {
+		t.Errorf("unexpected content: %q", imported.Content)
+	}
+
+	// 2回目はキャッシュから返し、refresh なら取得し直す
+	if imported, err = svc.FetchURL(context.Background(), server.URL+"/post#comments", false); err != nil || !imported.Cached || hits.Load() != 1 {
+		t.Errorf("expected a cached page, got %+v, %v (hits %d)", imported, err, hits.Load())
+	}
+	if _, err = svc.FetchURL(context.Background(), server.URL+"/post", true); err != nil || hits.Load() != 2 {
+		t.Errorf("expected a refetch, got %v (hits %d)", err, hits.Load())
+	}
+	now = now.Add(25 * time.Hour)
+	if imported, err = svc.FetchURL(context.Background(), server.URL+"/post", false); err != nil || imported.Cached || hits.Load() != 3 {
+		t.Errorf("expected the cache to expire, got %+v, %v (hits %d)", imported, err, hits.Load())
+	}
+
+	if _, err := svc.FetchURL(context.Background(), server.URL+"/empty", false); !errors.Is(err, ErrUnreadablePage) {
+		t.Errorf("expected ErrUnreadablePage, got %v", err)
+	}
+	if _, err := svc.FetchURL(context.Background(), "ftp://example.com/file", false); !errors.Is(err, ErrInvalidImportURL) {
+		t.Errorf("expected ErrInvalidImportURL, got %v", err)
+	}
+
+	blocked := NewImportService(store, webfetch.New(webfetch.DefaultOptions()))
+	if _, err := blocked.FetchURL(context.Background(), server.URL+"/post", true); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("expected ErrBlockedURL, got %v", err)
+	}
+}
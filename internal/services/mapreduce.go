@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yomek33/newln/internal/pkg/language"
+	"github.com/yomek33/newln/internal/pkg/lemma"
+	"github.com/yomek33/newln/internal/pkg/occurrence"
+	"github.com/yomek33/newln/internal/pkg/segment"
+)
+
+// 区間ごとの抽出を同時に投げる上限
+const extractConcurrency = 4
+
+// mapSegments は区間ごとに extract を最大 extractConcurrency 並列で実行する。
+// 結果は区間の順に並び、1つでも失敗したら残りを止めてそのエラーを返す
+func mapSegments[T any](ctx context.Context, segments []segment.Segment, extract func(ctx context.Context, seg segment.Segment) ([]T, error)) ([][]T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]T, len(segments))
+	sem := make(chan struct{}, extractConcurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for i, seg := range segments {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, seg segment.Segment) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			items, err := extract(ctx, seg)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = items
+		}(i, seg)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// mergeByKey は区間ごとの結果を key (大文字小文字を無視) で重複排除し、
+// 最初に現れたものを残す。seen は各項目が現れた区間の数
+func mergeByKey[T any](results [][]T, key func(T) string) (merged []T, seen []int) {
+	index := make(map[string]int)
+	for _, items := range results {
+		inSegment := make(map[string]bool)
+		for _, item := range items {
+			k := strings.ToLower(key(item))
+			if inSegment[k] {
+				continue
+			}
+			inSegment[k] = true
+
+			if i, ok := index[k]; ok {
+				seen[i]++
+				continue
+			}
+			index[k] = len(merged)
+			merged = append(merged, item)
+			seen = append(seen, 1)
+		}
+	}
+	return merged, seen
+}
+
+// countOccurrences は本文の索引 index で text が語として現れる回数を数える。
+// 語の境界は Unicode の文字で判定するので、ウムラウトや ß で始まる・終わる語も数えられる。
+// 分かち書きしない言語 lang では文字列として数える。見つからない (言い換え) ときは fallback を返す
+func countOccurrences(index *occurrence.Index, text, lang string, fallback int) int {
+	if language.Spaced(lang) {
+		// 句読点を落として索引の語と同じ区切りにそろえる
+		var words []string
+		for _, token := range occurrence.Tokenize(text) {
+			words = append(words, token.Lower)
+		}
+		text = strings.Join(words, " ")
+	}
+	if strings.TrimSpace(text) == "" {
+		return fallback
+	}
+	if n := len(index.Find(text)); n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// mergePhrases は区間ごとのフレーズをまとめ、出現回数の多い順に並べる
+func mergePhrases(results [][]PhraseResponse, content, lang string) []PhraseResponse {
+	merged, seen := mergeByKey(results, func(p PhraseResponse) string { return p.Phrase })
+	index := occurrence.NewIndexIn(content, lang)
+	for i := range merged {
+		merged[i].Occurrences = countOccurrences(index, merged[i].Phrase, lang, seen[i])
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Occurrences > merged[j].Occurrences })
+	return merged
+}
+
+// mergeWords は区間ごとの単語を見出し語でまとめ、出現回数の多い順に並べて ID を振り直す。
+// 出現回数は教材の言語 lang の活用形も含めて数える
+func mergeWords(results [][]WordResponse, content, lang string) []WordResponse {
+	key := func(w WordResponse) string { return lemma.LemmatizeIn(lang, w.Word, w.Pos) }
+	forms := make(map[string][]string)
+	for _, items := range results {
+		for _, w := range items {
+			k := key(w)
+			for _, form := range append([]string{strings.ToLower(w.Word)}, w.Forms...) {
+				if !slices.Contains(forms[k], form) {
+					forms[k] = append(forms[k], form)
+				}
+			}
+		}
+	}
+
+	merged, seen := mergeByKey(results, key)
+	index := occurrence.NewIndexIn(content, lang)
+	for i := range merged {
+		k := key(merged[i])
+		merged[i].Word = k
+		merged[i].Forms = forms[k]
+		merged[i].Occurrences = seen[i]
+		if n := len(index.Find(k)); n > 0 {
+			merged[i].Occurrences = n
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Occurrences > merged[j].Occurrences })
+	for i := range merged {
+		merged[i].ID = i + 1
+	}
+	return merged
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/segment"
+	"github.com/yomek33/newln/internal/pkg/vertex"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+	"gorm.io/gorm"
+)
+
+// scriptedVertexClient はプロンプトの内容に応じて応答を返す
+type scriptedVertexClient struct {
+	respond func(prompt string) (json.RawMessage, error)
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (c *scriptedVertexClient) GenerateJsonContent(_ context.Context, prompt string, _ *genai.Schema) (json.RawMessage, error) {
+	c.mu.Lock()
+	c.prompts = append(c.prompts, prompt)
+	c.mu.Unlock()
+	return c.respond(prompt)
+}
+
+func (c *scriptedVertexClient) IsMock() bool                          { return true }
+func (c *scriptedVertexClient) SetUsageRecorder(vertex.UsageRecorder) {}
+
+func TestMapSegments_BoundedConcurrency(t *testing.T) {
+	segments := make([]segment.Segment, 10)
+	for i := range segments {
+		segments[i] = segment.Segment{Index: i}
+	}
+
+	var running, peak int32
+	results, err := mapSegments(context.Background(), segments, func(_ context.Context, seg segment.Segment) ([]int, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return []int{seg.Index}, nil
+	})
+	if err != nil {
+		t.Fatalf("mapSegments failed: %v", err)
+	}
+	if peak > extractConcurrency {
+		t.Errorf("peak concurrency %d exceeds %d", peak, extractConcurrency)
+	}
+	for i, r := range results {
+		if len(r) != 1 || r[0] != i {
+			t.Errorf("results out of order: %v", results)
+			break
+		}
+	}
+}
+
+func TestMapSegments_StopsOnError(t *testing.T) {
+	boom := errors.New("boom")
+	segments := make([]segment.Segment, 20)
+	var calls int32
+	_, err := mapSegments(context.Background(), segments, func(ctx context.Context, _ segment.Segment) ([]int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, boom
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if calls >= 20 {
+		t.Errorf("expected remaining segments to be skipped, got %d calls", calls)
+	}
+}
+
+func TestMergeWords_CountsOccurrences(t *testing.T) {
+	content := "Engineers resolve issues. The engineers resolve them fast. Partners help."
+	merged := mergeWords([][]WordResponse{
+		{{ID: 1, Word: "resolve"}, {ID: 2, Word: "partner"}},
+		{{ID: 1, Word: "Resolve"}, {ID: 2, Word: "engineers"}},
+	}, content, "en")
+
+	if len(merged) != 3 {
+		t.Fatalf("expected 3 merged words, got %+v", merged)
+	}
+	if merged[0].Word != "resolve" || merged[0].Occurrences != 2 || merged[0].ID != 1 {
+		t.Errorf("unexpected first word: %+v", merged[0])
+	}
+	// "partner" は活用形 (Partners) として1回現れる
+	for _, w := range merged {
+		if w.Word == "partner" && w.Occurrences != 1 {
+			t.Errorf("partner occurrences = %d, expected 1", w.Occurrences)
+		}
+	}
+}
+
+func TestMergeWords_MergesInflections(t *testing.T) {
+	content := "They innovate. We innovated last year and keep innovating."
+	merged := mergeWords([][]WordResponse{
+		{{ID: 1, Word: "innovate", Pos: "verb"}, {ID: 2, Word: "innovated", Pos: "verb"}},
+		{{ID: 1, Word: "Innovating", Pos: "verb"}},
+	}, content, "en")
+
+	if len(merged) != 1 {
+		t.Fatalf("expected a single lemma, got %+v", merged)
+	}
+	w := merged[0]
+	if w.Word != "innovate" || w.Occurrences != 3 {
+		t.Errorf("unexpected merged word: %+v", w)
+	}
+	if strings.Join(w.Forms, ",") != "innovate,innovated,innovating" {
+		t.Errorf("forms = %v", w.Forms)
+	}
+}
+
+func TestWordService_GenerateWords_MapReduce(t *testing.T) {
+	paragraphs := []string{
+		"The engineers resolve every incident before lunch.",
+		"Our partner signed the deal. The engineers resolve disputes too.",
+		"Operation costs went down after the partner joined.",
+	}
+	materialStore := stores_mock.NewMockMaterialStore()
+	materialStore.Materials[1] = &models.Material{Model: gorm.Model{ID: 1}, Content: strings.Join(paragraphs, "\n\n")}
+
+	client := &scriptedVertexClient{respond: func(prompt string) (json.RawMessage, error) {
+		if strings.Contains(prompt, "part of speech") {
+			return json.RawMessage(`[
+				{"id": 1, "word": "resolve", "pos": "verb", "meaning": "to solve", "translation": "解決する"},
+				{"id": 2, "word": "partner", "pos": "noun", "meaning": "a collaborator", "translation": "提携先"},
+				{"id": 3, "word": "operation", "pos": "noun", "meaning": "functioning", "translation": "運用"}
+			]`), nil
+		}
+		var words []string
+		for _, w := range []string{"resolve", "partner", "operation"} {
+			if strings.Contains(strings.ToLower(prompt), w+" ") {
+				words = append(words, `{"id": 1, "word": "`+w+`", "pos": "noun"}`)
+			}
+		}
+		return json.RawMessage("[" + strings.Join(words, ",") + "]"), nil
+	}}
+
+	service := &wordService{
+		materialStore:  materialStore,
+		vertexClient:   client,
+		segmentOptions: segment.Options{MaxChars: 80, Overlap: 0},
+	}
+
+	words, err := service.GenerateWords(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GenerateWords failed: %v", err)
+	}
+	if len(words) != 3 {
+		t.Fatalf("expected 3 deduplicated words, got %+v", words)
+	}
+	// 3区間の抽出 + 1回の意味生成
+	if len(client.prompts) != 4 {
+		t.Errorf("expected 4 LLM calls, got %d", len(client.prompts))
+	}
+}
+
+func TestWordService_GenerateWords_SourceLanguage(t *testing.T) {
+	materialStore := stores_mock.NewMockMaterialStore()
+	materialStore.Materials[1] = &models.Material{
+		Model:          gorm.Model{ID: 1},
+		Content:        "Das Team hat einen Vertrag unterschrieben. Wir arbeiten jeden Tag, und sie arbeitet auch am Wochenende.",
+		SourceLanguage: "de",
+	}
+
+	client := &scriptedVertexClient{respond: func(prompt string) (json.RawMessage, error) {
+		if strings.Contains(prompt, "update each object") {
+			return json.RawMessage(`[
+				{"id": 1, "word": "arbeiten", "pos": "verb", "meaning": "tätig sein", "translation": "働く"},
+				{"id": 2, "word": "Vertrag", "pos": "noun", "meaning": "eine Vereinbarung", "translation": "契約"}
+			]`), nil
+		}
+		return json.RawMessage(`[
+			{"id": 1, "word": "arbeitet", "pos": "verb", "cefr": "A2"},
+			{"id": 2, "word": "Vertrag", "pos": "noun", "cefr": "B1"}
+		]`), nil
+	}}
+
+	service := &wordService{materialStore: materialStore, vertexClient: client}
+	words, err := service.GenerateWords(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GenerateWords failed: %v", err)
+	}
+
+	// ドイツ語用のテンプレートで抽出・意味生成する
+	for _, prompt := range client.prompts {
+		if !strings.Contains(prompt, "German learners") {
+			t.Errorf("expected the German template, got %q", prompt)
+		}
+	}
+	occurrences := make(map[string]int)
+	for _, w := range words {
+		occurrences[w.Lemma] = len(w.Occurrences)
+	}
+	// 活用形はドイツ語の規則でまとめ、名詞は大文字のまま残す
+	if len(words) != 2 || occurrences["arbeiten"] != 2 || occurrences["Vertrag"] != 1 {
+		t.Errorf("unexpected words: %+v", words)
+	}
+}
+
+func TestMergePhrases_CountsGermanOccurrences(t *testing.T) {
+	content := "Übung macht den Meister. Die Übung war schwer, aber der Erfolg war groß. Der Unterschied ist sehr groß."
+	merged := mergePhrases([][]PhraseResponse{
+		{{Phrase: "Übung macht den Meister"}, {Phrase: "sehr groß"}},
+		{{Phrase: "Übung"}},
+	}, content, "de")
+
+	occurrences := make(map[string]int)
+	for _, p := range merged {
+		occurrences[p.Phrase] = p.Occurrences
+	}
+	// ウムラウトや ß で始まる・終わる語も数える (区間数の 1 にフォールバックしない)
+	if occurrences["Übung"] != 2 {
+		t.Errorf("Übung occurrences = %d, expected 2", occurrences["Übung"])
+	}
+	if occurrences["Übung macht den Meister"] != 1 || occurrences["sehr groß"] != 1 {
+		t.Errorf("unexpected occurrences: %v", occurrences)
+	}
+	if merged[0].Phrase != "Übung" {
+		t.Errorf("expected the most frequent phrase first, got %+v", merged[0])
+	}
+}
+
+// ctxVertexClient は呼び出しの ctx も受け取って応答を返す
+type ctxVertexClient struct {
+	respond func(ctx context.Context, prompt string) (json.RawMessage, error)
+}
+
+func (c *ctxVertexClient) GenerateJsonContent(ctx context.Context, prompt string, _ *genai.Schema) (json.RawMessage, error) {
+	return c.respond(ctx, prompt)
+}
+
+func (c *ctxVertexClient) IsMock() bool                          { return true }
+func (c *ctxVertexClient) SetUsageRecorder(vertex.UsageRecorder) {}
+
+func TestPhraseService_GeneratePhrasesInChunks_StopsOnError(t *testing.T) {
+	// 30件ずつに分けるので意味の生成は2回
+	var phrases, items []string
+	for i := 0; i < 40; i++ {
+		phrase := "phrase" + string(rune('a'+i/26)) + string(rune('a'+i%26))
+		phrases = append(phrases, phrase)
+		items = append(items, `{"phrase": "`+phrase+`", "from_text": true, "example": "x", "difficulty": "easy"}`)
+	}
+	materialStore := stores_mock.NewMockMaterialStore()
+	materialStore.Materials[1] = &models.Material{Model: gorm.Model{ID: 1}, Content: strings.Join(phrases, " ")}
+
+	boom := errors.New("boom")
+	var meaningCalls int32
+	stopped := make(chan struct{})
+	client := &ctxVertexClient{respond: func(ctx context.Context, prompt string) (json.RawMessage, error) {
+		if !strings.Contains(prompt, "represents a collocation") {
+			return json.RawMessage("[" + strings.Join(items, ",") + "]"), nil
+		}
+		if atomic.AddInt32(&meaningCalls, 1) == 1 {
+			return nil, boom
+		}
+		<-ctx.Done()
+		close(stopped)
+		return nil, ctx.Err()
+	}}
+
+	service := &phraseService{materialStore: materialStore, vertexClient: client}
+	var saved int32
+	_, err := service.GeneratePhrasesInChunks(context.Background(), 1, func([]models.Phrase) error {
+		atomic.AddInt32(&saved, 1)
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	// 戻る前に残りのチャンクを止めている
+	select {
+	case <-stopped:
+	default:
+		t.Error("expected the other chunk to be canceled before returning")
+	}
+	if saved != 0 {
+		t.Errorf("expected nothing to be saved, got %d chunks", saved)
+	}
+}
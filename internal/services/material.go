@@ -13,11 +13,17 @@ import (
 )
 
 type MaterialService interface {
-	CreateMaterial(material *models.Material) (*models.Material, error)
+	CreateMaterial(material *models.Material, limit CreationLimit) (*models.Material, error)
 	GetMaterialByULID(ulid string, UserID uuid.UUID) (*models.Material, error)
-	UpdateMaterial(ulid string, material *models.Material) error
+	PatchMaterial(ulid string, UserID uuid.UUID, version int, patch MaterialPatch) (*models.Material, bool, error)
+	ResetDerivedLists(material *models.Material) error
+	ListRevisions(ulid string, UserID uuid.UUID) ([]models.MaterialRevision, error)
+	GetRevision(ulid string, UserID uuid.UUID, version int) (*models.MaterialRevision, error)
+	DiffRevisions(ulid string, UserID uuid.UUID, from, to int) (*RevisionDiff, error)
+	RestoreRevision(ulid string, UserID uuid.UUID, version, expectedVersion int) (*models.Material, bool, error)
+	GetSubtitleTimeline(ulid string, UserID uuid.UUID) (*SubtitleTimeline, error)
 	DeleteMaterial(ulid string, UserID uuid.UUID) error
-	GetAllMaterials(searchQuery string, UserID uuid.UUID) ([]models.Material, error)
+	ListMaterials(query models.MaterialQuery, cursor string) (*MaterialPage, error)
 	UpdateMaterialStatus(materialID uint, status string) error
 	GetMaterialStatus(ulid string) (string, error)
 	SubscribeToMaterialUpdates(materialULID string) chan string
@@ -42,15 +48,26 @@ func NewMaterialService(s stores.MaterialStore) MaterialService {
 }
 
 var (
-	ErrMaterialNil          = errors.New("material cannot be nil")
-	ErrMismatchedMaterialID = errors.New("mismatched material ID")
+	ErrMaterialNil = errors.New("material cannot be nil")
 )
 
-func (s *materialService) CreateMaterial(material *models.Material) (*models.Material, error) {
+// CreateMaterial は limit の上限を数え直してから教材を作る。上限に達していれば *QuotaError を返す
+func (s *materialService) CreateMaterial(material *models.Material, limit CreationLimit) (*models.Material, error) {
 	if material == nil {
 		return nil, errors.New("material cannot be nil")
 	}
-	return s.store.CreateMaterial(material)
+	material.WordCount = countWords(material.Content, materialLanguage(material))
+	if material.Version == 0 {
+		material.Version = 1
+	}
+	created, err := s.store.CreateMaterialWithinLimit(material, limit.Since, limit.Max)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, limit.exceeded()
+	}
+	return material, nil
 }
 
 func (s *materialService) GetMaterialByULID(ulid string, UserID uuid.UUID) (*models.Material, error) {
@@ -61,22 +78,40 @@ func (s *materialService) GetMaterialByULID(ulid string, UserID uuid.UUID) (*mod
 	return material, nil
 }
 
-func (s *materialService) UpdateMaterial(ulid string, material *models.Material) error {
-	if material == nil {
-		return ErrMaterialNil
-	}
-	if ulid != material.ULID {
-		return ErrMismatchedMaterialID
-	}
-	return s.store.UpdateMaterial(ulid, material)
-}
-
 func (s *materialService) DeleteMaterial(ulid string, UserID uuid.UUID) error {
 	return s.store.DeleteMaterial(ulid, UserID)
 }
 
-func (s *materialService) GetAllMaterials(searchQuery string, UserID uuid.UUID) ([]models.Material, error) {
-	return s.store.GetAllMaterials(searchQuery, UserID)
+// ListMaterials は query を検証し、cursor の次の1ページを返す
+func (s *materialService) ListMaterials(query models.MaterialQuery, cursor string) (*MaterialPage, error) {
+	if err := normalizeMaterialQuery(&query); err != nil {
+		return nil, err
+	}
+	if cursor != "" {
+		after, err := decodeMaterialCursor(cursor, query)
+		if err != nil {
+			return nil, err
+		}
+		query.After = after
+	}
+
+	// 次のページがあるかを知るため1件多く読む
+	limit := query.Limit
+	query.Limit++
+	materials, err := s.store.ListMaterials(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list materials: %w", err)
+	}
+
+	page := &MaterialPage{Items: materials}
+	if len(materials) > limit {
+		page.Items = materials[:limit]
+		page.NextCursor = encodeMaterialCursor(query, page.Items[limit-1])
+	}
+	if page.Items == nil {
+		page.Items = []models.MaterialSummary{}
+	}
+	return page, nil
 }
 
 func (s *materialService) UpdateMaterialStatus(id uint, status string) error {
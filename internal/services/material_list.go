@@ -0,0 +1,136 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/language"
+)
+
+const (
+	defaultMaterialPageSize = 20
+	maxMaterialPageSize     = 100
+)
+
+var (
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrInvalidListFilter = errors.New("invalid list filter")
+)
+
+var materialStatuses = []string{models.StatusDraft, models.StatusPublished, models.StatusArchived}
+
+// MaterialPage は一覧の1ページ。NextCursor が空なら最後のページ
+type MaterialPage struct {
+	Items      []models.MaterialSummary `json:"items"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// materialCursor はカーソルの中身。並び順が変わったカーソルは使えない
+type materialCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ULID  string `json:"u"`
+}
+
+// normalizeMaterialQuery は既定値を埋め、並び順・件数・絞り込みを検証する
+func normalizeMaterialQuery(query *models.MaterialQuery) error {
+	if query.Sort == "" {
+		query.Sort = models.MaterialSortCreated
+	}
+	switch query.Sort {
+	case models.MaterialSortCreated, models.MaterialSortUpdated, models.MaterialSortTitle, models.MaterialSortWordCount:
+	default:
+		return ErrInvalidListFilter
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultMaterialPageSize
+	}
+	if query.Limit < 0 || query.Limit > maxMaterialPageSize {
+		return ErrInvalidListFilter
+	}
+
+	for _, status := range query.Statuses {
+		if !slices.Contains(materialStatuses, status) {
+			return ErrInvalidListFilter
+		}
+	}
+	switch query.Generation {
+	case "", models.GenerationPending, models.GenerationReady:
+	default:
+		return ErrInvalidListFilter
+	}
+	if query.CreatedFrom != nil && query.CreatedTo != nil && !query.CreatedFrom.Before(*query.CreatedTo) {
+		return ErrInvalidListFilter
+	}
+	query.Search = strings.TrimSpace(query.Search)
+	if err := normalizeScope(&query.MaterialScope); err != nil {
+		return ErrInvalidListFilter
+	}
+	return nil
+}
+
+// encodeMaterialCursor は last の並び順の値と ULID を不透明な文字列にする
+func encodeMaterialCursor(query models.MaterialQuery, last models.MaterialSummary) string {
+	cursor := materialCursor{Sort: query.Sort, Desc: query.Desc, ULID: last.ULID}
+	switch query.Sort {
+	case models.MaterialSortCreated:
+		cursor.Value = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case models.MaterialSortUpdated:
+		cursor.Value = last.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case models.MaterialSortTitle:
+		cursor.Value = last.Title
+	case models.MaterialSortWordCount:
+		cursor.Value = strconv.Itoa(last.WordCount)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeMaterialCursor はカーソルを戻し、query と同じ並び順で作られたかを確かめる
+func decodeMaterialCursor(encoded string, query models.MaterialQuery) (*models.MaterialCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor materialCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ULID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != query.Sort || cursor.Desc != query.Desc {
+		return nil, ErrInvalidCursor
+	}
+	switch cursor.Sort {
+	case models.MaterialSortCreated, models.MaterialSortUpdated:
+		if _, err := time.Parse(time.RFC3339Nano, cursor.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	case models.MaterialSortWordCount:
+		if _, err := strconv.Atoi(cursor.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &models.MaterialCursor{Value: cursor.Value, ULID: cursor.ULID}, nil
+}
+
+// countWords は本文の語数を数える。分かち書きしない言語は文字数で数える
+func countWords(content, lang string) int {
+	if language.Spaced(lang) {
+		return len(strings.Fields(content))
+	}
+	count := 0
+	for _, r := range content {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			count++
+		}
+	}
+	return count
+}
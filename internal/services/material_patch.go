@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/language"
+
+	"github.com/google/uuid"
+)
+
+const maxTitleLength = 255
+
+var (
+	ErrVersionConflict      = errors.New("material was modified by another request")
+	ErrInvalidMaterialPatch = errors.New("invalid material update")
+)
+
+// MaterialPatch は利用者が変更できるフィールド。nil のフィールドは変更しない
+type MaterialPatch struct {
+	Title          *string
+	Content        *string
+	SourceLanguage *string
+	Status         *string
+}
+
+// PatchMaterial は version が現在の版と一致するときだけ patch を適用する。
+// version が 0 なら版を確かめない。本文か言語が変わったら contentChanged を返す
+// (単語・フレーズのリストは古いまま残るので、作り直すかは呼び出し側が決める)
+func (s *materialService) PatchMaterial(ulid string, UserID uuid.UUID, version int, patch MaterialPatch) (*models.Material, bool, error) {
+	return s.patchMaterial(ulid, UserID, version, patch, 0)
+}
+
+// patchMaterial はタイトル・本文・言語が変わったら新しい版を残す。restoredFrom は復元元の版
+func (s *materialService) patchMaterial(ulid string, UserID uuid.UUID, version int, patch MaterialPatch, restoredFrom int) (*models.Material, bool, error) {
+	current, err := s.store.GetMaterialByULID(ulid, UserID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get material by ID: %w", err)
+	}
+	if version == 0 {
+		version = current.Version
+	}
+	if version != current.Version {
+		return current, false, ErrVersionConflict
+	}
+
+	fields, contentChanged, err := patchFields(current, patch)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(fields) == 0 {
+		return current, false, nil
+	}
+
+	var revision *models.MaterialRevision
+	if revisedFields(fields) {
+		revision = newRevision(current, fields, UserID, restoredFrom)
+	}
+	applied, err := s.store.UpdateMaterialFields(ulid, UserID, version, fields, revision)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update material: %w", err)
+	}
+	updated, err := s.store.GetMaterialByULID(ulid, UserID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get material by ID: %w", err)
+	}
+	if !applied {
+		// 読んでから書くまでの間に別の更新が入った
+		return updated, false, ErrVersionConflict
+	}
+	return updated, contentChanged, nil
+}
+
+// patchFields は current から変わるフィールドだけを列名で返す
+func patchFields(current *models.Material, patch MaterialPatch) (map[string]interface{}, bool, error) {
+	fields := make(map[string]interface{})
+	if patch.Title != nil {
+		title := strings.TrimSpace(*patch.Title)
+		if title == "" || utf8.RuneCountInString(title) > maxTitleLength {
+			return nil, false, ErrInvalidMaterialPatch
+		}
+		if title != current.Title {
+			fields["title"] = title
+		}
+	}
+	if patch.Status != nil {
+		if !slices.Contains(materialStatuses, *patch.Status) {
+			return nil, false, ErrInvalidMaterialPatch
+		}
+		if *patch.Status != current.Status {
+			fields["status"] = *patch.Status
+		}
+	}
+
+	contentChanged := false
+	lang := materialLanguage(current)
+	if patch.SourceLanguage != nil {
+		code, ok := language.NormalizeSource(*patch.SourceLanguage)
+		if !ok {
+			return nil, false, ErrInvalidMaterialPatch
+		}
+		if code != current.SourceLanguage {
+			fields["source_language"] = code
+			lang = code
+			contentChanged = true
+		}
+	}
+	content := current.Content
+	if patch.Content != nil {
+		if strings.TrimSpace(*patch.Content) == "" {
+			return nil, false, ErrInvalidMaterialPatch
+		}
+		if *patch.Content != current.Content {
+			fields["content"] = *patch.Content
+			content = *patch.Content
+			contentChanged = true
+		}
+	}
+	if contentChanged {
+		fields["word_count"] = countWords(content, lang)
+	}
+	return fields, contentChanged, nil
+}
+
+// ResetDerivedLists は単語・フレーズのリストを削除して生成待ちに戻す。作り直す前に呼ぶ
+func (s *materialService) ResetDerivedLists(material *models.Material) error {
+	if material == nil {
+		return ErrMaterialNil
+	}
+	if err := s.store.ResetDerivedLists(material.ID); err != nil {
+		return fmt.Errorf("failed to reset derived lists: %w", err)
+	}
+	material.WordLists = nil
+	material.PhraseLists = nil
+	material.HasPendingWordList = true
+	material.HasPendingPhraseList = true
+	return nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/textdiff"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 差分の前後に付ける変わらない行の数
+const revisionDiffContext = 3
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// RevisionDiff は2つの版の差分。To が 0 なら今の教材と比べた
+type RevisionDiff struct {
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	FromTitle string `json:"from_title"`
+	ToTitle   string `json:"to_title"`
+	Diff      string `json:"diff"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// revisedFields は版を残す変更 (タイトル・本文・言語) があるかを返す
+func revisedFields(fields map[string]interface{}) bool {
+	for _, field := range []string{"title", "content", "source_language"} {
+		if _, ok := fields[field]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newRevision は current に fields を当てた後の状態を、current からの差分付きで版にする
+func newRevision(current *models.Material, fields map[string]interface{}, authorID uuid.UUID, restoredFrom int) *models.MaterialRevision {
+	revision := &models.MaterialRevision{
+		AuthorID:       authorID,
+		Title:          current.Title,
+		Content:        current.Content,
+		SourceLanguage: current.SourceLanguage,
+		RestoredFrom:   restoredFrom,
+	}
+	if title, ok := fields["title"].(string); ok {
+		revision.Title = title
+	}
+	if content, ok := fields["content"].(string); ok {
+		revision.Content = content
+	}
+	if code, ok := fields["source_language"].(string); ok {
+		revision.SourceLanguage = code
+	}
+
+	lines := textdiff.Text(current.Content, revision.Content)
+	revision.Diff = textdiff.Unified(fmt.Sprintf("v%d", current.Version), fmt.Sprintf("v%d", current.Version+1), lines, revisionDiffContext)
+	revision.Additions, revision.Deletions = textdiff.Stats(lines)
+	return revision
+}
+
+// ListRevisions は教材の版を新しい順に返す。本文と差分は含めない
+func (s *materialService) ListRevisions(ulid string, UserID uuid.UUID) ([]models.MaterialRevision, error) {
+	material, err := s.GetMaterialByULID(ulid, UserID)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.store.ListRevisions(material.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	if revisions == nil {
+		revisions = []models.MaterialRevision{}
+	}
+	return revisions, nil
+}
+
+func (s *materialService) GetRevision(ulid string, UserID uuid.UUID, version int) (*models.MaterialRevision, error) {
+	material, err := s.GetMaterialByULID(ulid, UserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.getRevision(material.ID, version)
+}
+
+func (s *materialService) getRevision(materialID uint, version int) (*models.MaterialRevision, error) {
+	revision, err := s.store.GetRevision(materialID, version)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision: %w", err)
+	}
+	return revision, nil
+}
+
+// DiffRevisions は版 from から版 to への本文の差分を返す。to が 0 なら今の教材と比べる
+func (s *materialService) DiffRevisions(ulid string, UserID uuid.UUID, from, to int) (*RevisionDiff, error) {
+	material, err := s.GetMaterialByULID(ulid, UserID)
+	if err != nil {
+		return nil, err
+	}
+	fromRevision, err := s.getRevision(material.ID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	toTitle, toContent, toVersion := material.Title, material.Content, material.Version
+	if to != 0 {
+		toRevision, err := s.getRevision(material.ID, to)
+		if err != nil {
+			return nil, err
+		}
+		toTitle, toContent, toVersion = toRevision.Title, toRevision.Content, toRevision.Version
+	}
+
+	lines := textdiff.Text(fromRevision.Content, toContent)
+	diff := &RevisionDiff{
+		From:      from,
+		To:        to,
+		FromTitle: fromRevision.Title,
+		ToTitle:   toTitle,
+		Diff:      textdiff.Unified(fmt.Sprintf("v%d", from), fmt.Sprintf("v%d", toVersion), lines, revisionDiffContext),
+	}
+	diff.Additions, diff.Deletions = textdiff.Stats(lines)
+	return diff, nil
+}
+
+// RestoreRevision は版 version のタイトル・本文・言語を新しい版として書き戻す。
+// expectedVersion は PatchMaterial と同じ楽観ロック。本文か言語が変わったら contentChanged を返す
+func (s *materialService) RestoreRevision(ulid string, UserID uuid.UUID, version, expectedVersion int) (*models.Material, bool, error) {
+	material, err := s.GetMaterialByULID(ulid, UserID)
+	if err != nil {
+		return nil, false, err
+	}
+	revision, err := s.getRevision(material.ID, version)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.patchMaterial(ulid, UserID, expectedVersion, MaterialPatch{
+		Title:          &revision.Title,
+		Content:        &revision.Content,
+		SourceLanguage: &revision.SourceLanguage,
+	}, revision.Version)
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yomek33/newln/internal/models"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+	"gorm.io/gorm"
+)
+
+func TestMaterialService_ListMaterials(t *testing.T) {
+	userID := uuid.New()
+	store := stores_mock.NewMockMaterialStore()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 5; i++ {
+		store.Materials[uint(i)] = &models.Material{
+			Model:     gorm.Model{ID: uint(i), CreatedAt: base.Add(time.Duration(i) * time.Hour)},
+			UserID:    userID,
+			ULID:      fmt.Sprintf("01J%d", i),
+			Title:     fmt.Sprintf("Article %d", i),
+			Content:   "body",
+			Status:    models.StatusDraft,
+			WordCount: 10 * (6 - i),
+		}
+	}
+	store.Materials[6] = &models.Material{Model: gorm.Model{ID: 6}, UserID: uuid.New(), ULID: "other"}
+	service := NewMaterialService(store)
+
+	t.Run("pages through newest first", func(t *testing.T) {
+		var ulids []string
+		cursor := ""
+		for pages := 0; pages < 5; pages++ {
+			page, err := service.ListMaterials(models.MaterialQuery{UserID: userID, Desc: true, Limit: 2}, cursor)
+			if err != nil {
+				t.Fatalf("ListMaterials failed: %v", err)
+			}
+			for _, item := range page.Items {
+				ulids = append(ulids, item.ULID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if fmt.Sprint(ulids) != "[01J5 01J4 01J3 01J2 01J1]" {
+			t.Errorf("unexpected order: %v", ulids)
+		}
+	})
+
+	t.Run("sorts by word count", func(t *testing.T) {
+		page, err := service.ListMaterials(models.MaterialQuery{UserID: userID, Sort: models.MaterialSortWordCount, Limit: 1}, "")
+		if err != nil {
+			t.Fatalf("ListMaterials failed: %v", err)
+		}
+		if len(page.Items) != 1 || page.Items[0].ULID != "01J5" || page.NextCursor == "" {
+			t.Errorf("unexpected page: %+v", page)
+		}
+
+		// 並び順が違うカーソルは使えない
+		_, err = service.ListMaterials(models.MaterialQuery{UserID: userID, Limit: 1}, page.NextCursor)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("rejects invalid input", func(t *testing.T) {
+		for _, query := range []models.MaterialQuery{
+			{UserID: userID, Sort: "content"},
+			{UserID: userID, Limit: maxMaterialPageSize + 1},
+			{UserID: userID, Statuses: []string{"deleted"}},
+			{UserID: userID, Generation: "soon"},
+		} {
+			if _, err := service.ListMaterials(query, ""); !errors.Is(err, ErrInvalidListFilter) {
+				t.Errorf("expected ErrInvalidListFilter for %+v, got %v", query, err)
+			}
+		}
+		if _, err := service.ListMaterials(models.MaterialQuery{UserID: userID}, "not-a-cursor"); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor, got %v", err)
+		}
+	})
+}
+
+func TestMaterialService_CreateMaterialRechecksDailyLimit(t *testing.T) {
+	now := time.Now().UTC()
+	userID := uuid.New()
+	store := stores_mock.NewMockMaterialStore()
+	svc := NewMaterialService(store)
+
+	// 2件とも確かめた時点では1件残っていた
+	limit := CreationLimit{Plan: "free", Since: now.Add(-time.Hour), ResetAt: now.Add(time.Hour), Max: 1}
+	if _, err := svc.CreateMaterial(&models.Material{UserID: userID, ULID: "first"}, limit); err != nil {
+		t.Fatalf("CreateMaterial failed: %v", err)
+	}
+	_, err := svc.CreateMaterial(&models.Material{UserID: userID, ULID: "second"}, limit)
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Limit != LimitMaterialsPerDay || quotaErr.Kind != QuotaKindRateLimit {
+		t.Fatalf("expected the daily limit, got %v", err)
+	}
+	if len(store.Materials) != 1 {
+		t.Errorf("expected only the first material to be created, got %d", len(store.Materials))
+	}
+
+	// 上限なし
+	if _, err := svc.CreateMaterial(&models.Material{UserID: userID, ULID: "third"}, CreationLimit{}); err != nil {
+		t.Errorf("CreateMaterial without a limit failed: %v", err)
+	}
+}
+
+func TestMaterialService_PatchMaterial(t *testing.T) {
+	userID := uuid.New()
+	newStore := func() *stores_mock.MockMaterialStore {
+		store := stores_mock.NewMockMaterialStore()
+		store.Materials[1] = &models.Material{
+			Model:          gorm.Model{ID: 1},
+			UserID:         userID,
+			ULID:           "01JPATCH",
+			Title:          "Draft",
+			Content:        "one two three",
+			SourceLanguage: "en",
+			Status:         models.StatusDraft,
+			Version:        3,
+		}
+		return store
+	}
+	str := func(s string) *string { return &s }
+
+	t.Run("title only", func(t *testing.T) {
+		service := NewMaterialService(newStore())
+		material, contentChanged, err := service.PatchMaterial("01JPATCH", userID, 3, MaterialPatch{Title: str(" Final ")})
+		if err != nil {
+			t.Fatalf("PatchMaterial failed: %v", err)
+		}
+		if material.Title != "Final" || material.Version != 4 || contentChanged {
+			t.Errorf("unexpected result: %+v, contentChanged=%v", material, contentChanged)
+		}
+	})
+
+	t.Run("content change", func(t *testing.T) {
+		service := NewMaterialService(newStore())
+		material, contentChanged, err := service.PatchMaterial("01JPATCH", userID, 3, MaterialPatch{Content: str("four five")})
+		if err != nil {
+			t.Fatalf("PatchMaterial failed: %v", err)
+		}
+		if !contentChanged || material.WordCount != 2 {
+			t.Errorf("unexpected result: %+v, contentChanged=%v", material, contentChanged)
+		}
+	})
+
+	t.Run("unchanged values keep the version", func(t *testing.T) {
+		service := NewMaterialService(newStore())
+		material, contentChanged, err := service.PatchMaterial("01JPATCH", userID, 3, MaterialPatch{Content: str("one two three"), Status: str(models.StatusDraft)})
+		if err != nil || contentChanged || material.Version != 3 {
+			t.Errorf("expected a no-op, got %+v, %v, %v", material, contentChanged, err)
+		}
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		service := NewMaterialService(newStore())
+		material, _, err := service.PatchMaterial("01JPATCH", userID, 2, MaterialPatch{Title: str("Final")})
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("expected ErrVersionConflict, got %v", err)
+		}
+		if material.Version != 3 || material.Title != "Draft" {
+			t.Errorf("expected the current material, got %+v", material)
+		}
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		service := NewMaterialService(newStore())
+		for _, patch := range []MaterialPatch{
+			{Title: str("  ")},
+			{Status: str("processing")},
+			{SourceLanguage: str("xx")},
+			{Content: str("")},
+		} {
+			if _, _, err := service.PatchMaterial("01JPATCH", userID, 3, patch); !errors.Is(err, ErrInvalidMaterialPatch) {
+				t.Errorf("expected ErrInvalidMaterialPatch for %+v, got %v", patch, err)
+			}
+		}
+	})
+}
+
+func TestMaterialService_Revisions(t *testing.T) {
+	userID := uuid.New()
+	store := stores_mock.NewMockMaterialStore()
+	// 履歴ができる前に作られた教材
+	store.Materials[1] = &models.Material{
+		Model:          gorm.Model{ID: 1},
+		UserID:         userID,
+		ULID:           "01JREV",
+		Title:          "Scan",
+		Content:        "Tbe deal\nwas closed.",
+		SourceLanguage: "en",
+		Status:         models.StatusDraft,
+		Version:        1,
+	}
+	service := NewMaterialService(store)
+	str := func(s string) *string { return &s }
+
+	if _, _, err := service.PatchMaterial("01JREV", userID, 1, MaterialPatch{Status: str(models.StatusPublished)}); err != nil {
+		t.Fatalf("PatchMaterial failed: %v", err)
+	}
+	if _, _, err := service.PatchMaterial("01JREV", userID, 2, MaterialPatch{Content: str("The deal\nwas closed.")}); err != nil {
+		t.Fatalf("PatchMaterial failed: %v", err)
+	}
+
+	// 状態だけの変更は版を残さない。変更前の状態を最初の版として残す
+	revisions, err := service.ListRevisions("01JREV", userID)
+	if err != nil {
+		t.Fatalf("ListRevisions failed: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Version != 3 || revisions[1].Version != 2 {
+		t.Fatalf("unexpected revisions: %+v", revisions)
+	}
+	latest, err := service.GetRevision("01JREV", userID, 3)
+	if err != nil {
+		t.Fatalf("GetRevision failed: %v", err)
+	}
+	if latest.AuthorID != userID || latest.Additions != 1 || latest.Deletions != 1 || !strings.Contains(latest.Diff, "-Tbe deal\n+The deal\n") {
+		t.Errorf("unexpected revision: %+v", latest)
+	}
+
+	diff, err := service.DiffRevisions("01JREV", userID, 2, 0)
+	if err != nil {
+		t.Fatalf("DiffRevisions failed: %v", err)
+	}
+	if diff.Additions != 1 || diff.Deletions != 1 {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+
+	material, contentChanged, err := service.RestoreRevision("01JREV", userID, 2, 3)
+	if err != nil {
+		t.Fatalf("RestoreRevision failed: %v", err)
+	}
+	if !contentChanged || material.Content != "Tbe deal\nwas closed." || material.Version != 4 {
+		t.Errorf("unexpected restored material: %+v", material)
+	}
+	restored, _ := service.GetRevision("01JREV", userID, 4)
+	if restored == nil || restored.RestoredFrom != 2 {
+		t.Errorf("expected a revision restored from v2, got %+v", restored)
+	}
+
+	if _, err := service.GetRevision("01JREV", userID, 9); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("expected ErrRevisionNotFound, got %v", err)
+	}
+}
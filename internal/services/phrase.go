@@ -5,12 +5,15 @@ import (
 	"fmt"
 
 	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/scoring"
+	"github.com/yomek33/newln/internal/pkg/segment"
 	"github.com/yomek33/newln/internal/pkg/vertex"
 	"github.com/yomek33/newln/internal/stores"
 )
 
 type PhraseService interface {
 	GeneratePhrases(ctx context.Context, materialId uint) ([]models.Phrase, error)
+	GeneratePhrasesInChunks(ctx context.Context, materialID uint, onChunk PhraseChunkHandler) ([]models.Phrase, error)
 	//StorePhrases(materialULID string, phrases []models.Phrase) error
 	GetPhrasesByMaterialID(materialULID string) ([]models.Phrase, error)
 	GetPhraseListByMaterialULID(materialULID string) ([]models.PhraseList, error)
@@ -19,14 +22,21 @@ type PhraseService interface {
 	UpdatePhraseListGenerateStatus(phraseListID uint, status string) error
 }
 
+// PhraseChunkHandler は意味まで生成できたフレーズを少しずつ受け取る。複数の goroutine から呼ばれる
+type PhraseChunkHandler func(phrases []models.Phrase) error
+
 type phraseService struct {
 	store         stores.PhraseStore
 	materialStore stores.MaterialStore
 	vertexClient  vertex.VertexService
+	// 長い教材を分割するときの区間サイズ。ゼロ値なら segment.DefaultOptions
+	segmentOptions segment.Options
+	// 重要度の採点。nil なら scoring.Default
+	scorer *scoring.Scorer
 }
 
-func NewPhraseService(s stores.PhraseStore, materialStore stores.MaterialStore, vertex vertex.VertexService) PhraseService {
-	return &phraseService{store: s, materialStore: materialStore, vertexClient: vertex}
+func NewPhraseService(s stores.PhraseStore, materialStore stores.MaterialStore, vertex vertex.VertexService, scorer *scoring.Scorer) PhraseService {
+	return &phraseService{store: s, materialStore: materialStore, vertexClient: vertex, scorer: scorer}
 }
 
 func (s *phraseService) CreatePhraseList(phraseList *models.PhraseList) error {
@@ -117,10 +127,6 @@ func GeneratePhrases(topic string) ([]string, error) {
 // 	return nil
 // }
 
-func determineImportance(_ string) string {
-	return "high"
-}
-
 func (s *phraseService) BulkInsertPhrases(phrases []models.Phrase) error {
 	return s.store.BulkInsertPhrases(phrases)
 }
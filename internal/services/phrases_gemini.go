@@ -2,14 +2,18 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 
 	"github.com/yomek33/newln/internal/logger"
 	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/occurrence"
+	"github.com/yomek33/newln/internal/pkg/scoring"
+	"github.com/yomek33/newln/internal/pkg/segment"
 	"github.com/yomek33/newln/internal/pkg/vertex"
+	"github.com/yomek33/newln/internal/services/prompts"
 )
 
 // Vertexからのレスポンス
@@ -17,47 +21,68 @@ type PhraseResponse struct {
 	Phrase string `json:"phrase"`
 	FromText    bool   `json:"from_text"`
 	Example     string `json:"example"`
-	Difficulty  string `json:"difficulty"`
+	Difficulty  string `json:"difficulty" schema:"enum=easy|intermediate|advanced"`
+
+	// 本文中の出現回数 (区間をまたいでまとめた後に数える)
+	Occurrences int `json:"-"`
 }
 
 func (s *phraseService) GeneratePhrases(ctx context.Context, materialID uint) ([]models.Phrase, error) {
-	logger.Infof("🚀 Start GeneratePhrases for materialID: %v", materialID)
-	promptFile, err := os.ReadFile("./internal/services/prompts/generate_phrases.txt")
-	if err != nil {
-		logger.Error(fmt.Errorf("failed to read prompt file: %w", err))
-		return nil, err
-	}
-	prompt := string(promptFile)
+	return s.GeneratePhrasesInChunks(ctx, materialID, nil)
+}
 
+// GeneratePhrasesInChunks は意味の生成が進むたびに onChunk へ渡す。onChunk が nil なら最後にまとめて返すだけ
+func (s *phraseService) GeneratePhrasesInChunks(ctx context.Context, materialID uint, onChunk PhraseChunkHandler) ([]models.Phrase, error) {
+	logger.Infof("🚀 Start GeneratePhrases for materialID: %v", materialID)
+	ctx = vertex.WithOperation(ctx, "generate_phrases")
 	material, err := s.materialStore.GetMaterialByID(materialID)
 	if err != nil {
 		logger.Error(fmt.Errorf("failed to get material: %w", err))
 		return nil, err
 	}
+	ctx = WithSourceLanguage(ctx, materialLanguage(material))
+	lang := sourceLanguage(ctx)
 
-	prompt = strings.ReplaceAll(prompt, "{{TEXT}}", material.Content)
-
-	jsonSchema := vertex.GenerateSchema[[]PhraseResponse]()
-	rawResponse, err := s.vertexClient.GenerateJsonContent(ctx, prompt, jsonSchema)
-	if err != nil {
-		logger.Error(fmt.Errorf("failed to generate phrases: %w", err))
-		return nil, err
-	}
-
-	// JSONリストを正しくデコード
-	phraseResponses, err := vertex.DecodeJsonContent[[]PhraseResponse](rawResponse)
+	phraseResponses, err := s.extractPhrases(ctx, material.Content)
 	if err != nil {
-		logger.Error(fmt.Errorf("failed to parse JSON: %w", err))
 		return nil, err
 	}
 
 	logger.Infof("✅ Genrerated Phrases: %v", phraseResponses)
 
+	// 保存前に重要度を付ける。出現回数は抽出時にまとめたものを使う
+	scorer := scorerOrDefault(s.scorer)
+	occurrences := make(map[string]int, len(phraseResponses))
+	for _, p := range phraseResponses {
+		occurrences[strings.ToLower(p.Phrase)] = p.Occurrences
+	}
+	index := occurrence.NewIndexIn(material.Content, lang)
+	withScores := func(chunk []models.Phrase) error {
+		// 他のチャンクが失敗した後は保存しない
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i := range chunk {
+			phrase := &chunk[i]
+			count, ok := occurrences[strings.ToLower(phrase.Text)]
+			if !ok {
+				count = countOccurrences(index, phrase.Text, lang, 0)
+			}
+			phrase.Importance = scorer.Score(scoring.Input{Text: phrase.Text, Level: phrase.Difficulty, Occurrences: count, Language: lang}).Importance
+		}
+		if onChunk != nil {
+			return onChunk(chunk)
+		}
+		return nil
+	}
+
 	// quotaが小さすぎる。。。
 	chunks := chunkAndDeduplicatePhrases(phraseResponses, 30)
 	logger.Infof("✅ Split phrases into %d chunks for processing", len(chunks))
 
-	// 並列処理で意味を生成
+	// 並列処理で意味を生成。1つ失敗したら残りを止める
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	var wg sync.WaitGroup
 	resultChan := make(chan []models.Phrase, len(chunks))
 	errChan := make(chan error, len(chunks))
@@ -74,7 +99,7 @@ func (s *phraseService) GeneratePhrases(ctx context.Context, materialID uint) ([
 			}
 			phrasesStr := strings.Join(phraseList, ", ")
 
-			meanings, err := s.GenerateMeaning(ctx, phrasesStr)
+			meanings, err := s.GenerateMeaning(vertex.WithOperation(ctx, "generate_phrase_meanings"), phrasesStr, withScores)
 			if err != nil {
 				logger.Error(fmt.Errorf("❌ Failed to generate meaning: %w", err))
 				errChan <- err
@@ -109,6 +134,9 @@ func (s *phraseService) GeneratePhrases(ctx context.Context, materialID uint) ([
 		case err, ok := <-errChan:
 			if ok {
 				logger.Error(fmt.Errorf("❌ Error received from errChan: %w", err))
+				// 戻った後に残りのチャンクが保存しないよう、止まるのを待つ
+				cancel()
+				wg.Wait()
 				return nil, err
 			} else {
 				logger.Infof("⚠️ errChan closed")
@@ -123,6 +151,44 @@ func (s *phraseService) GeneratePhrases(ctx context.Context, materialID uint) ([
 	return allPhrases, nil
 }
 
+// extractPhrases は本文を区間に分けて抽出し、重複をまとめる
+func (s *phraseService) extractPhrases(ctx context.Context, content string) ([]PhraseResponse, error) {
+	segments := segment.Split(content, s.segmentOptions)
+	logger.Infof("✂️ Split material into %d segments", len(segments))
+
+	jsonSchema, err := vertex.GenerateSchema[[]PhraseResponse]()
+	if err != nil {
+		return nil, err
+	}
+	results, err := mapSegments(ctx, segments, func(ctx context.Context, seg segment.Segment) ([]PhraseResponse, error) {
+		prompt, _, err := prompts.RenderFor(prompts.GeneratePhrases, sourceLanguage(ctx), promptData(ctx, seg.Text))
+		if err != nil {
+			logger.Error(fmt.Errorf("failed to render prompt: %w", err))
+			return nil, err
+		}
+
+		rawResponse, err := s.vertexClient.GenerateJsonContent(ctx, prompt, jsonSchema)
+		if err != nil {
+			logger.Error(fmt.Errorf("failed to generate phrases: %w", err))
+			return nil, err
+		}
+
+		// JSONリストを正しくデコード
+		phraseResponses, err := decodeResponse[[]PhraseResponse](ctx, "phrase", rawResponse)
+		if err != nil {
+			logger.Error(fmt.Errorf("failed to parse JSON: %w", err))
+			return nil, err
+		}
+		phraseResponses, rejections := validatePhraseResponses(phraseResponses)
+		reportRejections(ctx, rejections)
+		return phraseResponses, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergePhrases(results, content, sourceLanguage(ctx)), nil
+}
+
 func chunkAndDeduplicatePhrases(phrases []PhraseResponse, chunkSize int) [][]PhraseResponse {
 	var chunks [][]PhraseResponse
 	seen := make(map[string]bool)
@@ -153,44 +219,83 @@ type PhraseWithMeaning struct {
 	Phrase string `json:"phrase"`
 	FromText    bool   `json:"from_text"`
 	Example     string `json:"example"`
-	Difficulty  string `json:"difficulty"`
-	JPMeaning   string `json:"jp_meaning"`
+	Difficulty  string `json:"difficulty" schema:"enum=easy|intermediate|advanced"`
+	Translation string `json:"translation"`
 	Meaning     string `json:"meaning"`
 }
 
-// 意味を生成する関数
-func (s *phraseService) GenerateMeaning(ctx context.Context, phrasesStr string) ([]models.Phrase, error) {
-	promptFile, err := os.ReadFile("./internal/services/prompts/generate_meanings_phrases.txt")
+// 意味を生成する関数。ストリーミングで揃った分から streamFlushSize 件ずつ onChunk に渡す
+func (s *phraseService) GenerateMeaning(ctx context.Context, phrasesStr string, onChunk PhraseChunkHandler) ([]models.Phrase, error) {
+	prompt, tmpl, err := prompts.RenderFor(prompts.GeneratePhraseMeanings, sourceLanguage(ctx), promptData(ctx, phrasesStr))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+		return nil, err
 	}
-	prompt := string(promptFile)
-	prompt = strings.ReplaceAll(prompt, "{{INPUT}}", phrasesStr)
-
-	jsonSchema := vertex.GenerateSchema[[]PhraseWithMeaning]()
 
-	rawResponse, err := s.vertexClient.GenerateJsonContent(ctx, prompt, jsonSchema)
+	jsonSchema, err := vertex.GenerateSchema[[]PhraseWithMeaning]()
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate meanings: %w", err)
+		return nil, err
 	}
 
-	meaningResponses, err := vertex.DecodeJsonContent[[]PhraseWithMeaning](rawResponse)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
-	}
+	// 区切り (flush) をまたいで同じフレーズを2度保存しない
+	stored := make(map[string]bool)
 
 	var phrases []models.Phrase
-	for _, res := range meaningResponses {
-		phrases = append(phrases, models.Phrase{
-			Text:       res.Phrase,
-			Meaning:    res.Meaning,
-			JPMeaning:  res.JPMeaning,
-			Example:    res.Example,
-			FromText:   res.FromText,
-			Difficulty: res.Difficulty,
-			Importance: determineImportance(res.Difficulty),
-		})
+	var pending []PhraseWithMeaning
+	flush := func() error {
+		meaningResponses, rejections := validatePhraseMeanings(pending)
+		pending = nil
+
+		var chunk []models.Phrase
+		for _, res := range meaningResponses {
+			key := strings.ToLower(res.Phrase)
+			if stored[key] {
+				rejections = append(rejections, Rejection{Kind: "phrase", Item: res.Phrase, Field: "text", Reason: "duplicate", Dropped: true})
+				continue
+			}
+			stored[key] = true
+			chunk = append(chunk, models.Phrase{
+				Text:          res.Phrase,
+				Meaning:       res.Meaning,
+				Translations:  translationsOf(ctx, res.Translation),
+				Example:       res.Example,
+				FromText:      res.FromText,
+				Difficulty:    res.Difficulty,
+				PromptVersion: tmpl.ID(),
+			})
+		}
+		reportRejections(ctx, rejections)
+		if len(chunk) == 0 {
+			return nil
+		}
+		// onChunk で保存された ID を返り値にも残す
+		if onChunk != nil {
+			if err := onChunk(chunk); err != nil {
+				return err
+			}
+		}
+		phrases = append(phrases, chunk...)
+		return nil
 	}
 
+	rawResponse, err := vertex.StreamJsonContent(ctx, s.vertexClient, prompt, jsonSchema, func(element json.RawMessage) error {
+		item, ok := decodeElement[PhraseWithMeaning](ctx, "phrase", element)
+		if !ok {
+			return nil
+		}
+		pending = append(pending, item)
+		if len(pending) >= streamFlushSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return phrases, fmt.Errorf("failed to generate meanings: %w", err)
+	}
+	if err := flush(); err != nil {
+		return phrases, err
+	}
+	if err := checkStreamedResponse(ctx, "phrase", rawResponse, len(phrases)); err != nil {
+		return phrases, err
+	}
 	return phrases, nil
 }
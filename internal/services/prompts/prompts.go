@@ -0,0 +1,163 @@
+package prompts
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/yomek33/newln/internal/pkg/language"
+)
+
+// templates/<version>/<name>.tmpl と言語別の templates/<version>/<lang>/<name>.tmpl を埋め込む
+//
+//go:embed templates
+var templateFS embed.FS
+
+type Name string
+
+const (
+	GenerateWords          Name = "generate_words"
+	GenerateWordsMeanings  Name = "generate_words_meanings"
+	GeneratePhrases        Name = "generate_phrases"
+	GeneratePhraseMeanings Name = "generate_meanings_phrases"
+	// 辞書の語義から文脈に合うものを選ぶ (v3 以降)
+	GenerateWordSenses Name = "generate_words_senses"
+)
+
+// DefaultVersion は本番の生成で使うプロンプトのバージョン
+const DefaultVersion = "v3"
+
+// Data はテンプレートに渡す値
+type Data struct {
+	Text           string
+	NativeLanguage string
+	// 教材の言語の英語名 (German など)
+	SourceLanguage string
+	Level          string
+	Count          int
+}
+
+// NewData はテキスト以外をデフォルト値で埋める
+func NewData(text string) Data {
+	return Data{
+		Text:           text,
+		NativeLanguage: language.Name(language.DefaultNative),
+		SourceLanguage: language.Name(language.DefaultSource),
+		Level:          "intermediate",
+		Count:          20,
+	}
+}
+
+type Prompt struct {
+	Name    Name
+	Version string
+	// 教材の言語。英語用の共通テンプレートなら空
+	Language string
+	tmpl     *template.Template
+}
+
+// ID は "generate_words@v1" の形式。言語別のテンプレートなら "generate_words@v3/de"
+func (p *Prompt) ID() string {
+	if p.Language != "" {
+		return fmt.Sprintf("%s@%s/%s", p.Name, p.Version, p.Language)
+	}
+	return fmt.Sprintf("%s@%s", p.Name, p.Version)
+}
+
+func (p *Prompt) Render(data Data) (string, error) {
+	var sb strings.Builder
+	if err := p.tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", p.ID(), err)
+	}
+	return sb.String(), nil
+}
+
+var (
+	mu    sync.Mutex
+	cache = make(map[string]*Prompt)
+)
+
+// Load は DefaultVersion のプロンプトを返す
+func Load(name Name) (*Prompt, error) {
+	return LoadVersion(name, DefaultVersion)
+}
+
+func LoadVersion(name Name, version string) (*Prompt, error) {
+	return LoadVersionFor(name, version, "")
+}
+
+// LoadVersionFor は教材の言語 lang 用のテンプレート templates/<version>/<lang>/<name>.tmpl を返す。
+// なければ英語用の templates/<version>/<name>.tmpl を返す
+func LoadVersionFor(name Name, version, lang string) (*Prompt, error) {
+	if lang == language.DefaultSource {
+		lang = ""
+	}
+	p := &Prompt{Name: name, Version: version, Language: lang}
+	key := p.ID()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if cached, ok := cache[key]; ok {
+		return cached, nil
+	}
+
+	path := fmt.Sprintf("templates/%s/%s.tmpl", version, name)
+	if lang != "" {
+		path = fmt.Sprintf("templates/%s/%s/%s.tmpl", version, lang, name)
+	}
+	raw, err := templateFS.ReadFile(path)
+	if err != nil && lang != "" {
+		// 言語別のテンプレートがなければ共通のものを使う
+		p.Language = ""
+		raw, err = templateFS.ReadFile(fmt.Sprintf("templates/%s/%s.tmpl", version, name))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("prompt %s not found: %w", key, err)
+	}
+	tmpl, err := template.New(p.ID()).Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt %s: %w", key, err)
+	}
+
+	p.tmpl = tmpl
+	cache[key] = p
+	return p, nil
+}
+
+// Render は DefaultVersion の英語用のプロンプトを読み込んで描画する
+func Render(name Name, data Data) (string, *Prompt, error) {
+	return RenderFor(name, "", data)
+}
+
+// RenderFor は DefaultVersion の教材の言語 lang 用のプロンプトを読み込んで描画する
+func RenderFor(name Name, lang string, data Data) (string, *Prompt, error) {
+	p, err := LoadVersionFor(name, DefaultVersion, lang)
+	if err != nil {
+		return "", nil, err
+	}
+	rendered, err := p.Render(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return rendered, p, nil
+}
+
+// Versions は埋め込まれているバージョンの一覧
+func Versions() ([]string, error) {
+	entries, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
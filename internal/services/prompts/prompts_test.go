@@ -0,0 +1,74 @@
+package prompts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender_AllPrompts(t *testing.T) {
+	names := []Name{GenerateWords, GenerateWordsMeanings, GeneratePhrases, GeneratePhraseMeanings, GenerateWordSenses}
+	data := Data{Text: "SAMPLE_TEXT", NativeLanguage: "Korean", Level: "advanced", Count: 7}
+
+	for _, name := range names {
+		t.Run(string(name), func(t *testing.T) {
+			rendered, p, err := Render(name, data)
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			if p.Version != DefaultVersion {
+				t.Errorf("Version = %s, expected %s", p.Version, DefaultVersion)
+			}
+			if !strings.Contains(rendered, "SAMPLE_TEXT") {
+				t.Errorf("rendered prompt does not contain the text")
+			}
+			if strings.Contains(rendered, "{{") {
+				t.Errorf("rendered prompt still contains template actions")
+			}
+		})
+	}
+}
+
+func TestRender_UsesTypedData(t *testing.T) {
+	rendered, _, err := Render(GenerateWords, Data{Text: "x", Level: "advanced", Count: 7})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(rendered, "CEFR advanced-level") || !strings.Contains(rendered, "fewer than 7 vocabulary words") {
+		t.Errorf("level and count were not rendered: %s", rendered)
+	}
+}
+
+func TestLoadVersion_Unknown(t *testing.T) {
+	if _, err := LoadVersion(GenerateWords, "v999"); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
+
+func TestRenderFor_LanguageTemplates(t *testing.T) {
+	data := NewData("SAMPLE_TEXT")
+	names := []Name{GenerateWords, GenerateWordsMeanings, GeneratePhrases, GeneratePhraseMeanings}
+
+	for _, lang := range []string{"de", "ja"} {
+		for _, name := range names {
+			rendered, p, err := RenderFor(name, lang, data)
+			if err != nil {
+				t.Fatalf("RenderFor(%s, %s) failed: %v", name, lang, err)
+			}
+			if p.Language != lang || p.ID() != string(name)+"@"+DefaultVersion+"/"+lang {
+				t.Errorf("RenderFor(%s, %s) loaded %s", name, lang, p.ID())
+			}
+			if !strings.Contains(rendered, "SAMPLE_TEXT") || strings.Contains(rendered, "{{") {
+				t.Errorf("RenderFor(%s, %s) rendered an unexpected prompt", name, lang)
+			}
+		}
+	}
+
+	// 英語と言語別テンプレートのない言語は共通のテンプレート
+	english, _, _ := Render(GenerateWords, data)
+	for _, lang := range []string{"", "en", "ko"} {
+		rendered, p, err := RenderFor(GenerateWords, lang, data)
+		if err != nil || p.Language != "" || rendered != english {
+			t.Errorf("RenderFor(%q) did not fall back to the shared template: %v", lang, err)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yomek33/newln/internal/config"
+	"github.com/yomek33/newln/internal/pkg/vertex"
+	"github.com/yomek33/newln/internal/stores"
+
+	"cloud.google.com/go/vertexai/genai"
+	"github.com/google/uuid"
+)
+
+const (
+	// QuotaKindRateLimit は時間が経てば回復する上限 (HTTP 429)
+	QuotaKindRateLimit = "rate_limit"
+	// QuotaKindPlanLimit はプランの変更が必要な上限 (HTTP 402)
+	QuotaKindPlanLimit = "plan_limit"
+
+	LimitMaterialsPerDay     = "materials_per_day"
+	LimitMaxCharsPerMaterial = "max_chars_per_material"
+	LimitMonthlyTokenBudget  = "monthly_token_budget"
+)
+
+// QuotaError はプランの上限を超えたときに返す
+type QuotaError struct {
+	Kind      string
+	Limit     string
+	Plan      string
+	Max       int64
+	Used      int64
+	Remaining int64
+	ResetAt   *time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s (plan %s, used %d of %d)", e.Limit, e.Plan, e.Used, e.Max)
+}
+
+// Allowance は上限に対する現在の使用量。Unlimited のときは Max/Remaining は 0
+type Allowance struct {
+	Unlimited bool
+	Max       int64
+	Used      int64
+	Remaining int64
+	ResetAt   time.Time
+}
+
+// CreationLimit は教材を作るときに数え直す1日の作成数の上限。Max が 0 なら無制限
+type CreationLimit struct {
+	Plan    string
+	Since   time.Time
+	ResetAt time.Time
+	Max     int64
+}
+
+// exceeded は作る直前に数え直して上限に達していたときのエラー
+func (l CreationLimit) exceeded() *QuotaError {
+	resetAt := l.ResetAt
+	return &QuotaError{
+		Kind:    QuotaKindRateLimit,
+		Limit:   LimitMaterialsPerDay,
+		Plan:    l.Plan,
+		Max:     l.Max,
+		Used:    l.Max,
+		ResetAt: &resetAt,
+	}
+}
+
+type QuotaStatus struct {
+	Plan                string
+	MaterialsPerDay     Allowance
+	MonthlyTokens       Allowance
+	MaxCharsPerMaterial int
+}
+
+type QuotaService interface {
+	GetQuotaStatus(userID uuid.UUID) (*QuotaStatus, error)
+	CheckMaterialCreation(userID uuid.UUID, contentLength int) (CreationLimit, error)
+	CheckContentLength(userID uuid.UUID, contentLength int) error
+	CheckTokenBudget(userID uuid.UUID) error
+}
+
+type quotaService struct {
+	userStore     stores.UserStore
+	materialStore stores.MaterialStore
+	usageStore    stores.UsageStore
+	plans         config.Plans
+	now           func() time.Time
+}
+
+func NewQuotaService(userStore stores.UserStore, materialStore stores.MaterialStore, usageStore stores.UsageStore, plans config.Plans) QuotaService {
+	if plans == nil {
+		plans = config.DefaultPlans
+	}
+	return &quotaService{
+		userStore:     userStore,
+		materialStore: materialStore,
+		usageStore:    usageStore,
+		plans:         plans,
+		now:           time.Now,
+	}
+}
+
+func (s *quotaService) planFor(userID uuid.UUID) (config.Plan, error) {
+	user, err := s.userStore.GetUserByID(userID)
+	if err != nil {
+		return config.Plan{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return s.plans.Get(user.Plan), nil
+}
+
+func (s *quotaService) GetQuotaStatus(userID uuid.UUID) (*QuotaStatus, error) {
+	plan, err := s.planFor(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	daily, err := s.dailyMaterials(userID, plan)
+	if err != nil {
+		return nil, err
+	}
+	monthly, err := s.monthlyTokens(userID, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuotaStatus{
+		Plan:                plan.Name,
+		MaterialsPerDay:     daily,
+		MonthlyTokens:       monthly,
+		MaxCharsPerMaterial: plan.MaxCharsPerMaterial,
+	}, nil
+}
+
+// CheckMaterialCreation は教材を作れるかを確かめ、作るときに数え直す1日の上限を返す。
+// 同時に作ると両方がここを通れるので、上限は MaterialService.CreateMaterial でもう一度確かめる
+func (s *quotaService) CheckMaterialCreation(userID uuid.UUID, contentLength int) (CreationLimit, error) {
+	plan, err := s.planFor(userID)
+	if err != nil {
+		return CreationLimit{}, err
+	}
+
+	if err := checkContentLength(plan, contentLength); err != nil {
+		return CreationLimit{}, err
+	}
+
+	daily, err := s.dailyMaterials(userID, plan)
+	if err != nil {
+		return CreationLimit{}, err
+	}
+	if !daily.Unlimited && daily.Remaining <= 0 {
+		return CreationLimit{}, daily.exceeded(QuotaKindRateLimit, LimitMaterialsPerDay, plan.Name)
+	}
+
+	if err := s.checkTokenBudget(userID, plan); err != nil {
+		return CreationLimit{}, err
+	}
+	return CreationLimit{
+		Plan:    plan.Name,
+		Since:   daily.ResetAt.AddDate(0, 0, -1),
+		ResetAt: daily.ResetAt,
+		Max:     daily.Max,
+	}, nil
+}
+
+// CheckContentLength は編集後の本文の長さだけを確かめる (1日の作成数には数えない)
+func (s *quotaService) CheckContentLength(userID uuid.UUID, contentLength int) error {
+	plan, err := s.planFor(userID)
+	if err != nil {
+		return err
+	}
+	return checkContentLength(plan, contentLength)
+}
+
+func checkContentLength(plan config.Plan, contentLength int) error {
+	if plan.MaxCharsPerMaterial > 0 && contentLength > plan.MaxCharsPerMaterial {
+		return &QuotaError{
+			Kind:  QuotaKindPlanLimit,
+			Limit: LimitMaxCharsPerMaterial,
+			Plan:  plan.Name,
+			Max:   int64(plan.MaxCharsPerMaterial),
+			Used:  int64(contentLength),
+		}
+	}
+	return nil
+}
+
+func (s *quotaService) CheckTokenBudget(userID uuid.UUID) error {
+	plan, err := s.planFor(userID)
+	if err != nil {
+		return err
+	}
+	return s.checkTokenBudget(userID, plan)
+}
+
+func (s *quotaService) checkTokenBudget(userID uuid.UUID, plan config.Plan) error {
+	monthly, err := s.monthlyTokens(userID, plan)
+	if err != nil {
+		return err
+	}
+	if !monthly.Unlimited && monthly.Remaining <= 0 {
+		return monthly.exceeded(QuotaKindPlanLimit, LimitMonthlyTokenBudget, plan.Name)
+	}
+	return nil
+}
+
+func (s *quotaService) dailyMaterials(userID uuid.UUID, plan config.Plan) (Allowance, error) {
+	now := s.now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	reset := start.AddDate(0, 0, 1)
+	if plan.MaterialsPerDay <= 0 {
+		return Allowance{Unlimited: true, ResetAt: reset}, nil
+	}
+
+	used, err := s.materialStore.CountMaterialsCreatedSince(userID, start)
+	if err != nil {
+		return Allowance{}, fmt.Errorf("failed to count materials: %w", err)
+	}
+	return newAllowance(int64(plan.MaterialsPerDay), used, reset), nil
+}
+
+func (s *quotaService) monthlyTokens(userID uuid.UUID, plan config.Plan) (Allowance, error) {
+	now := s.now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	reset := start.AddDate(0, 1, 0)
+	if plan.MonthlyTokenBudget <= 0 {
+		return Allowance{Unlimited: true, ResetAt: reset}, nil
+	}
+
+	used, err := s.usageStore.GetUserTokensSince(userID, start)
+	if err != nil {
+		return Allowance{}, fmt.Errorf("failed to sum token usage: %w", err)
+	}
+	return newAllowance(plan.MonthlyTokenBudget, used, reset), nil
+}
+
+func newAllowance(max, used int64, resetAt time.Time) Allowance {
+	remaining := max - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Allowance{Max: max, Used: used, Remaining: remaining, ResetAt: resetAt}
+}
+
+func (a Allowance) exceeded(kind, limit, plan string) *QuotaError {
+	resetAt := a.ResetAt
+	return &QuotaError{
+		Kind:      kind,
+		Limit:     limit,
+		Plan:      plan,
+		Max:       a.Max,
+		Used:      a.Used,
+		Remaining: a.Remaining,
+		ResetAt:   &resetAt,
+	}
+}
+
+// MarshalEvent は進捗イベント用のJSONを返す
+func (e *QuotaError) MarshalEvent() string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"event":     "quota_exceeded",
+		"limit":     e.Limit,
+		"plan":      e.Plan,
+		"max":       e.Max,
+		"used":      e.Used,
+		"remaining": e.Remaining,
+		"reset_at":  e.ResetAt,
+	})
+	return string(data)
+}
+
+// quotaGuardedClient はパイプライン中の各LLM呼び出しの前に月間トークン予算を確認する
+type quotaGuardedClient struct {
+	vertex.VertexService
+	quota QuotaService
+}
+
+func newQuotaGuardedClient(client vertex.VertexService, quota QuotaService) vertex.VertexService {
+	return &quotaGuardedClient{VertexService: client, quota: quota}
+}
+
+func (c *quotaGuardedClient) GenerateJsonContent(ctx context.Context, prompt string, jsonSchema *genai.Schema) (json.RawMessage, error) {
+	if err := c.checkBudget(ctx); err != nil {
+		return nil, err
+	}
+	return c.VertexService.GenerateJsonContent(ctx, prompt, jsonSchema)
+}
+
+// StreamJsonContent は内側のクライアントがストリーミングに対応していればそのまま使う
+func (c *quotaGuardedClient) StreamJsonContent(ctx context.Context, prompt string, jsonSchema *genai.Schema, onElement vertex.ElementHandler) (json.RawMessage, error) {
+	if err := c.checkBudget(ctx); err != nil {
+		return nil, err
+	}
+	return vertex.StreamJsonContent(ctx, c.VertexService, prompt, jsonSchema, onElement)
+}
+
+func (c *quotaGuardedClient) checkBudget(ctx context.Context) error {
+	if scope := vertex.UsageScopeFrom(ctx); scope.UserID != uuid.Nil {
+		return c.quota.CheckTokenBudget(scope.UserID)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yomek33/newln/internal/config"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/vertex"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type quotaUserStore struct {
+	user *models.User
+}
+
+func (s *quotaUserStore) CreateUser(user *models.User) error { return nil }
+func (s *quotaUserStore) GetUserByEmail(email string) (*models.User, error) {
+	return s.user, nil
+}
+func (s *quotaUserStore) GetUserByID(userID uuid.UUID) (*models.User, error) {
+	if s.user.UserID != userID {
+		return nil, errors.New("user not found")
+	}
+	return s.user, nil
+}
+func (s *quotaUserStore) UpdateNativeLanguage(userID uuid.UUID, code string) error { return nil }
+
+type quotaUsageStore struct {
+	tokens int64
+}
+
+func (s *quotaUsageStore) CreateUsage(usage *models.LLMUsage) error { return nil }
+func (s *quotaUsageStore) GetDailyUsageByUser(userID uuid.UUID, from, to time.Time) ([]models.DailyUsage, error) {
+	return nil, nil
+}
+func (s *quotaUsageStore) GetUsageByMaterial(materialID uint) ([]models.OperationUsage, error) {
+	return nil, nil
+}
+func (s *quotaUsageStore) GetUsageTotalsByUser(from, to time.Time) ([]models.UserUsage, error) {
+	return nil, nil
+}
+func (s *quotaUsageStore) GetUsageTotals(from, to time.Time) (*models.UsageTotals, error) {
+	return &models.UsageTotals{}, nil
+}
+func (s *quotaUsageStore) GetUserTokensSince(userID uuid.UUID, since time.Time) (int64, error) {
+	return s.tokens, nil
+}
+
+func newTestQuotaService(user *models.User, usage *quotaUsageStore, materials *stores_mock.MockMaterialStore, now time.Time) *quotaService {
+	plans := config.Plans{
+		"free": {Name: "free", MaterialsPerDay: 2, MaxCharsPerMaterial: 100, MonthlyTokenBudget: 1000},
+	}
+	svc := NewQuotaService(&quotaUserStore{user: user}, materials, usage, plans).(*quotaService)
+	svc.now = func() time.Time { return now }
+	return svc
+}
+
+func TestQuotaService_CheckMaterialCreation(t *testing.T) {
+	now := time.Date(2025, 2, 10, 15, 0, 0, 0, time.UTC)
+	user := &models.User{UserID: uuid.New(), Plan: "free"}
+
+	t.Run("content too long", func(t *testing.T) {
+		svc := newTestQuotaService(user, &quotaUsageStore{}, stores_mock.NewMockMaterialStore(), now)
+		_, err := svc.CheckMaterialCreation(user.UserID, 101)
+
+		var quotaErr *QuotaError
+		assert.True(t, errors.As(err, &quotaErr))
+		assert.Equal(t, QuotaKindPlanLimit, quotaErr.Kind)
+		assert.Equal(t, LimitMaxCharsPerMaterial, quotaErr.Limit)
+	})
+
+	t.Run("daily materials exhausted", func(t *testing.T) {
+		materials := stores_mock.NewMockMaterialStore()
+		for i := uint(1); i <= 2; i++ {
+			materials.Creations = append(materials.Creations, models.MaterialCreation{
+				UserID: user.UserID, MaterialID: i, CreatedAt: now.Add(-time.Hour),
+			})
+		}
+		svc := newTestQuotaService(user, &quotaUsageStore{}, materials, now)
+		_, err := svc.CheckMaterialCreation(user.UserID, 10)
+
+		var quotaErr *QuotaError
+		assert.True(t, errors.As(err, &quotaErr))
+		assert.Equal(t, QuotaKindRateLimit, quotaErr.Kind)
+		assert.Equal(t, int64(0), quotaErr.Remaining)
+		assert.Equal(t, time.Date(2025, 2, 11, 0, 0, 0, 0, time.UTC), *quotaErr.ResetAt)
+	})
+
+	t.Run("token budget exhausted", func(t *testing.T) {
+		svc := newTestQuotaService(user, &quotaUsageStore{tokens: 1000}, stores_mock.NewMockMaterialStore(), now)
+		_, err := svc.CheckMaterialCreation(user.UserID, 10)
+
+		var quotaErr *QuotaError
+		assert.True(t, errors.As(err, &quotaErr))
+		assert.Equal(t, LimitMonthlyTokenBudget, quotaErr.Limit)
+		assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), *quotaErr.ResetAt)
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		svc := newTestQuotaService(user, &quotaUsageStore{tokens: 10}, stores_mock.NewMockMaterialStore(), now)
+		limit, err := svc.CheckMaterialCreation(user.UserID, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, CreationLimit{
+			Plan:    "free",
+			Since:   time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC),
+			ResetAt: time.Date(2025, 2, 11, 0, 0, 0, 0, time.UTC),
+			Max:     2,
+		}, limit)
+	})
+}
+
+func TestQuotaService_CheckContentLength(t *testing.T) {
+	now := time.Date(2025, 2, 10, 15, 0, 0, 0, time.UTC)
+	user := &models.User{UserID: uuid.New(), Plan: "free"}
+
+	// 編集は1日の作成数に数えない
+	materials := stores_mock.NewMockMaterialStore()
+	for i := uint(1); i <= 2; i++ {
+		materials.Creations = append(materials.Creations, models.MaterialCreation{
+			UserID: user.UserID, MaterialID: i, CreatedAt: now.Add(-time.Hour),
+		})
+	}
+	svc := newTestQuotaService(user, &quotaUsageStore{}, materials, now)
+	assert.NoError(t, svc.CheckContentLength(user.UserID, 100))
+
+	var quotaErr *QuotaError
+	assert.True(t, errors.As(svc.CheckContentLength(user.UserID, 101), &quotaErr))
+	assert.Equal(t, LimitMaxCharsPerMaterial, quotaErr.Limit)
+}
+
+func TestQuotaGuardedClient_BlocksWhenBudgetExhausted(t *testing.T) {
+	now := time.Now()
+	user := &models.User{UserID: uuid.New(), Plan: "free"}
+	svc := newTestQuotaService(user, &quotaUsageStore{tokens: 5000}, stores_mock.NewMockMaterialStore(), now)
+	client := newQuotaGuardedClient(vertex.NewMockVertexClient(), svc)
+
+	ctx := vertex.WithUsageScope(context.Background(), vertex.UsageScope{UserID: user.UserID})
+	_, err := client.GenerateJsonContent(ctx, "prompt", nil)
+
+	var quotaErr *QuotaError
+	assert.True(t, errors.As(err, &quotaErr))
+
+	// スコープのない呼び出しは対象外
+	_, err = client.GenerateJsonContent(context.Background(), "prompt", nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/stores"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	maxSearchQueryLen  = 200
+)
+
+var (
+	ErrEmptySearchQuery   = errors.New("search query is empty")
+	ErrInvalidSearchQuery = errors.New("invalid search query")
+)
+
+var searchTypes = []string{models.SearchTypeMaterial, models.SearchTypeWord, models.SearchTypePhrase}
+
+type SearchService interface {
+	Search(userID uuid.UUID, q string, types []string, scope models.MaterialScope, limit int) ([]models.SearchHit, error)
+}
+
+type searchService struct {
+	store stores.SearchStore
+}
+
+func NewSearchService(s stores.SearchStore) SearchService {
+	return &searchService{store: s}
+}
+
+// Search はユーザーの scope の教材・単語・フレーズを全文検索する。types が空ならすべての種類
+func (s *searchService) Search(userID uuid.UUID, q string, types []string, scope models.MaterialScope, limit int) ([]models.SearchHit, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	if utf8.RuneCountInString(q) > maxSearchQueryLen {
+		return nil, ErrInvalidSearchQuery
+	}
+	if len(types) == 0 {
+		types = searchTypes
+	}
+	for _, t := range types {
+		if !slices.Contains(searchTypes, t) {
+			return nil, ErrInvalidSearchQuery
+		}
+	}
+	if err := normalizeScope(&scope); err != nil {
+		return nil, ErrInvalidSearchQuery
+	}
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	if limit < 0 || limit > maxSearchLimit {
+		return nil, ErrInvalidSearchQuery
+	}
+
+	hits, err := s.store.Search(userID, q, types, scope, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	if hits == nil {
+		hits = []models.SearchHit{}
+	}
+	for i := range hits {
+		hits[i].Snippet = markSnippet(hits[i].Snippet)
+	}
+	return hits, nil
+}
+
+// markSnippet は抜粋を HTML エスケープし、一致箇所の区切りを <mark> にする。
+// 教材は任意のページやフィードから取り込まれるので、抜粋にタグが入っていても効かないようにする
+func markSnippet(snippet string) string {
+	return strings.NewReplacer(models.SnippetStart, "<mark>", models.SnippetStop, "</mark>").Replace(html.EscapeString(snippet))
+}
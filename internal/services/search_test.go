@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/yomek33/newln/internal/models"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+)
+
+func TestSearchService_Search(t *testing.T) {
+	store := stores_mock.NewMockSearchStore(
+		models.SearchHit{Type: models.SearchTypePhrase, Text: "close a deal", Snippet: "\ue000close\ue001 a \ue000deal\ue001", Rank: 0.9},
+		models.SearchHit{Type: models.SearchTypeMaterial, Text: "Quarterly sales", Snippet: "we \ue000closed\ue001 the <script>alert(1)</script> \ue000deal\ue001", Rank: 0.4},
+	)
+	service := NewSearchService(store)
+	userID := uuid.New()
+
+	hits, err := service.Search(userID, "  close a deal ", nil, models.MaterialScope{}, 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Errorf("expected 2 hits, got %+v", hits)
+	}
+	// 一致箇所だけを <mark> にし、本文のタグはエスケープする
+	if hits[0].Snippet != "<mark>close</mark> a <mark>deal</mark>" ||
+		hits[1].Snippet != "we <mark>closed</mark> the &lt;script&gt;alert(1)&lt;/script&gt; <mark>deal</mark>" {
+		t.Errorf("unexpected snippets: %q, %q", hits[0].Snippet, hits[1].Snippet)
+	}
+	// 空白を除き、種類と件数の既定値で検索する
+	if store.Queries[0] != "close a deal" || len(store.Types[0]) != 3 || store.Limits[0] != defaultSearchLimit {
+		t.Errorf("unexpected store call: %q %v %d", store.Queries[0], store.Types[0], store.Limits[0])
+	}
+
+	hits, err = service.Search(userID, "deal", []string{models.SearchTypeWord}, models.MaterialScope{Tags: []string{"sales"}}, 5)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if hits == nil || len(hits) != 0 {
+		t.Errorf("expected an empty non-nil result, got %#v", hits)
+	}
+	if len(store.Scopes[1].Tags) != 1 {
+		t.Errorf("expected the tag scope to reach the store, got %+v", store.Scopes[1])
+	}
+
+	if _, err := service.Search(userID, " ", nil, models.MaterialScope{}, 0); !errors.Is(err, ErrEmptySearchQuery) {
+		t.Errorf("expected ErrEmptySearchQuery, got %v", err)
+	}
+	for _, tc := range []struct {
+		q     string
+		types []string
+		limit int
+	}{
+		{"deal", []string{"chat"}, 0},
+		{"deal", nil, maxSearchLimit + 1},
+		{strings.Repeat("a", maxSearchQueryLen+1), nil, 0},
+	} {
+		if _, err := service.Search(userID, tc.q, tc.types, models.MaterialScope{}, tc.limit); !errors.Is(err, ErrInvalidSearchQuery) {
+			t.Errorf("expected ErrInvalidSearchQuery for %v %d, got %v", tc.types, tc.limit, err)
+		}
+	}
+}
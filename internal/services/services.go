@@ -1,22 +1,62 @@
 package services
 
 import (
+	"time"
+
+	"github.com/yomek33/newln/internal/config"
+	"github.com/yomek33/newln/internal/pkg/scoring"
 	"github.com/yomek33/newln/internal/pkg/vertex"
+	"github.com/yomek33/newln/internal/pkg/webfetch"
 	"github.com/yomek33/newln/internal/stores"
 )
 
 type Services struct {
-	UserService     UserService
-	MaterialService MaterialService
-	PhraseService   PhraseService
-	WordService     WordService
+	UserService       UserService
+	MaterialService   MaterialService
+	PhraseService     PhraseService
+	WordService       WordService
+	UsageService      UsageService
+	QuotaService      QuotaService
+	GenerationService GenerationService
+	SearchService     SearchService
+	TrashService      TrashService
+	TagService        TagService
+	CollectionService CollectionService
+	ExportService     ExportService
+	ImportService     ImportService
+	FeedService       FeedService
 }
 
-func NewServices(stores *stores.Stores, vertexService vertex.VertexService) *Services {
+func NewServices(stores *stores.Stores, vertexService vertex.VertexService, plans config.Plans, thresholds scoring.Thresholds, trashRetention, feedPollInterval time.Duration) *Services {
+	usageService := NewUsageService(stores.UsageStore)
+	vertexService.SetUsageRecorder(usageService)
+
+	quotaService := NewQuotaService(stores.UserStore, stores.MaterialStore, stores.UsageStore, plans)
+	vertexService = newQuotaGuardedClient(vertexService, quotaService)
+
+	materialService := NewMaterialService(stores.MaterialStore)
+	scorer := scoring.New(thresholds)
+	phraseService := NewPhraseService(stores.PhraseStore, stores.MaterialStore, vertexService, scorer)
+	wordService := NewWordService(stores.WordStore, stores.MaterialStore, stores.DictionaryStore, vertexService, scorer)
+
+	userService := NewUserService(stores.UserStore)
+	generationService := NewGenerationService(userService, materialService, phraseService, wordService, quotaService)
+	importService := NewImportService(stores.PageCacheStore, webfetch.New(webfetch.DefaultOptions()))
+
 	return &Services{
-		UserService:     NewUserService(stores.UserStore),
-		MaterialService: NewMaterialService(stores.MaterialStore),
-		PhraseService:   NewPhraseService(stores.PhraseStore, stores.MaterialStore, vertexService),
-		WordService:     NewWordService(stores.WordStore, stores.MaterialStore, vertexService),
+		UserService:       userService,
+		MaterialService:   materialService,
+		PhraseService:     phraseService,
+		WordService:       wordService,
+		UsageService:      usageService,
+		QuotaService:      quotaService,
+		GenerationService: generationService,
+		SearchService:     NewSearchService(stores.SearchStore),
+		TrashService:      NewTrashService(stores.MaterialStore, trashRetention),
+		TagService:        NewTagService(stores.TagStore, stores.MaterialStore),
+		CollectionService: NewCollectionService(stores.CollectionStore, stores.MaterialStore),
+		ExportService:     NewExportService(stores.MaterialStore, stores.TagStore),
+		ImportService:     importService,
+		FeedService:       NewFeedService(stores.FeedStore, materialService, generationService, quotaService, importService, webfetch.New(FeedFetchOptions()), feedPollInterval),
 	}
 }
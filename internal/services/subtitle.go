@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/occurrence"
+	"github.com/yomek33/newln/internal/pkg/subtitle"
+
+	"github.com/google/uuid"
+)
+
+var ErrNoSubtitles = errors.New("material has no subtitle cues")
+
+// SubtitleCue はキューの時刻 (ミリ秒) と本文の中の位置 (文字単位)
+type SubtitleCue struct {
+	Index       int    `json:"index"`
+	StartMs     int64  `json:"start_ms"`
+	EndMs       int64  `json:"end_ms"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	Text        string `json:"text"`
+}
+
+// CueMatch は単語・フレーズがキューの中に現れた位置
+type CueMatch struct {
+	Cue     int    `json:"cue"`
+	StartMs int64  `json:"start_ms"`
+	EndMs   int64  `json:"end_ms"`
+	Surface string `json:"surface"`
+}
+
+// SubtitleItem は単語かフレーズと、それが現れるキュー
+type SubtitleItem struct {
+	ID      uint       `json:"id"`
+	Text    string     `json:"text"`
+	Matches []CueMatch `json:"matches"`
+}
+
+// SubtitleTimeline は字幕の教材のキューと、生成した単語・フレーズが現れるキュー
+type SubtitleTimeline struct {
+	MaterialULID string         `json:"material_ulid"`
+	Cues         []SubtitleCue  `json:"cues"`
+	Words        []SubtitleItem `json:"words"`
+	Phrases      []SubtitleItem `json:"phrases"`
+}
+
+// buildSubtitleCues は字幕の教材のキューを作る。本文はキューの台詞をつないだもの
+func buildSubtitleCues(cues []subtitle.Cue) (string, []models.SubtitleCue) {
+	content, spans := subtitle.Build(cues)
+	result := make([]models.SubtitleCue, len(spans))
+	for i, span := range spans {
+		result[i] = models.SubtitleCue{
+			Index:       span.Index,
+			StartMs:     span.Start.Milliseconds(),
+			EndMs:       span.End.Milliseconds(),
+			StartOffset: span.StartOffset,
+			EndOffset:   span.EndOffset,
+			Text:        span.Text,
+		}
+	}
+	return content, result
+}
+
+// GetSubtitleTimeline は字幕の教材の単語・フレーズをキューと時刻に結び付ける。
+// 単語は保存した出現位置、フレーズは本文を探した位置を使う。どのキューにも現れないものは含めない
+func (s *materialService) GetSubtitleTimeline(ulid string, UserID uuid.UUID) (*SubtitleTimeline, error) {
+	material, err := s.store.GetMaterialByULID(ulid, UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get material by ID: %w", err)
+	}
+	if len(material.SubtitleCues) == 0 {
+		return nil, ErrNoSubtitles
+	}
+
+	timeline := &SubtitleTimeline{
+		MaterialULID: material.ULID,
+		Cues:         make([]SubtitleCue, len(material.SubtitleCues)),
+		Words:        []SubtitleItem{},
+		Phrases:      []SubtitleItem{},
+	}
+	spans := make([]subtitle.Span, len(material.SubtitleCues))
+	for i, cue := range material.SubtitleCues {
+		timeline.Cues[i] = SubtitleCue{
+			Index:       cue.Index,
+			StartMs:     cue.StartMs,
+			EndMs:       cue.EndMs,
+			StartOffset: cue.StartOffset,
+			EndOffset:   cue.EndOffset,
+			Text:        cue.Text,
+		}
+		spans[i] = subtitle.Span{
+			Cue:         subtitle.Cue{Start: time.Duration(cue.StartMs) * time.Millisecond, End: time.Duration(cue.EndMs) * time.Millisecond},
+			Index:       cue.Index,
+			StartOffset: cue.StartOffset,
+			EndOffset:   cue.EndOffset,
+		}
+	}
+	match := func(start int, surface string) (CueMatch, bool) {
+		span, ok := subtitle.Find(spans, start)
+		if !ok {
+			return CueMatch{}, false
+		}
+		return CueMatch{Cue: span.Index, StartMs: span.Start.Milliseconds(), EndMs: span.End.Milliseconds(), Surface: surface}, true
+	}
+
+	for _, list := range material.WordLists {
+		for _, word := range list.Words {
+			item := SubtitleItem{ID: word.ID, Text: word.Text}
+			for _, o := range word.Occurrences {
+				if m, ok := match(o.StartOffset, o.Surface); ok {
+					item.Matches = append(item.Matches, m)
+				}
+			}
+			if len(item.Matches) > 0 {
+				timeline.Words = append(timeline.Words, item)
+			}
+		}
+	}
+
+	index := occurrence.NewIndexIn(material.Content, materialLanguage(material))
+	for _, list := range material.PhraseLists {
+		for _, phrase := range list.Phrases {
+			item := SubtitleItem{ID: phrase.ID, Text: phrase.Text}
+			for _, o := range index.Find(phrase.Text) {
+				if m, ok := match(o.Start, o.Surface); ok {
+					item.Matches = append(item.Matches, m)
+				}
+			}
+			if len(item.Matches) > 0 {
+				timeline.Phrases = append(timeline.Phrases, item)
+			}
+		}
+	}
+	return timeline, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/occurrence"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const testSRT = `1
+00:00:01,000 --> 00:00:03,000
+<i>We need to figure out</i>
+
+2
+00:00:03,200 --> 00:00:04,000
+[MUSIC]
+
+3
+00:00:04,000 --> 00:00:07,500
+who broke the build.
+
+4
+00:00:12,000 --> 00:00:14,000
+Let's figure out the fix together.
+`
+
+func TestImportService_ExtractSubtitles(t *testing.T) {
+	svc := NewImportService(nil, nil)
+
+	imported, err := svc.ExtractFile("standup.srt", []byte(testSRT))
+	if err != nil {
+		t.Fatalf("ExtractFile failed: %v", err)
+	}
+	if imported.Title != "standup" || imported.Format != "srt" {
+		t.Errorf("unexpected import: %+v", imported)
+	}
+	if want := "We need to figure out who broke the build.\n\nLet's figure out the fix together."; imported.Content != want {
+		t.Errorf("unexpected content: %q", imported.Content)
+	}
+	want := []models.SubtitleCue{
+		{Index: 0, StartMs: 1000, EndMs: 3000, StartOffset: 0, EndOffset: 21, Text: "We need to figure out"},
+		{Index: 1, StartMs: 4000, EndMs: 7500, StartOffset: 22, EndOffset: 42, Text: "who broke the build."},
+		{Index: 2, StartMs: 12000, EndMs: 14000, StartOffset: 44, EndOffset: 78, Text: "Let's figure out the fix together."},
+	}
+	if len(imported.Cues) != len(want) {
+		t.Fatalf("unexpected cues: %+v", imported.Cues)
+	}
+	for i := range want {
+		if imported.Cues[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, imported.Cues[i], want[i])
+		}
+	}
+
+	if _, err := svc.ExtractFile("empty.vtt", []byte("WEBVTT\n\n00:01.000 --> 00:02.000\n[Applause]\n")); !errors.Is(err, ErrUnreadableFile) {
+		t.Errorf("expected ErrUnreadableFile, got %v", err)
+	}
+}
+
+func TestMaterialService_GetSubtitleTimeline(t *testing.T) {
+	imported, err := NewImportService(nil, nil).ExtractFile("standup.srt", []byte(testSRT))
+	if err != nil {
+		t.Fatalf("ExtractFile failed: %v", err)
+	}
+	userID := uuid.New()
+	index := occurrence.NewIndex(imported.Content)
+	material := &models.Material{
+		Model:          gorm.Model{ID: 1},
+		UserID:         userID,
+		ULID:           "01SUBTITLE",
+		Content:        imported.Content,
+		SourceLanguage: "en",
+		Version:        1,
+		SubtitleCues:   imported.Cues,
+		WordLists: []models.WordList{{Words: []models.Word{
+			{Model: gorm.Model{ID: 1}, Text: "break", Occurrences: findWordOccurrences(index, 1, "break")},
+			{Model: gorm.Model{ID: 2}, Text: "deploy"},
+		}}},
+		PhraseLists: []models.PhraseList{{Phrases: []models.Phrase{
+			{Model: gorm.Model{ID: 3}, Text: "figure out"},
+		}}},
+	}
+	store := stores_mock.NewMockMaterialStore()
+	store.Materials[material.ID] = material
+	svc := NewMaterialService(store)
+
+	timeline, err := svc.GetSubtitleTimeline(material.ULID, userID)
+	if err != nil {
+		t.Fatalf("GetSubtitleTimeline failed: %v", err)
+	}
+	if len(timeline.Cues) != 3 || timeline.Cues[2].StartMs != 12000 {
+		t.Errorf("unexpected cues: %+v", timeline.Cues)
+	}
+	if len(timeline.Words) != 1 || timeline.Words[0].Text != "break" ||
+		len(timeline.Words[0].Matches) != 1 || timeline.Words[0].Matches[0] != (CueMatch{Cue: 1, StartMs: 4000, EndMs: 7500, Surface: "broke"}) {
+		t.Errorf("unexpected words: %+v", timeline.Words)
+	}
+	if len(timeline.Phrases) != 1 || len(timeline.Phrases[0].Matches) != 2 ||
+		timeline.Phrases[0].Matches[0].Cue != 0 || timeline.Phrases[0].Matches[1].Cue != 2 || timeline.Phrases[0].Matches[1].StartMs != 12000 {
+		t.Errorf("unexpected phrases: %+v", timeline.Phrases)
+	}
+
+	// 本文を書き換えるとキューの位置が合わなくなるので消える
+	content := "A new transcript."
+	if _, _, err := svc.PatchMaterial(material.ULID, userID, 0, MaterialPatch{Content: &content}); err != nil {
+		t.Fatalf("PatchMaterial failed: %v", err)
+	}
+	if _, err := svc.GetSubtitleTimeline(material.ULID, userID); !errors.Is(err, ErrNoSubtitles) {
+		t.Errorf("expected ErrNoSubtitles, got %v", err)
+	}
+}
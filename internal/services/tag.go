@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/stores"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxTagLength       = 64
+	maxTagsPerMaterial = 20
+)
+
+var (
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrTagNotFound = errors.New("tag not found")
+	ErrTagExists   = errors.New("tag already exists")
+)
+
+type TagService interface {
+	ListTags(userID uuid.UUID) ([]models.TagCount, error)
+	RenameTag(userID uuid.UUID, id uint, name string) error
+	DeleteTag(userID uuid.UUID, id uint) error
+	SetMaterialTags(ulid string, userID uuid.UUID, names []string) ([]string, error)
+}
+
+type tagService struct {
+	store         stores.TagStore
+	materialStore stores.MaterialStore
+}
+
+func NewTagService(s stores.TagStore, materialStore stores.MaterialStore) TagService {
+	return &tagService{store: s, materialStore: materialStore}
+}
+
+// normalizeTags は前後と連続した空白をまとめ、重複を除く。空や長すぎる名前は ErrInvalidTag
+func normalizeTags(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		if name == "" || utf8.RuneCountInString(name) > maxTagLength {
+			return nil, ErrInvalidTag
+		}
+		if !slices.Contains(normalized, name) {
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeScope は一覧・検索・書き出しの絞り込みのタグを正規化する
+func normalizeScope(scope *models.MaterialScope) error {
+	tags, err := normalizeTags(scope.Tags)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	scope.Tags = tags
+	return nil
+}
+
+func (s *tagService) ListTags(userID uuid.UUID) ([]models.TagCount, error) {
+	tags, err := s.store.ListTags(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	if tags == nil {
+		tags = []models.TagCount{}
+	}
+	return tags, nil
+}
+
+func (s *tagService) RenameTag(userID uuid.UUID, id uint, name string) error {
+	names, err := normalizeTags([]string{name})
+	if err != nil {
+		return err
+	}
+	tags, err := s.store.ListTags(userID)
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+	for _, tag := range tags {
+		if tag.Name == names[0] && tag.ID != id {
+			return ErrTagExists
+		}
+	}
+	if err := s.store.RenameTag(userID, id, names[0]); err != nil {
+		return tagError(err)
+	}
+	return nil
+}
+
+// DeleteTag はタグを削除する。付いていた教材は残る
+func (s *tagService) DeleteTag(userID uuid.UUID, id uint) error {
+	if err := s.store.DeleteTag(userID, id); err != nil {
+		return tagError(err)
+	}
+	return nil
+}
+
+// SetMaterialTags は教材のタグを names に置き換え、付いたタグを名前順に返す
+func (s *tagService) SetMaterialTags(ulid string, userID uuid.UUID, names []string) ([]string, error) {
+	names, err := normalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > maxTagsPerMaterial {
+		return nil, ErrInvalidTag
+	}
+	material, err := s.materialStore.GetMaterialByULID(ulid, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get material by ID: %w", err)
+	}
+	if err := s.store.SetMaterialTags(userID, material.ID, names); err != nil {
+		return nil, fmt.Errorf("failed to set tags: %w", err)
+	}
+	tags, err := s.store.GetMaterialTags(material.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	return tags, nil
+}
+
+func tagError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTagNotFound
+	}
+	return fmt.Errorf("failed to update tag: %w", err)
+}
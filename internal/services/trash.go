@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/stores"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 保持期間を過ぎた教材を1回に削除する件数
+const trashPurgeBatchSize = 100
+
+var (
+	ErrNotInTrash = errors.New("material is not in the trash")
+)
+
+type TrashService interface {
+	ListTrash(userID uuid.UUID) ([]models.TrashedMaterial, error)
+	RestoreMaterial(ulid string, userID uuid.UUID) error
+	PurgeMaterial(ulid string, userID uuid.UUID) error
+	EmptyTrash(userID uuid.UUID) (int, error)
+	PurgeExpired() (int, error)
+}
+
+type trashService struct {
+	store stores.MaterialStore
+	// ゴミ箱に入れてから完全に削除するまでの期間。0 なら自動では削除しない
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewTrashService(s stores.MaterialStore, retention time.Duration) TrashService {
+	return &trashService{store: s, retention: retention, now: time.Now}
+}
+
+// ListTrash はゴミ箱の教材を、完全に削除される日時付きで返す
+func (s *trashService) ListTrash(userID uuid.UUID) ([]models.TrashedMaterial, error) {
+	materials, err := s.store.ListTrash(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+	if materials == nil {
+		materials = []models.TrashedMaterial{}
+	}
+	if s.retention > 0 {
+		for i := range materials {
+			materials[i].PurgeAt = materials[i].DeletedAt.Add(s.retention)
+		}
+	}
+	return materials, nil
+}
+
+func (s *trashService) RestoreMaterial(ulid string, userID uuid.UUID) error {
+	err := s.store.RestoreMaterial(ulid, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotInTrash
+	}
+	return err
+}
+
+// PurgeMaterial はゴミ箱の教材を完全に削除する
+func (s *trashService) PurgeMaterial(ulid string, userID uuid.UUID) error {
+	material, err := s.store.GetTrashedMaterial(ulid, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotInTrash
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get trashed material: %w", err)
+	}
+	return s.store.PurgeMaterials([]uint{material.ID})
+}
+
+// EmptyTrash はゴミ箱の教材を完全に削除し、削除した件数を返す
+func (s *trashService) EmptyTrash(userID uuid.UUID) (int, error) {
+	materials, err := s.store.ListTrash(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list trash: %w", err)
+	}
+	ids := make([]uint, 0, len(materials))
+	for _, material := range materials {
+		ids = append(ids, material.ID)
+	}
+	if err := s.store.PurgeMaterials(ids); err != nil {
+		return 0, fmt.Errorf("failed to purge materials: %w", err)
+	}
+	return len(ids), nil
+}
+
+// PurgeExpired は保持期間を過ぎた教材をすべてのユーザーについて完全に削除する
+func (s *trashService) PurgeExpired() (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	cutoff := s.now().Add(-s.retention)
+	purged := 0
+	for {
+		ids, err := s.store.ListTrashedBefore(cutoff, trashPurgeBatchSize)
+		if err != nil {
+			return purged, fmt.Errorf("failed to list expired trash: %w", err)
+		}
+		if len(ids) == 0 {
+			return purged, nil
+		}
+		if err := s.store.PurgeMaterials(ids); err != nil {
+			return purged, fmt.Errorf("failed to purge materials: %w", err)
+		}
+		purged += len(ids)
+		if len(ids) < trashPurgeBatchSize {
+			return purged, nil
+		}
+	}
+}
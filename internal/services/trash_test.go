@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yomek33/newln/internal/models"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+	"gorm.io/gorm"
+)
+
+func TestTrashService(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+	newStore := func() *stores_mock.MockMaterialStore {
+		store := stores_mock.NewMockMaterialStore()
+		trash := func(id uint, ulid string, created, deleted time.Time) {
+			store.Trash[id] = &models.Material{
+				Model:  gorm.Model{ID: id, CreatedAt: created, DeletedAt: gorm.DeletedAt{Time: deleted, Valid: true}},
+				UserID: userID,
+				ULID:   ulid,
+			}
+		}
+		trash(1, "old", now.AddDate(0, 0, -60), now.AddDate(0, 0, -31))
+		trash(2, "recent", now.AddDate(0, 0, -5), now.AddDate(0, 0, -1))
+		trash(3, "today", now.Add(-2*time.Hour), now.Add(-time.Hour))
+		store.Creations = append(store.Creations, models.MaterialCreation{
+			UserID: userID, MaterialID: 3, CreatedAt: now.Add(-2 * time.Hour),
+		})
+		return store
+	}
+	newService := func(store *stores_mock.MockMaterialStore) *trashService {
+		svc := NewTrashService(store, 30*24*time.Hour).(*trashService)
+		svc.now = func() time.Time { return now }
+		return svc
+	}
+
+	t.Run("list with purge dates", func(t *testing.T) {
+		materials, err := newService(newStore()).ListTrash(userID)
+		if err != nil {
+			t.Fatalf("ListTrash failed: %v", err)
+		}
+		if len(materials) != 3 || materials[0].ULID != "today" {
+			t.Fatalf("unexpected trash: %+v", materials)
+		}
+		if want := now.Add(-time.Hour).AddDate(0, 0, 30); !materials[0].PurgeAt.Equal(want) {
+			t.Errorf("PurgeAt = %v, expected %v", materials[0].PurgeAt, want)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		store := newStore()
+		svc := newService(store)
+		if err := svc.RestoreMaterial("recent", userID); err != nil {
+			t.Fatalf("RestoreMaterial failed: %v", err)
+		}
+		if _, ok := store.Materials[2]; !ok {
+			t.Error("expected the material to be restored")
+		}
+		if err := svc.RestoreMaterial("recent", userID); !errors.Is(err, ErrNotInTrash) {
+			t.Errorf("expected ErrNotInTrash, got %v", err)
+		}
+	})
+
+	t.Run("purge keeps the daily creation count", func(t *testing.T) {
+		store := newStore()
+		svc := newService(store)
+		if err := svc.PurgeMaterial("today", userID); err != nil {
+			t.Fatalf("PurgeMaterial failed: %v", err)
+		}
+		if err := svc.PurgeMaterial("today", userID); !errors.Is(err, ErrNotInTrash) {
+			t.Errorf("expected ErrNotInTrash, got %v", err)
+		}
+		purged, err := svc.EmptyTrash(userID)
+		if err != nil || purged != 2 {
+			t.Errorf("EmptyTrash = %d, %v, expected 2", purged, err)
+		}
+		if len(store.Trash) != 0 {
+			t.Errorf("expected the trash to be empty, got %+v", store.Trash)
+		}
+		count, _ := store.CountMaterialsCreatedSince(userID, now.Truncate(24*time.Hour))
+		if count != 1 {
+			t.Errorf("expected the purged material to be counted, got %d", count)
+		}
+	})
+
+	t.Run("purge expired", func(t *testing.T) {
+		store := newStore()
+		purged, err := newService(store).PurgeExpired()
+		if err != nil || purged != 1 {
+			t.Fatalf("PurgeExpired = %d, %v, expected 1", purged, err)
+		}
+		if _, ok := store.Trash[1]; ok {
+			t.Error("expected the expired material to be purged")
+		}
+
+		// 保持期間が 0 なら自動では削除しない
+		disabled := NewTrashService(newStore(), 0)
+		if purged, _ := disabled.PurgeExpired(); purged != 0 {
+			t.Errorf("expected nothing purged, got %d", purged)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/vertex"
+	"github.com/yomek33/newln/internal/stores"
+
+	"github.com/google/uuid"
+)
+
+type UsageService interface {
+	vertex.UsageRecorder
+	GetDailyUsage(userID uuid.UUID, from, to time.Time) ([]models.DailyUsage, error)
+	GetMaterialUsage(materialID uint) ([]models.OperationUsage, error)
+	GetUsageTotalsByUser(from, to time.Time) ([]models.UserUsage, error)
+	GetUsageTotals(from, to time.Time) (*models.UsageTotals, error)
+}
+
+type usageService struct {
+	store stores.UsageStore
+}
+
+func NewUsageService(s stores.UsageStore) UsageService {
+	return &usageService{store: s}
+}
+
+// RecordUsage は vertex クライアントから呼ばれる。記録に失敗しても生成処理は止めない
+func (s *usageService) RecordUsage(_ context.Context, usage vertex.Usage) {
+	record := &models.LLMUsage{
+		UserID:         usage.Scope.UserID,
+		MaterialID:     usage.Scope.MaterialID,
+		Operation:      usage.Scope.Operation,
+		ModelName:      usage.Model,
+		PromptTokens:   usage.PromptTokens,
+		ResponseTokens: usage.ResponseTokens,
+		TotalTokens:    usage.TotalTokens(),
+		LatencyMs:      usage.Latency.Milliseconds(),
+		Outcome:        usage.Outcome,
+		CostUSD:        usage.CostUSD(),
+	}
+	if usage.Err != nil {
+		record.ErrorMessage = usage.Err.Error()
+	}
+	if err := s.store.CreateUsage(record); err != nil {
+		logger.Errorf("❌ Failed to record LLM usage: %v, materialID: %v", err, usage.Scope.MaterialID)
+	}
+}
+
+func (s *usageService) GetDailyUsage(userID uuid.UUID, from, to time.Time) ([]models.DailyUsage, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid usage range: %v - %v", from, to)
+	}
+	return s.store.GetDailyUsageByUser(userID, from, to)
+}
+
+func (s *usageService) GetMaterialUsage(materialID uint) ([]models.OperationUsage, error) {
+	return s.store.GetUsageByMaterial(materialID)
+}
+
+func (s *usageService) GetUsageTotalsByUser(from, to time.Time) ([]models.UserUsage, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid usage range: %v - %v", from, to)
+	}
+	return s.store.GetUsageTotalsByUser(from, to)
+}
+
+func (s *usageService) GetUsageTotals(from, to time.Time) (*models.UsageTotals, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid usage range: %v - %v", from, to)
+	}
+	return s.store.GetUsageTotals(from, to)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/yomek33/newln/internal/models"
 	"github.com/yomek33/newln/internal/services"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,6 +31,24 @@ func (m *mockUserStore) GetUserByEmail(email string) (*models.User, error) {
 	return user, nil
 }
 
+func (m *mockUserStore) GetUserByID(userID uuid.UUID) (*models.User, error) {
+	for _, user := range m.users {
+		if user.UserID == userID {
+			return user, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (m *mockUserStore) UpdateNativeLanguage(userID uuid.UUID, code string) error {
+	user, err := m.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	user.NativeLanguage = code
+	return nil
+}
+
 func TestUserService(t *testing.T) {
 	mockStore := &mockUserStore{users: make(map[string]*models.User)}
 	userService := services.NewUserService(mockStore)
@@ -50,4 +69,15 @@ func TestUserService(t *testing.T) {
 		_, err = userService.LoginUser("test@example.com", "password123")
 		assert.NoError(t, err)
 	})
+	t.Run("UpdateNativeLanguage", func(t *testing.T) {
+		user, _ := mockStore.GetUserByEmail("test@example.com")
+
+		code, err := userService.UpdateNativeLanguage(user.UserID, "ko-KR")
+		assert.NoError(t, err)
+		assert.Equal(t, "ko", code)
+		assert.Equal(t, "ko", user.NativeLanguage)
+
+		_, err = userService.UpdateNativeLanguage(user.UserID, "klingon")
+		assert.ErrorIs(t, err, services.ErrUnsupportedLanguage)
+	})
 }
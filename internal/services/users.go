@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/language"
 	"github.com/yomek33/newln/internal/stores"
 
 	"github.com/google/uuid"
@@ -13,6 +14,8 @@ import (
 type UserService interface {
 	RegisterUser(email, password, name string) error
 	LoginUser(email, password string) (string, error)
+	GetUserByID(userID uuid.UUID) (*models.User, error)
+	UpdateNativeLanguage(userID uuid.UUID, code string) (string, error)
 }
 
 type userService struct {
@@ -59,3 +62,23 @@ func (s *userService) LoginUser(email, password string) (string, error) {
 
 	return token, nil
 }
+
+func (s *userService) GetUserByID(userID uuid.UUID) (*models.User, error) {
+	return s.Store.GetUserByID(userID)
+}
+
+// ErrUnsupportedLanguage は対応していない言語コードが指定されたときに返す
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// UpdateNativeLanguage は母語を変更し、正規化した言語コードを返す。
+// 以降に生成する意味はこの言語になる
+func (s *userService) UpdateNativeLanguage(userID uuid.UUID, code string) (string, error) {
+	normalized, ok := language.Normalize(code)
+	if !ok {
+		return "", ErrUnsupportedLanguage
+	}
+	if err := s.Store.UpdateNativeLanguage(userID, normalized); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
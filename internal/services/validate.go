@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/scoring"
+	"github.com/yomek33/newln/internal/pkg/vertex"
+)
+
+// DB の varchar(255) に合わせた上限
+const maxTextLength = 255
+
+// Rejection は LLM の出力1件に対する検証結果。Dropped=false は正規化して残したもの
+type Rejection struct {
+	Kind    string `json:"kind"`
+	Item    string `json:"item"`
+	Field   string `json:"field,omitempty"`
+	Reason  string `json:"reason"`
+	Dropped bool   `json:"dropped"`
+}
+
+// RejectionReporter は検証で落とした・直した項目を受け取る
+type RejectionReporter func(rejections []Rejection)
+
+type rejectionReporterKey struct{}
+
+// WithRejectionReporter は生成中の検証結果を ctx 経由で呼び出し元に返す
+func WithRejectionReporter(ctx context.Context, reporter RejectionReporter) context.Context {
+	return context.WithValue(ctx, rejectionReporterKey{}, reporter)
+}
+
+func reportRejections(ctx context.Context, rejections []Rejection) {
+	if len(rejections) == 0 {
+		return
+	}
+	for _, r := range rejections {
+		logger.Warnf("⚠️ %s %q %s: %s (dropped=%v)", r.Kind, r.Item, r.Field, r.Reason, r.Dropped)
+	}
+	if reporter, ok := ctx.Value(rejectionReporterKey{}).(RejectionReporter); ok && reporter != nil {
+		reporter(rejections)
+	}
+}
+
+// decodeResponse は途中で切れた応答を修復してからデコードし、修復したことを報告する
+func decodeResponse[T any](ctx context.Context, kind string, raw json.RawMessage) (T, error) {
+	output, repaired, err := vertex.DecodeRepairedJsonContent[T](raw)
+	if err == nil && repaired {
+		reportRejections(ctx, []Rejection{{Kind: kind, Field: "response", Reason: "malformed JSON repaired, incomplete items dropped"}})
+	}
+	return output, err
+}
+
+// decodeElement はストリーミング中の配列要素1件をデコードする。壊れた要素は落として報告する
+func decodeElement[T any](ctx context.Context, kind string, element json.RawMessage) (T, bool) {
+	item, err := vertex.DecodeJsonContent[T](element)
+	if err != nil {
+		reportRejections(ctx, []Rejection{{Kind: kind, Item: truncateRunes(string(element), 40), Reason: "malformed item", Dropped: true}})
+		return item, false
+	}
+	return item, true
+}
+
+// checkStreamedResponse はストリーミングし終えた応答全体を確認する。
+// 途中で切れていても揃った要素があれば修復扱いにし、何も取れなければエラーにする
+func checkStreamedResponse(ctx context.Context, kind string, raw json.RawMessage, items int) error {
+	if json.Valid(raw) {
+		return nil
+	}
+	if _, _, err := vertex.RepairJSON(raw); err != nil && items == 0 {
+		return fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	reportRejections(ctx, []Rejection{{Kind: kind, Field: "response", Reason: "malformed JSON repaired, incomplete items dropped"}})
+	return nil
+}
+
+// difficulty_level に入らない値の読み替え
+var difficultyAliases = map[string]string{
+	models.Easy:        models.Easy,
+	"beginner":         models.Easy,
+	"elementary":       models.Easy,
+	"basic":            models.Easy,
+	"a1":               models.Easy,
+	"a2":               models.Easy,
+	models.Intemediate: models.Intemediate,
+	"medium":           models.Intemediate,
+	"b1":               models.Intemediate,
+	"b2":               models.Intemediate,
+	models.Advance:     models.Advance,
+	"advance":          models.Advance,
+	"hard":             models.Advance,
+	"difficult":        models.Advance,
+	"c1":               models.Advance,
+	"c2":               models.Advance,
+}
+
+// normalizeDifficulty は enum に合わせる。読み替えられないときは ok=false
+func normalizeDifficulty(difficulty string) (string, bool) {
+	normalized, ok := difficultyAliases[strings.ToLower(strings.TrimSpace(difficulty))]
+	return normalized, ok
+}
+
+// validator は1回の検証で同じ項目の重複も落とす
+type validator struct {
+	kind       string
+	seen       map[string]bool
+	rejections []Rejection
+}
+
+func newValidator(kind string) *validator {
+	return &validator{kind: kind, seen: make(map[string]bool)}
+}
+
+func (v *validator) drop(item, field, reason string) {
+	v.rejections = append(v.rejections, Rejection{Kind: v.kind, Item: item, Field: field, Reason: reason, Dropped: true})
+}
+
+func (v *validator) fix(item, field, reason string) {
+	v.rejections = append(v.rejections, Rejection{Kind: v.kind, Item: item, Field: field, Reason: reason})
+}
+
+// text は見出し語を検証し、使えなければ false を返す
+func (v *validator) text(raw string) (string, bool) {
+	text := strings.Join(strings.Fields(raw), " ")
+	switch {
+	case text == "":
+		v.drop(raw, "text", "empty text")
+		return "", false
+	case utf8.RuneCountInString(text) > maxTextLength:
+		v.drop(truncateRunes(text, 40), "text", "text longer than 255 characters")
+		return "", false
+	case v.seen[strings.ToLower(text)]:
+		v.drop(text, "text", "duplicate")
+		return "", false
+	}
+	v.seen[strings.ToLower(text)] = true
+	return text, true
+}
+
+// limit は varchar(255) のカラムに入るよう切り詰める
+func (v *validator) limit(item, field, value string) string {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) <= maxTextLength {
+		return value
+	}
+	v.fix(item, field, "truncated to 255 characters")
+	return truncateRunes(value, maxTextLength)
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// validatePhraseResponses は抽出結果から意味生成に渡せないものを落とす
+func validatePhraseResponses(responses []PhraseResponse) ([]PhraseResponse, []Rejection) {
+	v := newValidator("phrase")
+	var valid []PhraseResponse
+	for _, res := range responses {
+		text, ok := v.text(res.Phrase)
+		if !ok {
+			continue
+		}
+		res.Phrase = text
+		valid = append(valid, res)
+	}
+	return valid, v.rejections
+}
+
+// validatePhraseMeanings は保存前にドメインルールで正規化・除外する
+func validatePhraseMeanings(responses []PhraseWithMeaning) ([]PhraseWithMeaning, []Rejection) {
+	v := newValidator("phrase")
+	var valid []PhraseWithMeaning
+	for _, res := range responses {
+		text, ok := v.text(res.Phrase)
+		if !ok {
+			continue
+		}
+		res.Phrase = text
+
+		res.Meaning = strings.TrimSpace(res.Meaning)
+		res.Translation = strings.TrimSpace(res.Translation)
+		if res.Meaning == "" && res.Translation == "" {
+			v.drop(text, "meaning", "empty meaning")
+			continue
+		}
+
+		difficulty, ok := normalizeDifficulty(res.Difficulty)
+		if !ok {
+			v.fix(text, "difficulty", fmt.Sprintf("unknown difficulty %q, using %s", res.Difficulty, models.Intemediate))
+			difficulty = models.Intemediate
+		} else if difficulty != res.Difficulty {
+			v.fix(text, "difficulty", fmt.Sprintf("%q normalized to %s", res.Difficulty, difficulty))
+		}
+		res.Difficulty = difficulty
+
+		valid = append(valid, res)
+	}
+	return valid, v.rejections
+}
+
+// validateWordResponses は抽出結果から意味生成に渡せないものを落とす
+func validateWordResponses(responses []WordResponse) ([]WordResponse, []Rejection) {
+	v := newValidator("word")
+	var valid []WordResponse
+	for _, res := range responses {
+		text, ok := v.text(res.Word)
+		if !ok {
+			continue
+		}
+		res.Word = text
+		res.Pos = strings.ToLower(strings.TrimSpace(res.Pos))
+		if res.CEFR != "" {
+			cefr := strings.ToUpper(strings.TrimSpace(res.CEFR))
+			if _, ok := scoring.LevelFromCEFR(cefr); !ok {
+				v.fix(text, "cefr", fmt.Sprintf("unknown CEFR level %q ignored", res.CEFR))
+				cefr = ""
+			}
+			res.CEFR = cefr
+		}
+		valid = append(valid, res)
+	}
+	return valid, v.rejections
+}
+
+// validateWordMeanings は保存前にドメインルールで正規化・除外する
+func validateWordMeanings(responses []WordWithMeaning) ([]WordWithMeaning, []Rejection) {
+	v := newValidator("word")
+	var valid []WordWithMeaning
+	for _, res := range responses {
+		text, ok := v.text(res.Word)
+		if !ok {
+			continue
+		}
+		res.Word = text
+
+		res.Meaning = v.limit(text, "meaning", res.Meaning)
+		res.Translation = v.limit(text, "translation", res.Translation)
+		if res.Meaning == "" && res.Translation == "" {
+			v.drop(text, "meaning", "empty meaning")
+			continue
+		}
+		valid = append(valid, res)
+	}
+	return valid, v.rejections
+}
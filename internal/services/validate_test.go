@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/vertex"
+)
+
+func TestValidatePhraseMeanings(t *testing.T) {
+	input := []PhraseWithMeaning{
+		{Phrase: "  close   a deal ", Meaning: "to agree", Difficulty: "Intermediate"},
+		{Phrase: "close a deal", Meaning: "duplicate", Difficulty: "easy"},
+		{Phrase: "", Meaning: "empty", Difficulty: "easy"},
+		{Phrase: strings.Repeat("x", 256), Meaning: "too long", Difficulty: "easy"},
+		{Phrase: "in operation", Meaning: "", Translation: "", Difficulty: "easy"},
+		{Phrase: "resolve issues", Meaning: "to fix problems", Difficulty: "C1"},
+		{Phrase: "worked tirelessly", Meaning: "worked hard", Difficulty: "expert"},
+	}
+
+	valid, rejections := validatePhraseMeanings(input)
+
+	expected := map[string]string{
+		"close a deal":      "intermediate",
+		"resolve issues":    "advanced",
+		"worked tirelessly": "intermediate",
+	}
+	if len(valid) != len(expected) {
+		t.Fatalf("expected %d valid phrases, got %d: %+v", len(expected), len(valid), valid)
+	}
+	for _, p := range valid {
+		if expected[p.Phrase] != p.Difficulty {
+			t.Errorf("phrase %q: difficulty = %q, expected %q", p.Phrase, p.Difficulty, expected[p.Phrase])
+		}
+	}
+
+	dropped := 0
+	for _, r := range rejections {
+		if r.Dropped {
+			dropped++
+		}
+	}
+	if dropped != 4 || len(rejections) != 7 {
+		t.Errorf("expected 4 dropped of 7 rejections, got %d of %d: %+v", dropped, len(rejections), rejections)
+	}
+}
+
+func TestValidateWordMeanings(t *testing.T) {
+	longMeaning := strings.Repeat("あ", 300)
+	valid, rejections := validateWordMeanings([]WordWithMeaning{
+		{Word: "resolve", Meaning: longMeaning, Translation: "解決する"},
+		{Word: "Resolve", Meaning: "duplicate"},
+		{Word: " ", Meaning: "empty"},
+	})
+
+	if len(valid) != 1 {
+		t.Fatalf("expected 1 valid word, got %+v", valid)
+	}
+	if n := len([]rune(valid[0].Meaning)); n != maxTextLength {
+		t.Errorf("meaning length = %d, expected %d", n, maxTextLength)
+	}
+	if len(rejections) != 3 || rejections[0].Dropped {
+		t.Errorf("unexpected rejections: %+v", rejections)
+	}
+}
+
+func TestPhraseService_GeneratePhrases_RepairsTruncatedMeanings(t *testing.T) {
+	var mu sync.Mutex
+	var reported []Rejection
+	ctx := WithRejectionReporter(context.Background(), func(rejections []Rejection) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, rejections...)
+	})
+
+	truncated := &vertex.Fault{Response: json.RawMessage(`[
+		{"phrase": "close a deal", "from_text": true, "example": "e", "difficulty": "B2", "meaning": "m", "translation": "契約を結ぶ"},
+		{"phrase": "in operation", "from_text": true, "exa`)}
+
+	service := &phraseService{
+		materialStore: newTestMaterialStore(),
+		vertexClient:  newTestVertexClient(t, "generate_phrases").WithFaults(truncated.OnCall(2)),
+	}
+
+	phrases, err := service.GeneratePhrases(ctx, 1)
+	if err != nil {
+		t.Fatalf("GeneratePhrases failed: %v", err)
+	}
+	if len(phrases) != 1 || phrases[0].Difficulty != "intermediate" {
+		t.Fatalf("expected the complete phrase with a normalized difficulty, got %+v", phrases)
+	}
+
+	var repaired, normalized bool
+	for _, r := range reported {
+		repaired = repaired || r.Field == "response"
+		normalized = normalized || (r.Field == "difficulty" && !r.Dropped)
+	}
+	if !repaired || !normalized {
+		t.Errorf("expected repair and normalization to be reported, got %+v", reported)
+	}
+}
+
+func TestPhraseService_GenerateMeaning_DropsDuplicatesAcrossFlushes(t *testing.T) {
+	client := &scriptedVertexClient{respond: func(string) (json.RawMessage, error) {
+		return json.RawMessage(`[
+			{"phrase": "close a deal", "difficulty": "easy", "meaning": "m"},
+			{"phrase": "in operation", "difficulty": "easy", "meaning": "m"},
+			{"phrase": "take over", "difficulty": "easy", "meaning": "m"},
+			{"phrase": "set up", "difficulty": "easy", "meaning": "m"},
+			{"phrase": "carry out", "difficulty": "easy", "meaning": "m"},
+			{"phrase": "Close a deal", "difficulty": "easy", "meaning": "m"}
+		]`), nil
+	}}
+
+	var saved []models.Phrase
+	service := &phraseService{vertexClient: client}
+	phrases, err := service.GenerateMeaning(context.Background(), "close a deal", func(chunk []models.Phrase) error {
+		saved = append(saved, chunk...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GenerateMeaning failed: %v", err)
+	}
+	// 2つ目の区切りに入った重複も保存しない
+	if len(saved) != streamFlushSize || len(phrases) != streamFlushSize {
+		t.Errorf("expected %d phrases, got %d saved and %d returned", streamFlushSize, len(saved), len(phrases))
+	}
+}
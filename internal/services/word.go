@@ -5,27 +5,50 @@ import (
 	"fmt"
 
 	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/scoring"
+	"github.com/yomek33/newln/internal/pkg/segment"
 	"github.com/yomek33/newln/internal/pkg/vertex"
 	"github.com/yomek33/newln/internal/stores"
+
+	"github.com/google/uuid"
 )
 
 type WordService interface {
 	GenerateWords(ctx context.Context, materialID uint) ([]models.Word, error)
+	GenerateWordsInChunks(ctx context.Context, materialID uint, onChunk WordChunkHandler) ([]models.Word, error)
 	GetWordsByMaterialID(materialULID string) ([]models.Word, error)
 	GetWordListByMaterialULID(materialULID string) ([]models.WordList, error)
 	CreateWordList(wordList *models.WordList) error
 	UpdateWordListGenerateStatus(wordListID uint, status string) error
 	BulkInsertWords(words []models.Word) error
+	GetVocabulary(userID uuid.UUID) ([]models.VocabularyEntry, error)
 }
 
+// WordChunkHandler は意味まで生成できた単語を少しずつ受け取る。複数の goroutine から呼ばれる
+type WordChunkHandler func(words []models.Word) error
+
 type wordService struct {
 	store         stores.WordStore
 	materialStore stores.MaterialStore
 	vertexClient  vertex.VertexService
+	// 長い教材を分割するときの区間サイズ。ゼロ値なら segment.DefaultOptions
+	segmentOptions segment.Options
+	// 難易度と重要度の採点。nil なら scoring.Default
+	scorer *scoring.Scorer
+	// オフライン辞書。nil なら意味はすべてモデルで生成する
+	dictionaryStore stores.DictionaryStore
+}
+
+func NewWordService(s stores.WordStore, materialStore stores.MaterialStore, dictionaryStore stores.DictionaryStore, vertexClient vertex.VertexService, scorer *scoring.Scorer) WordService {
+	return &wordService{store: s, materialStore: materialStore, dictionaryStore: dictionaryStore, vertexClient: vertexClient, scorer: scorer}
 }
 
-func NewWordService(s stores.WordStore, materialStore stores.MaterialStore, vertexClient vertex.VertexService) WordService {
-	return &wordService{store: s, materialStore: materialStore, vertexClient: vertexClient}
+// scorerOrDefault は nil なら DefaultThresholds の Scorer を返す
+func scorerOrDefault(scorer *scoring.Scorer) *scoring.Scorer {
+	if scorer == nil {
+		return scoring.Default()
+	}
+	return scorer
 }
 
 func (s *wordService) CreateWordList(wordList *models.WordList) error {
@@ -47,14 +70,10 @@ func (s *wordService) BulkInsertWords(words []models.Word) error {
 	return s.store.BulkInsertWords(words)
 }
 
-func (s *wordService) UpdateWordListGenerateStatus(wordListID uint, status string) error {
-	return s.store.UpdateWordListGenerateStatus(wordListID, status)
-}
-
-func determineWordImportance(_ string) string {
-	return "high"
+func (s *wordService) GetVocabulary(userID uuid.UUID) ([]models.VocabularyEntry, error) {
+	return s.store.GetVocabularyByUserID(userID)
 }
 
-func determineWordLevel(_ string) string {
-	return "easy"
+func (s *wordService) UpdateWordListGenerateStatus(wordListID uint, status string) error {
+	return s.store.UpdateWordListGenerateStatus(wordListID, status)
 }
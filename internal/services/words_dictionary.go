@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yomek33/newln/internal/logger"
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/dictionary"
+	"github.com/yomek33/newln/internal/pkg/vertex"
+	"github.com/yomek33/newln/internal/services/prompts"
+)
+
+// SourceLLM はモデルが生成したフィールドの出典
+const SourceLLM = "llm"
+
+// 語義の選択でモデルに渡す語義の上限
+const maxSenseCandidates = 5
+
+// WordSense は語義の選択の応答
+type WordSense struct {
+	ID          int    `json:"id"`
+	Word        string `json:"word"`
+	Sense       int    `json:"sense"`
+	Translation string `json:"translation"`
+}
+
+type senseCandidate struct {
+	sense  models.DictionarySense
+	source string
+}
+
+// dictionaryMatch は1語について辞書で引けた内容
+type dictionaryMatch struct {
+	senses            []senseCandidate
+	ipa               string
+	ipaSource         string
+	translations      []string
+	translationSource string
+}
+
+// lookupDictionary は words を辞書で引く。品詞が一致する項目があればそれだけを使う。
+// 辞書がない・引けないときは空の map を返し、すべてモデルに任せる
+func (s *wordService) lookupDictionary(ctx context.Context, words []WordResponse) map[string]*dictionaryMatch {
+	matches := make(map[string]*dictionaryMatch)
+	if s.dictionaryStore == nil || len(words) == 0 {
+		return matches
+	}
+
+	lemmas := make([]string, 0, len(words))
+	for _, w := range words {
+		lemmas = append(lemmas, dictionary.NormalizeLemma(w.Word))
+	}
+	entries, err := s.dictionaryStore.FindEntries(sourceLanguage(ctx), lemmas)
+	if err != nil {
+		logger.Error(fmt.Errorf("failed to look up dictionary: %w", err))
+		return matches
+	}
+
+	native := nativeLanguage(ctx)
+	for _, w := range words {
+		lemma := dictionary.NormalizeLemma(w.Word)
+		pos := dictionary.NormalizePos(w.Pos)
+		var candidates []models.DictionaryEntry
+		for _, entry := range entries {
+			if entry.Lemma == lemma && entry.Pos == pos {
+				candidates = append(candidates, entry)
+			}
+		}
+		if len(candidates) == 0 {
+			for _, entry := range entries {
+				if entry.Lemma == lemma {
+					candidates = append(candidates, entry)
+				}
+			}
+		}
+
+		match := &dictionaryMatch{}
+		for _, entry := range candidates {
+			for _, sense := range entry.Senses {
+				if sense.Definition != "" && len(match.senses) < maxSenseCandidates {
+					match.senses = append(match.senses, senseCandidate{sense: sense, source: entry.Source})
+				}
+			}
+			if match.ipa == "" && entry.IPA != "" {
+				match.ipa, match.ipaSource = entry.IPA, entry.Source
+			}
+			if len(match.translations) == 0 && len(entry.Translations[native]) > 0 {
+				match.translations, match.translationSource = entry.Translations[native], entry.Source
+			}
+		}
+		if len(match.senses) > 0 {
+			matches[strings.ToLower(w.Word)] = match
+		}
+	}
+	return matches
+}
+
+// fillFromDictionary は辞書で引けた語を辞書の内容で作り、onChunk に渡す。
+// 語義が1つで訳もある語はモデルを呼ばない。語義が複数か訳がない語は語義の選択と訳だけをモデルに頼む。
+// 辞書にない語を返す
+func (s *wordService) fillFromDictionary(ctx context.Context, wordsChunk []WordResponse, onChunk WordChunkHandler) ([]WordResponse, []models.Word, error) {
+	matches := s.lookupDictionary(ctx, wordsChunk)
+	if len(matches) == 0 {
+		return wordsChunk, nil, nil
+	}
+
+	var unknown, ambiguous []WordResponse
+	var resolved []models.Word
+	for _, w := range wordsChunk {
+		match, ok := matches[strings.ToLower(w.Word)]
+		switch {
+		case !ok:
+			unknown = append(unknown, w)
+		case len(match.senses) == 1 && len(match.translations) > 0:
+			resolved = append(resolved, dictionaryWord(ctx, w, match, 0, joinTranslations(match.translations), match.translationSource))
+		default:
+			ambiguous = append(ambiguous, w)
+		}
+	}
+	logger.Infof("📖 Dictionary: %d resolved, %d to disambiguate, %d unknown", len(resolved), len(ambiguous), len(unknown))
+
+	if len(ambiguous) > 0 {
+		words, err := s.chooseSenses(ctx, ambiguous, matches)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved = append(resolved, words...)
+	}
+	if len(resolved) > 0 && onChunk != nil {
+		if err := onChunk(resolved); err != nil {
+			return nil, nil, err
+		}
+	}
+	return unknown, resolved, nil
+}
+
+// chooseSenses は文脈に合う語義と、その語義での訳をモデルに選ばせる
+func (s *wordService) chooseSenses(ctx context.Context, words []WordResponse, matches map[string]*dictionaryMatch) ([]models.Word, error) {
+	type senseInput struct {
+		Sense      int    `json:"sense"`
+		Definition string `json:"definition"`
+	}
+	type wordInput struct {
+		ID      int          `json:"id"`
+		Word    string       `json:"word"`
+		Pos     string       `json:"pos"`
+		Context string       `json:"context,omitempty"`
+		Senses  []senseInput `json:"senses"`
+	}
+	inputs := make([]wordInput, 0, len(words))
+	for i, w := range words {
+		input := wordInput{ID: i + 1, Word: w.Word, Pos: w.Pos, Context: w.Context}
+		for j, candidate := range matches[strings.ToLower(w.Word)].senses {
+			input.Senses = append(input.Senses, senseInput{Sense: j + 1, Definition: candidate.sense.Definition})
+		}
+		inputs = append(inputs, input)
+	}
+	inputJSON, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, tmpl, err := prompts.RenderFor(prompts.GenerateWordSenses, sourceLanguage(ctx), promptData(ctx, string(inputJSON)))
+	if err != nil {
+		return nil, err
+	}
+	jsonSchema, err := vertex.GenerateSchema[[]WordSense]()
+	if err != nil {
+		return nil, err
+	}
+	rawResponse, err := s.vertexClient.GenerateJsonContent(vertex.WithOperation(ctx, "choose_word_senses"), prompt, jsonSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to choose senses: %w", err)
+	}
+	choices, err := decodeResponse[[]WordSense](ctx, "word", rawResponse)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]WordSense, len(choices))
+	for _, choice := range choices {
+		byID[choice.ID] = choice
+	}
+
+	var rejections []Rejection
+	result := make([]models.Word, 0, len(words))
+	for i, w := range words {
+		match := matches[strings.ToLower(w.Word)]
+		choice, ok := byID[i+1]
+		if !ok {
+			rejections = append(rejections, Rejection{Kind: "word", Item: w.Word, Field: "sense", Reason: "no sense chosen, using the first sense"})
+		}
+		sense := choice.Sense - 1
+		if ok && (sense < 0 || sense >= len(match.senses)) {
+			rejections = append(rejections, Rejection{Kind: "word", Item: w.Word, Field: "sense", Reason: fmt.Sprintf("sense %d out of range, using the first sense", choice.Sense)})
+		}
+		if sense < 0 || sense >= len(match.senses) {
+			sense = 0
+		}
+
+		translation, translationSource := strings.TrimSpace(choice.Translation), SourceLLM
+		if translation == "" && len(match.translations) > 0 {
+			translation, translationSource = joinTranslations(match.translations), match.translationSource
+		}
+		word := dictionaryWord(ctx, w, match, sense, translation, translationSource)
+		word.PromptVersion = tmpl.ID()
+		result = append(result, word)
+	}
+	reportRejections(ctx, rejections)
+	return result, nil
+}
+
+// dictionaryWord は辞書の語義 sense から単語を作り、フィールドごとの出典を記録する
+func dictionaryWord(ctx context.Context, w WordResponse, match *dictionaryMatch, sense int, translation, translationSource string) models.Word {
+	candidate := match.senses[sense]
+	word := models.Word{
+		Text:         w.Word,
+		Lemma:        w.Word,
+		Pos:          w.Pos,
+		Meaning:      truncateRunes(candidate.sense.Definition, maxTextLength),
+		Translations: translationsOf(ctx, translation),
+		IPA:          match.ipa,
+		Synonyms:     candidate.sense.Synonyms,
+		Sources:      map[string]string{"meaning": candidate.source},
+	}
+	if len(candidate.sense.Examples) > 0 {
+		word.Example = candidate.sense.Examples[0]
+		word.Sources["example"] = candidate.source
+	}
+	if len(word.Synonyms) > 0 {
+		word.Sources["synonyms"] = candidate.source
+	}
+	if word.IPA != "" {
+		word.Sources["ipa"] = match.ipaSource
+	}
+	if translation != "" {
+		word.Sources["translation"] = translationSource
+	}
+	return word
+}
+
+// joinTranslations は辞書の訳語を最大3つ並べる
+func joinTranslations(translations []string) string {
+	if len(translations) > 3 {
+		translations = translations[:3]
+	}
+	return strings.Join(translations, ", ")
+}
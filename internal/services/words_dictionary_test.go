@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yomek33/newln/internal/models"
+	stores_mock "github.com/yomek33/newln/internal/stores/mocks"
+)
+
+func TestWordService_GenerateWords_Dictionary(t *testing.T) {
+	dictionaryStore := stores_mock.NewMockDictionaryStore(
+		models.DictionaryEntry{
+			Language: "en", Lemma: "partner", Pos: "noun", Source: "wordnet",
+			Senses: []models.DictionarySense{{Definition: "a person who is a member of a partnership", Examples: []string{"he was a partner in a law firm"}, Synonyms: []string{"collaborator"}}},
+		},
+		models.DictionaryEntry{
+			Language: "en", Lemma: "partner", Pos: "noun", Source: "wiktextract", IPA: "/ˈpɑːtnə/",
+			Translations: map[string][]string{"ja": {"提携先", "パートナー"}},
+		},
+		models.DictionaryEntry{
+			Language: "en", Lemma: "resolve", Pos: "verb", Source: "wiktextract",
+			Senses: []models.DictionarySense{{Definition: "To make a firm decision."}, {Definition: "To find a solution to."}},
+		},
+	)
+
+	var sensePrompt, meaningPrompt string
+	client := &scriptedVertexClient{respond: func(prompt string) (json.RawMessage, error) {
+		switch {
+		case strings.Contains(prompt, "choose the sense"):
+			sensePrompt = prompt
+			return json.RawMessage(`[{"id": 1, "word": "resolve", "sense": 2, "translation": "解決する"}]`), nil
+		case strings.Contains(prompt, "update each object"):
+			meaningPrompt = prompt
+			return json.RawMessage(`[{"id": 1, "word": "tirelessly", "pos": "adverb", "meaning": "without getting tired", "translation": "たゆまず"}]`), nil
+		}
+		return json.RawMessage(`[
+			{"id": 1, "word": "resolve", "pos": "verb", "cefr": "B2"},
+			{"id": 2, "word": "partner", "pos": "noun", "cefr": "B1"},
+			{"id": 3, "word": "tirelessly", "pos": "adverb", "cefr": "C1"}
+		]`), nil
+	}}
+
+	service := &wordService{materialStore: newTestMaterialStore(), dictionaryStore: dictionaryStore, vertexClient: client}
+	words, err := service.GenerateWords(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GenerateWords failed: %v", err)
+	}
+
+	// 辞書にない語だけを意味生成に、語義が複数ある語だけを語義の選択に送る
+	if strings.Contains(meaningPrompt, "partner") || strings.Contains(meaningPrompt, "resolve") || !strings.Contains(meaningPrompt, "tirelessly") {
+		t.Errorf("unexpected words in the meaning prompt: %s", meaningPrompt)
+	}
+	if !strings.Contains(sensePrompt, "engineers can resolve issues quickly") || strings.Contains(sensePrompt, "partner") {
+		t.Errorf("unexpected sense prompt: %s", sensePrompt)
+	}
+
+	byLemma := make(map[string]models.Word)
+	for _, w := range words {
+		byLemma[w.Lemma] = w
+	}
+	if len(byLemma) != 3 {
+		t.Fatalf("expected 3 words, got %+v", words)
+	}
+
+	partner := byLemma["partner"]
+	if partner.Meaning != "a person who is a member of a partnership" || partner.IPA != "/ˈpɑːtnə/" || partner.Example == "" {
+		t.Errorf("partner was not filled from the dictionary: %+v", partner)
+	}
+	if partner.Sources["meaning"] != "wordnet" || partner.Sources["translation"] != "wiktextract" || partner.Sources["ipa"] != "wiktextract" {
+		t.Errorf("unexpected partner sources: %v", partner.Sources)
+	}
+	if len(partner.Translations) != 1 || partner.Translations[0].Meaning != "提携先, パートナー" {
+		t.Errorf("unexpected partner translations: %+v", partner.Translations)
+	}
+
+	resolve := byLemma["resolve"]
+	if resolve.Meaning != "To find a solution to." || resolve.Sources["meaning"] != "wiktextract" || resolve.Sources["translation"] != SourceLLM {
+		t.Errorf("resolve did not use the chosen sense: %+v", resolve)
+	}
+	if tirelessly := byLemma["tirelessly"]; tirelessly.Sources["meaning"] != SourceLLM || len(tirelessly.Occurrences) == 0 {
+		t.Errorf("unexpected tirelessly: %+v", tirelessly)
+	}
+}
+
+func TestWordService_GenerateWordMeanings_KeepsDictionaryWordsOnError(t *testing.T) {
+	dictionaryStore := stores_mock.NewMockDictionaryStore(models.DictionaryEntry{
+		Language: "en", Lemma: "partner", Pos: "noun", Source: "wordnet",
+		Senses:       []models.DictionarySense{{Definition: "a person who is a member of a partnership"}},
+		Translations: map[string][]string{"ja": {"提携先"}},
+	})
+	client := &scriptedVertexClient{respond: func(string) (json.RawMessage, error) {
+		return json.RawMessage(`not json`), nil
+	}}
+
+	var saved []models.Word
+	service := &wordService{dictionaryStore: dictionaryStore, vertexClient: client}
+	words, err := service.GenerateWordMeanings(context.Background(), []WordResponse{
+		{ID: 1, Word: "partner", Pos: "noun"},
+		{ID: 2, Word: "tirelessly", Pos: "adverb"},
+	}, "partner, tirelessly", func(chunk []models.Word) error {
+		saved = append(saved, chunk...)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error for a malformed response")
+	}
+	// 辞書で引けて保存済みの単語は失敗しても返す
+	if len(saved) != 1 || len(words) != 1 || words[0].Lemma != "partner" {
+		t.Errorf("expected the stored dictionary word to be returned, got %+v (saved %+v)", words, saved)
+	}
+}
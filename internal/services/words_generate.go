@@ -2,64 +2,102 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 
 	"github.com/yomek33/newln/internal/logger"
 	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/pkg/lemma"
+	"github.com/yomek33/newln/internal/pkg/occurrence"
+	"github.com/yomek33/newln/internal/pkg/scoring"
+	"github.com/yomek33/newln/internal/pkg/segment"
 	"github.com/yomek33/newln/internal/pkg/vertex"
+	"github.com/yomek33/newln/internal/services/prompts"
 )
 
 type WordResponse struct {
 	ID   int    `json:"id"`
 	Word string `json:"word"`
 	Pos  string `json:"pos"`
+	CEFR string `json:"cefr,omitempty" schema:"enum=A1|A2|B1|B2|C1|C2"`
+
+	// 本文中の出現回数 (区間をまたいでまとめた後に数える)
+	Occurrences int `json:"-"`
+	// 抽出された語形 (小文字)。まとめた後の Word は見出し語
+	Forms []string `json:"-"`
+	// 本文中で最初に現れた文。辞書の語義を選ぶときに使う
+	Context string `json:"-"`
 }
 
 type WordWithMeaning struct {
-	ID        int    `json:"id"`
-	Word      string `json:"word"`
-	Pos       string `json:"pos"`
-	Meaning   string `json:"meaning"`
-	JPMeaning string `json:"jp-meaning"`
+	ID          int    `json:"id"`
+	Word        string `json:"word"`
+	Pos         string `json:"pos"`
+	Meaning     string `json:"meaning"`
+	Translation string `json:"translation"`
 }
 
 // `generateWords` を実装
 func (s *wordService) GenerateWords(ctx context.Context, materialID uint) ([]models.Word, error) {
+	return s.GenerateWordsInChunks(ctx, materialID, nil)
+}
+
+// GenerateWordsInChunks は意味の生成が進むたびに onChunk へ渡す。onChunk が nil なら最後にまとめて返すだけ
+func (s *wordService) GenerateWordsInChunks(ctx context.Context, materialID uint, onChunk WordChunkHandler) ([]models.Word, error) {
+	ctx = vertex.WithOperation(ctx, "generate_words")
 	// 1回目のリクエスト（単語と品詞を取得）
-	promptFile, err := os.ReadFile("./internal/services/prompts/generate_words.txt")
-	if err != nil {
-		logger.Error(fmt.Errorf("failed to read prompt file: %w", err))
-		return nil, err
-	}
-	prompt := string(promptFile)
 	material, err := s.materialStore.GetMaterialByID(materialID)
 	if err != nil {
 		logger.Error(fmt.Errorf("failed to get material: %w", err))
 		return nil, err
 	}
+	ctx = WithSourceLanguage(ctx, materialLanguage(material))
+	lang := sourceLanguage(ctx)
 
-	prompt = strings.ReplaceAll(prompt, "{{TEXT}}", material.Content)
-	jsonSchema := vertex.GenerateSchema[[]WordResponse]()
-	rawResponse, err := s.vertexClient.GenerateJsonContent(ctx, prompt, jsonSchema)
+	wordResponses, err := s.extractWords(ctx, material.Content)
 	if err != nil {
-		logger.Error(fmt.Errorf("failed to generate words: %w", err))
 		return nil, err
 	}
 
-	// JSONをデコード
-	wordResponses, err := vertex.DecodeJsonContent[[]WordResponse](rawResponse)
-	if err != nil {
-		logger.Error(fmt.Errorf("failed to parse JSON: %w", err))
-		return nil, err
+	logger.Infof("✅ Retrieved %d words: %v", len(wordResponses), wordResponses)
+
+	// 保存前に本文中の出現位置と採点結果を付ける。品詞と CEFR は抽出時のものを優先する
+	index := occurrence.NewIndexIn(material.Content, lang)
+	scorer := scorerOrDefault(s.scorer)
+	extracted := make(map[string]WordResponse, len(wordResponses))
+	for _, w := range wordResponses {
+		extracted[strings.ToLower(w.Word)] = w
+	}
+	withOccurrences := func(chunk []models.Word) error {
+		for i := range chunk {
+			word := &chunk[i]
+			word.Occurrences = findWordOccurrences(index, materialID, word.Text)
+			res := extracted[strings.ToLower(word.Text)]
+			if res.Pos != "" {
+				word.Pos = res.Pos
+			}
+			word.Forms = wordForms(res.Forms, word.Occurrences)
+			score := scorer.Score(scoring.Input{Text: word.Text, CEFR: res.CEFR, Occurrences: len(word.Occurrences), Language: lang})
+			word.Level = score.Level
+			word.Importance = score.Importance
+		}
+		if onChunk != nil {
+			return onChunk(chunk)
+		}
+		return nil
 	}
 
-	logger.Infof("✅ Retrieved %d words: %v", len(wordResponses), wordResponses)
+	sentences := segment.Sentences(material.Content)
+	for i := range wordResponses {
+		if found := index.Find(wordResponses[i].Word); len(found) > 0 && found[0].Sentence < len(sentences) {
+			wordResponses[i].Context = sentences[found[0].Sentence].Text
+		}
+	}
 
 	// quota小さすぎる。。。
-	chunks := chunkWords(wordResponses, 30)
+	chunks := chunkWords(wordResponses, 30, lang)
 	logger.Infof("✅ Split words into %d chunks for processing", len(chunks))
 
 	var wg sync.WaitGroup
@@ -79,7 +117,7 @@ func (s *wordService) GenerateWords(ctx context.Context, materialID uint) ([]mod
 			}
 			wordsStr := strings.Join(wordList, ", ")
 
-			meanings, err := s.GenerateWordMeanings(ctx, wordsChunk, wordsStr)
+			meanings, err := s.GenerateWordMeanings(vertex.WithOperation(ctx, "generate_word_meanings"), wordsChunk, wordsStr, withOccurrences)
 			if err != nil {
 				logger.Error(fmt.Errorf("❌ Failed to generate meanings: %w", err))
 				errChan <- err
@@ -124,17 +162,89 @@ func (s *wordService) GenerateWords(ctx context.Context, materialID uint) ([]mod
 	return allWords, nil
 }
 
+// extractWords は本文を区間に分けて抽出し、重複をまとめる
+func (s *wordService) extractWords(ctx context.Context, content string) ([]WordResponse, error) {
+	segments := segment.Split(content, s.segmentOptions)
+	logger.Infof("✂️ Split material into %d segments", len(segments))
+
+	jsonSchema, err := vertex.GenerateSchema[[]WordResponse]()
+	if err != nil {
+		return nil, err
+	}
+	results, err := mapSegments(ctx, segments, func(ctx context.Context, seg segment.Segment) ([]WordResponse, error) {
+		prompt, _, err := prompts.RenderFor(prompts.GenerateWords, sourceLanguage(ctx), promptData(ctx, seg.Text))
+		if err != nil {
+			logger.Error(fmt.Errorf("failed to render prompt: %w", err))
+			return nil, err
+		}
+		rawResponse, err := s.vertexClient.GenerateJsonContent(ctx, prompt, jsonSchema)
+		if err != nil {
+			logger.Error(fmt.Errorf("failed to generate words: %w", err))
+			return nil, err
+		}
+
+		// JSONをデコード
+		wordResponses, err := decodeResponse[[]WordResponse](ctx, "word", rawResponse)
+		if err != nil {
+			logger.Error(fmt.Errorf("failed to parse JSON: %w", err))
+			return nil, err
+		}
+		wordResponses, rejections := validateWordResponses(wordResponses)
+		reportRejections(ctx, rejections)
+		return wordResponses, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeWords(results, content, sourceLanguage(ctx)), nil
+}
+
+// wordForms は抽出時の語形と本文中の語形を重複なく並べる
+func wordForms(extracted []string, occurrences []models.WordOccurrence) []string {
+	seen := make(map[string]bool)
+	var forms []string
+	add := func(form string) {
+		form = strings.ToLower(form)
+		if form != "" && !seen[form] {
+			seen[form] = true
+			forms = append(forms, form)
+		}
+	}
+	for _, form := range extracted {
+		add(form)
+	}
+	for _, o := range occurrences {
+		add(o.Surface)
+	}
+	return forms
+}
+
+func findWordOccurrences(index *occurrence.Index, materialID uint, word string) []models.WordOccurrence {
+	var occurrences []models.WordOccurrence
+	for _, o := range index.Find(word) {
+		occurrences = append(occurrences, models.WordOccurrence{
+			MaterialID:    materialID,
+			StartOffset:   o.Start,
+			EndOffset:     o.End,
+			SentenceIndex: o.Sentence,
+			Surface:       truncateRunes(o.Surface, maxTextLength),
+		})
+	}
+	return occurrences
+}
+
 // **単語リストを分割する関数**
-func chunkWords(words []WordResponse, chunkSize int) [][]WordResponse {
+func chunkWords(words []WordResponse, chunkSize int, lang string) [][]WordResponse {
 	var chunks [][]WordResponse
 	seen := make(map[string]bool)
 	var currentChunk []WordResponse
 
 	for _, word := range words {
-		if _, exists := seen[word.Word]; exists {
+		key := lemma.LemmatizeIn(lang, word.Word, word.Pos)
+		if _, exists := seen[key]; exists {
 			continue
 		}
-		seen[word.Word] = true
+		seen[key] = true
 
 		currentChunk = append(currentChunk, word)
 
@@ -152,34 +262,110 @@ func chunkWords(words []WordResponse, chunkSize int) [][]WordResponse {
 }
 
 // **単語の意味を取得する関数**
-func (s *wordService) GenerateWordMeanings(ctx context.Context, wordsChunk []WordResponse, wordsStr string) ([]models.Word, error) {
-	promptFile, err := os.ReadFile("./internal/services/prompts/generate_words_meanings.txt")
+// ストリーミングで揃った分から streamFlushSize 件ずつ onChunk に渡す
+func (s *wordService) GenerateWordMeanings(ctx context.Context, wordsChunk []WordResponse, wordsStr string, onChunk WordChunkHandler) ([]models.Word, error) {
+	// 辞書で引ける語は辞書を使い、モデルには辞書にない語だけを頼む
+	wordsChunk, resolved, err := s.fillFromDictionary(ctx, wordsChunk, onChunk)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+		return resolved, err
+	}
+	if len(wordsChunk) == 0 {
+		return resolved, nil
+	}
+	if len(resolved) > 0 {
+		var wordList []string
+		for _, word := range wordsChunk {
+			wordList = append(wordList, word.Word)
+		}
+		wordsStr = strings.Join(wordList, ", ")
 	}
-	prompt := string(promptFile)
-	prompt = strings.ReplaceAll(prompt, "{{TEXT}}", wordsStr)
-
-	jsonSchema := vertex.GenerateSchema[[]WordWithMeaning]()
 
-	rawResponse, err := s.vertexClient.GenerateJsonContent(ctx, prompt, jsonSchema)
+	lang := sourceLanguage(ctx)
+	prompt, tmpl, err := prompts.RenderFor(prompts.GenerateWordsMeanings, lang, promptData(ctx, wordsStr))
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate meanings: %w", err)
+		return resolved, err
 	}
 
-	meaningResponses, err := vertex.DecodeJsonContent[[]WordWithMeaning](rawResponse)
+	jsonSchema, err := vertex.GenerateSchema[[]WordWithMeaning]()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		return resolved, err
+	}
+
+	// 依頼した見出し語だけを残す。言い換えや活用形で返ってきても同じ見出し語は1つにする
+	// 小文字にした見出し語 -> 見出し語 (ドイツ語の名詞は大文字で始まる)
+	requested := make(map[string]string, len(wordsChunk))
+	for _, w := range wordsChunk {
+		requested[strings.ToLower(w.Word)] = w.Word
 	}
+	stored := make(map[string]bool)
 
 	var words []models.Word
-	for _, res := range meaningResponses {
-		words = append(words, models.Word{
-			Text:      res.Word,
-			Meaning:   res.Meaning,
-			JPMeaning: res.JPMeaning,
-		})
+	var pending []WordWithMeaning
+	flush := func() error {
+		meaningResponses, rejections := validateWordMeanings(pending)
+		pending = nil
+
+		var chunk []models.Word
+		for _, res := range meaningResponses {
+			key := strings.ToLower(res.Word)
+			if _, ok := requested[key]; !ok {
+				key = strings.ToLower(lemma.LemmatizeIn(lang, res.Word, res.Pos))
+			}
+			headword, ok := requested[key]
+			if !ok {
+				rejections = append(rejections, Rejection{Kind: "word", Item: res.Word, Field: "word", Reason: "not in the requested words", Dropped: true})
+				continue
+			}
+			if stored[key] {
+				rejections = append(rejections, Rejection{Kind: "word", Item: res.Word, Field: "word", Reason: "duplicate lemma " + key, Dropped: true})
+				continue
+			}
+			stored[key] = true
+			chunk = append(chunk, models.Word{
+				Text:          headword,
+				Lemma:         headword,
+				Pos:           res.Pos,
+				Meaning:       res.Meaning,
+				Translations:  translationsOf(ctx, res.Translation),
+				PromptVersion: tmpl.ID(),
+				Sources:       map[string]string{"meaning": SourceLLM, "translation": SourceLLM},
+			})
+		}
+		reportRejections(ctx, rejections)
+		if len(chunk) == 0 {
+			return nil
+		}
+		// onChunk で保存された ID を返り値にも残す
+		if onChunk != nil {
+			if err := onChunk(chunk); err != nil {
+				return err
+			}
+		}
+		words = append(words, chunk...)
+		return nil
+	}
+
+	rawResponse, err := vertex.StreamJsonContent(ctx, s.vertexClient, prompt, jsonSchema, func(element json.RawMessage) error {
+		item, ok := decodeElement[WordWithMeaning](ctx, "word", element)
+		if !ok {
+			return nil
+		}
+		pending = append(pending, item)
+		if len(pending) >= streamFlushSize {
+			return flush()
+		}
+		return nil
+	})
+	// 辞書で引けた語も含め、保存済みの単語は失敗しても返す
+	if err != nil {
+		return append(resolved, words...), fmt.Errorf("failed to generate meanings: %w", err)
+	}
+	if err := flush(); err != nil {
+		return append(resolved, words...), err
+	}
+	if err := checkStreamedResponse(ctx, "word", rawResponse, len(words)); err != nil {
+		return append(resolved, words...), err
 	}
 	logger.Infof("Generated words: %v", words)
-	return words, nil
+	return append(resolved, words...), nil
 }
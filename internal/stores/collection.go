@@ -0,0 +1,93 @@
+package stores
+
+import (
+	"github.com/yomek33/newln/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CollectionStore interface {
+	ListCollections(userID uuid.UUID) ([]models.Collection, error)
+	GetCollection(userID uuid.UUID, id uint) (*models.Collection, error)
+	CreateCollection(collection *models.Collection) error
+	UpdateCollection(collection *models.Collection) error
+	DeleteCollection(userID uuid.UUID, id uint) error
+	ListCollectionMaterials(collectionID uint) ([]models.MaterialSummary, error)
+	SetCollectionMaterials(collectionID uint, materialIDs []uint) error
+}
+
+type collectionStore struct {
+	DB *gorm.DB
+}
+
+func NewCollectionStore(db *gorm.DB) CollectionStore {
+	return &collectionStore{DB: db}
+}
+
+// ListCollections はユーザーのコレクションを親・位置の順に返す。木は呼び出し側で組む
+func (s *collectionStore) ListCollections(userID uuid.UUID) ([]models.Collection, error) {
+	var collections []models.Collection
+	err := s.DB.Where("user_id = ?", userID).
+		Order("parent_id NULLS FIRST, position, id").
+		Find(&collections).Error
+	return collections, err
+}
+
+func (s *collectionStore) GetCollection(userID uuid.UUID, id uint) (*models.Collection, error) {
+	var collection models.Collection
+	err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&collection).Error
+	return &collection, err
+}
+
+func (s *collectionStore) CreateCollection(collection *models.Collection) error {
+	return s.DB.Create(collection).Error
+}
+
+// UpdateCollection は名前・親・位置を保存する
+func (s *collectionStore) UpdateCollection(collection *models.Collection) error {
+	return s.DB.Model(collection).
+		Select("name", "parent_id", "position").
+		Updates(collection).Error
+}
+
+// DeleteCollection はコレクションを配下のコレクションごと完全に削除する。教材そのものは消さない
+func (s *collectionStore) DeleteCollection(userID uuid.UUID, id uint) error {
+	result := s.DB.Unscoped().Where("id = ? AND user_id = ?", id, userID).Delete(&models.Collection{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListCollectionMaterials はコレクションの教材を位置の順に返す (ゴミ箱を除く)
+func (s *collectionStore) ListCollectionMaterials(collectionID uint) ([]models.MaterialSummary, error) {
+	var materials []models.MaterialSummary
+	err := s.DB.Model(&models.Material{}).
+		Select("materials.id, materials.ul_id, materials.title, materials.source_language, materials.status, materials.summary, materials.word_count, materials.words_count, materials.phrases_count, materials.has_pending_word_list, materials.has_pending_phrase_list, materials.created_at, materials.updated_at").
+		Joins("JOIN collection_materials ON collection_materials.material_id = materials.id").
+		Where("collection_materials.collection_id = ?", collectionID).
+		Order("collection_materials.position, materials.id").
+		Find(&materials).Error
+	return materials, err
+}
+
+// SetCollectionMaterials はコレクションの教材を materialIDs の順に置き換える
+func (s *collectionStore) SetCollectionMaterials(collectionID uint, materialIDs []uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collectionID).Delete(&models.CollectionMaterial{}).Error; err != nil {
+			return err
+		}
+		if len(materialIDs) == 0 {
+			return nil
+		}
+		items := make([]models.CollectionMaterial, len(materialIDs))
+		for i, id := range materialIDs {
+			items[i] = models.CollectionMaterial{CollectionID: collectionID, MaterialID: id, Position: i}
+		}
+		return tx.Create(&items).Error
+	})
+}
@@ -0,0 +1,45 @@
+package stores
+
+import (
+	"github.com/yomek33/newln/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DictionaryStore interface {
+	UpsertEntries(entries []models.DictionaryEntry) error
+	FindEntries(language string, lemmas []string) ([]models.DictionaryEntry, error)
+}
+
+type dictionaryStore struct {
+	DB *gorm.DB
+}
+
+func NewDictionaryStore(db *gorm.DB) DictionaryStore {
+	return &dictionaryStore{DB: db}
+}
+
+// UpsertEntries は同じ言語・見出し語・品詞・出典の項目を上書きする
+func (s *dictionaryStore) UpsertEntries(entries []models.DictionaryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "language"}, {Name: "lemma"}, {Name: "pos"}, {Name: "source"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ipa", "senses", "translations", "updated_at"}),
+	}).CreateInBatches(entries, 500).Error
+}
+
+// FindEntries は lemmas (小文字) のいずれかに一致する項目をすべて返す
+func (s *dictionaryStore) FindEntries(language string, lemmas []string) ([]models.DictionaryEntry, error) {
+	var entries []models.DictionaryEntry
+	if len(lemmas) == 0 {
+		return entries, nil
+	}
+	err := s.DB.
+		Where("language = ? AND lemma IN ?", language, lemmas).
+		Order("lemma, pos, source").
+		Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,127 @@
+package stores
+
+import (
+	"time"
+
+	"github.com/yomek33/newln/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FeedStore interface {
+	ListSubscriptions(userID uuid.UUID) ([]models.FeedSubscription, error)
+	GetSubscription(userID uuid.UUID, id uint) (*models.FeedSubscription, error)
+	CreateSubscription(subscription *models.FeedSubscription) error
+	UpdateSubscription(subscription *models.FeedSubscription) error
+	SavePollState(subscription *models.FeedSubscription) error
+	DeleteSubscription(userID uuid.UUID, id uint) error
+	ListDueSubscriptions(now time.Time, limit int) ([]models.FeedSubscription, error)
+	HasEntry(subscriptionID uint, guid string) (bool, error)
+	HasContentHash(userID uuid.UUID, hash string) (bool, error)
+	CountCreatedSince(subscriptionID uint, since time.Time) (int64, error)
+	CreateEntry(entry *models.FeedEntry) error
+	ListEntries(subscriptionID uint, limit int) ([]models.FeedEntry, error)
+}
+
+type feedStore struct {
+	DB *gorm.DB
+}
+
+func NewFeedStore(db *gorm.DB) FeedStore {
+	return &feedStore{DB: db}
+}
+
+func (s *feedStore) ListSubscriptions(userID uuid.UUID) ([]models.FeedSubscription, error) {
+	var subscriptions []models.FeedSubscription
+	err := s.DB.Where("user_id = ?", userID).Order("id").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (s *feedStore) GetSubscription(userID uuid.UUID, id uint) (*models.FeedSubscription, error) {
+	var subscription models.FeedSubscription
+	err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&subscription).Error
+	return &subscription, err
+}
+
+func (s *feedStore) CreateSubscription(subscription *models.FeedSubscription) error {
+	return s.DB.Create(subscription).Error
+}
+
+// UpdateSubscription は設定を保存する。next_poll_at は再開したときにすぐ取得するため
+func (s *feedStore) UpdateSubscription(subscription *models.FeedSubscription) error {
+	return s.DB.Model(subscription).
+		Select("title", "source_language", "keywords", "max_length", "max_per_day", "full_text", "active", "next_poll_at").
+		Updates(subscription).Error
+}
+
+// SavePollState は取得の状態だけを保存する。取得の間に変わった設定は上書きしない。
+// タイトルは空のときだけフィードのタイトルで埋める
+func (s *feedStore) SavePollState(subscription *models.FeedSubscription) error {
+	return s.DB.Model(&models.FeedSubscription{}).
+		Where("id = ?", subscription.ID).
+		Updates(map[string]interface{}{
+			"next_poll_at":   subscription.NextPollAt,
+			"last_polled_at": subscription.LastPolledAt,
+			"last_error":     subscription.LastError,
+			"title":          gorm.Expr("CASE WHEN title = '' THEN ? ELSE title END", subscription.Title),
+		}).Error
+}
+
+// DeleteSubscription は購読を処理した記事の記録ごと完全に削除する。作った教材は消さない
+func (s *feedStore) DeleteSubscription(userID uuid.UUID, id uint) error {
+	result := s.DB.Unscoped().Where("id = ? AND user_id = ?", id, userID).Delete(&models.FeedSubscription{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListDueSubscriptions は取得の時刻が来た有効な購読を古い順に返す
+func (s *feedStore) ListDueSubscriptions(now time.Time, limit int) ([]models.FeedSubscription, error) {
+	var subscriptions []models.FeedSubscription
+	err := s.DB.Where("active AND next_poll_at <= ?", now).
+		Order("next_poll_at, id").
+		Limit(limit).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (s *feedStore) HasEntry(subscriptionID uint, guid string) (bool, error) {
+	var count int64
+	err := s.DB.Model(&models.FeedEntry{}).Where("subscription_id = ? AND guid = ?", subscriptionID, guid).Count(&count).Error
+	return count > 0, err
+}
+
+// HasContentHash はユーザーのどれかの購読で同じ本文の記事を処理したかを返す
+func (s *feedStore) HasContentHash(userID uuid.UUID, hash string) (bool, error) {
+	var count int64
+	err := s.DB.Model(&models.FeedEntry{}).Where("user_id = ? AND content_hash = ?", userID, hash).Count(&count).Error
+	return count > 0, err
+}
+
+// CountCreatedSince は since 以降に購読から作った教材の数を返す
+func (s *feedStore) CountCreatedSince(subscriptionID uint, since time.Time) (int64, error) {
+	var count int64
+	err := s.DB.Model(&models.FeedEntry{}).
+		Where("subscription_id = ? AND status = ? AND created_at >= ?", subscriptionID, models.FeedEntryCreated, since).
+		Count(&count).Error
+	return count, err
+}
+
+func (s *feedStore) CreateEntry(entry *models.FeedEntry) error {
+	return s.DB.Create(entry).Error
+}
+
+// ListEntries は購読で処理した記事を新しい順に返す
+func (s *feedStore) ListEntries(subscriptionID uint, limit int) ([]models.FeedEntry, error) {
+	var entries []models.FeedEntry
+	err := s.DB.Where("subscription_id = ?", subscriptionID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
@@ -2,33 +2,49 @@ package stores
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/yomek33/newln/internal/logger"
 	"github.com/yomek33/newln/internal/models"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
-	ErrMaterialCannotBeNil  = "material cannot be nil"
-	ErrMismatchedMaterialID = "material ID does not match the provided ID"
+	ErrMaterialCannotBeNil = "material cannot be nil"
 )
 
 type MaterialStore interface {
 	CreateMaterial(material *models.Material) (*models.Material, error)
+	CreateMaterialWithinLimit(material *models.Material, since time.Time, limit int64) (bool, error)
 	GetMaterialByULID(ulid string, UserID uuid.UUID) (*models.Material, error)
 	GetMaterialByID(id uint) (*models.Material, error)
-	UpdateMaterial(ulid string, material *models.Material) error
+	UpdateMaterialFields(ulid string, UserID uuid.UUID, version int, fields map[string]interface{}, revision *models.MaterialRevision) (bool, error)
+	ListRevisions(materialID uint) ([]models.MaterialRevision, error)
+	GetRevision(materialID uint, version int) (*models.MaterialRevision, error)
+	ResetDerivedLists(materialID uint) error
 	DeleteMaterial(ulid string, UserID uuid.UUID) error
-	GetAllMaterials(searchQuery string, UserID uuid.UUID) ([]models.Material, error)
+	ListMaterials(query models.MaterialQuery) ([]models.MaterialSummary, error)
 	UpdateMaterialStatus(id uint, status string) error
 	GetMaterialStatus(ulid string) (string, error)
 	CheckAllCompleted(materialID uint) (bool, error)
 	UpdateMaterialField(ulid string, field string, value interface{}) error
 	UpdateHasPendingWordStatus(ulid string, status bool) error
 	UpdateHasPendingPhraseStatus(ulid string, status bool) error
+	CountMaterialsCreatedSince(UserID uuid.UUID, since time.Time) (int64, error)
+	ListTrash(UserID uuid.UUID) ([]models.TrashedMaterial, error)
+	GetTrashedMaterial(ulid string, UserID uuid.UUID) (*models.TrashedMaterial, error)
+	ListTrashedBefore(cutoff time.Time, limit int) ([]uint, error)
+	RestoreMaterial(ulid string, UserID uuid.UUID) error
+	PurgeMaterials(ids []uint) error
+	GetMaterialIDs(ulids []string, UserID uuid.UUID) (map[string]uint, error)
+	ExportMaterials(UserID uuid.UUID, scope models.MaterialScope) ([]models.Material, error)
 }
 
 type materialStore struct {
@@ -43,21 +59,89 @@ func (s *materialStore) CreateMaterial(material *models.Material) (*models.Mater
 	if material == nil {
 		return nil, errors.New(ErrMaterialCannotBeNil)
 	}
-	err := s.DB.Create(material).Error
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		return createMaterial(tx, material)
+	})
 	if err != nil {
 		return nil, err
 	}
 	return material, nil
 }
 
+// CreateMaterialWithinLimit は since 以降に作った教材が limit 件未満のときだけ教材を作る。limit が 0 以下なら数えない。
+// 同じユーザーの作成はユーザーの行のロックで順番にし、数えてから作るまでの間に他の作成を挟ませない。
+// 上限に達していれば false を返す
+func (s *materialStore) CreateMaterialWithinLimit(material *models.Material, since time.Time, limit int64) (bool, error) {
+	if material == nil {
+		return false, errors.New(ErrMaterialCannotBeNil)
+	}
+	created := false
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if limit > 0 {
+			var user models.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select("user_id").
+				Where("user_id = ?", material.UserID).
+				First(&user).Error; err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&models.MaterialCreation{}).
+				Where("user_id = ? AND created_at >= ?", material.UserID, since).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= limit {
+				return nil
+			}
+		}
+		if err := createMaterial(tx, material); err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+// createMaterial は教材と最初の版を保存する。作成の記録は1日の作成数を数えるためのもので、完全に削除しても消さない
+func createMaterial(tx *gorm.DB, material *models.Material) error {
+	if err := tx.Create(material).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(baseRevision(material)).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.MaterialCreation{
+		UserID:     material.UserID,
+		MaterialID: material.ID,
+		CreatedAt:  material.CreatedAt,
+	}).Error
+}
+
+// baseRevision は material の今の状態を差分なしの版にする
+func baseRevision(material *models.Material) *models.MaterialRevision {
+	return &models.MaterialRevision{
+		MaterialID:     material.ID,
+		Version:        material.Version,
+		AuthorID:       material.UserID,
+		Title:          material.Title,
+		Content:        material.Content,
+		SourceLanguage: material.SourceLanguage,
+	}
+}
+
 func (s *materialStore) GetMaterialByULID(ulid string, userID uuid.UUID) (*models.Material, error) {
 	log.Println("store material id", ulid)
 	var material models.Material
 
 	err := s.DB.
-		Preload("WordLists.Words").
-		Preload("PhraseLists.Phrases").
+		Preload("WordLists.Words.Occurrences").
+		Preload("WordLists.Words.Translations").
+		Preload("PhraseLists.Phrases.Translations").
 		Preload("ChatLists.Chats").
+		// subtitle.Find が二分探索するので本文の位置の順 (時刻の順とは限らない)
+		Preload("SubtitleCues", func(db *gorm.DB) *gorm.DB { return db.Order("start_offset, id") }).
 		Where("ul_id = ? AND user_id = ?", ulid, userID).
 		First(&material).
 		Error
@@ -76,28 +160,215 @@ func (s *materialStore) GetMaterialByID(id uint) (*models.Material, error) {
 
 }
 
-func (s *materialStore) UpdateMaterial(ulid string, material *models.Material) error {
-	if material == nil {
-		return errors.New(ErrMaterialCannotBeNil)
-	}
-	if ulid != material.ULID {
-		return errors.New(ErrMismatchedMaterialID)
+// UpdateMaterialFields は version が一致するときだけ fields を更新して version を1つ増やす。
+// revision があれば新しい版として同じトランザクションで保存する。一致する行がなければ false を返す
+func (s *materialStore) UpdateMaterialFields(ulid string, UserID uuid.UUID, version int, fields map[string]interface{}, revision *models.MaterialRevision) (bool, error) {
+	updates := make(map[string]interface{}, len(fields)+1)
+	for field, value := range fields {
+		updates[field] = value
 	}
-	return s.DB.Model(&models.Material{}).Where("id = ?", ulid).Updates(material).Error
+	updates["version"] = gorm.Expr("version + 1")
+
+	applied := false
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.Material
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("ul_id = ? AND user_id = ? AND version = ?", ulid, UserID, version).
+			First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if revision != nil {
+			// 履歴ができる前に作られた教材は、変更前の状態を先に残す
+			var count int64
+			if err := tx.Model(&models.MaterialRevision{}).Where("material_id = ?", current.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				if err := tx.Create(baseRevision(&current)).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Model(&current).Updates(updates).Error; err != nil {
+			return err
+		}
+		// 本文が変わると字幕のキューの位置が合わなくなるので消す
+		if _, ok := fields["content"]; ok {
+			if err := tx.Where("material_id = ?", current.ID).Delete(&models.SubtitleCue{}).Error; err != nil {
+				return err
+			}
+		}
+		if revision != nil {
+			revision.MaterialID = current.ID
+			revision.Version = version + 1
+			if err := tx.Create(revision).Error; err != nil {
+				return err
+			}
+		}
+		applied = true
+		return nil
+	})
+	return applied, err
+}
+
+// ListRevisions は版を新しい順に返す。本文と差分は読まない
+func (s *materialStore) ListRevisions(materialID uint) ([]models.MaterialRevision, error) {
+	var revisions []models.MaterialRevision
+	err := s.DB.
+		Select("id, created_at, updated_at, material_id, version, author_id, title, source_language, additions, deletions, restored_from").
+		Where("material_id = ?", materialID).
+		Order("version DESC").
+		Find(&revisions).Error
+	return revisions, err
+}
+
+func (s *materialStore) GetRevision(materialID uint, version int) (*models.MaterialRevision, error) {
+	var revision models.MaterialRevision
+	err := s.DB.Where("material_id = ? AND version = ?", materialID, version).First(&revision).Error
+	return &revision, err
+}
+
+// ResetDerivedLists は教材の単語・フレーズのリストを訳も含めて削除し、生成待ちに戻す
+func (s *materialStore) ResetDerivedLists(materialID uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteTranslations(tx, []uint{materialID}); err != nil {
+			return err
+		}
+		// 単語・フレーズ・出現位置は外部キーの ON DELETE CASCADE で消える
+		if err := tx.Unscoped().Where("material_id = ?", materialID).Delete(&models.WordList{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("material_id = ?", materialID).Delete(&models.PhraseList{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Material{}).Where("id = ?", materialID).Updates(map[string]interface{}{
+			"has_pending_word_list":   true,
+			"has_pending_phrase_list": true,
+		}).Error
+	})
 }
 
 func (s *materialStore) DeleteMaterial(ulid string, UserID uuid.UUID) error {
 	return s.DB.Where("ul_id = ? AND user_id = ?", ulid, UserID).Delete(&models.Material{}).Error
 }
 
-func (s *materialStore) GetAllMaterials(searchQuery string, UserID uuid.UUID) ([]models.Material, error) {
-	var materials []models.Material
-	query := s.DB.Where("user_id = ?", UserID)
-	if searchQuery != "" {
-		query = query.Where("title LIKE ?", "%"+searchQuery+"%")
+// 一覧の並び順ごとの列。title は大文字小文字を区別しない
+var materialSortColumns = map[string]string{
+	models.MaterialSortCreated:   "created_at",
+	models.MaterialSortUpdated:   "updated_at",
+	models.MaterialSortTitle:     "lower(title)",
+	models.MaterialSortWordCount: "word_count",
+}
+
+const materialSummaryColumns = "id, ul_id, title, source_language, status, summary, word_count, words_count, phrases_count, has_pending_word_list, has_pending_phrase_list, created_at, updated_at"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ListMaterials は (並び順の列, ul_id) のキーセットで1ページ分を返す。Content は読まない
+func (s *materialStore) ListMaterials(query models.MaterialQuery) ([]models.MaterialSummary, error) {
+	column, ok := materialSortColumns[query.Sort]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort: %q", query.Sort)
 	}
-	err := query.Find(&materials).Error
-	return materials, err
+
+	db := s.DB.Model(&models.Material{}).
+		Select(materialSummaryColumns).
+		Where("user_id = ?", query.UserID)
+	if query.Search != "" {
+		db = db.Where("title ILIKE ?", "%"+likeEscaper.Replace(query.Search)+"%")
+	}
+	if len(query.Statuses) > 0 {
+		db = db.Where("status IN ?", query.Statuses)
+	}
+	if query.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		db = db.Where("created_at < ?", *query.CreatedTo)
+	}
+	if condition, args := materialScopeSQL("id", query.UserID, query.MaterialScope); condition != "" {
+		db = db.Where(condition, args)
+	}
+	switch query.Generation {
+	case models.GenerationPending:
+		db = db.Where("has_pending_word_list OR has_pending_phrase_list")
+	case models.GenerationReady:
+		db = db.Where("NOT has_pending_word_list AND NOT has_pending_phrase_list")
+	}
+
+	direction, op := "ASC", ">"
+	if query.Desc {
+		direction, op = "DESC", "<"
+	}
+	if query.After != nil {
+		value, err := materialCursorValue(query.Sort, query.After.Value)
+		if err != nil {
+			return nil, err
+		}
+		placeholder := "?"
+		if query.Sort == models.MaterialSortTitle {
+			placeholder = "lower(?)"
+		}
+		db = db.Where(fmt.Sprintf("(%s, ul_id) %s (%s, ?)", column, op, placeholder), value, query.After.ULID)
+	}
+
+	var materials []models.MaterialSummary
+	err := db.Order(fmt.Sprintf("%s %s, ul_id %s", column, direction, direction)).
+		Limit(query.Limit).
+		Find(&materials).Error
+	if err != nil {
+		return nil, err
+	}
+	return materials, s.attachTags(materials)
+}
+
+// attachTags は一覧の教材にタグの名前を付ける
+func (s *materialStore) attachTags(materials []models.MaterialSummary) error {
+	if len(materials) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(materials))
+	for i, material := range materials {
+		ids[i] = material.ID
+	}
+	var rows []struct {
+		MaterialID uint
+		Name       string
+	}
+	err := s.DB.Table("material_tags").
+		Select("material_tags.material_id, tags.name").
+		Joins("JOIN tags ON tags.id = material_tags.tag_id AND tags.deleted_at IS NULL").
+		Where("material_tags.material_id IN ?", ids).
+		Order("tags.name").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	tags := make(map[uint][]string)
+	for _, row := range rows {
+		tags[row.MaterialID] = append(tags[row.MaterialID], row.Name)
+	}
+	for i := range materials {
+		materials[i].Tags = tags[materials[i].ID]
+	}
+	return nil
+}
+
+// materialCursorValue はカーソルの値を並び順の列の型に戻す
+func materialCursorValue(sort, value string) (interface{}, error) {
+	switch sort {
+	case models.MaterialSortCreated, models.MaterialSortUpdated:
+		return time.Parse(time.RFC3339Nano, value)
+	case models.MaterialSortWordCount:
+		return strconv.Atoi(value)
+	}
+	return value, nil
 }
 
 func (s *materialStore) UpdateMaterialStatus(id uint, status string) error {
@@ -150,3 +421,129 @@ func (s *materialStore) UpdateHasPendingWordStatus(ulid string, status bool) err
 func (s *materialStore) UpdateHasPendingPhraseStatus(ulid string, status bool) error {
 	return s.DB.Model(&models.Material{}).Where("ul_id = ?", ulid).Update("has_pending_phrase_list", status).Error
 }
+
+// CountMaterialsCreatedSince は作成の記録から数える (作成→完全に削除で上限を回避させない)
+func (s *materialStore) CountMaterialsCreatedSince(UserID uuid.UUID, since time.Time) (int64, error) {
+	var count int64
+	err := s.DB.Model(&models.MaterialCreation{}).
+		Where("user_id = ? AND created_at >= ?", UserID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// deleteTranslations は教材の単語・フレーズの訳を削除する。訳は polymorphic で外部キーがないため、
+// リストを消す前に呼ぶ
+func deleteTranslations(tx *gorm.DB, materialIDs []uint) error {
+	if err := tx.Exec(`DELETE FROM translations WHERE item_type = ? AND item_id IN (
+		SELECT words.id FROM words JOIN word_lists ON word_lists.id = words.word_list_id WHERE word_lists.material_id IN ?)`,
+		models.TranslationItemWord, materialIDs).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`DELETE FROM translations WHERE item_type = ? AND item_id IN (
+		SELECT phrases.id FROM phrases JOIN phrase_lists ON phrase_lists.id = phrases.phrase_list_id WHERE phrase_lists.material_id IN ?)`,
+		models.TranslationItemPhrase, materialIDs).Error
+}
+
+// ListTrash はゴミ箱の教材を削除の新しい順に返す
+func (s *materialStore) ListTrash(UserID uuid.UUID) ([]models.TrashedMaterial, error) {
+	var materials []models.TrashedMaterial
+	err := s.DB.Unscoped().Model(&models.Material{}).
+		Select(materialSummaryColumns+", deleted_at").
+		Where("user_id = ? AND deleted_at IS NOT NULL", UserID).
+		Order("deleted_at DESC").
+		Find(&materials).Error
+	return materials, err
+}
+
+func (s *materialStore) GetTrashedMaterial(ulid string, UserID uuid.UUID) (*models.TrashedMaterial, error) {
+	var material models.TrashedMaterial
+	err := s.DB.Unscoped().Model(&models.Material{}).
+		Select(materialSummaryColumns+", deleted_at").
+		Where("ul_id = ? AND user_id = ? AND deleted_at IS NOT NULL", ulid, UserID).
+		First(&material).Error
+	return &material, err
+}
+
+// ListTrashedBefore は cutoff より前にゴミ箱に入った教材の ID を古い順に limit 件返す
+func (s *materialStore) ListTrashedBefore(cutoff time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := s.DB.Unscoped().Model(&models.Material{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Order("deleted_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// RestoreMaterial はゴミ箱の教材を戻す。ゴミ箱になければ gorm.ErrRecordNotFound
+func (s *materialStore) RestoreMaterial(ulid string, UserID uuid.UUID) error {
+	result := s.DB.Unscoped().Model(&models.Material{}).
+		Where("ul_id = ? AND user_id = ? AND deleted_at IS NOT NULL", ulid, UserID).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// PurgeMaterials は教材を完全に削除する。単語・フレーズ・チャットのリストと字幕のキューは外部キーの
+// ON DELETE CASCADE で消え、外部キーのない訳・版・タグ・コレクションの項目はここで消す。使用量の記録と
+// フィードの記事の記録 (重複の判定に使う) は残す
+func (s *materialStore) PurgeMaterials(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteTranslations(tx, ids); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("material_id IN ?", ids).Delete(&models.MaterialRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("material_id IN ?", ids).Delete(&models.MaterialTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("material_id IN ?", ids).Delete(&models.CollectionMaterial{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.FeedEntry{}).Where("material_id IN ?", ids).
+			Updates(map[string]interface{}{"material_id": nil, "material_ul_id": ""}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Material{}).Error
+	})
+}
+
+// GetMaterialIDs はユーザーの教材 (ゴミ箱を除く) の ULID -> ID を返す。見つからない ULID は含まない
+func (s *materialStore) GetMaterialIDs(ulids []string, UserID uuid.UUID) (map[string]uint, error) {
+	var rows []struct {
+		ID   uint
+		ULID string
+	}
+	err := s.DB.Model(&models.Material{}).
+		Select("id, ul_id").
+		Where("ul_id IN ? AND user_id = ?", ulids, UserID).
+		Scan(&rows).Error
+	ids := make(map[string]uint, len(rows))
+	for _, row := range rows {
+		ids[row.ULID] = row.ID
+	}
+	return ids, err
+}
+
+// ExportMaterials は scope の教材を単語・フレーズ・訳・タグ付きで作成順に返す
+func (s *materialStore) ExportMaterials(UserID uuid.UUID, scope models.MaterialScope) ([]models.Material, error) {
+	db := s.DB.
+		Preload("WordLists.Words.Translations").
+		Preload("PhraseLists.Phrases.Translations").
+		Where("user_id = ?", UserID)
+	if condition, args := materialScopeSQL("id", UserID, scope); condition != "" {
+		db = db.Where(condition, args)
+	}
+	var materials []models.Material
+	err := db.Order("created_at, id").Find(&materials).Error
+	return materials, err
+}
@@ -0,0 +1,106 @@
+package stores_mock
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/yomek33/newln/internal/models"
+	"gorm.io/gorm"
+)
+
+type MockCollectionStore struct {
+	Collections map[uint]*models.Collection
+	// コレクションの ID -> 教材の ID (並び順)
+	Items     map[uint][]uint
+	Materials *MockMaterialStore
+	nextID    uint
+}
+
+func NewMockCollectionStore(materials *MockMaterialStore) *MockCollectionStore {
+	return &MockCollectionStore{
+		Collections: make(map[uint]*models.Collection),
+		Items:       make(map[uint][]uint),
+		Materials:   materials,
+	}
+}
+
+func (m *MockCollectionStore) ListCollections(userID uuid.UUID) ([]models.Collection, error) {
+	var collections []models.Collection
+	for _, collection := range m.Collections {
+		if collection.UserID == userID {
+			collections = append(collections, *collection)
+		}
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		a, b := collections[i], collections[j]
+		if (a.ParentID == nil) != (b.ParentID == nil) {
+			return a.ParentID == nil
+		}
+		if a.ParentID != nil && *a.ParentID != *b.ParentID {
+			return *a.ParentID < *b.ParentID
+		}
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return a.ID < b.ID
+	})
+	return collections, nil
+}
+
+func (m *MockCollectionStore) GetCollection(userID uuid.UUID, id uint) (*models.Collection, error) {
+	collection, ok := m.Collections[id]
+	if !ok || collection.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *collection
+	return &copied, nil
+}
+
+func (m *MockCollectionStore) CreateCollection(collection *models.Collection) error {
+	m.nextID++
+	collection.ID = m.nextID
+	copied := *collection
+	m.Collections[collection.ID] = &copied
+	return nil
+}
+
+func (m *MockCollectionStore) UpdateCollection(collection *models.Collection) error {
+	stored, ok := m.Collections[collection.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	stored.Name, stored.ParentID, stored.Position = collection.Name, collection.ParentID, collection.Position
+	return nil
+}
+
+// DeleteCollection は配下のコレクションごと削除する
+func (m *MockCollectionStore) DeleteCollection(userID uuid.UUID, id uint) error {
+	collection, ok := m.Collections[id]
+	if !ok || collection.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(m.Collections, id)
+	delete(m.Items, id)
+	for childID, child := range m.Collections {
+		if child.ParentID != nil && *child.ParentID == id {
+			m.DeleteCollection(userID, childID)
+		}
+	}
+	return nil
+}
+
+func (m *MockCollectionStore) ListCollectionMaterials(collectionID uint) ([]models.MaterialSummary, error) {
+	var materials []models.MaterialSummary
+	for _, id := range m.Items[collectionID] {
+		if material, ok := m.Materials.Materials[id]; ok {
+			materials = append(materials, models.MaterialSummary{ID: material.ID, ULID: material.ULID, Title: material.Title})
+		}
+	}
+	return materials, nil
+}
+
+func (m *MockCollectionStore) SetCollectionMaterials(collectionID uint, materialIDs []uint) error {
+	m.Items[collectionID] = slices.Clone(materialIDs)
+	return nil
+}
@@ -0,0 +1,33 @@
+package stores_mock
+
+import (
+	"slices"
+
+	"github.com/yomek33/newln/internal/models"
+)
+
+type MockDictionaryStore struct {
+	Entries []models.DictionaryEntry
+	// FindEntries に渡された見出し語
+	Lookups [][]string
+}
+
+func NewMockDictionaryStore(entries ...models.DictionaryEntry) *MockDictionaryStore {
+	return &MockDictionaryStore{Entries: entries}
+}
+
+func (m *MockDictionaryStore) UpsertEntries(entries []models.DictionaryEntry) error {
+	m.Entries = append(m.Entries, entries...)
+	return nil
+}
+
+func (m *MockDictionaryStore) FindEntries(language string, lemmas []string) ([]models.DictionaryEntry, error) {
+	m.Lookups = append(m.Lookups, lemmas)
+	var found []models.DictionaryEntry
+	for _, entry := range m.Entries {
+		if entry.Language == language && slices.Contains(lemmas, entry.Lemma) {
+			found = append(found, entry)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,149 @@
+package stores_mock
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yomek33/newln/internal/models"
+	"gorm.io/gorm"
+)
+
+type MockFeedStore struct {
+	Subscriptions map[uint]*models.FeedSubscription
+	Entries       []models.FeedEntry
+	nextID        uint
+}
+
+func NewMockFeedStore() *MockFeedStore {
+	return &MockFeedStore{Subscriptions: make(map[uint]*models.FeedSubscription)}
+}
+
+func (m *MockFeedStore) ListSubscriptions(userID uuid.UUID) ([]models.FeedSubscription, error) {
+	var subscriptions []models.FeedSubscription
+	for _, subscription := range m.Subscriptions {
+		if subscription.UserID == userID {
+			subscriptions = append(subscriptions, *subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].ID < subscriptions[j].ID })
+	return subscriptions, nil
+}
+
+func (m *MockFeedStore) GetSubscription(userID uuid.UUID, id uint) (*models.FeedSubscription, error) {
+	subscription, ok := m.Subscriptions[id]
+	if !ok || subscription.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *subscription
+	return &copied, nil
+}
+
+func (m *MockFeedStore) CreateSubscription(subscription *models.FeedSubscription) error {
+	m.nextID++
+	subscription.ID = m.nextID
+	copied := *subscription
+	m.Subscriptions[subscription.ID] = &copied
+	return nil
+}
+
+// UpdateSubscription は設定と次の取得の時刻だけを保存する (取得の状態は SavePollState)
+func (m *MockFeedStore) UpdateSubscription(subscription *models.FeedSubscription) error {
+	saved, ok := m.Subscriptions[subscription.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	copied := *subscription
+	copied.LastPolledAt = saved.LastPolledAt
+	copied.LastError = saved.LastError
+	m.Subscriptions[subscription.ID] = &copied
+	return nil
+}
+
+func (m *MockFeedStore) SavePollState(subscription *models.FeedSubscription) error {
+	saved, ok := m.Subscriptions[subscription.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	saved.NextPollAt = subscription.NextPollAt
+	saved.LastPolledAt = subscription.LastPolledAt
+	saved.LastError = subscription.LastError
+	if saved.Title == "" {
+		saved.Title = subscription.Title
+	}
+	return nil
+}
+
+func (m *MockFeedStore) DeleteSubscription(userID uuid.UUID, id uint) error {
+	subscription, ok := m.Subscriptions[id]
+	if !ok || subscription.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(m.Subscriptions, id)
+	entries := m.Entries[:0]
+	for _, entry := range m.Entries {
+		if entry.SubscriptionID != id {
+			entries = append(entries, entry)
+		}
+	}
+	m.Entries = entries
+	return nil
+}
+
+func (m *MockFeedStore) ListDueSubscriptions(now time.Time, limit int) ([]models.FeedSubscription, error) {
+	var subscriptions []models.FeedSubscription
+	for _, subscription := range m.Subscriptions {
+		if subscription.Active && !subscription.NextPollAt.After(now) {
+			subscriptions = append(subscriptions, *subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].ID < subscriptions[j].ID })
+	if len(subscriptions) > limit {
+		subscriptions = subscriptions[:limit]
+	}
+	return subscriptions, nil
+}
+
+func (m *MockFeedStore) HasEntry(subscriptionID uint, guid string) (bool, error) {
+	for _, entry := range m.Entries {
+		if entry.SubscriptionID == subscriptionID && entry.GUID == guid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MockFeedStore) HasContentHash(userID uuid.UUID, hash string) (bool, error) {
+	for _, entry := range m.Entries {
+		if entry.UserID == userID && entry.ContentHash == hash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MockFeedStore) CountCreatedSince(subscriptionID uint, since time.Time) (int64, error) {
+	var count int64
+	for _, entry := range m.Entries {
+		if entry.SubscriptionID == subscriptionID && entry.Status == models.FeedEntryCreated && !entry.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MockFeedStore) CreateEntry(entry *models.FeedEntry) error {
+	entry.ID = uint(len(m.Entries) + 1)
+	m.Entries = append(m.Entries, *entry)
+	return nil
+}
+
+func (m *MockFeedStore) ListEntries(subscriptionID uint, limit int) ([]models.FeedEntry, error) {
+	var entries []models.FeedEntry
+	for i := len(m.Entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		if m.Entries[i].SubscriptionID == subscriptionID {
+			entries = append(entries, m.Entries[i])
+		}
+	}
+	return entries, nil
+}
@@ -2,26 +2,76 @@ package stores_mock
 
 import (
 	"errors"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/yomek33/newln/internal/models"
+	"gorm.io/gorm"
 )
 
 type MockMaterialStore struct {
 	Materials map[uint]*models.Material
+	Revisions []models.MaterialRevision
+	// DeleteMaterial でゴミ箱に入った教材
+	Trash map[uint]*models.Material
+	// CreateMaterial の記録。完全に削除しても残る
+	Creations []models.MaterialCreation
 }
 
 func NewMockMaterialStore() *MockMaterialStore {
 	return &MockMaterialStore{
 		Materials: make(map[uint]*models.Material),
+		Trash:     make(map[uint]*models.Material),
 	}
 }
 
 func (m *MockMaterialStore) CreateMaterial(material *models.Material) (*models.Material, error) {
+	if material.ID == 0 {
+		for id := range m.Materials {
+			material.ID = max(material.ID, id)
+		}
+		for id := range m.Trash {
+			material.ID = max(material.ID, id)
+		}
+		material.ID++
+	}
+	if material.CreatedAt.IsZero() {
+		material.CreatedAt = time.Now()
+	}
 	m.Materials[material.ID] = material
+	m.Revisions = append(m.Revisions, baseRevision(material))
+	m.Creations = append(m.Creations, models.MaterialCreation{
+		UserID:     material.UserID,
+		MaterialID: material.ID,
+		CreatedAt:  material.CreatedAt,
+	})
 	return material, nil
 }
 
+func (m *MockMaterialStore) CreateMaterialWithinLimit(material *models.Material, since time.Time, limit int64) (bool, error) {
+	if limit > 0 {
+		count, _ := m.CountMaterialsCreatedSince(material.UserID, since)
+		if count >= limit {
+			return false, nil
+		}
+	}
+	_, err := m.CreateMaterial(material)
+	return err == nil, err
+}
+
+func baseRevision(material *models.Material) models.MaterialRevision {
+	return models.MaterialRevision{
+		MaterialID:     material.ID,
+		Version:        material.Version,
+		AuthorID:       material.UserID,
+		Title:          material.Title,
+		Content:        material.Content,
+		SourceLanguage: material.SourceLanguage,
+	}
+}
+
 func (m *MockMaterialStore) GetMaterialByULID(ulid string, UserID uuid.UUID) (*models.Material, error) {
 	for _, material := range m.Materials {
 		if material.ULID == ulid && material.UserID == UserID {
@@ -38,19 +88,79 @@ func (m *MockMaterialStore) GetMaterialByID(id uint) (*models.Material, error) {
 	return nil, errors.New("material not found")
 }
 
-func (m *MockMaterialStore) UpdateMaterial(ulid string, material *models.Material) error {
-	for id, mat := range m.Materials {
-		if mat.ULID == ulid {
-			m.Materials[id] = material
-			return nil
+func (m *MockMaterialStore) UpdateMaterialFields(ulid string, UserID uuid.UUID, version int, fields map[string]interface{}, revision *models.MaterialRevision) (bool, error) {
+	for _, material := range m.Materials {
+		if material.ULID != ulid || material.UserID != UserID || material.Version != version {
+			continue
+		}
+		if revision != nil && !slices.ContainsFunc(m.Revisions, func(r models.MaterialRevision) bool { return r.MaterialID == material.ID }) {
+			m.Revisions = append(m.Revisions, baseRevision(material))
+		}
+		for field, value := range fields {
+			switch field {
+			case "title":
+				material.Title = value.(string)
+			case "content":
+				material.Content = value.(string)
+				material.SubtitleCues = nil
+			case "source_language":
+				material.SourceLanguage = value.(string)
+			case "status":
+				material.Status = value.(string)
+			case "word_count":
+				material.WordCount = value.(int)
+			}
+		}
+		material.Version++
+		if revision != nil {
+			revision.MaterialID = material.ID
+			revision.Version = material.Version
+			m.Revisions = append(m.Revisions, *revision)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *MockMaterialStore) ListRevisions(materialID uint) ([]models.MaterialRevision, error) {
+	var revisions []models.MaterialRevision
+	for _, revision := range m.Revisions {
+		if revision.MaterialID == materialID {
+			revision.Content = ""
+			revision.Diff = ""
+			revisions = append(revisions, revision)
 		}
 	}
-	return errors.New("material not found")
+	slices.SortFunc(revisions, func(a, b models.MaterialRevision) int { return b.Version - a.Version })
+	return revisions, nil
+}
+
+func (m *MockMaterialStore) GetRevision(materialID uint, version int) (*models.MaterialRevision, error) {
+	for _, revision := range m.Revisions {
+		if revision.MaterialID == materialID && revision.Version == version {
+			return &revision, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockMaterialStore) ResetDerivedLists(materialID uint) error {
+	material, exists := m.Materials[materialID]
+	if !exists {
+		return errors.New("material not found")
+	}
+	material.WordLists = nil
+	material.PhraseLists = nil
+	material.HasPendingWordList = true
+	material.HasPendingPhraseList = true
+	return nil
 }
 
 func (m *MockMaterialStore) DeleteMaterial(ulid string, UserID uuid.UUID) error {
 	for id, material := range m.Materials {
 		if material.ULID == ulid && material.UserID == UserID {
+			material.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+			m.Trash[id] = material
 			delete(m.Materials, id)
 			return nil
 		}
@@ -58,12 +168,78 @@ func (m *MockMaterialStore) DeleteMaterial(ulid string, UserID uuid.UUID) error
 	return errors.New("material not found")
 }
 
-func (m *MockMaterialStore) GetAllMaterials(searchQuery string, UserID uuid.UUID) ([]models.Material, error) {
-	var materials []models.Material
+// ListMaterials はタグ・コレクションの絞り込み (MaterialScope) を見ない
+func (m *MockMaterialStore) ListMaterials(query models.MaterialQuery) ([]models.MaterialSummary, error) {
+	var materials []models.MaterialSummary
 	for _, material := range m.Materials {
-		if material.UserID == UserID {
-			materials = append(materials, *material)
+		if material.UserID != query.UserID {
+			continue
+		}
+		if query.Search != "" && !strings.Contains(strings.ToLower(material.Title), strings.ToLower(query.Search)) {
+			continue
+		}
+		if len(query.Statuses) > 0 && !slices.Contains(query.Statuses, material.Status) {
+			continue
 		}
+		if query.CreatedFrom != nil && material.CreatedAt.Before(*query.CreatedFrom) {
+			continue
+		}
+		if query.CreatedTo != nil && !material.CreatedAt.Before(*query.CreatedTo) {
+			continue
+		}
+		pending := material.HasPendingWordList || material.HasPendingPhraseList
+		if query.Generation == models.GenerationPending && !pending || query.Generation == models.GenerationReady && pending {
+			continue
+		}
+		materials = append(materials, models.MaterialSummary{
+			ID:                   material.ID,
+			ULID:                 material.ULID,
+			Title:                material.Title,
+			SourceLanguage:       material.SourceLanguage,
+			Status:               material.Status,
+			Summary:              material.Summary,
+			WordCount:            material.WordCount,
+			WordsCount:           material.WordsCount,
+			PhrasesCount:         material.PhrasesCount,
+			HasPendingWordList:   material.HasPendingWordList,
+			HasPendingPhraseList: material.HasPendingPhraseList,
+			CreatedAt:            material.CreatedAt,
+			UpdatedAt:            material.UpdatedAt,
+		})
+	}
+
+	compare := func(a, b models.MaterialSummary) int {
+		var c int
+		switch query.Sort {
+		case models.MaterialSortUpdated:
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		case models.MaterialSortTitle:
+			c = strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		case models.MaterialSortWordCount:
+			c = a.WordCount - b.WordCount
+		default:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = strings.Compare(a.ULID, b.ULID)
+		}
+		if query.Desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(materials, compare)
+
+	if query.After != nil {
+		for i, material := range materials {
+			if material.ULID == query.After.ULID {
+				materials = materials[i+1:]
+				break
+			}
+		}
+	}
+	if query.Limit > 0 && len(materials) > query.Limit {
+		materials = materials[:query.Limit]
 	}
 	return materials, nil
 }
@@ -103,3 +279,102 @@ func (m *MockMaterialStore) UpdateHasPendingWordStatus(ulid string, status bool)
 func (m *MockMaterialStore) UpdateHasPendingPhraseStatus(ulid string, status bool) error {
 	return nil
 }
+
+func (m *MockMaterialStore) CountMaterialsCreatedSince(UserID uuid.UUID, since time.Time) (int64, error) {
+	var count int64
+	for _, creation := range m.Creations {
+		if creation.UserID == UserID && !creation.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func trashedMaterial(material *models.Material) models.TrashedMaterial {
+	return models.TrashedMaterial{
+		MaterialSummary: models.MaterialSummary{
+			ID:             material.ID,
+			ULID:           material.ULID,
+			Title:          material.Title,
+			SourceLanguage: material.SourceLanguage,
+			Status:         material.Status,
+			CreatedAt:      material.CreatedAt,
+			UpdatedAt:      material.UpdatedAt,
+		},
+		DeletedAt: material.DeletedAt.Time,
+	}
+}
+
+func (m *MockMaterialStore) ListTrash(UserID uuid.UUID) ([]models.TrashedMaterial, error) {
+	var materials []models.TrashedMaterial
+	for _, material := range m.Trash {
+		if material.UserID == UserID {
+			materials = append(materials, trashedMaterial(material))
+		}
+	}
+	slices.SortFunc(materials, func(a, b models.TrashedMaterial) int { return b.DeletedAt.Compare(a.DeletedAt) })
+	return materials, nil
+}
+
+func (m *MockMaterialStore) GetTrashedMaterial(ulid string, UserID uuid.UUID) (*models.TrashedMaterial, error) {
+	for _, material := range m.Trash {
+		if material.ULID == ulid && material.UserID == UserID {
+			trashed := trashedMaterial(material)
+			return &trashed, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockMaterialStore) ListTrashedBefore(cutoff time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	for id, material := range m.Trash {
+		if material.DeletedAt.Time.Before(cutoff) && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MockMaterialStore) RestoreMaterial(ulid string, UserID uuid.UUID) error {
+	for id, material := range m.Trash {
+		if material.ULID == ulid && material.UserID == UserID {
+			material.DeletedAt = gorm.DeletedAt{}
+			m.Materials[id] = material
+			delete(m.Trash, id)
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (m *MockMaterialStore) PurgeMaterials(ids []uint) error {
+	for _, id := range ids {
+		delete(m.Trash, id)
+		delete(m.Materials, id)
+	}
+	m.Revisions = slices.DeleteFunc(m.Revisions, func(r models.MaterialRevision) bool { return slices.Contains(ids, r.MaterialID) })
+	return nil
+}
+
+func (m *MockMaterialStore) GetMaterialIDs(ulids []string, UserID uuid.UUID) (map[string]uint, error) {
+	ids := make(map[string]uint)
+	for id, material := range m.Materials {
+		if material.UserID == UserID && slices.Contains(ulids, material.ULID) {
+			ids[material.ULID] = id
+		}
+	}
+	return ids, nil
+}
+
+// ExportMaterials は scope を見ずにユーザーの教材を作成順に返す
+func (m *MockMaterialStore) ExportMaterials(UserID uuid.UUID, scope models.MaterialScope) ([]models.Material, error) {
+	var materials []models.Material
+	for _, material := range m.Materials {
+		if material.UserID == UserID {
+			materials = append(materials, *material)
+		}
+	}
+	slices.SortFunc(materials, func(a, b models.Material) int { return int(a.ID) - int(b.ID) })
+	return materials, nil
+}
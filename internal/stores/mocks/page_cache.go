@@ -0,0 +1,30 @@
+package stores_mock
+
+import (
+	"time"
+
+	"github.com/yomek33/newln/internal/models"
+	"gorm.io/gorm"
+)
+
+type MockPageCacheStore struct {
+	Pages map[string]*models.FetchedPage
+}
+
+func NewMockPageCacheStore() *MockPageCacheStore {
+	return &MockPageCacheStore{Pages: make(map[string]*models.FetchedPage)}
+}
+
+func (m *MockPageCacheStore) GetPage(url string, notBefore time.Time) (*models.FetchedPage, error) {
+	page, ok := m.Pages[url]
+	if !ok || page.FetchedAt.Before(notBefore) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return page, nil
+}
+
+func (m *MockPageCacheStore) SavePage(page *models.FetchedPage) error {
+	copied := *page
+	m.Pages[page.URL] = &copied
+	return nil
+}
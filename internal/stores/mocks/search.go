@@ -0,0 +1,36 @@
+package stores_mock
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/yomek33/newln/internal/models"
+)
+
+// MockSearchStore は Hits のうち種類が一致するものを返す
+type MockSearchStore struct {
+	Hits []models.SearchHit
+	// Search に渡された引数
+	Queries []string
+	Types   [][]string
+	Limits  []int
+	Scopes  []models.MaterialScope
+}
+
+func NewMockSearchStore(hits ...models.SearchHit) *MockSearchStore {
+	return &MockSearchStore{Hits: hits}
+}
+
+func (m *MockSearchStore) Search(userID uuid.UUID, q string, types []string, scope models.MaterialScope, limit int) ([]models.SearchHit, error) {
+	m.Scopes = append(m.Scopes, scope)
+	m.Queries = append(m.Queries, q)
+	m.Types = append(m.Types, types)
+	m.Limits = append(m.Limits, limit)
+	var hits []models.SearchHit
+	for _, hit := range m.Hits {
+		if slices.Contains(types, hit.Type) && len(hits) < limit {
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
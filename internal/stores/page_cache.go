@@ -0,0 +1,38 @@
+package stores
+
+import (
+	"time"
+
+	"github.com/yomek33/newln/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PageCacheStore interface {
+	GetPage(url string, notBefore time.Time) (*models.FetchedPage, error)
+	SavePage(page *models.FetchedPage) error
+}
+
+type pageCacheStore struct {
+	DB *gorm.DB
+}
+
+func NewPageCacheStore(db *gorm.DB) PageCacheStore {
+	return &pageCacheStore{DB: db}
+}
+
+// GetPage は notBefore 以降に取得した url のページを返す。なければ gorm.ErrRecordNotFound
+func (s *pageCacheStore) GetPage(url string, notBefore time.Time) (*models.FetchedPage, error) {
+	var page models.FetchedPage
+	err := s.DB.Where("url = ? AND fetched_at >= ?", url, notBefore).First(&page).Error
+	return &page, err
+}
+
+// SavePage は url ごとに1つのページを保存する (あれば上書き)
+func (s *pageCacheStore) SavePage(page *models.FetchedPage) error {
+	return s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "url"}},
+		DoUpdates: clause.AssignmentColumns([]string{"final_url", "title", "content", "fetched_at"}),
+	}).Create(page).Error
+}
@@ -0,0 +1,39 @@
+package stores
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yomek33/newln/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// materialScopeSQL は column (教材の id) を scope で絞る条件と、その named 引数を返す。
+// 絞り込みがなければ空文字列
+func materialScopeSQL(column string, userID uuid.UUID, scope models.MaterialScope) (string, map[string]interface{}) {
+	var conditions []string
+	args := map[string]interface{}{"scope_user": userID}
+
+	if len(scope.Tags) > 0 {
+		conditions = append(conditions, fmt.Sprintf(`%s IN (
+			SELECT material_tags.material_id FROM material_tags
+			JOIN tags ON tags.id = material_tags.tag_id
+			WHERE tags.user_id = @scope_user AND tags.deleted_at IS NULL AND tags.name IN @scope_tags
+			GROUP BY material_tags.material_id
+			HAVING COUNT(DISTINCT tags.id) = @scope_tag_count)`, column))
+		args["scope_tags"] = scope.Tags
+		args["scope_tag_count"] = len(scope.Tags)
+	}
+	if scope.CollectionID != 0 {
+		conditions = append(conditions, fmt.Sprintf(`%s IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM collections WHERE id = @scope_collection AND user_id = @scope_user AND deleted_at IS NULL
+				UNION ALL
+				SELECT collections.id FROM collections JOIN tree ON collections.parent_id = tree.id WHERE collections.deleted_at IS NULL
+			)
+			SELECT collection_materials.material_id FROM collection_materials JOIN tree ON tree.id = collection_materials.collection_id)`, column))
+		args["scope_collection"] = scope.CollectionID
+	}
+	return strings.Join(conditions, " AND "), args
+}
@@ -0,0 +1,114 @@
+package stores
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/yomek33/newln/internal/models"
+	"github.com/yomek33/newln/internal/models/migrations"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ts_headline の抜粋の形。一致箇所は models.SnippetStart/SnippetStop で囲む (HTML にはしない)
+const searchHeadlineOptions = "StartSel=" + models.SnippetStart + ", StopSel=" + models.SnippetStop + ", MaxWords=20, MinWords=6, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// 種類ごとの検索。? はユーザー ID と検索語。
+// 抜粋は順位づけの後に上位だけ作る (本文の ts_headline は重い)
+var searchQueries = map[string]string{
+	models.SearchTypeMaterial: `SELECT 'material' AS type, m.id AS item_id, m.id AS material_id, m.ul_id AS material_ulid, m.title AS material_title,
+			m.title AS text, concat_ws(' ', m.summary, m.content) AS document, c.config, q.query,
+			ts_rank_cd(m.search_vector, q.query) AS rank
+		FROM materials m
+		CROSS JOIN LATERAL (SELECT ` + strings.ReplaceAll(migrations.MaterialSearchConfig, "source_language", "m.source_language") + ` AS config) c
+		CROSS JOIN LATERAL (SELECT websearch_to_tsquery(c.config, @q) AS query) q
+		WHERE m.user_id = @user AND m.deleted_at IS NULL AND m.search_vector @@ q.query`,
+	models.SearchTypeWord: `SELECT 'word' AS type, w.id AS item_id, m.id AS material_id, m.ul_id AS material_ulid, m.title AS material_title,
+			w.text, concat_ws(' — ', w.text, w.meaning, tr.meanings) AS document, ` + migrations.ItemSearchConfig + ` AS config, q.query,
+			ts_rank_cd(w.search_vector || ` + translationVector + `, q.query) AS rank
+		FROM (` + matchedItems("words", models.TranslationItemWord) + `) matched
+		JOIN words w ON w.id = matched.id
+		JOIN word_lists l ON l.id = w.word_list_id AND l.deleted_at IS NULL
+		JOIN materials m ON m.id = l.material_id AND m.deleted_at IS NULL
+		CROSS JOIN LATERAL (SELECT websearch_to_tsquery(` + migrations.ItemSearchConfig + `, @q) AS query) q
+		LEFT JOIN LATERAL (` + itemTranslations("w", models.TranslationItemWord) + `) tr ON TRUE
+		WHERE m.user_id = @user AND w.deleted_at IS NULL`,
+	models.SearchTypePhrase: `SELECT 'phrase' AS type, p.id AS item_id, m.id AS material_id, m.ul_id AS material_ulid, m.title AS material_title,
+			p.text, concat_ws(' — ', p.text, p.meaning, tr.meanings, p.example) AS document, ` + migrations.ItemSearchConfig + ` AS config, q.query,
+			ts_rank_cd(p.search_vector || ` + translationVector + `, q.query) AS rank
+		FROM (` + matchedItems("phrases", models.TranslationItemPhrase) + `) matched
+		JOIN phrases p ON p.id = matched.id
+		JOIN phrase_lists l ON l.id = p.phrase_list_id AND l.deleted_at IS NULL
+		JOIN materials m ON m.id = l.material_id AND m.deleted_at IS NULL
+		CROSS JOIN LATERAL (SELECT websearch_to_tsquery(` + migrations.ItemSearchConfig + `, @q) AS query) q
+		LEFT JOIN LATERAL (` + itemTranslations("p", models.TranslationItemPhrase) + `) tr ON TRUE
+		WHERE m.user_id = @user AND p.deleted_at IS NULL`,
+}
+
+// 訳語は意味と同じ重み (B) で順位に入れる
+const translationVector = `setweight(to_tsvector(` + migrations.ItemSearchConfig + `, coalesce(tr.meanings, '')), 'B')`
+
+// matchedItems は本文か訳語が検索語に一致する table の ID。どちらも GIN 索引を使う
+func matchedItems(table, itemType string) string {
+	query := `websearch_to_tsquery(` + migrations.ItemSearchConfig + `, @q)`
+	return `SELECT id FROM ` + table + ` WHERE search_vector @@ ` + query + `
+			UNION
+			SELECT item_id FROM translations
+			WHERE item_type = '` + itemType + `' AND deleted_at IS NULL AND ` + migrations.TranslationSearchVector + ` @@ ` + query
+}
+
+// itemTranslations は alias の項目の訳語をまとめた meanings
+func itemTranslations(alias, itemType string) string {
+	return `SELECT string_agg(t.meaning, ' ' ORDER BY t.language) AS meanings FROM translations t
+			WHERE t.item_type = '` + itemType + `' AND t.item_id = ` + alias + `.id AND t.deleted_at IS NULL`
+}
+
+type SearchStore interface {
+	Search(userID uuid.UUID, q string, types []string, scope models.MaterialScope, limit int) ([]models.SearchHit, error)
+}
+
+type searchStore struct {
+	DB *gorm.DB
+}
+
+func NewSearchStore(db *gorm.DB) SearchStore {
+	return &searchStore{DB: db}
+}
+
+// Search は scope の教材から types の種類をまとめて順位の高い順に limit 件返す
+func (s *searchStore) Search(userID uuid.UUID, q string, types []string, scope models.MaterialScope, limit int) ([]models.SearchHit, error) {
+	var parts []string
+	for _, t := range types {
+		query, ok := searchQueries[t]
+		if !ok {
+			return nil, errors.New("invalid search type: " + t)
+		}
+		parts = append(parts, query)
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	condition, args := materialScopeSQL("material_id", userID, scope)
+	if condition == "" {
+		condition = "TRUE"
+	}
+	sql := `WITH hits AS (` + strings.Join(parts, "\nUNION ALL\n") + `),
+		top AS (SELECT * FROM hits WHERE ` + condition + ` ORDER BY rank DESC, type, item_id LIMIT @limit)
+		SELECT type, item_id, material_ulid, material_title, text,
+			ts_headline(config, translate(document, @delimiters, ''), query, @options) AS snippet, rank
+		FROM top
+		ORDER BY rank DESC, type, item_id`
+
+	args["user"] = userID
+	args["q"] = q
+	args["limit"] = limit
+	args["options"] = searchHeadlineOptions
+	// 本文に区切りの文字があれば落として、一致箇所と取り違えないようにする
+	args["delimiters"] = models.SnippetStart + models.SnippetStop
+
+	var hits []models.SearchHit
+	err := s.DB.Raw(sql, args).Scan(&hits).Error
+	return hits, err
+}
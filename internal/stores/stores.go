@@ -5,19 +5,33 @@ import (
 )
 
 type Stores struct {
-	DB            *gorm.DB
-	UserStore     UserStore
-	MaterialStore MaterialStore
-	PhraseStore   PhraseStore
-	WordStore     WordStore
+	DB              *gorm.DB
+	UserStore       UserStore
+	MaterialStore   MaterialStore
+	PhraseStore     PhraseStore
+	WordStore       WordStore
+	UsageStore      UsageStore
+	DictionaryStore DictionaryStore
+	SearchStore     SearchStore
+	TagStore        TagStore
+	CollectionStore CollectionStore
+	PageCacheStore  PageCacheStore
+	FeedStore       FeedStore
 }
 
 func NewStores(db *gorm.DB) *Stores {
 	return &Stores{
-		DB:            db,
-		UserStore:     NewUserStore(db),
-		MaterialStore: NewMaterialStore(db),
-		PhraseStore:   NewPhraseStore(db),
-		WordStore:     NewWordStore(db),
+		DB:              db,
+		UserStore:       NewUserStore(db),
+		MaterialStore:   NewMaterialStore(db),
+		PhraseStore:     NewPhraseStore(db),
+		WordStore:       NewWordStore(db),
+		UsageStore:      NewUsageStore(db),
+		DictionaryStore: NewDictionaryStore(db),
+		SearchStore:     NewSearchStore(db),
+		TagStore:        NewTagStore(db),
+		CollectionStore: NewCollectionStore(db),
+		PageCacheStore:  NewPageCacheStore(db),
+		FeedStore:       NewFeedStore(db),
 	}
 }
@@ -0,0 +1,106 @@
+package stores
+
+import (
+	"github.com/yomek33/newln/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagStore interface {
+	ListTags(userID uuid.UUID) ([]models.TagCount, error)
+	RenameTag(userID uuid.UUID, id uint, name string) error
+	DeleteTag(userID uuid.UUID, id uint) error
+	SetMaterialTags(userID uuid.UUID, materialID uint, names []string) error
+	GetMaterialTags(materialID uint) ([]string, error)
+}
+
+type tagStore struct {
+	DB *gorm.DB
+}
+
+func NewTagStore(db *gorm.DB) TagStore {
+	return &tagStore{DB: db}
+}
+
+// ListTags はユーザーのタグを名前順に、付いている教材 (ゴミ箱を除く) の数と返す
+func (s *tagStore) ListTags(userID uuid.UUID) ([]models.TagCount, error) {
+	var tags []models.TagCount
+	err := s.DB.Model(&models.Tag{}).
+		Select("tags.id, tags.name, COUNT(materials.id) AS material_count").
+		Joins("LEFT JOIN material_tags ON material_tags.tag_id = tags.id").
+		Joins("LEFT JOIN materials ON materials.id = material_tags.material_id AND materials.deleted_at IS NULL").
+		Where("tags.user_id = ?", userID).
+		Group("tags.id, tags.name").
+		Order("tags.name").
+		Scan(&tags).Error
+	return tags, err
+}
+
+// RenameTag はタグの名前を変える。見つからなければ gorm.ErrRecordNotFound
+func (s *tagStore) RenameTag(userID uuid.UUID, id uint, name string) error {
+	result := s.DB.Model(&models.Tag{}).Where("id = ? AND user_id = ?", id, userID).Update("name", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteTag はタグを完全に削除する。教材との紐付けは外部キーの ON DELETE CASCADE で消える
+func (s *tagStore) DeleteTag(userID uuid.UUID, id uint) error {
+	result := s.DB.Unscoped().Where("id = ? AND user_id = ?", id, userID).Delete(&models.Tag{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetMaterialTags は教材のタグを names に置き換える。ないタグは作る
+func (s *tagStore) SetMaterialTags(userID uuid.UUID, materialID uint, names []string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("material_id = ?", materialID).Delete(&models.MaterialTag{}).Error; err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return nil
+		}
+
+		tags := make([]models.Tag, len(names))
+		for i, name := range names {
+			tags[i] = models.Tag{UserID: userID, Name: name}
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "name"}},
+			DoNothing: true,
+		}).Create(&tags).Error; err != nil {
+			return err
+		}
+
+		var ids []uint
+		if err := tx.Model(&models.Tag{}).Where("user_id = ? AND name IN ?", userID, names).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		links := make([]models.MaterialTag, len(ids))
+		for i, id := range ids {
+			links[i] = models.MaterialTag{MaterialID: materialID, TagID: id}
+		}
+		return tx.Create(&links).Error
+	})
+}
+
+func (s *tagStore) GetMaterialTags(materialID uint) ([]string, error) {
+	var names []string
+	err := s.DB.Model(&models.Tag{}).
+		Joins("JOIN material_tags ON material_tags.tag_id = tags.id").
+		Where("material_tags.material_id = ?", materialID).
+		Order("tags.name").
+		Pluck("tags.name", &names).Error
+	return names, err
+}
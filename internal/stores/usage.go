@@ -0,0 +1,93 @@
+package stores
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yomek33/newln/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const usageTotalsSelect = `COUNT(*) AS calls,
+	COUNT(*) FILTER (WHERE outcome <> 'success') AS failed_calls,
+	COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+	COALESCE(SUM(response_tokens), 0) AS response_tokens,
+	COALESCE(SUM(total_tokens), 0) AS total_tokens,
+	COALESCE(SUM(cost_usd), 0) AS cost_usd`
+
+type UsageStore interface {
+	CreateUsage(usage *models.LLMUsage) error
+	GetDailyUsageByUser(userID uuid.UUID, from, to time.Time) ([]models.DailyUsage, error)
+	GetUsageByMaterial(materialID uint) ([]models.OperationUsage, error)
+	GetUsageTotalsByUser(from, to time.Time) ([]models.UserUsage, error)
+	GetUsageTotals(from, to time.Time) (*models.UsageTotals, error)
+	GetUserTokensSince(userID uuid.UUID, since time.Time) (int64, error)
+}
+
+type usageStore struct {
+	DB *gorm.DB
+}
+
+func NewUsageStore(db *gorm.DB) UsageStore {
+	return &usageStore{DB: db}
+}
+
+func (s *usageStore) CreateUsage(usage *models.LLMUsage) error {
+	if usage == nil {
+		return errors.New("usage cannot be nil")
+	}
+	return s.DB.Create(usage).Error
+}
+
+func (s *usageStore) GetDailyUsageByUser(userID uuid.UUID, from, to time.Time) ([]models.DailyUsage, error) {
+	var usages []models.DailyUsage
+	err := s.DB.Model(&models.LLMUsage{}).
+		Select("date_trunc('day', created_at) AS day, "+usageTotalsSelect).
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, from, to).
+		Group("day").
+		Order("day").
+		Scan(&usages).Error
+	return usages, err
+}
+
+func (s *usageStore) GetUsageByMaterial(materialID uint) ([]models.OperationUsage, error) {
+	var usages []models.OperationUsage
+	err := s.DB.Model(&models.LLMUsage{}).
+		Select("operation, "+usageTotalsSelect).
+		Where("material_id = ?", materialID).
+		Group("operation").
+		Order("operation").
+		Scan(&usages).Error
+	return usages, err
+}
+
+func (s *usageStore) GetUsageTotalsByUser(from, to time.Time) ([]models.UserUsage, error) {
+	var usages []models.UserUsage
+	err := s.DB.Model(&models.LLMUsage{}).
+		Select("user_id, "+usageTotalsSelect).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("user_id").
+		Order("total_tokens DESC").
+		Scan(&usages).Error
+	return usages, err
+}
+
+func (s *usageStore) GetUsageTotals(from, to time.Time) (*models.UsageTotals, error) {
+	var totals models.UsageTotals
+	err := s.DB.Model(&models.LLMUsage{}).
+		Select(usageTotalsSelect).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Scan(&totals).Error
+	return &totals, err
+}
+
+func (s *usageStore) GetUserTokensSince(userID uuid.UUID, since time.Time) (int64, error) {
+	var total int64
+	err := s.DB.Model(&models.LLMUsage{}).
+		Select("COALESCE(SUM(total_tokens), 0)").
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Scan(&total).Error
+	return total, err
+}
@@ -3,12 +3,15 @@ package stores
 import (
 	"github.com/yomek33/newln/internal/models"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type UserStore interface {
 	CreateUser(user *models.User) error
 	GetUserByEmail(email string) (*models.User, error)
+	GetUserByID(userID uuid.UUID) (*models.User, error)
+	UpdateNativeLanguage(userID uuid.UUID, code string) error
 }
 
 type userStore struct {
@@ -30,3 +33,22 @@ func (s *userStore) GetUserByEmail(email string) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+func (s *userStore) GetUserByID(userID uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := s.db.Where("user_id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *userStore) UpdateNativeLanguage(userID uuid.UUID, code string) error {
+	result := s.db.Model(&models.User{}).Where("user_id = ?", userID).Update("native_language", code)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
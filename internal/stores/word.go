@@ -5,6 +5,7 @@ import (
 
 	"github.com/yomek33/newln/internal/models"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -15,6 +16,7 @@ type WordStore interface {
 	GetWordListByMaterialULID(materialULID string) ([]models.WordList, error)
 	BulkInsertWords(words []models.Word) error
 	UpdateWordListGenerateStatus(wordListID uint, status string) error
+	GetVocabularyByUserID(userID uuid.UUID) ([]models.VocabularyEntry, error)
 }
 
 type wordStore struct {
@@ -98,3 +100,22 @@ func (s *wordStore) BulkInsertWords(words []models.Word) error {
 func (s *wordStore) UpdateWordListGenerateStatus(wordListID uint, status string) error {
 	return s.DB.Model(&models.WordList{}).Where("id = ?", wordListID).Update("generate_status", status).Error
 }
+
+// GetVocabularyByUserID はユーザーの全教材の単語を見出し語ごとにまとめる
+func (s *wordStore) GetVocabularyByUserID(userID uuid.UUID) ([]models.VocabularyEntry, error) {
+	var entries []models.VocabularyEntry
+	err := s.DB.Model(&models.Word{}).
+		Select(`words.lemma,
+			COUNT(DISTINCT word_lists.material_id) AS material_count,
+			COUNT(word_occurrences.id) AS occurrences,
+			MIN(words.created_at) AS first_seen_at,
+			MAX(words.created_at) AS last_seen_at`).
+		Joins("JOIN word_lists ON word_lists.id = words.word_list_id").
+		Joins("JOIN materials ON materials.id = word_lists.material_id AND materials.deleted_at IS NULL").
+		Joins("LEFT JOIN word_occurrences ON word_occurrences.word_id = words.id AND word_occurrences.deleted_at IS NULL").
+		Where("materials.user_id = ? AND words.lemma <> ''", userID).
+		Group("words.lemma").
+		Order("material_count DESC, occurrences DESC, words.lemma").
+		Scan(&entries).Error
+	return entries, err
+}